@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"worksentry/internal/config"
+	"worksentry/internal/db"
+	"worksentry/internal/http/handlers"
+)
+
+// hourly-backfill 按 time_segments 重建指定日期区间的小时统计，用于上线小时汇总表或修复历史数据。
+func main() {
+	startValue := flag.String("start", "", "开始日期（含），格式 2006-01-02")
+	endValue := flag.String("end", "", "结束日期（含），默认今天")
+	employeeCode := flag.String("employee", "", "仅回填指定工号")
+	flag.Parse()
+
+	cfgPath := os.Getenv("WORKSENTRY_CONFIG")
+	if cfgPath == "" {
+		cfgPath = "config.yaml"
+	}
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		log.Fatalf("配置加载失败: %v", err)
+	}
+	if cfg.App.Timezone != "" {
+		if loc, tzErr := time.LoadLocation(cfg.App.Timezone); tzErr == nil {
+			time.Local = loc
+		}
+	}
+
+	start, err := time.ParseInLocation("2006-01-02", *startValue, time.Local)
+	if err != nil {
+		log.Fatalf("开始日期格式错误: %v", err)
+	}
+	now := time.Now()
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if *endValue != "" {
+		end, err = time.ParseInLocation("2006-01-02", *endValue, time.Local)
+		if err != nil {
+			log.Fatalf("结束日期格式错误: %v", err)
+		}
+	}
+	end = end.AddDate(0, 0, 1)
+
+	sqlDB, err := db.Open(cfg.Database.DSN)
+	if err != nil {
+		log.Fatalf("数据库连接失败: %v", err)
+	}
+	defer sqlDB.Close()
+
+	ctx := context.Background()
+	h := handlers.NewHandler(cfg, sqlDB)
+	var employeeID int64
+	if *employeeCode != "" {
+		employee, err := h.Queries.GetEmployeeByCode(ctx, *employeeCode)
+		if err != nil {
+			log.Fatalf("员工 %s 不存在", *employeeCode)
+		}
+		employeeID = employee.ID
+	}
+
+	processed, err := h.BackfillHourlyStats(ctx, start, end, employeeID)
+	if err != nil {
+		log.Fatalf("小时统计回填失败（已完成 %d 名员工）: %v", processed, err)
+	}
+	log.Printf("小时统计回填完成: %s 至 %s，员工 %d 名", start.Format("2006-01-02"), end.AddDate(0, 0, -1).Format("2006-01-02"), processed)
+}
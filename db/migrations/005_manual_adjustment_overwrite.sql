@@ -0,0 +1,21 @@
+ALTER TABLE manual_adjustments
+  ADD COLUMN target_status VARCHAR(16) NOT NULL DEFAULT 'work' AFTER end_at,
+  ADD COLUMN label VARCHAR(64) NULL AFTER target_status,
+  ADD COLUMN mode VARCHAR(16) NOT NULL DEFAULT 'legacy' AFTER label;
+
+ALTER TABLE time_segments
+  ADD COLUMN adjustment_id BIGINT NULL AFTER source,
+  ADD INDEX idx_time_segments_adjustment (adjustment_id);
+
+CREATE TABLE IF NOT EXISTS manual_adjustment_originals (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  adjustment_id BIGINT NOT NULL,
+  employee_id BIGINT NOT NULL,
+  start_at DATETIME NOT NULL,
+  end_at DATETIME NOT NULL,
+  status VARCHAR(16) NOT NULL,
+  description VARCHAR(255) NULL,
+  source VARCHAR(16) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_manual_adjustment_originals_adjustment (adjustment_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS correction_requests (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  employee_id BIGINT NOT NULL,
+  start_at DATETIME NOT NULL,
+  end_at DATETIME NOT NULL,
+  target_status VARCHAR(16) NOT NULL DEFAULT 'work',
+  reason VARCHAR(64) NOT NULL,
+  note TEXT NOT NULL,
+  status ENUM('pending','approved','rejected') NOT NULL DEFAULT 'pending',
+  reviewer_id BIGINT NULL,
+  review_comment TEXT NULL,
+  reviewed_at DATETIME NULL,
+  adjustment_id BIGINT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  INDEX idx_correction_requests_employee (employee_id, created_at),
+  INDEX idx_correction_requests_status (status, created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
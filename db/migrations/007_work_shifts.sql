@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS work_shifts (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  name VARCHAR(64) NOT NULL,
+  start_minute INT NOT NULL,
+  end_minute INT NOT NULL,
+  grace_minutes INT NOT NULL DEFAULT 0,
+  enabled TINYINT(1) NOT NULL DEFAULT 1,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+ALTER TABLE departments
+  ADD COLUMN shift_id BIGINT NULL;
+
+ALTER TABLE employees
+  ADD COLUMN shift_id BIGINT NULL,
+  ADD INDEX idx_employees_shift (shift_id);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS calendar_days (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  department_id BIGINT NOT NULL DEFAULT 0,
+  cal_date DATE NOT NULL,
+  day_type ENUM('workday','restday','holiday') NOT NULL,
+  name VARCHAR(64) NULL,
+  source ENUM('manual','ics') NOT NULL DEFAULT 'manual',
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  UNIQUE KEY uk_calendar_days_department_date (department_id, cal_date),
+  INDEX idx_calendar_days_date (cal_date)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS attendance_records (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  employee_id BIGINT NOT NULL,
+  department_id BIGINT NULL,
+  work_date DATE NOT NULL,
+  shift_id BIGINT NULL,
+  expected_start DATETIME NOT NULL,
+  expected_end DATETIME NOT NULL,
+  actual_start DATETIME NULL,
+  actual_end DATETIME NULL,
+  status ENUM('on_time','late','early_leave','late_early_leave','absent','leave') NOT NULL,
+  late_minutes INT NOT NULL DEFAULT 0,
+  early_minutes INT NOT NULL DEFAULT 0,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  UNIQUE KEY uk_attendance_records_employee_date (employee_id, work_date),
+  INDEX idx_attendance_records_date (work_date),
+  INDEX idx_attendance_records_status (status, work_date)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,6 @@
+ALTER TABLE settings
+  ADD COLUMN session_auto_close_minutes INT NOT NULL DEFAULT 720;
+
+ALTER TABLE work_sessions
+  ADD COLUMN auto_closed TINYINT(1) NOT NULL DEFAULT 0,
+  ADD COLUMN close_reason VARCHAR(32) NULL;
@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS overtime_rules (
+  department_id BIGINT PRIMARY KEY,
+  rounding_minutes INT NOT NULL DEFAULT 30,
+  rounding_mode ENUM('floor','ceil','nearest') NOT NULL DEFAULT 'floor',
+  min_minutes INT NOT NULL DEFAULT 30,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS overtime_records (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  work_session_id BIGINT NOT NULL,
+  employee_id BIGINT NOT NULL,
+  department_id BIGINT NULL,
+  work_date DATE NOT NULL,
+  category ENUM('weekday','restday','holiday') NOT NULL,
+  scheduled_seconds INT NOT NULL DEFAULT 0,
+  worked_seconds INT NOT NULL DEFAULT 0,
+  overtime_seconds INT NOT NULL DEFAULT 0,
+  overtime_minutes INT NOT NULL DEFAULT 0,
+  approved_minutes INT NULL,
+  status ENUM('pending','approved','rejected') NOT NULL DEFAULT 'pending',
+  reviewer_id BIGINT NULL,
+  review_comment VARCHAR(255) NULL,
+  reviewed_at DATETIME NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  UNIQUE KEY uk_overtime_records_session (work_session_id),
+  INDEX idx_overtime_records_date (work_date),
+  INDEX idx_overtime_records_status (status, work_date),
+  INDEX idx_overtime_records_employee (employee_id, work_date)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
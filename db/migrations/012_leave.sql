@@ -0,0 +1,59 @@
+ALTER TABLE time_segments
+  MODIFY status ENUM('work','normal','fish','idle','offline','incident','break','leave','leave_unpaid') NOT NULL;
+
+ALTER TABLE daily_stats
+  ADD COLUMN leave_seconds INT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS leave_types (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  name VARCHAR(64) NOT NULL,
+  count_policy ENUM('attendance','none') NOT NULL DEFAULT 'attendance',
+  annual_quota_minutes INT NOT NULL DEFAULT 0,
+  enabled TINYINT(1) NOT NULL DEFAULT 1,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS leave_balances (
+  employee_id BIGINT NOT NULL,
+  leave_type_id BIGINT NOT NULL,
+  year INT NOT NULL,
+  quota_minutes INT NOT NULL DEFAULT 0,
+  used_minutes INT NOT NULL DEFAULT 0,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (employee_id, leave_type_id, year)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS leave_requests (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  employee_id BIGINT NOT NULL,
+  leave_type_id BIGINT NOT NULL,
+  start_at DATETIME NOT NULL,
+  end_at DATETIME NOT NULL,
+  minutes INT NOT NULL DEFAULT 0,
+  reason VARCHAR(255) NOT NULL,
+  status ENUM('pending','approved','rejected','cancelled') NOT NULL DEFAULT 'pending',
+  source ENUM('admin','client') NOT NULL DEFAULT 'admin',
+  created_by BIGINT NULL,
+  reviewer_id BIGINT NULL,
+  review_comment VARCHAR(255) NULL,
+  reviewed_at DATETIME NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  INDEX idx_leave_requests_employee (employee_id, start_at),
+  INDEX idx_leave_requests_status (status, start_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS leave_request_segments (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  leave_request_id BIGINT NOT NULL,
+  employee_id BIGINT NOT NULL,
+  start_at DATETIME NOT NULL,
+  end_at DATETIME NOT NULL,
+  adjustment_id BIGINT NULL,
+  status ENUM('pending','applied','conflict','reverted') NOT NULL DEFAULT 'pending',
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  INDEX idx_leave_request_segments_request (leave_request_id),
+  INDEX idx_leave_request_segments_status (status, end_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,11 @@
+ALTER TABLE settings
+  ADD COLUMN raw_retention_days INT NOT NULL DEFAULT 7;
+
+CREATE TABLE IF NOT EXISTS raw_event_archives (
+  archive_date DATE PRIMARY KEY,
+  file_path VARCHAR(512) NOT NULL,
+  row_count INT NOT NULL DEFAULT 0,
+  size_bytes BIGINT NOT NULL DEFAULT 0,
+  archived_at DATETIME NOT NULL,
+  restored_at DATETIME NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
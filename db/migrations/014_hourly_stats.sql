@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS hourly_stats (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  stat_hour DATETIME NOT NULL,
+  employee_id BIGINT NOT NULL,
+  work_seconds INT NOT NULL DEFAULT 0,
+  normal_seconds INT NOT NULL DEFAULT 0,
+  fish_seconds INT NOT NULL DEFAULT 0,
+  idle_seconds INT NOT NULL DEFAULT 0,
+  offline_seconds INT NOT NULL DEFAULT 0,
+  attendance_seconds INT NOT NULL DEFAULT 0,
+  effective_seconds INT NOT NULL DEFAULT 0,
+  leave_seconds INT NOT NULL DEFAULT 0,
+  UNIQUE KEY uk_hourly_stats_hour_employee (stat_hour, employee_id),
+  INDEX idx_hourly_stats_employee_hour (employee_id, stat_hour)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
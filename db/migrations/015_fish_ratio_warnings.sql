@@ -0,0 +1,18 @@
+ALTER TABLE settings
+  ADD COLUMN fish_warn_client_notice TINYINT(1) NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS fish_ratio_warnings (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  employee_id BIGINT NOT NULL,
+  scope ENUM('day', 'session') NOT NULL,
+  stat_date DATE NOT NULL,
+  work_session_id BIGINT NOT NULL DEFAULT 0,
+  fish_seconds INT NOT NULL,
+  attendance_seconds INT NOT NULL,
+  ratio_percent DECIMAL(5, 1) NOT NULL,
+  threshold_percent INT NOT NULL,
+  client_notified_at DATETIME NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE KEY uk_fish_ratio_warnings_scope (employee_id, scope, stat_date, work_session_id),
+  INDEX idx_fish_ratio_warnings_created (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
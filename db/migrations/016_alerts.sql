@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS alert_rules (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  name VARCHAR(64) NOT NULL,
+  rule_type ENUM('session_offline', 'department_idle_rate', 'review_needs_reason') NOT NULL,
+  department_id BIGINT NOT NULL DEFAULT 0,
+  threshold INT NOT NULL DEFAULT 0,
+  window_minutes INT NOT NULL DEFAULT 60,
+  cooldown_minutes INT NOT NULL DEFAULT 60,
+  channels VARCHAR(64) NOT NULL DEFAULT 'in_app',
+  webhook_url VARCHAR(512) NULL,
+  email_to VARCHAR(512) NULL,
+  enabled TINYINT(1) NOT NULL DEFAULT 1,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS alert_events (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  rule_id BIGINT NOT NULL,
+  dedup_key VARCHAR(128) NOT NULL,
+  employee_id BIGINT NULL,
+  department_id BIGINT NULL,
+  title VARCHAR(255) NOT NULL,
+  message TEXT NOT NULL,
+  status ENUM('open', 'acked', 'resolved') NOT NULL DEFAULT 'open',
+  triggered_at DATETIME NOT NULL,
+  last_seen_at DATETIME NOT NULL,
+  acked_by BIGINT NULL,
+  acked_at DATETIME NULL,
+  resolved_by BIGINT NULL,
+  resolved_at DATETIME NULL,
+  resolve_note VARCHAR(255) NULL,
+  INDEX idx_alert_events_rule_key (rule_id, dedup_key, status),
+  INDEX idx_alert_events_triggered (triggered_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS alert_deliveries (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  alert_event_id BIGINT NOT NULL,
+  channel VARCHAR(16) NOT NULL,
+  status ENUM('sent', 'failed') NOT NULL,
+  error_message VARCHAR(512) NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_alert_deliveries_event (alert_event_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
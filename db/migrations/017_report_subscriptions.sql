@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS report_subscriptions (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  name VARCHAR(64) NOT NULL,
+  report_type ENUM('daily', 'rank', 'reviews', 'checkout_records') NOT NULL,
+  schedule ENUM('daily', 'weekly', 'monthly') NOT NULL,
+  send_hour TINYINT NOT NULL DEFAULT 8,
+  weekday TINYINT NOT NULL DEFAULT 1,
+  month_day TINYINT NOT NULL DEFAULT 1,
+  department_id BIGINT NOT NULL DEFAULT 0,
+  recipients VARCHAR(1024) NOT NULL,
+  attach_xlsx TINYINT(1) NOT NULL DEFAULT 1,
+  enabled TINYINT(1) NOT NULL DEFAULT 1,
+  next_run_at DATETIME NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  INDEX idx_report_subscriptions_next_run (enabled, next_run_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS report_deliveries (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  subscription_id BIGINT NOT NULL,
+  trigger_type ENUM('schedule', 'manual') NOT NULL DEFAULT 'schedule',
+  period_key VARCHAR(32) NOT NULL,
+  period_start DATE NOT NULL,
+  period_end DATE NOT NULL,
+  recipients VARCHAR(1024) NOT NULL,
+  status ENUM('pending', 'sent', 'failed') NOT NULL DEFAULT 'pending',
+  attempts INT NOT NULL DEFAULT 0,
+  next_attempt_at DATETIME NOT NULL,
+  error_message VARCHAR(512) NULL,
+  sent_at DATETIME NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  INDEX idx_report_deliveries_subscription (subscription_id, created_at),
+  INDEX idx_report_deliveries_due (status, next_attempt_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
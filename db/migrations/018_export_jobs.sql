@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS export_jobs (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  admin_id BIGINT NOT NULL,
+  kind VARCHAR(32) NOT NULL,
+  format ENUM('xlsx', 'csv', 'ndjson') NOT NULL,
+  params_json JSON NOT NULL,
+  status ENUM('queued', 'running', 'succeeded', 'failed', 'expired') NOT NULL DEFAULT 'queued',
+  total_rows BIGINT NOT NULL DEFAULT 0,
+  processed_rows BIGINT NOT NULL DEFAULT 0,
+  file_name VARCHAR(255) NULL,
+  file_path VARCHAR(512) NULL,
+  file_size BIGINT NOT NULL DEFAULT 0,
+  error_message VARCHAR(512) NULL,
+  started_at DATETIME NULL,
+  finished_at DATETIME NULL,
+  expires_at DATETIME NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  INDEX idx_export_jobs_admin (admin_id, created_at),
+  INDEX idx_export_jobs_status (status, created_at),
+  INDEX idx_export_jobs_expires (status, expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
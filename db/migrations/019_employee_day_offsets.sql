@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS employee_day_offset_changes (
+  id BIGINT PRIMARY KEY AUTO_INCREMENT,
+  employee_id BIGINT NOT NULL,
+  offset_minutes INT NOT NULL,
+  changed_at DATETIME NOT NULL,
+  INDEX idx_employee_day_offset_changes_employee (employee_id, changed_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,2 @@
+ALTER TABLE work_shifts
+  ADD COLUMN break_minutes INT NOT NULL DEFAULT 0 AFTER grace_minutes;
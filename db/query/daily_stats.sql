@@ -8,8 +8,9 @@ INSERT INTO daily_stats (
   idle_seconds,
   offline_seconds,
   attendance_seconds,
-  effective_seconds
-) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+  effective_seconds,
+  leave_seconds
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 ON DUPLICATE KEY UPDATE
   work_seconds = GREATEST(0, work_seconds + VALUES(work_seconds)),
   normal_seconds = GREATEST(0, normal_seconds + VALUES(normal_seconds)),
@@ -17,12 +18,14 @@ ON DUPLICATE KEY UPDATE
   idle_seconds = GREATEST(0, idle_seconds + VALUES(idle_seconds)),
   offline_seconds = GREATEST(0, offline_seconds + VALUES(offline_seconds)),
   attendance_seconds = GREATEST(0, attendance_seconds + VALUES(attendance_seconds)),
-  effective_seconds = GREATEST(0, effective_seconds + VALUES(effective_seconds));
+  effective_seconds = GREATEST(0, effective_seconds + VALUES(effective_seconds)),
+  leave_seconds = GREATEST(0, leave_seconds + VALUES(leave_seconds));
 
 -- name: ListDailyStatsByDate :many
 SELECT ds.stat_date,
        e.employee_code,
        e.name,
+       e.department_id,
        d.name AS department_name,
        ds.work_seconds,
        ds.normal_seconds,
@@ -30,10 +33,13 @@ SELECT ds.stat_date,
        ds.idle_seconds,
        ds.offline_seconds,
        ds.attendance_seconds,
-       ds.effective_seconds
+       ds.effective_seconds,
+       ds.leave_seconds,
+       s.name AS shift_name
 FROM daily_stats ds
 JOIN employees e ON ds.employee_id = e.id
 LEFT JOIN departments d ON e.department_id = d.id
+LEFT JOIN work_shifts s ON s.id = COALESCE(e.shift_id, d.shift_id) AND s.enabled = 1
 WHERE ds.stat_date = ?
-  AND (? = 0 OR e.department_id = ?)
+  AND (? = 0 OR e.department_id IN (sqlc.slice('department_ids')))
 ORDER BY ds.attendance_seconds DESC;
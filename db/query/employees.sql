@@ -36,3 +36,10 @@ SELECT id, employee_code, name, department_id, fingerprint_hash, enabled, last_s
 FROM employees
 WHERE enabled = 1
   AND last_seen_at IS NOT NULL;
+
+-- name: LockEmployeeByID :one
+SELECT id, employee_code, name, department_id, fingerprint_hash, enabled, last_seen_at, last_status, last_description, last_segment_end_at, created_at
+FROM employees
+WHERE id = ?
+LIMIT 1
+FOR UPDATE;
@@ -0,0 +1,26 @@
+-- name: AddHourlyStats :exec
+INSERT INTO hourly_stats (
+  stat_hour,
+  employee_id,
+  work_seconds,
+  normal_seconds,
+  fish_seconds,
+  idle_seconds,
+  offline_seconds,
+  attendance_seconds,
+  effective_seconds,
+  leave_seconds
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+  work_seconds = GREATEST(0, work_seconds + VALUES(work_seconds)),
+  normal_seconds = GREATEST(0, normal_seconds + VALUES(normal_seconds)),
+  fish_seconds = GREATEST(0, fish_seconds + VALUES(fish_seconds)),
+  idle_seconds = GREATEST(0, idle_seconds + VALUES(idle_seconds)),
+  offline_seconds = GREATEST(0, offline_seconds + VALUES(offline_seconds)),
+  attendance_seconds = GREATEST(0, attendance_seconds + VALUES(attendance_seconds)),
+  effective_seconds = GREATEST(0, effective_seconds + VALUES(effective_seconds)),
+  leave_seconds = GREATEST(0, leave_seconds + VALUES(leave_seconds));
+
+-- name: DeleteHourlyStatsByRange :exec
+DELETE FROM hourly_stats
+WHERE employee_id = ? AND stat_hour >= ? AND stat_hour < ?;
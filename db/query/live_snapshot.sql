@@ -1,6 +1,7 @@
 -- name: ListLiveSnapshot :many
 SELECT e.employee_code,
        e.name,
+       e.department_id,
        d.name AS department_name,
        e.last_status,
        e.last_description,
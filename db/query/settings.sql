@@ -1,5 +1,5 @@
 -- name: GetSettings :one
-SELECT id, idle_threshold_seconds, heartbeat_interval_seconds, offline_threshold_seconds, fish_ratio_warn_percent, update_policy, latest_version, update_url, updated_at
+SELECT id, idle_threshold_seconds, heartbeat_interval_seconds, offline_threshold_seconds, fish_ratio_warn_percent, update_policy, latest_version, update_url, session_auto_close_minutes, raw_retention_days, fish_warn_client_notice, updated_at
 FROM settings
 WHERE id = 1;
 
@@ -13,9 +13,12 @@ INSERT INTO settings (
   update_policy,
   latest_version,
   update_url,
+  session_auto_close_minutes,
+  raw_retention_days,
+  fish_warn_client_notice,
   updated_at
 ) VALUES (
-  1, ?, ?, ?, ?, ?, ?, ?, NOW()
+  1, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW()
 )
 ON DUPLICATE KEY UPDATE
   idle_threshold_seconds = VALUES(idle_threshold_seconds),
@@ -25,4 +28,7 @@ ON DUPLICATE KEY UPDATE
   update_policy = VALUES(update_policy),
   latest_version = VALUES(latest_version),
   update_url = VALUES(update_url),
+  session_auto_close_minutes = VALUES(session_auto_close_minutes),
+  raw_retention_days = VALUES(raw_retention_days),
+  fish_warn_client_notice = VALUES(fish_warn_client_notice),
   updated_at = NOW();
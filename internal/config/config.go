@@ -25,9 +25,30 @@ type DatabaseConfig struct {
 }
 
 type AppConfig struct {
-	Timezone    string      `yaml:"timezone"`
-	Environment string      `yaml:"environment"`
-	Admin       AdminConfig `yaml:"admin"`
+	Timezone      string       `yaml:"timezone"`
+	Environment   string       `yaml:"environment"`
+	Admin         AdminConfig  `yaml:"admin"`
+	RawArchiveDir string       `yaml:"raw_archive_dir"`
+	SMTP          SMTPConfig   `yaml:"smtp"`
+	Export        ExportConfig `yaml:"export"`
+}
+
+type ExportConfig struct {
+	Dir      string `yaml:"dir"`
+	Workers  int    `yaml:"workers"`
+	TTLHours int    `yaml:"ttl_hours"`
+	// SignKey 用于签名下载链接，留空时每次启动随机生成，重启后旧链接失效
+	SignKey string `yaml:"sign_key"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+	// TLS 为 true 时使用隐式 TLS（通常为 465 端口），否则在服务端支持时升级 STARTTLS
+	TLS bool `yaml:"tls"`
 }
 
 type AdminConfig struct {
@@ -67,9 +88,12 @@ func defaultConfig() Config {
 			IdleTimeoutSeconds:  60,
 		},
 		App: AppConfig{
-			Timezone:    "Asia/Shanghai",
-			Environment: "dev",
-			Admin:       AdminConfig{},
+			Timezone:      "Asia/Shanghai",
+			Environment:   "dev",
+			Admin:         AdminConfig{},
+			RawArchiveDir: "data/raw_archive",
+			SMTP:          SMTPConfig{Port: 25},
+			Export:        ExportConfig{Dir: "data/exports", Workers: 2, TTLHours: 24},
 		},
 	}
 }
@@ -8,6 +8,7 @@ package sqlc
 import (
 	"context"
 	"database/sql"
+	"strings"
 	"time"
 )
 
@@ -21,8 +22,9 @@ INSERT INTO daily_stats (
   idle_seconds,
   offline_seconds,
   attendance_seconds,
-  effective_seconds
-) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+  effective_seconds,
+  leave_seconds
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 ON DUPLICATE KEY UPDATE
   work_seconds = GREATEST(0, work_seconds + VALUES(work_seconds)),
   normal_seconds = GREATEST(0, normal_seconds + VALUES(normal_seconds)),
@@ -30,7 +32,8 @@ ON DUPLICATE KEY UPDATE
   idle_seconds = GREATEST(0, idle_seconds + VALUES(idle_seconds)),
   offline_seconds = GREATEST(0, offline_seconds + VALUES(offline_seconds)),
   attendance_seconds = GREATEST(0, attendance_seconds + VALUES(attendance_seconds)),
-  effective_seconds = GREATEST(0, effective_seconds + VALUES(effective_seconds))
+  effective_seconds = GREATEST(0, effective_seconds + VALUES(effective_seconds)),
+  leave_seconds = GREATEST(0, leave_seconds + VALUES(leave_seconds))
 `
 
 type AddDailyStatsParams struct {
@@ -43,6 +46,7 @@ type AddDailyStatsParams struct {
 	OfflineSeconds    int32     `json:"offline_seconds"`
 	AttendanceSeconds int32     `json:"attendance_seconds"`
 	EffectiveSeconds  int32     `json:"effective_seconds"`
+	LeaveSeconds      int32     `json:"leave_seconds"`
 }
 
 func (q *Queries) AddDailyStats(ctx context.Context, arg AddDailyStatsParams) error {
@@ -56,6 +60,7 @@ func (q *Queries) AddDailyStats(ctx context.Context, arg AddDailyStatsParams) er
 		arg.OfflineSeconds,
 		arg.AttendanceSeconds,
 		arg.EffectiveSeconds,
+		arg.LeaveSeconds,
 	)
 	return err
 }
@@ -64,6 +69,7 @@ const listDailyStatsByDate = `-- name: ListDailyStatsByDate :many
 SELECT ds.stat_date,
        e.employee_code,
        e.name,
+       e.department_id,
        d.name AS department_name,
        ds.work_seconds,
        ds.normal_seconds,
@@ -71,25 +77,29 @@ SELECT ds.stat_date,
        ds.idle_seconds,
        ds.offline_seconds,
        ds.attendance_seconds,
-       ds.effective_seconds
+       ds.effective_seconds,
+       ds.leave_seconds,
+       s.name AS shift_name
 FROM daily_stats ds
 JOIN employees e ON ds.employee_id = e.id
 LEFT JOIN departments d ON e.department_id = d.id
+LEFT JOIN work_shifts s ON s.id = COALESCE(e.shift_id, d.shift_id) AND s.enabled = 1
 WHERE ds.stat_date = ?
-  AND (? = 0 OR e.department_id = ?)
+  AND (? = 0 OR e.department_id IN (/*SLICE:department_ids*/?))
 ORDER BY ds.attendance_seconds DESC
 `
 
 type ListDailyStatsByDateParams struct {
-	StatDate     time.Time     `json:"stat_date"`
-	Column2      interface{}   `json:"column_2"`
-	DepartmentID sql.NullInt64 `json:"department_id"`
+	StatDate      time.Time       `json:"stat_date"`
+	Column2       interface{}     `json:"column_2"`
+	DepartmentIds []sql.NullInt64 `json:"department_ids"`
 }
 
 type ListDailyStatsByDateRow struct {
 	StatDate          time.Time      `json:"stat_date"`
 	EmployeeCode      string         `json:"employee_code"`
 	Name              string         `json:"name"`
+	DepartmentID      sql.NullInt64  `json:"department_id"`
 	DepartmentName    sql.NullString `json:"department_name"`
 	WorkSeconds       int32          `json:"work_seconds"`
 	NormalSeconds     int32          `json:"normal_seconds"`
@@ -98,10 +108,24 @@ type ListDailyStatsByDateRow struct {
 	OfflineSeconds    int32          `json:"offline_seconds"`
 	AttendanceSeconds int32          `json:"attendance_seconds"`
 	EffectiveSeconds  int32          `json:"effective_seconds"`
+	LeaveSeconds      int32          `json:"leave_seconds"`
+	ShiftName         sql.NullString `json:"shift_name"`
 }
 
 func (q *Queries) ListDailyStatsByDate(ctx context.Context, arg ListDailyStatsByDateParams) ([]ListDailyStatsByDateRow, error) {
-	rows, err := q.db.QueryContext(ctx, listDailyStatsByDate, arg.StatDate, arg.Column2, arg.DepartmentID)
+	query := listDailyStatsByDate
+	var queryParams []interface{}
+	queryParams = append(queryParams, arg.StatDate)
+	queryParams = append(queryParams, arg.Column2)
+	if len(arg.DepartmentIds) > 0 {
+		for _, v := range arg.DepartmentIds {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:department_ids*/?", strings.Repeat(",?", len(arg.DepartmentIds))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:department_ids*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
 	if err != nil {
 		return nil, err
 	}
@@ -113,6 +137,7 @@ func (q *Queries) ListDailyStatsByDate(ctx context.Context, arg ListDailyStatsBy
 			&i.StatDate,
 			&i.EmployeeCode,
 			&i.Name,
+			&i.DepartmentID,
 			&i.DepartmentName,
 			&i.WorkSeconds,
 			&i.NormalSeconds,
@@ -121,6 +146,8 @@ func (q *Queries) ListDailyStatsByDate(ctx context.Context, arg ListDailyStatsBy
 			&i.OfflineSeconds,
 			&i.AttendanceSeconds,
 			&i.EffectiveSeconds,
+			&i.LeaveSeconds,
+			&i.ShiftName,
 		); err != nil {
 			return nil, err
 		}
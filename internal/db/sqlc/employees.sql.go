@@ -148,6 +148,33 @@ func (q *Queries) ListEmployeesForOfflineRefresh(ctx context.Context) ([]Employe
 	return items, nil
 }
 
+const lockEmployeeByID = `-- name: LockEmployeeByID :one
+SELECT id, employee_code, name, department_id, fingerprint_hash, enabled, last_seen_at, last_status, last_description, last_segment_end_at, created_at
+FROM employees
+WHERE id = ?
+LIMIT 1
+FOR UPDATE
+`
+
+func (q *Queries) LockEmployeeByID(ctx context.Context, id int64) (Employee, error) {
+	row := q.db.QueryRowContext(ctx, lockEmployeeByID, id)
+	var i Employee
+	err := row.Scan(
+		&i.ID,
+		&i.EmployeeCode,
+		&i.Name,
+		&i.DepartmentID,
+		&i.FingerprintHash,
+		&i.Enabled,
+		&i.LastSeenAt,
+		&i.LastStatus,
+		&i.LastDescription,
+		&i.LastSegmentEndAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
 const updateEmployeeFingerprint = `-- name: UpdateEmployeeFingerprint :exec
 UPDATE employees
 SET fingerprint_hash = ?
@@ -13,6 +13,7 @@ import (
 const listLiveSnapshot = `-- name: ListLiveSnapshot :many
 SELECT e.employee_code,
        e.name,
+       e.department_id,
        d.name AS department_name,
        e.last_status,
        e.last_description,
@@ -33,6 +34,7 @@ ORDER BY e.id DESC
 type ListLiveSnapshotRow struct {
 	EmployeeCode    string                  `json:"employee_code"`
 	Name            string                  `json:"name"`
+	DepartmentID    sql.NullInt64           `json:"department_id"`
 	DepartmentName  sql.NullString          `json:"department_name"`
 	LastStatus      NullEmployeesLastStatus `json:"last_status"`
 	LastDescription sql.NullString          `json:"last_description"`
@@ -52,6 +54,7 @@ func (q *Queries) ListLiveSnapshot(ctx context.Context) ([]ListLiveSnapshotRow,
 		if err := rows.Scan(
 			&i.EmployeeCode,
 			&i.Name,
+			&i.DepartmentID,
 			&i.DepartmentName,
 			&i.LastStatus,
 			&i.LastDescription,
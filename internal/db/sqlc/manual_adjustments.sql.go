@@ -16,20 +16,26 @@ INSERT INTO manual_adjustments (
   employee_id,
   start_at,
   end_at,
+  target_status,
+  label,
+  mode,
   reason,
   note,
   operator_id,
   status
-) VALUES (?, ?, ?, ?, ?, ?, 'active')
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, 'active')
 `
 
 type CreateManualAdjustmentParams struct {
-	EmployeeID int64     `json:"employee_id"`
-	StartAt    time.Time `json:"start_at"`
-	EndAt      time.Time `json:"end_at"`
-	Reason     string    `json:"reason"`
-	Note       string    `json:"note"`
-	OperatorID int64     `json:"operator_id"`
+	EmployeeID   int64          `json:"employee_id"`
+	StartAt      time.Time      `json:"start_at"`
+	EndAt        time.Time      `json:"end_at"`
+	TargetStatus string         `json:"target_status"`
+	Label        sql.NullString `json:"label"`
+	Mode         string         `json:"mode"`
+	Reason       string         `json:"reason"`
+	Note         string         `json:"note"`
+	OperatorID   int64          `json:"operator_id"`
 }
 
 func (q *Queries) CreateManualAdjustment(ctx context.Context, arg CreateManualAdjustmentParams) (sql.Result, error) {
@@ -37,6 +43,9 @@ func (q *Queries) CreateManualAdjustment(ctx context.Context, arg CreateManualAd
 		arg.EmployeeID,
 		arg.StartAt,
 		arg.EndAt,
+		arg.TargetStatus,
+		arg.Label,
+		arg.Mode,
 		arg.Reason,
 		arg.Note,
 		arg.OperatorID,
@@ -44,7 +53,7 @@ func (q *Queries) CreateManualAdjustment(ctx context.Context, arg CreateManualAd
 }
 
 const getManualAdjustment = `-- name: GetManualAdjustment :one
-SELECT id, employee_id, start_at, end_at, reason, note, operator_id, status, created_at, updated_at
+SELECT id, employee_id, start_at, end_at, target_status, label, mode, reason, note, operator_id, status, created_at, updated_at
 FROM manual_adjustments
 WHERE id = ?
 LIMIT 1
@@ -58,6 +67,9 @@ func (q *Queries) GetManualAdjustment(ctx context.Context, id int64) (ManualAdju
 		&i.EmployeeID,
 		&i.StartAt,
 		&i.EndAt,
+		&i.TargetStatus,
+		&i.Label,
+		&i.Mode,
 		&i.Reason,
 		&i.Note,
 		&i.OperatorID,
@@ -69,7 +81,7 @@ func (q *Queries) GetManualAdjustment(ctx context.Context, id int64) (ManualAdju
 }
 
 const listManualAdjustments = `-- name: ListManualAdjustments :many
-SELECT ma.id, ma.start_at, ma.end_at, ma.reason, ma.note, ma.operator_id, ma.status, ma.created_at,
+SELECT ma.id, ma.start_at, ma.end_at, ma.target_status, ma.label, ma.mode, ma.reason, ma.note, ma.operator_id, ma.status, ma.created_at,
        e.employee_code, e.name, d.name AS department_name
 FROM manual_adjustments ma
 JOIN employees e ON ma.employee_id = e.id
@@ -87,6 +99,9 @@ type ListManualAdjustmentsRow struct {
 	ID             int64                   `json:"id"`
 	StartAt        time.Time               `json:"start_at"`
 	EndAt          time.Time               `json:"end_at"`
+	TargetStatus   string                  `json:"target_status"`
+	Label          sql.NullString          `json:"label"`
+	Mode           string                  `json:"mode"`
 	Reason         string                  `json:"reason"`
 	Note           string                  `json:"note"`
 	OperatorID     int64                   `json:"operator_id"`
@@ -110,6 +125,9 @@ func (q *Queries) ListManualAdjustments(ctx context.Context, arg ListManualAdjus
 			&i.ID,
 			&i.StartAt,
 			&i.EndAt,
+			&i.TargetStatus,
+			&i.Label,
+			&i.Mode,
 			&i.Reason,
 			&i.Note,
 			&i.OperatorID,
@@ -145,22 +163,28 @@ func (q *Queries) RevokeManualAdjustment(ctx context.Context, id int64) error {
 
 const updateManualAdjustment = `-- name: UpdateManualAdjustment :exec
 UPDATE manual_adjustments
-SET start_at = ?, end_at = ?, reason = ?, note = ?, updated_at = NOW()
+SET start_at = ?, end_at = ?, target_status = ?, label = ?, mode = ?, reason = ?, note = ?, updated_at = NOW()
 WHERE id = ? AND status = 'active'
 `
 
 type UpdateManualAdjustmentParams struct {
-	StartAt time.Time `json:"start_at"`
-	EndAt   time.Time `json:"end_at"`
-	Reason  string    `json:"reason"`
-	Note    string    `json:"note"`
-	ID      int64     `json:"id"`
+	StartAt      time.Time      `json:"start_at"`
+	EndAt        time.Time      `json:"end_at"`
+	TargetStatus string         `json:"target_status"`
+	Label        sql.NullString `json:"label"`
+	Mode         string         `json:"mode"`
+	Reason       string         `json:"reason"`
+	Note         string         `json:"note"`
+	ID           int64          `json:"id"`
 }
 
 func (q *Queries) UpdateManualAdjustment(ctx context.Context, arg UpdateManualAdjustmentParams) error {
 	_, err := q.db.ExecContext(ctx, updateManualAdjustment,
 		arg.StartAt,
 		arg.EndAt,
+		arg.TargetStatus,
+		arg.Label,
+		arg.Mode,
 		arg.Reason,
 		arg.Note,
 		arg.ID,
@@ -275,13 +275,15 @@ func (ns NullTimeSegmentsSource) Value() (driver.Value, error) {
 type TimeSegmentsStatus string
 
 const (
-	TimeSegmentsStatusWork     TimeSegmentsStatus = "work"
-	TimeSegmentsStatusNormal   TimeSegmentsStatus = "normal"
-	TimeSegmentsStatusFish     TimeSegmentsStatus = "fish"
-	TimeSegmentsStatusIdle     TimeSegmentsStatus = "idle"
-	TimeSegmentsStatusOffline  TimeSegmentsStatus = "offline"
-	TimeSegmentsStatusIncident TimeSegmentsStatus = "incident"
-	TimeSegmentsStatusBreak    TimeSegmentsStatus = "break"
+	TimeSegmentsStatusWork        TimeSegmentsStatus = "work"
+	TimeSegmentsStatusNormal      TimeSegmentsStatus = "normal"
+	TimeSegmentsStatusFish        TimeSegmentsStatus = "fish"
+	TimeSegmentsStatusIdle        TimeSegmentsStatus = "idle"
+	TimeSegmentsStatusOffline     TimeSegmentsStatus = "offline"
+	TimeSegmentsStatusIncident    TimeSegmentsStatus = "incident"
+	TimeSegmentsStatusBreak       TimeSegmentsStatus = "break"
+	TimeSegmentsStatusLeave       TimeSegmentsStatus = "leave"
+	TimeSegmentsStatusLeaveUnpaid TimeSegmentsStatus = "leave_unpaid"
 )
 
 func (e *TimeSegmentsStatus) Scan(src interface{}) error {
@@ -366,6 +368,7 @@ type DailyStat struct {
 	OfflineSeconds    int32     `json:"offline_seconds"`
 	AttendanceSeconds int32     `json:"attendance_seconds"`
 	EffectiveSeconds  int32     `json:"effective_seconds"`
+	LeaveSeconds      int32     `json:"leave_seconds"`
 }
 
 type Department struct {
@@ -398,17 +401,46 @@ type WorkSession struct {
 	UpdatedAt  time.Time    `json:"updated_at"`
 }
 
+type HourlyStat struct {
+	ID                int64     `json:"id"`
+	StatHour          time.Time `json:"stat_hour"`
+	EmployeeID        int64     `json:"employee_id"`
+	WorkSeconds       int32     `json:"work_seconds"`
+	NormalSeconds     int32     `json:"normal_seconds"`
+	FishSeconds       int32     `json:"fish_seconds"`
+	IdleSeconds       int32     `json:"idle_seconds"`
+	OfflineSeconds    int32     `json:"offline_seconds"`
+	AttendanceSeconds int32     `json:"attendance_seconds"`
+	EffectiveSeconds  int32     `json:"effective_seconds"`
+	LeaveSeconds      int32     `json:"leave_seconds"`
+}
+
 type ManualAdjustment struct {
-	ID         int64                   `json:"id"`
-	EmployeeID int64                   `json:"employee_id"`
-	StartAt    time.Time               `json:"start_at"`
-	EndAt      time.Time               `json:"end_at"`
-	Reason     string                  `json:"reason"`
-	Note       string                  `json:"note"`
-	OperatorID int64                   `json:"operator_id"`
-	Status     ManualAdjustmentsStatus `json:"status"`
-	CreatedAt  time.Time               `json:"created_at"`
-	UpdatedAt  time.Time               `json:"updated_at"`
+	ID           int64                   `json:"id"`
+	EmployeeID   int64                   `json:"employee_id"`
+	StartAt      time.Time               `json:"start_at"`
+	EndAt        time.Time               `json:"end_at"`
+	TargetStatus string                  `json:"target_status"`
+	Label        sql.NullString          `json:"label"`
+	Mode         string                  `json:"mode"`
+	Reason       string                  `json:"reason"`
+	Note         string                  `json:"note"`
+	OperatorID   int64                   `json:"operator_id"`
+	Status       ManualAdjustmentsStatus `json:"status"`
+	CreatedAt    time.Time               `json:"created_at"`
+	UpdatedAt    time.Time               `json:"updated_at"`
+}
+
+type ManualAdjustmentOriginal struct {
+	ID           int64          `json:"id"`
+	AdjustmentID int64          `json:"adjustment_id"`
+	EmployeeID   int64          `json:"employee_id"`
+	StartAt      time.Time      `json:"start_at"`
+	EndAt        time.Time      `json:"end_at"`
+	Status       string         `json:"status"`
+	Description  sql.NullString `json:"description"`
+	Source       string         `json:"source"`
+	CreatedAt    time.Time      `json:"created_at"`
 }
 
 type RawEvent struct {
@@ -443,6 +475,9 @@ type Setting struct {
 	UpdatePolicy             int8           `json:"update_policy"`
 	LatestVersion            sql.NullString `json:"latest_version"`
 	UpdateUrl                sql.NullString `json:"update_url"`
+	SessionAutoCloseMinutes  int32          `json:"session_auto_close_minutes"`
+	RawRetentionDays         int32          `json:"raw_retention_days"`
+	FishWarnClientNotice     bool           `json:"fish_warn_client_notice"`
 	UpdatedAt                time.Time      `json:"updated_at"`
 }
 
@@ -456,13 +491,14 @@ type SystemIncident struct {
 }
 
 type TimeSegment struct {
-	ID          int64              `json:"id"`
-	EmployeeID  int64              `json:"employee_id"`
-	StartAt     time.Time          `json:"start_at"`
-	EndAt       time.Time          `json:"end_at"`
-	Status      TimeSegmentsStatus `json:"status"`
-	Description sql.NullString     `json:"description"`
-	Source      TimeSegmentsSource `json:"source"`
-	CreatedAt   time.Time          `json:"created_at"`
+	ID           int64              `json:"id"`
+	EmployeeID   int64              `json:"employee_id"`
+	StartAt      time.Time          `json:"start_at"`
+	EndAt        time.Time          `json:"end_at"`
+	Status       TimeSegmentsStatus `json:"status"`
+	Description  sql.NullString     `json:"description"`
+	Source       TimeSegmentsSource `json:"source"`
+	AdjustmentID sql.NullInt64      `json:"adjustment_id"`
+	CreatedAt    time.Time          `json:"created_at"`
 }
 
@@ -12,6 +12,7 @@ import (
 
 type Querier interface {
 	AddDailyStats(ctx context.Context, arg AddDailyStatsParams) error
+	AddHourlyStats(ctx context.Context, arg AddHourlyStatsParams) error
 	ClearEmployeeFingerprint(ctx context.Context, id int64) error
 	CountAdminUsers(ctx context.Context) (int64, error)
 	CountEmployeesByDepartment(ctx context.Context, departmentID sql.NullInt64) (int64, error)
@@ -33,6 +34,7 @@ type Querier interface {
 	GetOpenWorkSessionByEmployee(ctx context.Context, employeeID int64) (WorkSession, error)
 	CreateToken(ctx context.Context, arg CreateTokenParams) error
 	DeleteDepartment(ctx context.Context, id int64) error
+	DeleteHourlyStatsByRange(ctx context.Context, arg DeleteHourlyStatsByRangeParams) error
 	DeleteIncident(ctx context.Context, id int64) error
 	DeleteManualSegment(ctx context.Context, arg DeleteManualSegmentParams) error
 	DeleteRawEventsBefore(ctx context.Context, receivedAt time.Time) error
@@ -60,6 +62,7 @@ type Querier interface {
 	ListOfflineSegmentsByEmployeeAndRange(ctx context.Context, arg ListOfflineSegmentsByEmployeeAndRangeParams) ([]ListOfflineSegmentsByEmployeeAndRangeRow, error)
 	ListRules(ctx context.Context) ([]ListRulesRow, error)
 	ListTimeSegmentsByEmployeeAndRange(ctx context.Context, arg ListTimeSegmentsByEmployeeAndRangeParams) ([]ListTimeSegmentsByEmployeeAndRangeRow, error)
+	LockEmployeeByID(ctx context.Context, id int64) (Employee, error)
 	RevokeAdminSession(ctx context.Context, token string) error
 	RevokeManualAdjustment(ctx context.Context, id int64) error
 	RevokeToken(ctx context.Context, token string) error
@@ -11,7 +11,7 @@ import (
 )
 
 const getSettings = `-- name: GetSettings :one
-SELECT id, idle_threshold_seconds, heartbeat_interval_seconds, offline_threshold_seconds, fish_ratio_warn_percent, update_policy, latest_version, update_url, updated_at
+SELECT id, idle_threshold_seconds, heartbeat_interval_seconds, offline_threshold_seconds, fish_ratio_warn_percent, update_policy, latest_version, update_url, session_auto_close_minutes, raw_retention_days, fish_warn_client_notice, updated_at
 FROM settings
 WHERE id = 1
 `
@@ -28,6 +28,9 @@ func (q *Queries) GetSettings(ctx context.Context) (Setting, error) {
 		&i.UpdatePolicy,
 		&i.LatestVersion,
 		&i.UpdateUrl,
+		&i.SessionAutoCloseMinutes,
+		&i.RawRetentionDays,
+		&i.FishWarnClientNotice,
 		&i.UpdatedAt,
 	)
 	return i, err
@@ -43,9 +46,12 @@ INSERT INTO settings (
   update_policy,
   latest_version,
   update_url,
+  session_auto_close_minutes,
+  raw_retention_days,
+  fish_warn_client_notice,
   updated_at
 ) VALUES (
-  1, ?, ?, ?, ?, ?, ?, ?, NOW()
+  1, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW()
 )
 ON DUPLICATE KEY UPDATE
   idle_threshold_seconds = VALUES(idle_threshold_seconds),
@@ -55,6 +61,9 @@ ON DUPLICATE KEY UPDATE
   update_policy = VALUES(update_policy),
   latest_version = VALUES(latest_version),
   update_url = VALUES(update_url),
+  session_auto_close_minutes = VALUES(session_auto_close_minutes),
+  raw_retention_days = VALUES(raw_retention_days),
+  fish_warn_client_notice = VALUES(fish_warn_client_notice),
   updated_at = NOW()
 `
 
@@ -66,6 +75,9 @@ type UpsertSettingsParams struct {
 	UpdatePolicy             int8           `json:"update_policy"`
 	LatestVersion            sql.NullString `json:"latest_version"`
 	UpdateUrl                sql.NullString `json:"update_url"`
+	SessionAutoCloseMinutes  int32          `json:"session_auto_close_minutes"`
+	RawRetentionDays         int32          `json:"raw_retention_days"`
+	FishWarnClientNotice     bool           `json:"fish_warn_client_notice"`
 }
 
 func (q *Queries) UpsertSettings(ctx context.Context, arg UpsertSettingsParams) error {
@@ -77,6 +89,9 @@ func (q *Queries) UpsertSettings(ctx context.Context, arg UpsertSettingsParams)
 		arg.UpdatePolicy,
 		arg.LatestVersion,
 		arg.UpdateUrl,
+		arg.SessionAutoCloseMinutes,
+		arg.RawRetentionDays,
+		arg.FishWarnClientNotice,
 	)
 	return err
 }
@@ -0,0 +1,206 @@
+package sqlc
+
+import (
+	"context"
+	"time"
+)
+
+type WorkShift struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	StartMinute  int32     `json:"start_minute"`
+	EndMinute    int32     `json:"end_minute"`
+	GraceMinutes int32     `json:"grace_minutes"`
+	BreakMinutes int32     `json:"break_minutes"`
+	Enabled      bool      `json:"enabled"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type EmployeeShift struct {
+	EmployeeID int64 `json:"employee_id"`
+	WorkShift
+}
+
+const listWorkShifts = `SELECT id, name, start_minute, end_minute, grace_minutes, break_minutes, enabled, created_at, updated_at
+FROM work_shifts
+ORDER BY id ASC`
+
+func (q *Queries) ListWorkShifts(ctx context.Context) ([]WorkShift, error) {
+	rows, err := q.db.QueryContext(ctx, listWorkShifts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WorkShift
+	for rows.Next() {
+		var item WorkShift
+		if err := rows.Scan(
+			&item.ID,
+			&item.Name,
+			&item.StartMinute,
+			&item.EndMinute,
+			&item.GraceMinutes,
+			&item.BreakMinutes,
+			&item.Enabled,
+			&item.CreatedAt,
+			&item.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWorkShiftByID = `SELECT id, name, start_minute, end_minute, grace_minutes, break_minutes, enabled, created_at, updated_at
+FROM work_shifts
+WHERE id = ?`
+
+func (q *Queries) GetWorkShiftByID(ctx context.Context, id int64) (WorkShift, error) {
+	row := q.db.QueryRowContext(ctx, getWorkShiftByID, id)
+	var item WorkShift
+	err := row.Scan(
+		&item.ID,
+		&item.Name,
+		&item.StartMinute,
+		&item.EndMinute,
+		&item.GraceMinutes,
+		&item.BreakMinutes,
+		&item.Enabled,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+	)
+	return item, err
+}
+
+const createWorkShift = `INSERT INTO work_shifts (name, start_minute, end_minute, grace_minutes, break_minutes, enabled)
+VALUES (?, ?, ?, ?, ?, ?)`
+
+type CreateWorkShiftParams struct {
+	Name         string
+	StartMinute  int32
+	EndMinute    int32
+	GraceMinutes int32
+	BreakMinutes int32
+	Enabled      bool
+}
+
+func (q *Queries) CreateWorkShift(ctx context.Context, arg CreateWorkShiftParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, createWorkShift, arg.Name, arg.StartMinute, arg.EndMinute, arg.GraceMinutes, arg.BreakMinutes, arg.Enabled)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+const updateWorkShift = `UPDATE work_shifts
+SET name = ?, start_minute = ?, end_minute = ?, grace_minutes = ?, break_minutes = ?, enabled = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?`
+
+type UpdateWorkShiftParams struct {
+	ID           int64
+	Name         string
+	StartMinute  int32
+	EndMinute    int32
+	GraceMinutes int32
+	BreakMinutes int32
+	Enabled      bool
+}
+
+func (q *Queries) UpdateWorkShift(ctx context.Context, arg UpdateWorkShiftParams) error {
+	_, err := q.db.ExecContext(ctx, updateWorkShift, arg.Name, arg.StartMinute, arg.EndMinute, arg.GraceMinutes, arg.BreakMinutes, arg.Enabled, arg.ID)
+	return err
+}
+
+const deleteWorkShift = `DELETE FROM work_shifts WHERE id = ?`
+
+func (q *Queries) DeleteWorkShift(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteWorkShift, id)
+	return err
+}
+
+func (q *Queries) ClearWorkShiftAssignments(ctx context.Context, shiftID int64) error {
+	if _, err := q.db.ExecContext(ctx, "UPDATE employees SET shift_id = NULL WHERE shift_id = ?", shiftID); err != nil {
+		return err
+	}
+	_, err := q.db.ExecContext(ctx, "UPDATE departments SET shift_id = NULL WHERE shift_id = ?", shiftID)
+	return err
+}
+
+const setEmployeeShift = `UPDATE employees SET shift_id = ? WHERE id = ?`
+
+func (q *Queries) SetEmployeeShift(ctx context.Context, employeeID int64, shiftID interface{}) error {
+	_, err := q.db.ExecContext(ctx, setEmployeeShift, shiftID, employeeID)
+	return err
+}
+
+const setDepartmentShift = `UPDATE departments SET shift_id = ? WHERE id = ?`
+
+func (q *Queries) SetDepartmentShift(ctx context.Context, departmentID int64, shiftID interface{}) error {
+	_, err := q.db.ExecContext(ctx, setDepartmentShift, shiftID, departmentID)
+	return err
+}
+
+// 员工自身班次优先，未设置时沿用部门班次。
+const listEmployeeShifts = `SELECT e.id, s.id, s.name, s.start_minute, s.end_minute, s.grace_minutes, s.break_minutes, s.enabled, s.created_at, s.updated_at
+FROM employees e
+LEFT JOIN departments d ON e.department_id = d.id
+JOIN work_shifts s ON s.id = COALESCE(e.shift_id, d.shift_id)
+WHERE s.enabled = 1`
+
+func (q *Queries) GetEmployeeShift(ctx context.Context, employeeID int64) (WorkShift, error) {
+	row := q.db.QueryRowContext(ctx, listEmployeeShifts+" AND e.id = ?", employeeID)
+	var item EmployeeShift
+	err := row.Scan(
+		&item.EmployeeID,
+		&item.ID,
+		&item.Name,
+		&item.StartMinute,
+		&item.EndMinute,
+		&item.GraceMinutes,
+		&item.BreakMinutes,
+		&item.Enabled,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+	)
+	return item.WorkShift, err
+}
+
+func (q *Queries) ListEmployeeShifts(ctx context.Context) ([]EmployeeShift, error) {
+	rows, err := q.db.QueryContext(ctx, listEmployeeShifts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []EmployeeShift
+	for rows.Next() {
+		var item EmployeeShift
+		if err := rows.Scan(
+			&item.EmployeeID,
+			&item.ID,
+			&item.Name,
+			&item.StartMinute,
+			&item.EndMinute,
+			&item.GraceMinutes,
+			&item.BreakMinutes,
+			&item.Enabled,
+			&item.CreatedAt,
+			&item.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
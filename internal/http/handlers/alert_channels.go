@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	alertChannelInApp   = "in_app"
+	alertChannelWebhook = "webhook"
+	alertChannelSMTP    = "smtp"
+
+	alertWebhookTimeout = 5 * time.Second
+	// 投递队列容量，积压超过时直接记为失败，避免拖慢告警评估
+	alertDeliveryQueueSize = 256
+)
+
+type alertDeliveryJob struct {
+	Rule  alertRule
+	Event AlertEventView
+}
+
+// alertChannel 告警投递渠道，新增渠道实现该接口并在 alertChannels 中注册。
+type alertChannel interface {
+	Deliver(ctx context.Context, rule alertRule, event AlertEventView) error
+}
+
+type inAppAlertChannel struct {
+	hub *LiveHub
+}
+
+type webhookAlertChannel struct {
+	client *http.Client
+}
+
+type smtpAlertChannel struct {
+	h *Handler
+}
+
+type alertWebhookPayload struct {
+	Type  string         `json:"type"`
+	Rule  AlertRuleView  `json:"rule"`
+	Event AlertEventView `json:"event"`
+}
+
+func (h *Handler) alertChannels() map[string]alertChannel {
+	return map[string]alertChannel{
+		alertChannelInApp:   inAppAlertChannel{hub: h.Hub},
+		alertChannelWebhook: webhookAlertChannel{client: &http.Client{Timeout: alertWebhookTimeout}},
+		alertChannelSMTP:    smtpAlertChannel{h: h},
+	}
+}
+
+// enqueueAlertDelivery 将新告警交给投递协程，评估循环不等待 SMTP 或 Webhook 响应。
+func (h *Handler) enqueueAlertDelivery(ctx context.Context, rule alertRule, event AlertEventView) {
+	select {
+	case h.alertQueue <- alertDeliveryJob{Rule: rule, Event: event}:
+	default:
+		log.Printf("告警投递队列已满: event=%d", event.ID)
+		for _, name := range rule.Channels {
+			h.recordAlertDelivery(ctx, event.ID, name, errors.New("投递队列已满"))
+		}
+	}
+}
+
+func (h *Handler) alertDeliveryLoop(ctx context.Context) {
+	channels := h.alertChannels()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-h.alertQueue:
+			h.deliverAlert(ctx, job.Rule, job.Event, channels)
+		}
+	}
+}
+
+// deliverAlert 逐个渠道投递并记录结果，单个渠道失败不影响其他渠道。
+func (h *Handler) deliverAlert(ctx context.Context, rule alertRule, event AlertEventView, channels map[string]alertChannel) {
+	for _, name := range rule.Channels {
+		channel, ok := channels[name]
+		if !ok {
+			continue
+		}
+		err := channel.Deliver(ctx, rule, event)
+		if err != nil {
+			log.Printf("告警投递失败: event=%d channel=%s %v", event.ID, name, err)
+		}
+		h.recordAlertDelivery(ctx, event.ID, name, err)
+	}
+}
+
+func (h *Handler) recordAlertDelivery(ctx context.Context, eventID int64, channel string, deliverErr error) {
+	status := "sent"
+	errorMessage := ""
+	if deliverErr != nil {
+		status = "failed"
+		errorMessage = deliverErr.Error()
+		if utf8.RuneCountInString(errorMessage) > 500 {
+			errorMessage = string([]rune(errorMessage)[:500])
+		}
+	}
+	if _, err := h.DB.ExecContext(ctx, `INSERT INTO alert_deliveries (alert_event_id, channel, status, error_message)
+VALUES (?, ?, ?, ?)`, eventID, channel, status, toNullString(errorMessage)); err != nil {
+		log.Printf("告警投递记录失败: event=%d %v", eventID, err)
+	}
+}
+
+func alertChannelLabel(channel string) string {
+	switch channel {
+	case alertChannelInApp:
+		return "站内"
+	case alertChannelWebhook:
+		return "Webhook"
+	case alertChannelSMTP:
+		return "邮件"
+	default:
+		return "未知"
+	}
+}
+
+func (c inAppAlertChannel) Deliver(ctx context.Context, rule alertRule, event AlertEventView) error {
+	c.hub.Broadcast(LiveMessage{Type: "alert", Item: event, Time: formatTime(time.Now())})
+	return nil
+}
+
+func (c webhookAlertChannel) Deliver(ctx context.Context, rule alertRule, event AlertEventView) error {
+	if rule.WebhookURL == "" {
+		return fmt.Errorf("未配置 Webhook 地址")
+	}
+	body, err := json.Marshal(alertWebhookPayload{Type: "alert", Rule: rule.view(), Event: event})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rule.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook 返回 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (c smtpAlertChannel) Deliver(ctx context.Context, rule alertRule, event AlertEventView) error {
+	recipients := parseMailRecipients(rule.EmailTo)
+	if len(recipients) == 0 {
+		return fmt.Errorf("未配置收件人")
+	}
+	if c.h.Config == nil {
+		return fmt.Errorf("未配置邮件服务器")
+	}
+	body := fmt.Sprintf("%s\n\n规则：%s\n触发时间：%s\n", event.Message, rule.Name, event.TriggeredAt)
+	return sendMail(ctx, c.h.Config.App.SMTP, mailMessage{
+		To:      recipients,
+		Subject: "[WorkSentry 告警] " + event.Title,
+		Body:    body,
+	})
+}
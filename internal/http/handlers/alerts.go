@@ -0,0 +1,927 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	alertRuleSessionOffline = "session_offline"
+	alertRuleDepartmentIdle = "department_idle_rate"
+	alertRuleReviewReason   = "review_needs_reason"
+
+	alertStatusOpen     = "open"
+	alertStatusAcked    = "acked"
+	alertStatusResolved = "resolved"
+
+	// 空闲率样本不足 10 分钟时不判断，避免刚上线的部门误报
+	alertIdleMinActiveSeconds = 10 * 60
+	alertMaxWindowMinutes     = 7 * 24 * 60
+	alertAutoResolveNote      = "条件已恢复"
+)
+
+type AlertRulePayload struct {
+	ID              int64    `json:"id"`
+	Name            string   `json:"name"`
+	RuleType        string   `json:"ruleType"`
+	DepartmentID    int64    `json:"departmentId"`
+	Threshold       int32    `json:"threshold"`
+	WindowMinutes   int32    `json:"windowMinutes"`
+	CooldownMinutes int32    `json:"cooldownMinutes"`
+	Channels        []string `json:"channels"`
+	WebhookURL      string   `json:"webhookUrl"`
+	EmailTo         string   `json:"emailTo"`
+	Enabled         bool     `json:"enabled"`
+}
+
+type AlertRuleView struct {
+	ID              int64    `json:"id"`
+	Name            string   `json:"name"`
+	RuleType        string   `json:"ruleType"`
+	RuleTypeLabel   string   `json:"ruleTypeLabel"`
+	DepartmentID    int64    `json:"departmentId"`
+	Department      string   `json:"department"`
+	Threshold       int32    `json:"threshold"`
+	WindowMinutes   int32    `json:"windowMinutes"`
+	CooldownMinutes int32    `json:"cooldownMinutes"`
+	Channels        []string `json:"channels"`
+	WebhookURL      string   `json:"webhookUrl"`
+	EmailTo         string   `json:"emailTo"`
+	Enabled         bool     `json:"enabled"`
+}
+
+type AlertDeliveryView struct {
+	Channel      string `json:"channel"`
+	ChannelLabel string `json:"channelLabel"`
+	Status       string `json:"status"`
+	Error        string `json:"error"`
+	CreatedAt    string `json:"createdAt"`
+}
+
+type AlertEventView struct {
+	ID           int64               `json:"id"`
+	RuleID       int64               `json:"ruleId"`
+	RuleName     string              `json:"ruleName"`
+	RuleType     string              `json:"ruleType"`
+	EmployeeCode string              `json:"employeeCode"`
+	Name         string              `json:"name"`
+	DepartmentID int64               `json:"departmentId"`
+	Department   string              `json:"department"`
+	Title        string              `json:"title"`
+	Message      string              `json:"message"`
+	Status       string              `json:"status"`
+	StatusLabel  string              `json:"statusLabel"`
+	TriggeredAt  string              `json:"triggeredAt"`
+	LastSeenAt   string              `json:"lastSeenAt"`
+	AckedBy      string              `json:"ackedBy"`
+	AckedAt      string              `json:"ackedAt"`
+	ResolvedBy   string              `json:"resolvedBy"`
+	ResolvedAt   string              `json:"resolvedAt"`
+	ResolveNote  string              `json:"resolveNote"`
+	Deliveries   []AlertDeliveryView `json:"deliveries,omitempty"`
+}
+
+type AlertEventListResponse struct {
+	Total int64            `json:"total"`
+	Items []AlertEventView `json:"items"`
+}
+
+type AlertActionPayload struct {
+	ID     int64  `json:"id"`
+	Action string `json:"action"`
+	Note   string `json:"note"`
+}
+
+type alertRule struct {
+	ID              int64
+	Name            string
+	RuleType        string
+	DepartmentID    int64
+	Department      string
+	Threshold       int32
+	WindowMinutes   int32
+	CooldownMinutes int32
+	Channels        []string
+	WebhookURL      string
+	EmailTo         string
+	Enabled         bool
+}
+
+// alertCandidate 一次评估中满足条件的对象，DedupKey 相同视为同一告警。
+type alertCandidate struct {
+	DedupKey     string
+	EmployeeID   int64
+	EmployeeCode string
+	Name         string
+	DepartmentID int64
+	Department   string
+	Title        string
+	Message      string
+}
+
+func alertRuleTypeLabel(ruleType string) string {
+	switch ruleType {
+	case alertRuleSessionOffline:
+		return "上班期间离线"
+	case alertRuleDepartmentIdle:
+		return "部门空闲率过高"
+	case alertRuleReviewReason:
+		return "上班考核待补录原因"
+	default:
+		return "未知"
+	}
+}
+
+func alertStatusLabel(status string) string {
+	switch status {
+	case alertStatusOpen:
+		return "待处理"
+	case alertStatusAcked:
+		return "已确认"
+	case alertStatusResolved:
+		return "已解决"
+	default:
+		return "未知"
+	}
+}
+
+func (r alertRule) view() AlertRuleView {
+	return AlertRuleView{
+		ID:              r.ID,
+		Name:            r.Name,
+		RuleType:        r.RuleType,
+		RuleTypeLabel:   alertRuleTypeLabel(r.RuleType),
+		DepartmentID:    r.DepartmentID,
+		Department:      r.Department,
+		Threshold:       r.Threshold,
+		WindowMinutes:   r.WindowMinutes,
+		CooldownMinutes: r.CooldownMinutes,
+		Channels:        r.Channels,
+		WebhookURL:      r.WebhookURL,
+		EmailTo:         r.EmailTo,
+		Enabled:         r.Enabled,
+	}
+}
+
+func splitAlertChannels(value string) []string {
+	channels := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			channels = append(channels, item)
+		}
+	}
+	return channels
+}
+
+func (h *Handler) listAlertRules(ctx context.Context, enabledOnly bool) ([]alertRule, error) {
+	query := `SELECT r.id, r.name, r.rule_type, r.department_id, COALESCE(d.name, ''), r.threshold, r.window_minutes, r.cooldown_minutes,
+ r.channels, COALESCE(r.webhook_url, ''), COALESCE(r.email_to, ''), r.enabled
+FROM alert_rules r
+LEFT JOIN departments d ON r.department_id = d.id`
+	if enabledOnly {
+		query += " WHERE r.enabled = 1"
+	}
+	rows, err := h.DB.QueryContext(ctx, query+" ORDER BY r.id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := make([]alertRule, 0)
+	for rows.Next() {
+		var item alertRule
+		var channels string
+		if err := rows.Scan(&item.ID, &item.Name, &item.RuleType, &item.DepartmentID, &item.Department, &item.Threshold, &item.WindowMinutes,
+			&item.CooldownMinutes, &channels, &item.WebhookURL, &item.EmailTo, &item.Enabled); err != nil {
+			return nil, err
+		}
+		item.Channels = splitAlertChannels(channels)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// AlertRules 维护告警规则。
+func (h *Handler) AlertRules(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		items, err := h.listAlertRules(r.Context(), false)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "读取告警规则失败")
+			return
+		}
+		views := make([]AlertRuleView, 0, len(items))
+		for _, item := range items {
+			views = append(views, item.view())
+		}
+		writeJSON(w, http.StatusOK, views)
+	case http.MethodPost:
+		h.saveAlertRule(w, r, false)
+	case http.MethodPut:
+		h.saveAlertRule(w, r, true)
+	case http.MethodDelete:
+		h.deleteAlertRule(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+	}
+}
+
+// normalizeAlertRule 校验规则参数并补齐各类型的默认值，返回错误提示。
+func normalizeAlertRule(payload *AlertRulePayload) string {
+	payload.Name = strings.TrimSpace(payload.Name)
+	payload.RuleType = strings.TrimSpace(payload.RuleType)
+	payload.WebhookURL = strings.TrimSpace(payload.WebhookURL)
+	payload.EmailTo = strings.TrimSpace(payload.EmailTo)
+	if payload.Name == "" {
+		return "规则名称不能为空"
+	}
+	if utf8.RuneCountInString(payload.Name) > 64 {
+		return "规则名称长度不能超过 64 个字符"
+	}
+	switch payload.RuleType {
+	case alertRuleSessionOffline:
+		// 阈值为离线分钟数，按员工最后上报时间判断，不需要评估窗口
+		if payload.Threshold == 0 {
+			payload.Threshold = 30
+		}
+		if payload.Threshold < 1 || payload.Threshold > 24*60 {
+			return "离线分钟数需在 1 到 1440 之间"
+		}
+		payload.WindowMinutes = 0
+	case alertRuleDepartmentIdle:
+		if payload.Threshold < 1 || payload.Threshold > 100 {
+			return "空闲率阈值需在 1 到 100 之间"
+		}
+		if payload.WindowMinutes == 0 {
+			payload.WindowMinutes = 60
+		}
+		if payload.WindowMinutes < 10 || payload.WindowMinutes > 24*60 {
+			return "评估窗口需在 10 到 1440 分钟之间"
+		}
+	case alertRuleReviewReason:
+		payload.Threshold = 0
+		if payload.WindowMinutes == 0 {
+			payload.WindowMinutes = 24 * 60
+		}
+		if payload.WindowMinutes < 1 || payload.WindowMinutes > alertMaxWindowMinutes {
+			return "评估窗口需在 1 到 10080 分钟之间"
+		}
+	default:
+		return "规则类型无效"
+	}
+	if payload.CooldownMinutes < 0 || payload.CooldownMinutes > alertMaxWindowMinutes {
+		return "冷却时间需在 0 到 10080 分钟之间"
+	}
+	if payload.DepartmentID < 0 {
+		return "部门无效"
+	}
+
+	seen := map[string]bool{}
+	channels := make([]string, 0, len(payload.Channels))
+	for _, channel := range payload.Channels {
+		channel = strings.TrimSpace(channel)
+		if channel == "" || seen[channel] {
+			continue
+		}
+		if channel != alertChannelInApp && channel != alertChannelWebhook && channel != alertChannelSMTP {
+			return "通知渠道无效"
+		}
+		seen[channel] = true
+		channels = append(channels, channel)
+	}
+	if len(channels) == 0 {
+		return "至少选择一个通知渠道"
+	}
+	payload.Channels = channels
+	if seen[alertChannelWebhook] {
+		parsed, err := url.Parse(payload.WebhookURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return "Webhook 地址无效"
+		}
+		if len(payload.WebhookURL) > 512 {
+			return "Webhook 地址过长"
+		}
+	}
+	if seen[alertChannelSMTP] {
+		recipients := parseMailRecipients(payload.EmailTo)
+		if len(recipients) == 0 {
+			return "邮件通知需填写收件人"
+		}
+		for _, recipient := range recipients {
+			if !validMailAddress(recipient) {
+				return "收件人邮箱格式错误：" + recipient
+			}
+		}
+		payload.EmailTo = strings.Join(recipients, ",")
+		if len(payload.EmailTo) > 512 {
+			return "收件人过多"
+		}
+	}
+	return ""
+}
+
+func (h *Handler) saveAlertRule(w http.ResponseWriter, r *http.Request, update bool) {
+	var payload AlertRulePayload
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "参数格式错误")
+		return
+	}
+	if message := normalizeAlertRule(&payload); message != "" {
+		writeError(w, http.StatusBadRequest, message)
+		return
+	}
+	if payload.DepartmentID > 0 {
+		var exists int64
+		if err := h.DB.QueryRowContext(r.Context(), "SELECT COUNT(1) FROM departments WHERE id = ?", payload.DepartmentID).Scan(&exists); err != nil {
+			writeError(w, http.StatusInternalServerError, "读取部门失败")
+			return
+		}
+		if exists == 0 {
+			writeError(w, http.StatusBadRequest, "部门不存在")
+			return
+		}
+	}
+	channels := strings.Join(payload.Channels, ",")
+
+	if update {
+		if payload.ID <= 0 {
+			writeError(w, http.StatusBadRequest, "告警规则编号无效")
+			return
+		}
+		var exists int64
+		if err := h.DB.QueryRowContext(r.Context(), "SELECT COUNT(1) FROM alert_rules WHERE id = ?", payload.ID).Scan(&exists); err != nil {
+			writeError(w, http.StatusInternalServerError, "更新告警规则失败")
+			return
+		}
+		if exists == 0 {
+			writeError(w, http.StatusNotFound, "告警规则不存在")
+			return
+		}
+		_, err := h.DB.ExecContext(r.Context(), `UPDATE alert_rules SET name = ?, rule_type = ?, department_id = ?, threshold = ?, window_minutes = ?,
+ cooldown_minutes = ?, channels = ?, webhook_url = ?, email_to = ?, enabled = ?
+WHERE id = ?`, payload.Name, payload.RuleType, payload.DepartmentID, payload.Threshold, payload.WindowMinutes, payload.CooldownMinutes,
+			channels, toNullString(payload.WebhookURL), toNullString(payload.EmailTo), payload.Enabled, payload.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "更新告警规则失败")
+			return
+		}
+		h.logAudit(r, "update_alert_rule", "alert_rule", sql.NullInt64{Int64: payload.ID, Valid: true}, payload)
+		writeJSON(w, http.StatusOK, map[string]any{"id": payload.ID})
+		return
+	}
+
+	result, err := h.DB.ExecContext(r.Context(), `INSERT INTO alert_rules
+(name, rule_type, department_id, threshold, window_minutes, cooldown_minutes, channels, webhook_url, email_to, enabled)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, payload.Name, payload.RuleType, payload.DepartmentID, payload.Threshold, payload.WindowMinutes,
+		payload.CooldownMinutes, channels, toNullString(payload.WebhookURL), toNullString(payload.EmailTo), payload.Enabled)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "创建告警规则失败")
+		return
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "创建告警规则失败")
+		return
+	}
+	h.logAudit(r, "create_alert_rule", "alert_rule", sql.NullInt64{Int64: id, Valid: true}, payload)
+	writeJSON(w, http.StatusOK, map[string]any{"id": id})
+}
+
+// deleteAlertRule 删除规则时保留历史告警，未处理的告警一并关闭。
+func (h *Handler) deleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	id := parseInt64(r.URL.Query().Get("id"))
+	if id <= 0 {
+		writeError(w, http.StatusBadRequest, "告警规则编号无效")
+		return
+	}
+	result, err := h.DB.ExecContext(r.Context(), "DELETE FROM alert_rules WHERE id = ?", id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "删除告警规则失败")
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		writeError(w, http.StatusNotFound, "告警规则不存在")
+		return
+	}
+	if _, err := h.DB.ExecContext(r.Context(), `UPDATE alert_events SET status = ?, resolved_by = ?, resolved_at = ?, resolve_note = ?
+WHERE rule_id = ? AND status <> ?`, alertStatusResolved, adminIDFromRequest(r), time.Now(), "规则已删除", id, alertStatusResolved); err != nil {
+		writeError(w, http.StatusInternalServerError, "删除告警规则失败")
+		return
+	}
+	h.logAudit(r, "delete_alert_rule", "alert_rule", sql.NullInt64{Int64: id, Valid: true}, nil)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "已删除"})
+}
+
+func (h *Handler) alertEvaluateLoop(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.evaluateAlertRules(ctx)
+		}
+	}
+}
+
+func (h *Handler) evaluateAlertRules(ctx context.Context) {
+	if h.DB == nil {
+		return
+	}
+	rules, err := h.listAlertRules(ctx, true)
+	if err != nil {
+		log.Printf("告警评估失败: %v", err)
+		return
+	}
+	now := time.Now()
+	for _, rule := range rules {
+		candidates, err := h.alertCandidates(ctx, rule, now)
+		if err != nil {
+			log.Printf("告警评估失败: rule=%d %v", rule.ID, err)
+			continue
+		}
+		if err := h.applyAlertCandidates(ctx, rule, candidates, now); err != nil {
+			log.Printf("告警写入失败: rule=%d %v", rule.ID, err)
+		}
+	}
+}
+
+func (h *Handler) alertCandidates(ctx context.Context, rule alertRule, now time.Time) ([]alertCandidate, error) {
+	switch rule.RuleType {
+	case alertRuleSessionOffline:
+		return h.sessionOfflineCandidates(ctx, rule, now)
+	case alertRuleDepartmentIdle:
+		return h.departmentIdleCandidates(ctx, rule, now)
+	case alertRuleReviewReason:
+		return h.reviewReasonCandidates(ctx, rule, now)
+	default:
+		return nil, nil
+	}
+}
+
+// sessionOfflineCandidates 上班记录未结束，但最后一次上报已超过阈值分钟数。
+func (h *Handler) sessionOfflineCandidates(ctx context.Context, rule alertRule, now time.Time) ([]alertCandidate, error) {
+	cutoff := now.Add(-time.Duration(rule.Threshold) * time.Minute)
+	query := `SELECT ws.id, e.id, e.employee_code, e.name, COALESCE(e.department_id, 0), COALESCE(d.name, ''), e.last_seen_at
+FROM work_sessions ws
+JOIN employees e ON ws.employee_id = e.id
+LEFT JOIN departments d ON e.department_id = d.id
+WHERE ws.end_at IS NULL AND e.last_seen_at IS NOT NULL AND e.last_seen_at < ?`
+	args := []any{cutoff}
+	clause, clauseArgs, err := h.departmentFilter(ctx, "e.department_id", rule.DepartmentID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := h.DB.QueryContext(ctx, query+clause, append(args, clauseArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := make([]alertCandidate, 0)
+	for rows.Next() {
+		var sessionID int64
+		var lastSeen time.Time
+		var item alertCandidate
+		if err := rows.Scan(&sessionID, &item.EmployeeID, &item.EmployeeCode, &item.Name, &item.DepartmentID, &item.Department, &lastSeen); err != nil {
+			return nil, err
+		}
+		item.DedupKey = "session:" + strconv.FormatInt(sessionID, 10)
+		item.Title = fmt.Sprintf("%s（%s）上班期间离线", item.Name, item.EmployeeCode)
+		item.Message = fmt.Sprintf("%s（%s）上班期间已离线 %d 分钟，最后上报时间 %s。", item.Name, item.EmployeeCode,
+			int(now.Sub(lastSeen).Minutes()), formatTime(lastSeen))
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// departmentIdleCandidates 按评估窗口汇总时间段，空闲时长占在线时长的比例逐级累加到上级部门后判断。
+func (h *Handler) departmentIdleCandidates(ctx context.Context, rule alertRule, now time.Time) ([]alertCandidate, error) {
+	start := now.Add(-time.Duration(rule.WindowMinutes) * time.Minute)
+	query := `SELECT e.department_id,
+ SUM(CASE WHEN ts.status = 'idle' THEN TIMESTAMPDIFF(SECOND, GREATEST(ts.start_at, ?), LEAST(ts.end_at, ?)) ELSE 0 END),
+ SUM(TIMESTAMPDIFF(SECOND, GREATEST(ts.start_at, ?), LEAST(ts.end_at, ?)))
+FROM time_segments ts
+JOIN employees e ON ts.employee_id = e.id
+WHERE ts.start_at < ? AND ts.end_at > ? AND ts.status IN ('work', 'normal', 'fish', 'idle') AND e.department_id IS NOT NULL`
+	args := []any{start, now, start, now, now, start}
+	clause, clauseArgs, err := h.departmentFilter(ctx, "e.department_id", rule.DepartmentID)
+	if err != nil {
+		return nil, err
+	}
+	query += clause + " GROUP BY e.id, e.department_id"
+	rows, err := h.DB.QueryContext(ctx, query, append(args, clauseArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	members := make([]departmentMember, 0)
+	for rows.Next() {
+		var member departmentMember
+		if err := rows.Scan(&member.DepartmentID, &member.Values.IdleSeconds, &member.Values.AttendanceSeconds); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tree, err := loadDepartmentTree(ctx, h.Queries)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]alertCandidate, 0)
+	for _, total := range tree.rollupDepartments(rule.DepartmentID, members) {
+		active := total.Values.AttendanceSeconds
+		if total.DepartmentID <= 0 || active < alertIdleMinActiveSeconds {
+			continue
+		}
+		if total.Values.IdleSeconds*100 < int64(rule.Threshold)*active {
+			continue
+		}
+		ratio := formatPercent(float64(total.Values.IdleSeconds) / float64(active))
+		items = append(items, alertCandidate{
+			DedupKey:     "department:" + strconv.FormatInt(total.DepartmentID, 10),
+			DepartmentID: total.DepartmentID,
+			Department:   total.Department,
+			Title:        fmt.Sprintf("%s空闲率过高", total.Department),
+			Message: fmt.Sprintf("%s近 %d 分钟空闲率 %s，超过阈值 %d%%（在线 %d 人，在线时长 %s，空闲 %s）。", total.Department,
+				rule.WindowMinutes, ratio, rule.Threshold, total.EmployeeCount, formatDuration(active), formatDuration(total.Values.IdleSeconds)),
+		})
+	}
+	return items, nil
+}
+
+// reviewReasonCandidates 评估窗口内新生成、需要补录原因但尚未填写的上班考核；
+// 已触发的告警在补录原因前持续有效，不随窗口滑出而自动关闭。
+func (h *Handler) reviewReasonCandidates(ctx context.Context, rule alertRule, now time.Time) ([]alertCandidate, error) {
+	since := now.Add(-time.Duration(rule.WindowMinutes) * time.Minute)
+	query := `SELECT r.id, r.work_date, e.id, e.employee_code, e.name, COALESCE(r.department_id, 0), COALESCE(d.name, '')
+FROM work_session_reviews r
+JOIN employees e ON r.employee_id = e.id
+LEFT JOIN departments d ON r.department_id = d.id
+WHERE r.need_reason = 1 AND (r.reason IS NULL OR r.reason = '')
+ AND (r.created_at >= ? OR EXISTS (SELECT 1 FROM alert_events ae
+  WHERE ae.rule_id = ? AND ae.status <> ? AND ae.dedup_key = CONCAT('review:', r.id)))`
+	args := []any{since, rule.ID, alertStatusResolved}
+	clause, clauseArgs, err := h.departmentFilter(ctx, "r.department_id", rule.DepartmentID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := h.DB.QueryContext(ctx, query+clause, append(args, clauseArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := make([]alertCandidate, 0)
+	for rows.Next() {
+		var reviewID int64
+		var workDate time.Time
+		var item alertCandidate
+		if err := rows.Scan(&reviewID, &workDate, &item.EmployeeID, &item.EmployeeCode, &item.Name, &item.DepartmentID, &item.Department); err != nil {
+			return nil, err
+		}
+		item.DedupKey = "review:" + strconv.FormatInt(reviewID, 10)
+		item.Title = fmt.Sprintf("%s（%s）上班考核待补录原因", item.Name, item.EmployeeCode)
+		item.Message = fmt.Sprintf("%s（%s）%s 的上班考核未达标，需要补录原因。", item.Name, item.EmployeeCode, workDate.Format("2006-01-02"))
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// applyAlertCandidates 未关闭的同键告警只刷新最近命中时间；新告警需过冷却时间才触发并投递；
+// 不再满足条件的未关闭告警自动解决。
+func (h *Handler) applyAlertCandidates(ctx context.Context, rule alertRule, candidates []alertCandidate, now time.Time) error {
+	rows, err := h.DB.QueryContext(ctx, "SELECT id, dedup_key FROM alert_events WHERE rule_id = ? AND status <> ?", rule.ID, alertStatusResolved)
+	if err != nil {
+		return err
+	}
+	active := map[string]int64{}
+	for rows.Next() {
+		var id int64
+		var key string
+		if err := rows.Scan(&id, &key); err != nil {
+			rows.Close()
+			return err
+		}
+		active[key] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	matched := map[string]bool{}
+	for _, candidate := range candidates {
+		if matched[candidate.DedupKey] {
+			continue
+		}
+		matched[candidate.DedupKey] = true
+		if id, ok := active[candidate.DedupKey]; ok {
+			if _, err := h.DB.ExecContext(ctx, "UPDATE alert_events SET last_seen_at = ?, message = ? WHERE id = ?", now, candidate.Message, id); err != nil {
+				return err
+			}
+			continue
+		}
+		if rule.CooldownMinutes > 0 {
+			var last sql.NullTime
+			if err := h.DB.QueryRowContext(ctx, "SELECT MAX(triggered_at) FROM alert_events WHERE rule_id = ? AND dedup_key = ?",
+				rule.ID, candidate.DedupKey).Scan(&last); err != nil {
+				return err
+			}
+			if last.Valid && now.Sub(last.Time) < time.Duration(rule.CooldownMinutes)*time.Minute {
+				continue
+			}
+		}
+		result, err := h.DB.ExecContext(ctx, `INSERT INTO alert_events
+(rule_id, dedup_key, employee_id, department_id, title, message, status, triggered_at, last_seen_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`, rule.ID, candidate.DedupKey, toNullInt64(candidate.EmployeeID), toNullInt64(candidate.DepartmentID),
+			candidate.Title, candidate.Message, alertStatusOpen, now, now)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		event := AlertEventView{
+			ID:           id,
+			RuleID:       rule.ID,
+			RuleName:     rule.Name,
+			RuleType:     rule.RuleType,
+			EmployeeCode: candidate.EmployeeCode,
+			Name:         candidate.Name,
+			DepartmentID: candidate.DepartmentID,
+			Department:   candidate.Department,
+			Title:        candidate.Title,
+			Message:      candidate.Message,
+			Status:       alertStatusOpen,
+			StatusLabel:  alertStatusLabel(alertStatusOpen),
+			TriggeredAt:  formatTime(now),
+			LastSeenAt:   formatTime(now),
+		}
+		h.enqueueAlertDelivery(ctx, rule, event)
+	}
+
+	for key, id := range active {
+		if matched[key] {
+			continue
+		}
+		if _, err := h.DB.ExecContext(ctx, `UPDATE alert_events SET status = ?, resolved_at = ?, resolve_note = ?
+WHERE id = ? AND status <> ?`, alertStatusResolved, now, alertAutoResolveNote, id, alertStatusResolved); err != nil {
+			return err
+		}
+		h.Hub.Broadcast(LiveMessage{Type: "alert_update", Item: map[string]any{"id": id, "status": alertStatusResolved}, Time: formatTime(now)})
+	}
+	return nil
+}
+
+// Alerts 查询告警历史。
+func (h *Handler) Alerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+
+	query := r.URL.Query()
+	page := parseInt(query.Get("page"), 1)
+	pageSize := parseInt(query.Get("pageSize"), 20)
+	if pageSize <= 0 || pageSize > 200 {
+		pageSize = 20
+	}
+	if page <= 0 {
+		page = 1
+	}
+
+	where := "WHERE 1 = 1"
+	args := []any{}
+	if startValue := query.Get("startDate"); startValue != "" {
+		startDate, err := parseDate(startValue)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "开始日期格式错误")
+			return
+		}
+		where += " AND a.triggered_at >= ?"
+		args = append(args, startDate)
+	}
+	if endValue := query.Get("endDate"); endValue != "" {
+		endDate, err := parseDate(endValue)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "结束日期格式错误")
+			return
+		}
+		where += " AND a.triggered_at < ?"
+		args = append(args, endDate.AddDate(0, 0, 1))
+	}
+	switch status := strings.TrimSpace(query.Get("status")); status {
+	case "":
+	case alertStatusOpen, alertStatusAcked, alertStatusResolved:
+		where += " AND a.status = ?"
+		args = append(args, status)
+	case "unresolved":
+		where += " AND a.status <> ?"
+		args = append(args, alertStatusResolved)
+	default:
+		writeError(w, http.StatusBadRequest, "告警状态无效")
+		return
+	}
+	if ruleID := parseInt64(query.Get("ruleId")); ruleID > 0 {
+		where += " AND a.rule_id = ?"
+		args = append(args, ruleID)
+	}
+	if ruleType := strings.TrimSpace(query.Get("ruleType")); ruleType != "" {
+		where += " AND r.rule_type = ?"
+		args = append(args, ruleType)
+	}
+	clause, clauseArgs, err := h.departmentFilter(r.Context(), "a.department_id", parseInt64(query.Get("departmentId")))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取部门失败")
+		return
+	}
+	where += clause
+	args = append(args, clauseArgs...)
+	if keyword := strings.TrimSpace(query.Get("keyword")); keyword != "" {
+		where += " AND (a.title LIKE ? OR e.employee_code LIKE ? OR e.name LIKE ?)"
+		like := "%" + keyword + "%"
+		args = append(args, like, like, like)
+	}
+
+	from := ` FROM alert_events a
+LEFT JOIN alert_rules r ON a.rule_id = r.id
+LEFT JOIN employees e ON a.employee_id = e.id
+LEFT JOIN departments d ON a.department_id = d.id
+LEFT JOIN admin_users ack ON a.acked_by = ack.id
+LEFT JOIN admin_users res ON a.resolved_by = res.id `
+	var total int64
+	if err := h.DB.QueryRowContext(r.Context(), "SELECT COUNT(1)"+from+where, args...).Scan(&total); err != nil {
+		writeError(w, http.StatusInternalServerError, "读取告警失败")
+		return
+	}
+
+	args = append(args, pageSize, (page-1)*pageSize)
+	rows, err := h.DB.QueryContext(r.Context(), `SELECT a.id, a.rule_id, COALESCE(r.name, ''), COALESCE(r.rule_type, ''),
+ COALESCE(e.employee_code, ''), COALESCE(e.name, ''), COALESCE(a.department_id, 0), COALESCE(d.name, ''),
+ a.title, a.message, a.status, a.triggered_at, a.last_seen_at, COALESCE(ack.display_name, ''), a.acked_at,
+ COALESCE(res.display_name, ''), a.resolved_at, COALESCE(a.resolve_note, '')`+from+where+
+		" ORDER BY a.triggered_at DESC, a.id DESC LIMIT ? OFFSET ?", args...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取告警失败")
+		return
+	}
+	defer rows.Close()
+	items := make([]AlertEventView, 0)
+	index := map[int64]int{}
+	for rows.Next() {
+		var item AlertEventView
+		var triggeredAt time.Time
+		var lastSeenAt time.Time
+		var ackedAt sql.NullTime
+		var resolvedAt sql.NullTime
+		if err := rows.Scan(&item.ID, &item.RuleID, &item.RuleName, &item.RuleType, &item.EmployeeCode, &item.Name, &item.DepartmentID,
+			&item.Department, &item.Title, &item.Message, &item.Status, &triggeredAt, &lastSeenAt, &item.AckedBy, &ackedAt,
+			&item.ResolvedBy, &resolvedAt, &item.ResolveNote); err != nil {
+			writeError(w, http.StatusInternalServerError, "读取告警失败")
+			return
+		}
+		item.StatusLabel = alertStatusLabel(item.Status)
+		item.TriggeredAt = formatTime(triggeredAt)
+		item.LastSeenAt = formatTime(lastSeenAt)
+		if ackedAt.Valid {
+			item.AckedAt = formatTime(ackedAt.Time)
+		}
+		if resolvedAt.Valid {
+			item.ResolvedAt = formatTime(resolvedAt.Time)
+			if item.ResolvedBy == "" {
+				item.ResolvedBy = "系统"
+			}
+		}
+		index[item.ID] = len(items)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "读取告警失败")
+		return
+	}
+
+	if len(items) > 0 {
+		ids := make([]int64, 0, len(items))
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		inClause, inArgs := departmentInClause("alert_event_id", ids)
+		deliveries, err := h.DB.QueryContext(r.Context(), `SELECT alert_event_id, channel, status, COALESCE(error_message, ''), created_at
+FROM alert_deliveries WHERE `+inClause+" ORDER BY id", inArgs...)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "读取告警失败")
+			return
+		}
+		defer deliveries.Close()
+		for deliveries.Next() {
+			var eventID int64
+			var delivery AlertDeliveryView
+			var createdAt time.Time
+			if err := deliveries.Scan(&eventID, &delivery.Channel, &delivery.Status, &delivery.Error, &createdAt); err != nil {
+				writeError(w, http.StatusInternalServerError, "读取告警失败")
+				return
+			}
+			delivery.ChannelLabel = alertChannelLabel(delivery.Channel)
+			delivery.CreatedAt = formatTime(createdAt)
+			i := index[eventID]
+			items[i].Deliveries = append(items[i].Deliveries, delivery)
+		}
+		if err := deliveries.Err(); err != nil {
+			writeError(w, http.StatusInternalServerError, "读取告警失败")
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, AlertEventListResponse{Total: total, Items: items})
+}
+
+// AlertAction 确认或解决告警。确认只对待处理的告警生效，解决后同键条件再次满足时会按冷却时间重新触发。
+func (h *Handler) AlertAction(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	var payload AlertActionPayload
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "参数格式错误")
+		return
+	}
+	payload.Note = strings.TrimSpace(payload.Note)
+	if payload.ID <= 0 {
+		writeError(w, http.StatusBadRequest, "告警编号无效")
+		return
+	}
+	if utf8.RuneCountInString(payload.Note) > 255 {
+		writeError(w, http.StatusBadRequest, "备注长度不能超过 255 个字符")
+		return
+	}
+
+	var status string
+	if err := h.DB.QueryRowContext(r.Context(), "SELECT status FROM alert_events WHERE id = ?", payload.ID).Scan(&status); err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "告警不存在")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "读取告警失败")
+		return
+	}
+
+	adminID := adminIDFromRequest(r)
+	now := time.Now()
+	var result sql.Result
+	var err error
+	switch payload.Action {
+	case "ack":
+		if status != alertStatusOpen {
+			writeError(w, http.StatusConflict, "只能确认待处理的告警")
+			return
+		}
+		result, err = h.DB.ExecContext(r.Context(), "UPDATE alert_events SET status = ?, acked_by = ?, acked_at = ? WHERE id = ? AND status = ?",
+			alertStatusAcked, adminID, now, payload.ID, alertStatusOpen)
+		status = alertStatusAcked
+	case "resolve":
+		if status == alertStatusResolved {
+			writeError(w, http.StatusConflict, "告警已解决")
+			return
+		}
+		result, err = h.DB.ExecContext(r.Context(), `UPDATE alert_events SET status = ?, resolved_by = ?, resolved_at = ?, resolve_note = ?
+WHERE id = ? AND status <> ?`, alertStatusResolved, adminID, now, toNullString(payload.Note), payload.ID, alertStatusResolved)
+		status = alertStatusResolved
+	default:
+		writeError(w, http.StatusBadRequest, "操作类型无效")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "更新告警失败")
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		writeError(w, http.StatusConflict, "告警状态已变化，请刷新后重试")
+		return
+	}
+	h.logAudit(r, payload.Action+"_alert", "alert_event", sql.NullInt64{Int64: payload.ID, Valid: true}, payload)
+	h.Hub.Broadcast(LiveMessage{Type: "alert_update", Item: map[string]any{"id": payload.ID, "status": status}, Time: formatTime(now)})
+	writeJSON(w, http.StatusOK, map[string]any{"id": payload.ID, "status": status})
+}
@@ -0,0 +1,600 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+
+	"worksentry/internal/db/sqlc"
+)
+
+const (
+	attendanceOnTime         = "on_time"
+	attendanceLate           = "late"
+	attendanceEarlyLeave     = "early_leave"
+	attendanceLateEarlyLeave = "late_early_leave"
+	attendanceAbsent         = "absent"
+	attendanceLeave          = "leave"
+
+	attendanceMaxRangeDays = 31
+)
+
+type AttendanceRecordView struct {
+	ID            int64  `json:"id"`
+	WorkDate      string `json:"workDate"`
+	EmployeeCode  string `json:"employeeCode"`
+	Name          string `json:"name"`
+	Department    string `json:"department"`
+	Shift         string `json:"shift"`
+	ExpectedStart string `json:"expectedStart"`
+	ExpectedEnd   string `json:"expectedEnd"`
+	ActualStart   string `json:"actualStart"`
+	ActualEnd     string `json:"actualEnd"`
+	Status        string `json:"status"`
+	StatusLabel   string `json:"statusLabel"`
+	LateMinutes   int32  `json:"lateMinutes"`
+	EarlyMinutes  int32  `json:"earlyMinutes"`
+}
+
+type AttendanceSummary struct {
+	OnTime     int64 `json:"onTime"`
+	Late       int64 `json:"late"`
+	EarlyLeave int64 `json:"earlyLeave"`
+	Absent     int64 `json:"absent"`
+	Leave      int64 `json:"leave"`
+}
+
+type AttendanceReportResponse struct {
+	Total   int64                  `json:"total"`
+	Summary AttendanceSummary      `json:"summary"`
+	Items   []AttendanceRecordView `json:"items"`
+}
+
+type AttendanceEvaluatePayload struct {
+	StartDate string `json:"startDate"`
+	EndDate   string `json:"endDate"`
+}
+
+// attendanceEvaluateResult 中 Unevaluated 为未配置班次、无法评估出勤的启用员工工号。
+type attendanceEvaluateResult struct {
+	Evaluated   int      `json:"evaluated"`
+	Pending     int      `json:"pending"`
+	Removed     int      `json:"removed"`
+	Unevaluated []string `json:"unevaluated"`
+}
+
+type attendanceEmployee struct {
+	Code         string
+	DepartmentID int64
+}
+
+type attendanceSession struct {
+	StartAt time.Time
+	EndAt   sql.NullTime
+}
+
+type attendanceKey struct {
+	EmployeeID int64
+	Date       string
+}
+
+// evaluateAttendance 按员工生效班次与工作日历评估 [start, end) 内每个业务日的出勤情况；
+// 班次尚未结束的日期暂不评估，非工作日清理已有记录；未配置班次的员工列入 Unevaluated。
+func (h *Handler) evaluateAttendance(ctx context.Context, start time.Time, end time.Time, now time.Time) (attendanceEvaluateResult, error) {
+	result := attendanceEvaluateResult{Unevaluated: []string{}}
+	if h.DB == nil {
+		return result, fmt.Errorf("数据库未初始化")
+	}
+
+	shifts, err := h.Queries.ListEmployeeShifts(ctx)
+	if err != nil {
+		return result, err
+	}
+	employees, err := loadEnabledAttendanceEmployees(ctx, h.DB)
+	if err != nil {
+		return result, err
+	}
+	withShift := make(map[int64]bool, len(shifts))
+	for _, item := range shifts {
+		withShift[item.EmployeeID] = true
+	}
+	for id, employee := range employees {
+		if !withShift[id] {
+			result.Unevaluated = append(result.Unevaluated, employee.Code)
+		}
+	}
+	sort.Strings(result.Unevaluated)
+	if len(shifts) == 0 {
+		return result, nil
+	}
+	sessions, err := loadAttendanceSessions(ctx, h.DB, start.AddDate(0, 0, -1), end.AddDate(0, 0, 2), shifts)
+	if err != nil {
+		return result, err
+	}
+	leaves, err := loadApprovedLeaves(ctx, h.DB, start.AddDate(0, 0, -1), end.AddDate(0, 0, 2))
+	if err != nil {
+		return result, err
+	}
+
+	calendars := map[int64]map[string]calendarDay{}
+	for _, item := range shifts {
+		employee, enabled := employees[item.EmployeeID]
+		if !enabled {
+			continue
+		}
+		departmentID := employee.DepartmentID
+		calendar, ok := calendars[departmentID]
+		if !ok {
+			days, err := h.resolveCalendarDays(ctx, departmentID, start, end)
+			if err != nil {
+				return result, err
+			}
+			calendar = make(map[string]calendarDay, len(days))
+			for _, day := range days {
+				calendar[day.Date.Format("2006-01-02")] = day
+			}
+			calendars[departmentID] = calendar
+		}
+
+		for date := start; date.Before(end); date = date.AddDate(0, 0, 1) {
+			dateKey := date.Format("2006-01-02")
+			if day, ok := calendar[dateKey]; ok && !day.IsWorkday() {
+				removed, err := h.DB.ExecContext(ctx, "DELETE FROM attendance_records WHERE employee_id = ? AND work_date = ?", item.EmployeeID, dateKey)
+				if err != nil {
+					return result, err
+				}
+				if affected, _ := removed.RowsAffected(); affected > 0 {
+					result.Removed++
+				}
+				continue
+			}
+
+			expectedStart, expectedEnd := shiftExpectedRange(item.WorkShift, date)
+			if now.Before(expectedEnd.Add(time.Duration(item.GraceMinutes) * time.Minute)) {
+				result.Pending++
+				continue
+			}
+
+			var actualStart sql.NullTime
+			var actualEnd sql.NullTime
+			for _, session := range sessions[attendanceKey{EmployeeID: item.EmployeeID, Date: dateKey}] {
+				if !actualStart.Valid || session.StartAt.Before(actualStart.Time) {
+					actualStart = sql.NullTime{Time: session.StartAt, Valid: true}
+				}
+				if session.EndAt.Valid && (!actualEnd.Valid || session.EndAt.Time.After(actualEnd.Time)) {
+					actualEnd = session.EndAt
+				}
+			}
+
+			// 请假覆盖的时段不计迟到早退，整段请假记为请假
+			status, lateMinutes, earlyMinutes := attendanceLeave, int32(0), int32(0)
+			if dutyStart, dutyEnd, onDuty := trimExpectedRangeByLeave(expectedStart, expectedEnd, leaves[item.EmployeeID]); onDuty {
+				status, lateMinutes, earlyMinutes = classifyAttendance(item.WorkShift, dutyStart, dutyEnd, actualStart, actualEnd)
+			}
+			if _, err := h.DB.ExecContext(ctx, `INSERT INTO attendance_records (employee_id, department_id, work_date, shift_id, expected_start, expected_end, actual_start, actual_end, status, late_minutes, early_minutes)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE department_id = VALUES(department_id), shift_id = VALUES(shift_id), expected_start = VALUES(expected_start), expected_end = VALUES(expected_end),
+ actual_start = VALUES(actual_start), actual_end = VALUES(actual_end),
+ status = VALUES(status), late_minutes = VALUES(late_minutes), early_minutes = VALUES(early_minutes)`,
+				item.EmployeeID, nullIfZeroID(departmentID), dateKey, item.ID, expectedStart, expectedEnd, actualStart, actualEnd, status, lateMinutes, earlyMinutes); err != nil {
+				return result, err
+			}
+			result.Evaluated++
+		}
+	}
+	return result, nil
+}
+
+// classifyAttendance 迟到以超过宽限时间为准，分钟数按实际偏离上班时间计；
+// 未下班的记录无法判断早退，只按迟到处理。
+func classifyAttendance(shift sqlc.WorkShift, expectedStart time.Time, expectedEnd time.Time, actualStart sql.NullTime, actualEnd sql.NullTime) (string, int32, int32) {
+	if !actualStart.Valid {
+		return attendanceAbsent, 0, 0
+	}
+	var lateMinutes int32
+	var earlyMinutes int32
+	grace := time.Duration(shift.GraceMinutes) * time.Minute
+	if actualStart.Time.After(expectedStart.Add(grace)) {
+		lateMinutes = ceilMinutes(actualStart.Time.Sub(expectedStart))
+	}
+	if actualEnd.Valid && actualEnd.Time.Before(expectedEnd) {
+		earlyMinutes = ceilMinutes(expectedEnd.Sub(actualEnd.Time))
+	}
+	switch {
+	case lateMinutes > 0 && earlyMinutes > 0:
+		return attendanceLateEarlyLeave, lateMinutes, earlyMinutes
+	case lateMinutes > 0:
+		return attendanceLate, lateMinutes, 0
+	case earlyMinutes > 0:
+		return attendanceEarlyLeave, 0, earlyMinutes
+	default:
+		return attendanceOnTime, 0, 0
+	}
+}
+
+func shiftExpectedRange(shift sqlc.WorkShift, date time.Time) (time.Time, time.Time) {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	expectedStart := day.Add(time.Duration(shift.StartMinute) * time.Minute)
+	expectedEnd := day.Add(time.Duration(shift.EndMinute) * time.Minute)
+	if isCrossMidnightShift(shift) {
+		expectedEnd = expectedEnd.AddDate(0, 0, 1)
+	}
+	return expectedStart, expectedEnd
+}
+
+func ceilMinutes(d time.Duration) int32 {
+	return int32((d + time.Minute - 1) / time.Minute)
+}
+
+func loadEnabledAttendanceEmployees(ctx context.Context, db sqlc.DBTX) (map[int64]attendanceEmployee, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, employee_code, COALESCE(department_id, 0) FROM employees WHERE enabled = 1")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	employees := map[int64]attendanceEmployee{}
+	for rows.Next() {
+		var id int64
+		var employee attendanceEmployee
+		if err := rows.Scan(&id, &employee.Code, &employee.DepartmentID); err != nil {
+			return nil, err
+		}
+		employees[id] = employee
+	}
+	return employees, rows.Err()
+}
+
+// loadAttendanceSessions 读取区间内的上班记录，并按员工班次归属到业务日。
+func loadAttendanceSessions(ctx context.Context, db sqlc.DBTX, start time.Time, end time.Time, shifts []sqlc.EmployeeShift) (map[attendanceKey][]attendanceSession, error) {
+	offsets := make(map[int64]time.Duration, len(shifts))
+	for _, item := range shifts {
+		offsets[item.EmployeeID] = shiftDayOffset(item.WorkShift)
+	}
+	rows, err := db.QueryContext(ctx, `SELECT employee_id, start_at, end_at FROM work_sessions
+WHERE start_at >= ? AND start_at < ?`, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := map[attendanceKey][]attendanceSession{}
+	for rows.Next() {
+		var employeeID int64
+		var session attendanceSession
+		if err := rows.Scan(&employeeID, &session.StartAt, &session.EndAt); err != nil {
+			return nil, err
+		}
+		offset, ok := offsets[employeeID]
+		if !ok {
+			continue
+		}
+		key := attendanceKey{EmployeeID: employeeID, Date: businessDate(session.StartAt, offset).Format("2006-01-02")}
+		sessions[key] = append(sessions[key], session)
+	}
+	return sessions, rows.Err()
+}
+
+func (h *Handler) attendanceEvaluateLoop(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.evaluateRecentAttendance(ctx)
+		}
+	}
+}
+
+// evaluateRecentAttendance 重新评估最近三个业务日，覆盖跨零点班次在次日结束的情况。
+func (h *Handler) evaluateRecentAttendance(ctx context.Context) {
+	if h.DB == nil {
+		return
+	}
+	now := time.Now()
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1)
+	result, err := h.evaluateAttendance(ctx, end.AddDate(0, 0, -3), end, now)
+	if err != nil {
+		log.Printf("出勤评估失败: %v", err)
+		return
+	}
+	if len(result.Unevaluated) > 0 {
+		// 名单可能很长且每小时重复，只记录人数；名单见手动评估的返回结果
+		log.Printf("出勤评估跳过 %d 名未配置班次的员工", len(result.Unevaluated))
+	}
+}
+
+func (h *Handler) AttendanceEvaluate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	var payload AttendanceEvaluatePayload
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "参数格式错误")
+		return
+	}
+	start, end, message := parseAttendanceRange(payload.StartDate, payload.EndDate)
+	if message != "" {
+		writeError(w, http.StatusBadRequest, message)
+		return
+	}
+
+	result, err := h.evaluateAttendance(r.Context(), start, end, time.Now())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "出勤评估失败")
+		return
+	}
+	h.logAudit(r, "evaluate_attendance", "attendance_record", sql.NullInt64{}, map[string]any{
+		"startDate":   start.Format("2006-01-02"),
+		"endDate":     end.AddDate(0, 0, -1).Format("2006-01-02"),
+		"evaluated":   result.Evaluated,
+		"unevaluated": len(result.Unevaluated),
+	})
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h *Handler) ReportAttendance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	where, args, message, err := h.buildAttendanceFilter(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取部门失败")
+		return
+	}
+	if message != "" {
+		writeError(w, http.StatusBadRequest, message)
+		return
+	}
+
+	page := parseInt(r.URL.Query().Get("page"), 1)
+	pageSize := parseInt(r.URL.Query().Get("pageSize"), 20)
+	if pageSize <= 0 || pageSize > 200 {
+		pageSize = 20
+	}
+	if page <= 0 {
+		page = 1
+	}
+
+	response := AttendanceReportResponse{}
+	summaryRows, err := h.DB.QueryContext(r.Context(), "SELECT a.status, COUNT(1) FROM attendance_records a JOIN employees e ON a.employee_id = e.id "+where+" GROUP BY a.status", args...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取出勤记录失败")
+		return
+	}
+	defer summaryRows.Close()
+	for summaryRows.Next() {
+		var status string
+		var count int64
+		if err := summaryRows.Scan(&status, &count); err != nil {
+			writeError(w, http.StatusInternalServerError, "读取出勤记录失败")
+			return
+		}
+		response.Total += count
+		switch status {
+		case attendanceOnTime:
+			response.Summary.OnTime += count
+		case attendanceLate:
+			response.Summary.Late += count
+		case attendanceEarlyLeave:
+			response.Summary.EarlyLeave += count
+		case attendanceLateEarlyLeave:
+			response.Summary.Late += count
+			response.Summary.EarlyLeave += count
+		case attendanceAbsent:
+			response.Summary.Absent += count
+		case attendanceLeave:
+			response.Summary.Leave += count
+		}
+	}
+	if err := summaryRows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "读取出勤记录失败")
+		return
+	}
+
+	args = append(args, pageSize, (page-1)*pageSize)
+	items, err := h.queryAttendanceRecords(r.Context(), where+" ORDER BY a.work_date DESC, e.employee_code ASC LIMIT ? OFFSET ?", args...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取出勤记录失败")
+		return
+	}
+	response.Items = items
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) ExportAttendance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	where, args, message, err := h.buildAttendanceFilter(r)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取部门失败")
+		return
+	}
+	if message != "" {
+		writeError(w, http.StatusBadRequest, message)
+		return
+	}
+	items, err := h.queryAttendanceRecords(r.Context(), where+" ORDER BY a.work_date ASC, e.employee_code ASC", args...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "导出失败")
+		return
+	}
+
+	file := excelize.NewFile()
+	sheet := "出勤记录"
+	file.SetSheetName("Sheet1", sheet)
+
+	headers := []string{"日期", "工号", "姓名", "部门", "班次", "应上班", "应下班", "实际上班", "实际下班", "出勤结果", "迟到(分钟)", "早退(分钟)"}
+	for col, header := range headers {
+		cell, _ := excelize.CoordinatesToCellName(col+1, 1)
+		_ = file.SetCellValue(sheet, cell, header)
+	}
+	for i, item := range items {
+		values := []any{
+			item.WorkDate,
+			item.EmployeeCode,
+			item.Name,
+			item.Department,
+			item.Shift,
+			item.ExpectedStart,
+			item.ExpectedEnd,
+			item.ActualStart,
+			item.ActualEnd,
+			item.StatusLabel,
+			item.LateMinutes,
+			item.EarlyMinutes,
+		}
+		for col, value := range values {
+			cell, _ := excelize.CoordinatesToCellName(col+1, i+2)
+			_ = file.SetCellValue(sheet, cell, spreadsheetCellValue(value))
+		}
+	}
+	file.SetColWidth(sheet, "A", "L", 18)
+
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", "attachment; filename=worksentry_attendance.xlsx")
+	_ = file.Write(w)
+}
+
+func (h *Handler) buildAttendanceFilter(r *http.Request) (string, []any, string, error) {
+	start, end, message := parseAttendanceRange(r.URL.Query().Get("startDate"), r.URL.Query().Get("endDate"))
+	if message != "" {
+		return "", nil, message, nil
+	}
+	where := "WHERE a.work_date >= ? AND a.work_date < ?"
+	args := []any{start.Format("2006-01-02"), end.Format("2006-01-02")}
+
+	clause, clauseArgs, err := h.departmentFilter(r.Context(), "a.department_id", parseInt64(r.URL.Query().Get("departmentId")))
+	if err != nil {
+		return "", nil, "", err
+	}
+	where += clause
+	args = append(args, clauseArgs...)
+	switch status := strings.TrimSpace(r.URL.Query().Get("status")); status {
+	case "":
+	case attendanceLate, attendanceEarlyLeave:
+		where += " AND a.status IN (?, ?)"
+		args = append(args, status, attendanceLateEarlyLeave)
+	case attendanceOnTime, attendanceLateEarlyLeave, attendanceAbsent, attendanceLeave:
+		where += " AND a.status = ?"
+		args = append(args, status)
+	default:
+		return "", nil, "出勤状态无效", nil
+	}
+	if keyword := strings.TrimSpace(r.URL.Query().Get("keyword")); keyword != "" {
+		where += " AND (e.employee_code LIKE ? OR e.name LIKE ?)"
+		like := "%" + keyword + "%"
+		args = append(args, like, like)
+	}
+	return where, args, "", nil
+}
+
+// parseAttendanceRange 解析日期区间，返回 [start, end) 形式，默认为昨天。
+func parseAttendanceRange(startValue string, endValue string) (time.Time, time.Time, string) {
+	if startValue == "" {
+		startValue = time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	}
+	if endValue == "" {
+		endValue = startValue
+	}
+	start, err := parseDate(startValue)
+	if err != nil {
+		return time.Time{}, time.Time{}, "开始日期格式错误"
+	}
+	endDate, err := parseDate(endValue)
+	if err != nil {
+		return time.Time{}, time.Time{}, "结束日期格式错误"
+	}
+	if endDate.Before(start) {
+		return time.Time{}, time.Time{}, "结束日期不能早于开始日期"
+	}
+	if endDate.Sub(start) >= attendanceMaxRangeDays*24*time.Hour {
+		return time.Time{}, time.Time{}, "日期范围不能超过 31 天"
+	}
+	return start, endDate.AddDate(0, 0, 1), ""
+}
+
+func (h *Handler) queryAttendanceRecords(ctx context.Context, where string, args ...any) ([]AttendanceRecordView, error) {
+	rows, err := h.DB.QueryContext(ctx, `SELECT a.id, a.work_date, e.employee_code, e.name, COALESCE(d.name, ''), COALESCE(s.name, ''),
+ a.expected_start, a.expected_end, a.actual_start, a.actual_end, a.status, a.late_minutes, a.early_minutes
+FROM attendance_records a
+JOIN employees e ON a.employee_id = e.id
+LEFT JOIN departments d ON a.department_id = d.id
+LEFT JOIN work_shifts s ON a.shift_id = s.id `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]AttendanceRecordView, 0)
+	for rows.Next() {
+		var (
+			item          AttendanceRecordView
+			workDate      time.Time
+			expectedStart time.Time
+			expectedEnd   time.Time
+			actualStart   sql.NullTime
+			actualEnd     sql.NullTime
+		)
+		if err := rows.Scan(&item.ID, &workDate, &item.EmployeeCode, &item.Name, &item.Department, &item.Shift,
+			&expectedStart, &expectedEnd, &actualStart, &actualEnd, &item.Status, &item.LateMinutes, &item.EarlyMinutes); err != nil {
+			return nil, err
+		}
+		item.WorkDate = workDate.Format("2006-01-02")
+		item.ExpectedStart = formatTime(expectedStart)
+		item.ExpectedEnd = formatTime(expectedEnd)
+		item.ActualStart = "-"
+		if actualStart.Valid {
+			item.ActualStart = formatTime(actualStart.Time)
+		}
+		item.ActualEnd = "-"
+		if actualEnd.Valid {
+			item.ActualEnd = formatTime(actualEnd.Time)
+		}
+		item.StatusLabel = attendanceStatusLabel(item.Status)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func attendanceStatusLabel(status string) string {
+	switch status {
+	case attendanceOnTime:
+		return "正常"
+	case attendanceLate:
+		return "迟到"
+	case attendanceEarlyLeave:
+		return "早退"
+	case attendanceLateEarlyLeave:
+		return "迟到且早退"
+	case attendanceAbsent:
+		return "缺勤"
+	case attendanceLeave:
+		return "请假"
+	default:
+		return "未知"
+	}
+}
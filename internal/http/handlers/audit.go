@@ -22,6 +22,9 @@ func (h *Handler) AuditLogs(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
 		return
 	}
+	if h.exportIfRequested(w, r, h.auditLogsExport) {
+		return
+	}
 	date := r.URL.Query().Get("date")
 	params := sqlc.ListAuditLogsParams{
 		Column1:   date,
@@ -3,7 +3,6 @@ package handlers
 import (
 	"database/sql"
 	"net/http"
-	"time"
 )
 
 type CheckoutTemplateResponse struct {
@@ -31,37 +30,8 @@ func (h *Handler) ClientCheckoutTemplate(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	token := readBearerToken(r)
-	if token == "" {
-		writeError(w, http.StatusUnauthorized, "缺少令牌")
-		return
-	}
-
-	clientToken, err := h.Queries.GetToken(r.Context(), token)
-	if err == sql.ErrNoRows {
-		writeError(w, http.StatusUnauthorized, "令牌无效")
-		return
-	}
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "令牌校验失败")
-		return
-	}
-	if clientToken.Revoked {
-		writeError(w, http.StatusUnauthorized, "令牌已失效")
-		return
-	}
-	if clientToken.ExpiresAt.Valid && clientToken.ExpiresAt.Time.Before(time.Now()) {
-		writeError(w, http.StatusUnauthorized, "令牌已过期")
-		return
-	}
-
-	employee, err := h.Queries.GetEmployeeByID(r.Context(), clientToken.EmployeeID)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "员工不存在")
-		return
-	}
-	if !employee.Enabled {
-		writeError(w, http.StatusForbidden, "员工已停用")
+	employee, ok := h.authenticateClient(w, r)
+	if !ok {
 		return
 	}
 
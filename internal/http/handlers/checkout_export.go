@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// checkoutExportMaxFieldColumns 字段列上限，模板过多时需按模板或部门缩小范围
+const checkoutExportMaxFieldColumns = 300
+
+var checkoutExportBaseColumns = []exportColumn{
+	{Key: "id", Label: "编号"},
+	{Key: "employeeCode", Label: "工号"},
+	{Key: "name", Label: "姓名"},
+	{Key: "department", Label: "部门"},
+	{Key: "startAt", Label: "上班时间"},
+	{Key: "endAt", Label: "下班时间"},
+	{Key: "templateName", Label: "模板"},
+	{Key: "templateVersion", Label: "模板版本"},
+	{Key: "createdAt", Label: "提交时间"},
+}
+
+// checkoutTemplateVersion 模板快照的一个版本。提交时保存的快照内容不同即视为不同版本，
+// 同一模板按首次出现的先后编号。
+type checkoutTemplateVersion struct {
+	TemplateID int64
+	Number     int
+	Digest     string
+	Snapshot   CheckoutTemplateSnapshot
+}
+
+// checkoutFieldColumnKey 字段在各版本间保持编号、名称和类型不变时共用一列，改名或改类型后另起一列。
+type checkoutFieldColumnKey struct {
+	TemplateID int64
+	FieldID    int64
+	Name       string
+	Type       string
+}
+
+// ExportCheckoutRecords 默认导出 xlsx，参数与下班填报列表一致。
+func (h *Handler) ExportCheckoutRecords(w http.ResponseWriter, r *http.Request) {
+	h.serveExport(w, r, h.checkoutRecordsExport)
+}
+
+// checkoutRecordsExport 每条填报一行，字段按模板快照展开为独立的列，数字字段按数值写出。
+func (h *Handler) checkoutRecordsExport(ctx context.Context, params url.Values) (exportPlan, int, string) {
+	whereSQL, args, status, message := h.checkoutRecordFilter(ctx, params)
+	if message != "" {
+		return exportPlan{}, status, message
+	}
+	versions, err := h.loadCheckoutTemplateVersions(ctx, whereSQL, args)
+	if err != nil {
+		return exportPlan{}, http.StatusInternalServerError, "读取模板失败"
+	}
+
+	columns, fieldIndex := checkoutExportColumns(versions)
+	if len(fieldIndex) > checkoutExportMaxFieldColumns {
+		return exportPlan{}, http.StatusBadRequest, fmt.Sprintf("填报字段超过 %d 列，请按模板或部门缩小导出范围", checkoutExportMaxFieldColumns)
+	}
+
+	versionNumbers := make(map[string]int, len(versions))
+	for _, version := range versions {
+		versionNumbers[fmt.Sprintf("%d:%s", version.TemplateID, version.Digest)] = version.Number
+	}
+	snapshots := map[string]CheckoutTemplateSnapshot{}
+
+	query := "SELECT c.id, c.template_id, e.employee_code, e.name, COALESCE(d.name, ''), ws.start_at, ws.end_at, COALESCE(t.name_zh, ''), c.created_at, c.template_snapshot_json, c.data_json " +
+		checkoutRecordFrom + "WHERE " + whereSQL + " ORDER BY c.created_at, c.id"
+	return exportPlan{
+		Name:    "checkout_records",
+		Sheet:   "下班填报",
+		Columns: columns,
+		Query:   query,
+		Args:    args,
+		Convert: func(rows *sql.Rows) ([]any, error) {
+			var id, templateID int64
+			var code, name, department, templateName, snapshotJSON, dataJSON string
+			var startAt, createdAt time.Time
+			var endAt sql.NullTime
+			if err := rows.Scan(&id, &templateID, &code, &name, &department, &startAt, &endAt, &templateName, &createdAt, &snapshotJSON, &dataJSON); err != nil {
+				return nil, err
+			}
+			digest := checkoutSnapshotDigest(snapshotJSON)
+			snapshot, ok := snapshots[digest]
+			if !ok {
+				_ = json.Unmarshal([]byte(snapshotJSON), &snapshot)
+				snapshots[digest] = snapshot
+			}
+			if templateName == "" {
+				templateName = snapshot.Name
+			}
+			values := make([]any, len(columns))
+			values[0], values[1], values[2], values[3] = id, code, name, department
+			values[4], values[5], values[6] = formatTime(startAt), nullTimeText(endAt), templateName
+			if number := versionNumbers[fmt.Sprintf("%d:%s", templateID, digest)]; number > 0 {
+				values[7] = fmt.Sprintf("v%d", number)
+			}
+			values[8] = formatTime(createdAt)
+
+			data := map[string]string{}
+			_ = json.Unmarshal([]byte(dataJSON), &data)
+			fillCheckoutFieldValues(values, fieldIndex, templateID, snapshot, data)
+			return values, nil
+		},
+	}, 0, ""
+}
+
+// checkoutExportColumns 返回基础列加按模板快照展开的字段列，以及字段在行中的下标。
+func checkoutExportColumns(versions []checkoutTemplateVersion) ([]exportColumn, map[checkoutFieldColumnKey]int) {
+	columns := append([]exportColumn{}, checkoutExportBaseColumns...)
+	fieldIndex := map[checkoutFieldColumnKey]int{}
+	fieldVersions := map[checkoutFieldColumnKey][]int{}
+	fieldKeys := []checkoutFieldColumnKey{}
+	templateNames := map[int64]string{}
+	for _, version := range versions {
+		// 列标签使用模板最新版本的名称
+		if version.Snapshot.Name != "" {
+			templateNames[version.TemplateID] = version.Snapshot.Name
+		}
+		for _, field := range version.Snapshot.Fields {
+			key := checkoutFieldColumnKey{TemplateID: version.TemplateID, FieldID: field.ID, Name: field.Name, Type: field.Type}
+			if _, ok := fieldIndex[key]; !ok {
+				fieldIndex[key] = len(columns) + len(fieldKeys)
+				fieldKeys = append(fieldKeys, key)
+			}
+			fieldVersions[key] = append(fieldVersions[key], version.Number)
+		}
+	}
+	for _, key := range fieldKeys {
+		numbers := fieldVersions[key]
+		columns = append(columns, exportColumn{
+			Key:   fmt.Sprintf("t%d_f%d_v%d", key.TemplateID, key.FieldID, numbers[0]),
+			Label: fmt.Sprintf("%s（%s %s）", key.Name, templateNames[key.TemplateID], checkoutVersionText(numbers)),
+		})
+	}
+	return columns, fieldIndex
+}
+
+// fillCheckoutFieldValues 将填报内容写入对应字段列，数字字段能解析时写为数值。
+func fillCheckoutFieldValues(values []any, fieldIndex map[checkoutFieldColumnKey]int, templateID int64, snapshot CheckoutTemplateSnapshot, data map[string]string) {
+	for _, field := range snapshot.Fields {
+		index, ok := fieldIndex[checkoutFieldColumnKey{TemplateID: templateID, FieldID: field.ID, Name: field.Name, Type: field.Type}]
+		if !ok {
+			continue
+		}
+		value := strings.TrimSpace(data[strconv.FormatInt(field.ID, 10)])
+		if value == "" {
+			continue
+		}
+		values[index] = value
+		if field.Type == "number" {
+			if number, err := strconv.ParseFloat(value, 64); err == nil {
+				values[index] = number
+			}
+		}
+	}
+}
+
+// loadCheckoutTemplateVersions 读取筛选结果涉及模板的全部快照版本。版本号按模板的全部填报计算，
+// 不受本次筛选区间影响，因此不同时间导出的版本号保持一致。
+func (h *Handler) loadCheckoutTemplateVersions(ctx context.Context, whereSQL string, args []any) ([]checkoutTemplateVersion, error) {
+	rows, err := h.DB.QueryContext(ctx, `SELECT cv.template_id, MIN(cv.id)
+FROM work_session_checkouts cv
+WHERE cv.template_id IN (SELECT DISTINCT c.template_id `+checkoutRecordFrom+"WHERE "+whereSQL+`)
+GROUP BY cv.template_id, MD5(cv.template_snapshot_json)
+ORDER BY cv.template_id, MIN(cv.id)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	firstIDs := []int64{}
+	for rows.Next() {
+		var templateID, firstID int64
+		if err := rows.Scan(&templateID, &firstID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		firstIDs = append(firstIDs, firstID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(firstIDs) == 0 {
+		return nil, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(firstIDs)), ",")
+	idArgs := make([]any, 0, len(firstIDs))
+	for _, id := range firstIDs {
+		idArgs = append(idArgs, id)
+	}
+	rows, err = h.DB.QueryContext(ctx, "SELECT id, template_id, template_snapshot_json FROM work_session_checkouts WHERE id IN ("+placeholders+")", idArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	type firstSnapshot struct {
+		ID         int64
+		TemplateID int64
+		JSON       string
+	}
+	items := make([]firstSnapshot, 0, len(firstIDs))
+	for rows.Next() {
+		var item firstSnapshot
+		if err := rows.Scan(&item.ID, &item.TemplateID, &item.JSON); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].TemplateID != items[j].TemplateID {
+			return items[i].TemplateID < items[j].TemplateID
+		}
+		return items[i].ID < items[j].ID
+	})
+
+	versions := make([]checkoutTemplateVersion, 0, len(items))
+	numbers := map[int64]int{}
+	for _, item := range items {
+		// 快照无法解析时仍占用版本号，该版本只是没有字段列
+		snapshot := CheckoutTemplateSnapshot{}
+		_ = json.Unmarshal([]byte(item.JSON), &snapshot)
+		numbers[item.TemplateID]++
+		versions = append(versions, checkoutTemplateVersion{
+			TemplateID: item.TemplateID,
+			Number:     numbers[item.TemplateID],
+			Digest:     checkoutSnapshotDigest(item.JSON),
+			Snapshot:   snapshot,
+		})
+	}
+	return versions, nil
+}
+
+// checkoutSnapshotDigest 与查询中的 MD5(template_snapshot_json) 一致，用于识别快照版本。
+func checkoutSnapshotDigest(snapshotJSON string) string {
+	sum := md5.Sum([]byte(snapshotJSON))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkoutVersionText 将版本号列表格式化为 v1、v2-v4 这样的文本。
+func checkoutVersionText(numbers []int) string {
+	parts := make([]string, 0, len(numbers))
+	for i := 0; i < len(numbers); {
+		j := i
+		for j+1 < len(numbers) && numbers[j+1] == numbers[j]+1 {
+			j++
+		}
+		if j == i {
+			parts = append(parts, fmt.Sprintf("v%d", numbers[i]))
+		} else {
+			parts = append(parts, fmt.Sprintf("v%d-v%d", numbers[i], numbers[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, "、")
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckoutVersionText(t *testing.T) {
+	tests := []struct {
+		name    string
+		numbers []int
+		want    string
+	}{
+		{name: "空列表", want: ""},
+		{name: "单个版本", numbers: []int{3}, want: "v3"},
+		{name: "连续版本", numbers: []int{1, 2, 3}, want: "v1-v3"},
+		{name: "两个相邻版本", numbers: []int{4, 5}, want: "v4-v5"},
+		{name: "不连续版本", numbers: []int{1, 3, 5}, want: "v1、v3、v5"},
+		{name: "混合", numbers: []int{1, 2, 3, 5, 7, 8}, want: "v1-v3、v5、v7-v8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkoutVersionText(tt.numbers); got != tt.want {
+				t.Fatalf("checkoutVersionText(%v) = %q，期望 %q", tt.numbers, got, tt.want)
+			}
+		})
+	}
+}
+
+func checkoutTestVersion(number int, name string, fields ...CheckoutFieldSnapshot) checkoutTemplateVersion {
+	return checkoutTemplateVersion{TemplateID: 1, Number: number, Snapshot: CheckoutTemplateSnapshot{TemplateID: 1, Name: name, Fields: fields}}
+}
+
+func TestCheckoutExportColumns(t *testing.T) {
+	output := CheckoutFieldSnapshot{ID: 1, Name: "产出", Type: "number"}
+	tests := []struct {
+		name     string
+		versions []checkoutTemplateVersion
+		want     []exportColumn
+	}{
+		{
+			name: "同一字段跨两个版本",
+			versions: []checkoutTemplateVersion{
+				checkoutTestVersion(1, "日报", output),
+				checkoutTestVersion(2, "日报", output, CheckoutFieldSnapshot{ID: 2, Name: "备注", Type: "text"}),
+			},
+			want: []exportColumn{
+				{Key: "t1_f1_v1", Label: "产出（日报 v1-v2）"},
+				{Key: "t1_f2_v2", Label: "备注（日报 v2）"},
+			},
+		},
+		{
+			name: "字段改名另起一列",
+			versions: []checkoutTemplateVersion{
+				checkoutTestVersion(1, "日报", CheckoutFieldSnapshot{ID: 2, Name: "备注", Type: "text"}),
+				checkoutTestVersion(2, "日报", CheckoutFieldSnapshot{ID: 2, Name: "说明", Type: "text"}),
+				checkoutTestVersion(3, "日报模板", CheckoutFieldSnapshot{ID: 2, Name: "说明", Type: "text"}),
+			},
+			want: []exportColumn{
+				{Key: "t1_f2_v1", Label: "备注（日报模板 v1）"},
+				{Key: "t1_f2_v2", Label: "说明（日报模板 v2-v3）"},
+			},
+		},
+		{
+			name: "字段改类型另起一列",
+			versions: []checkoutTemplateVersion{
+				checkoutTestVersion(1, "日报", output),
+				checkoutTestVersion(2, "日报", CheckoutFieldSnapshot{ID: 1, Name: "产出", Type: "text"}),
+				checkoutTestVersion(3, "日报", output),
+			},
+			want: []exportColumn{
+				{Key: "t1_f1_v1", Label: "产出（日报 v1、v3）"},
+				{Key: "t1_f1_v2", Label: "产出（日报 v2）"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, fieldIndex := checkoutExportColumns(tt.versions)
+			base := len(checkoutExportBaseColumns)
+			if !reflect.DeepEqual(columns[:base], checkoutExportBaseColumns) {
+				t.Fatalf("基础列 = %v", columns[:base])
+			}
+			if got := columns[base:]; !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("字段列 = %v，期望 %v", got, tt.want)
+			}
+			if len(fieldIndex) != len(tt.want) {
+				t.Fatalf("字段下标数 = %d，期望 %d", len(fieldIndex), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestFillCheckoutFieldValues(t *testing.T) {
+	numberField := CheckoutFieldSnapshot{ID: 1, Name: "产出", Type: "number"}
+	textField := CheckoutFieldSnapshot{ID: 1, Name: "产出", Type: "text"}
+	noteField := CheckoutFieldSnapshot{ID: 2, Name: "备注", Type: "text"}
+	columns, fieldIndex := checkoutExportColumns([]checkoutTemplateVersion{
+		checkoutTestVersion(1, "日报", numberField, noteField),
+		checkoutTestVersion(2, "日报", textField),
+	})
+	base := len(checkoutExportBaseColumns)
+	tests := []struct {
+		name     string
+		snapshot CheckoutTemplateSnapshot
+		data     map[string]string
+		want     map[int]any
+	}{
+		{
+			name:     "数字字段写为浮点数",
+			snapshot: checkoutTestVersion(1, "日报", numberField, noteField).Snapshot,
+			data:     map[string]string{"1": " 3.5 ", "2": "正常"},
+			want:     map[int]any{base: 3.5, base + 1: "正常"},
+		},
+		{
+			name:     "无法解析的数字保留原文",
+			snapshot: checkoutTestVersion(1, "日报", numberField).Snapshot,
+			data:     map[string]string{"1": "约 3 个"},
+			want:     map[int]any{base: "约 3 个"},
+		},
+		{
+			name:     "改为文本后的同编号字段",
+			snapshot: checkoutTestVersion(2, "日报", textField).Snapshot,
+			data:     map[string]string{"1": "3.5"},
+			want:     map[int]any{base + 2: "3.5"},
+		},
+		{
+			name:     "空值不写入",
+			snapshot: checkoutTestVersion(1, "日报", numberField, noteField).Snapshot,
+			data:     map[string]string{"1": "  "},
+			want:     map[int]any{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := make([]any, len(columns))
+			fillCheckoutFieldValues(values, fieldIndex, 1, tt.snapshot, tt.data)
+			for index := base; index < len(values); index++ {
+				want, ok := tt.want[index]
+				if !ok {
+					want = nil
+				}
+				if values[index] != want {
+					t.Fatalf("%s = %#v，期望 %#v", columns[index].Label, values[index], want)
+				}
+			}
+			if number, ok := tt.want[base].(float64); ok {
+				if got, isFloat := values[base].(float64); !isFloat || got != number {
+					t.Fatalf("数字字段类型 = %T，期望 float64", values[base])
+				}
+			}
+		})
+	}
+}
@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -47,18 +49,16 @@ type CheckoutRecordDetailView struct {
 	Fields       []CheckoutRecordFieldView `json:"fields"`
 }
 
-func (h *Handler) CheckoutRecords(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
-		return
-	}
-	if h.DB == nil {
-		writeError(w, http.StatusInternalServerError, "数据库未就绪")
-		return
-	}
+const checkoutRecordFrom = "FROM work_session_checkouts c " +
+	"JOIN work_sessions ws ON ws.id = c.work_session_id " +
+	"JOIN employees e ON e.id = ws.employee_id " +
+	"LEFT JOIN departments d ON d.id = e.department_id " +
+	"LEFT JOIN checkout_templates t ON t.id = c.template_id "
 
-	startDate := strings.TrimSpace(r.URL.Query().Get("startDate"))
-	endDate := strings.TrimSpace(r.URL.Query().Get("endDate"))
+// checkoutRecordFilter 解析列表与导出共用的筛选条件，返回不含 WHERE 的条件语句。
+func (h *Handler) checkoutRecordFilter(ctx context.Context, query url.Values) (string, []any, int, string) {
+	startDate := strings.TrimSpace(query.Get("startDate"))
+	endDate := strings.TrimSpace(query.Get("endDate"))
 	if startDate == "" || endDate == "" {
 		today := time.Now().Format("2006-01-02")
 		if startDate == "" {
@@ -71,43 +71,32 @@ func (h *Handler) CheckoutRecords(w http.ResponseWriter, r *http.Request) {
 
 	start, err := parseDate(startDate)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "开始日期格式错误")
-		return
+		return "", nil, http.StatusBadRequest, "开始日期格式错误"
 	}
 	end, err := parseDate(endDate)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "结束日期格式错误")
-		return
+		return "", nil, http.StatusBadRequest, "结束日期格式错误"
 	}
 	if end.Before(start) {
-		writeError(w, http.StatusBadRequest, "结束日期不能早于开始日期")
-		return
+		return "", nil, http.StatusBadRequest, "结束日期不能早于开始日期"
 	}
 	endExclusive := end.Add(24 * time.Hour)
 
-	departmentID, _ := strconv.ParseInt(r.URL.Query().Get("departmentId"), 10, 64)
-	templateID, _ := strconv.ParseInt(r.URL.Query().Get("templateId"), 10, 64)
-	employeeKeyword := strings.TrimSpace(r.URL.Query().Get("employeeKeyword"))
-
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page <= 0 {
-		page = 1
-	}
-	pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
-	if pageSize <= 0 {
-		pageSize = 20
-	}
-	if pageSize > 100 {
-		pageSize = 100
-	}
-	offset := (page - 1) * pageSize
+	departmentID, _ := strconv.ParseInt(query.Get("departmentId"), 10, 64)
+	templateID, _ := strconv.ParseInt(query.Get("templateId"), 10, 64)
+	employeeKeyword := strings.TrimSpace(query.Get("employeeKeyword"))
 
 	whereClauses := []string{"c.created_at >= ?", "c.created_at < ?"}
 	args := []any{start, endExclusive}
 
 	if departmentID > 0 {
-		whereClauses = append(whereClauses, "e.department_id = ?")
-		args = append(args, departmentID)
+		ids, err := h.departmentScope(ctx, departmentID)
+		if err != nil {
+			return "", nil, http.StatusInternalServerError, "读取部门失败"
+		}
+		clause, clauseArgs := departmentInClause("e.department_id", ids)
+		whereClauses = append(whereClauses, clause)
+		args = append(args, clauseArgs...)
 	}
 	if templateID > 0 {
 		whereClauses = append(whereClauses, "c.template_id = ?")
@@ -119,14 +108,42 @@ func (h *Handler) CheckoutRecords(w http.ResponseWriter, r *http.Request) {
 		args = append(args, like, like)
 	}
 
-	whereSQL := strings.Join(whereClauses, " AND ")
+	return strings.Join(whereClauses, " AND "), args, 0, ""
+}
 
-	countSQL := "SELECT COUNT(1) FROM work_session_checkouts c " +
-		"JOIN work_sessions ws ON ws.id = c.work_session_id " +
-		"JOIN employees e ON e.id = ws.employee_id " +
-		"LEFT JOIN departments d ON d.id = e.department_id " +
-		"LEFT JOIN checkout_templates t ON t.id = c.template_id " +
-		"WHERE " + whereSQL
+func (h *Handler) CheckoutRecords(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未就绪")
+		return
+	}
+	if h.exportIfRequested(w, r, h.checkoutRecordsExport) {
+		return
+	}
+
+	whereSQL, args, status, message := h.checkoutRecordFilter(r.Context(), r.URL.Query())
+	if message != "" {
+		writeError(w, status, message)
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+	offset := (page - 1) * pageSize
+
+	countSQL := "SELECT COUNT(1) " + checkoutRecordFrom + "WHERE " + whereSQL
 
 	var total int64
 	if err := h.DB.QueryRowContext(r.Context(), countSQL, args...).Scan(&total); err != nil {
@@ -135,12 +152,7 @@ func (h *Handler) CheckoutRecords(w http.ResponseWriter, r *http.Request) {
 	}
 
 	listSQL := "SELECT c.id, e.employee_code, e.name, COALESCE(d.name, ''), ws.start_at, ws.end_at, COALESCE(t.name_zh, ''), c.created_at, c.template_snapshot_json, c.data_json " +
-		"FROM work_session_checkouts c " +
-		"JOIN work_sessions ws ON ws.id = c.work_session_id " +
-		"JOIN employees e ON e.id = ws.employee_id " +
-		"LEFT JOIN departments d ON d.id = e.department_id " +
-		"LEFT JOIN checkout_templates t ON t.id = c.template_id " +
-		"WHERE " + whereSQL + " ORDER BY c.created_at DESC LIMIT ? OFFSET ?"
+		checkoutRecordFrom + "WHERE " + whereSQL + " ORDER BY c.created_at DESC LIMIT ? OFFSET ?"
 
 	listArgs := append(append([]any{}, args...), pageSize, offset)
 	rows, err := h.DB.QueryContext(r.Context(), listSQL, listArgs...)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -52,6 +53,7 @@ type ClientReportResponse struct {
 	LatestVersion            string `json:"latestVersion"`
 	UpdateURL                string `json:"updateUrl"`
 	ServerTime               string `json:"serverTime"`
+	Notice                   string `json:"notice,omitempty"`
 }
 
 func (h *Handler) ClientBind(w http.ResponseWriter, r *http.Request) {
@@ -140,27 +142,8 @@ func (h *Handler) ClientReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token := readBearerToken(r)
-	if token == "" {
-		writeError(w, http.StatusUnauthorized, "缺少令牌")
-		return
-	}
-
-	clientToken, err := h.Queries.GetToken(r.Context(), token)
-	if err == sql.ErrNoRows {
-		writeError(w, http.StatusUnauthorized, "令牌无效")
-		return
-	}
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "令牌校验失败")
-		return
-	}
-	if clientToken.Revoked {
-		writeError(w, http.StatusUnauthorized, "令牌已失效")
-		return
-	}
-	if clientToken.ExpiresAt.Valid && clientToken.ExpiresAt.Time.Before(time.Now()) {
-		writeError(w, http.StatusUnauthorized, "令牌已过期")
+	employee, ok := h.authenticateClient(w, r)
+	if !ok {
 		return
 	}
 
@@ -170,20 +153,11 @@ func (h *Handler) ClientReport(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	employee, err := h.Queries.GetEmployeeByID(r.Context(), clientToken.EmployeeID)
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "员工不存在")
-		return
-	}
-	if !employee.Enabled {
-		writeError(w, http.StatusForbidden, "员工已停用")
-		return
-	}
-
-	now := time.Now()
+	// DATETIME 列只精确到秒，按秒截断使 daily_stats 增量与落库的时间段一致
+	now := time.Now().Truncate(time.Second)
 	_ = h.Queries.UpdateTokenLastSeen(r.Context(), sqlc.UpdateTokenLastSeenParams{
 		LastSeen: sql.NullTime{Time: now, Valid: true},
-		Token:    token,
+		Token:    readBearerToken(r),
 	})
 
 	settings := h.getSettingsOrDefault(r)
@@ -211,53 +185,66 @@ func (h *Handler) ClientReport(w http.ResponseWriter, r *http.Request) {
 		description = "休息中"
 	}
 
-	prevEvent, prevErr := h.Queries.GetLastRawEventByEmployee(r.Context(), employee.ID)
-
-	if err := h.Queries.CreateRawEvent(r.Context(), sqlc.CreateRawEventParams{
-		EmployeeID:    employee.ID,
-		ReceivedAt:    now,
-		ProcessName:   toNullString(payload.ProcessName),
-		WindowTitle:   toNullString(payload.WindowTitle),
-		IdleSeconds:   payload.IdleSeconds,
-		Status:        sqlc.RawEventsStatus(status),
-		ClientVersion: toNullString(payload.ClientVersion),
-		IpAddress:     toNullString(clientIP(r)),
-	}); err != nil {
-		writeError(w, http.StatusInternalServerError, "写入上报失败")
-		return
-	}
+	if err := h.withEmployeeTx(r.Context(), employee.ID, func(qtx *sqlc.Queries, tx *sql.Tx, locked sqlc.Employee) error {
+		prevEvent, prevErr := qtx.GetLastRawEventByEmployee(r.Context(), locked.ID)
+		if prevErr != nil && prevErr != sql.ErrNoRows {
+			return prevErr
+		}
 
-	_ = h.Queries.UpdateEmployeeLastSeen(r.Context(), sqlc.UpdateEmployeeLastSeenParams{
-		LastSeenAt:      sql.NullTime{Time: now, Valid: true},
-		LastStatus:      sqlc.NullEmployeesLastStatus{EmployeesLastStatus: sqlc.EmployeesLastStatus(status), Valid: true},
-		LastDescription: toNullString(description),
-		ID:              employee.ID,
-	})
+		if err := qtx.CreateRawEvent(r.Context(), sqlc.CreateRawEventParams{
+			EmployeeID:    locked.ID,
+			ReceivedAt:    now,
+			ProcessName:   toNullString(payload.ProcessName),
+			WindowTitle:   toNullString(payload.WindowTitle),
+			IdleSeconds:   payload.IdleSeconds,
+			Status:        sqlc.RawEventsStatus(status),
+			ClientVersion: toNullString(payload.ClientVersion),
+			IpAddress:     toNullString(clientIP(r)),
+		}); err != nil {
+			return err
+		}
 
-	if prevErr == nil {
-		segmentStart := prevEvent.ReceivedAt
-		if employee.LastSegmentEndAt.Valid && employee.LastSegmentEndAt.Time.After(segmentStart) {
-			segmentStart = employee.LastSegmentEndAt.Time
+		if err := qtx.UpdateEmployeeLastSeen(r.Context(), sqlc.UpdateEmployeeLastSeenParams{
+			LastSeenAt:      sql.NullTime{Time: now, Valid: true},
+			LastStatus:      sqlc.NullEmployeesLastStatus{EmployeesLastStatus: sqlc.EmployeesLastStatus(status), Valid: true},
+			LastDescription: toNullString(description),
+			ID:              locked.ID,
+		}); err != nil {
+			return err
 		}
-		gap := now.Sub(prevEvent.ReceivedAt)
-		if now.After(segmentStart) {
-			if gap > time.Duration(settings.OfflineThresholdSeconds)*time.Second {
-				h.createSegmentAndStatsByContext(r.Context(), employee.ID, segmentStart, now, "offline", "", "offline")
-			} else {
-				prevDesc := buildDescription(nullString(prevEvent.ProcessName), nullString(prevEvent.WindowTitle))
-				h.createSegmentAndStatsByContext(r.Context(), employee.ID, segmentStart, now, string(prevEvent.Status), prevDesc, "system")
+
+		if prevErr == nil {
+			segmentStart := prevEvent.ReceivedAt
+			if locked.LastSegmentEndAt.Valid && locked.LastSegmentEndAt.Time.After(segmentStart) {
+				segmentStart = locked.LastSegmentEndAt.Time
+			}
+			gap := now.Sub(prevEvent.ReceivedAt)
+			if now.After(segmentStart) {
+				var err error
+				if gap > time.Duration(settings.OfflineThresholdSeconds)*time.Second {
+					err = h.createSegmentAndStatsByContext(r.Context(), tx, locked.ID, segmentStart, now, "offline", "", "offline")
+				} else {
+					prevDesc := buildDescription(nullString(prevEvent.ProcessName), nullString(prevEvent.WindowTitle))
+					err = h.createSegmentAndStatsByContext(r.Context(), tx, locked.ID, segmentStart, now, string(prevEvent.Status), prevDesc, "system")
+				}
+				if err != nil {
+					return err
+				}
 			}
 		}
-	}
 
-	segmentEnd := now
-	if employee.LastSegmentEndAt.Valid && employee.LastSegmentEndAt.Time.After(segmentEnd) {
-		segmentEnd = employee.LastSegmentEndAt.Time
+		segmentEnd := now
+		if locked.LastSegmentEndAt.Valid && locked.LastSegmentEndAt.Time.After(segmentEnd) {
+			segmentEnd = locked.LastSegmentEndAt.Time
+		}
+		return qtx.UpdateEmployeeLastSegmentEnd(r.Context(), sqlc.UpdateEmployeeLastSegmentEndParams{
+			LastSegmentEndAt: sql.NullTime{Time: segmentEnd, Valid: true},
+			ID:               locked.ID,
+		})
+	}); err != nil {
+		writeError(w, http.StatusInternalServerError, "写入上报失败")
+		return
 	}
-	_ = h.Queries.UpdateEmployeeLastSegmentEnd(r.Context(), sqlc.UpdateEmployeeLastSegmentEndParams{
-		LastSegmentEndAt: sql.NullTime{Time: segmentEnd, Valid: true},
-		ID:               employee.ID,
-	})
 
 	workEndAccepted := false
 	var workEndErr error
@@ -289,6 +276,14 @@ func (h *Handler) ClientReport(w http.ResponseWriter, r *http.Request) {
 		Time: formatTime(now),
 	})
 
+	notice := ""
+	if isWorking && reportType != "break" {
+		var warnErr error
+		if notice, warnErr = h.evaluateFishRatio(r.Context(), employee, settings, now); warnErr != nil {
+			log.Printf("摸鱼占比预警计算失败: employee=%d %v", employee.ID, warnErr)
+		}
+	}
+
 	if workEndErr != nil {
 		if typed, ok := workEndErr.(*workEndError); ok {
 			h.writeJSONWithData(w, typed.Status, typed.Message, typed.Code, typed.Data)
@@ -306,6 +301,7 @@ func (h *Handler) ClientReport(w http.ResponseWriter, r *http.Request) {
 		LatestVersion:            nullString(settings.LatestVersion),
 		UpdateURL:                nullString(settings.UpdateUrl),
 		ServerTime:               formatTime(now),
+		Notice:                   notice,
 	})
 }
 
@@ -395,82 +391,81 @@ func ruleMatch(rule sqlc.ListEnabledRulesRow, processName string, windowTitle st
 	}
 }
 
-func (h *Handler) createSegmentAndStatsByContext(ctx context.Context, employeeID int64, start time.Time, end time.Time, status string, description string, source string) {
+func (h *Handler) createSegmentAndStatsByContext(ctx context.Context, db sqlc.DBTX, employeeID int64, start time.Time, end time.Time, status string, description string, source string) error {
 	if end.Before(start) || end.Equal(start) {
-		return
+		return nil
 	}
 
 	status = strings.TrimSpace(status)
 	source = strings.TrimSpace(source)
 	description = strings.TrimSpace(description)
+	q := sqlc.New(db)
 
-	if h.DB != nil {
-		var lastID int64
-		var lastEnd time.Time
-		var lastStatus string
-		var lastSource string
-		var lastDesc sql.NullString
+	var lastID int64
+	var lastEnd time.Time
+	var lastStatus string
+	var lastSource string
+	var lastDesc sql.NullString
 
-		err := h.DB.QueryRowContext(ctx, `SELECT id, end_at, status, description, source
+	err := db.QueryRowContext(ctx, `SELECT id, end_at, status, description, source
 FROM time_segments WHERE employee_id = ? ORDER BY end_at DESC, id DESC LIMIT 1`, employeeID).Scan(&lastID, &lastEnd, &lastStatus, &lastDesc, &lastSource)
-		if err == nil {
-			lastDescription := strings.TrimSpace(nullString(lastDesc))
-			if lastEnd.Equal(start) && lastStatus == status && lastSource == source && lastDescription == description {
-				if result, err := h.DB.ExecContext(ctx, "UPDATE time_segments SET end_at = ? WHERE id = ? AND end_at = ?", end, lastID, lastEnd); err == nil {
-					if rows, rowsErr := result.RowsAffected(); rowsErr == nil && rows > 0 {
-						h.addDailyStatsByRange(ctx, employeeID, status, start, end)
-						return
-					}
-				}
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil {
+		lastDescription := strings.TrimSpace(nullString(lastDesc))
+		if lastEnd.Equal(start) && lastStatus == status && lastSource == source && lastDescription == description {
+			result, err := db.ExecContext(ctx, "UPDATE time_segments SET end_at = ? WHERE id = ? AND end_at = ?", end, lastID, lastEnd)
+			if err != nil {
+				return err
+			}
+			if rows, rowsErr := result.RowsAffected(); rowsErr == nil && rows > 0 {
+				return h.addDailyStatsByRange(ctx, q, employeeID, status, start, end)
 			}
 		}
 	}
 
-	_ = h.Queries.CreateTimeSegment(ctx, sqlc.CreateTimeSegmentParams{
+	if err := q.CreateTimeSegment(ctx, sqlc.CreateTimeSegmentParams{
 		EmployeeID:  employeeID,
 		StartAt:     start,
 		EndAt:       end,
 		Status:      sqlc.TimeSegmentsStatus(status),
 		Description: toNullString(description),
 		Source:      sqlc.TimeSegmentsSource(source),
-	})
+	}); err != nil {
+		return err
+	}
 
-	h.addDailyStatsByRange(ctx, employeeID, status, start, end)
+	return h.addDailyStatsByRange(ctx, q, employeeID, status, start, end)
 }
 
-func (h *Handler) addDailyStatsByRange(ctx context.Context, employeeID int64, status string, start time.Time, end time.Time) {
-	for _, part := range splitByDay(start, end) {
+func (h *Handler) addDailyStatsByRange(ctx context.Context, q *sqlc.Queries, employeeID int64, status string, start time.Time, end time.Time) error {
+	return h.applyDailyStatsDelta(ctx, q, employeeID, status, start, end, 1)
+}
+
+func (h *Handler) applyDailyStatsDelta(ctx context.Context, q *sqlc.Queries, employeeID int64, status string, start time.Time, end time.Time, sign int32) error {
+	offset, err := h.employeeDayOffset(ctx, employeeID)
+	if err != nil {
+		return err
+	}
+	for _, part := range splitByBusinessDay(start, end, offset) {
 		increments := buildDailyStatIncrement(status, part.Seconds)
-		_ = h.Queries.AddDailyStats(ctx, sqlc.AddDailyStatsParams{
+		if err := q.AddDailyStats(ctx, sqlc.AddDailyStatsParams{
 			StatDate:          part.Date,
 			EmployeeID:        employeeID,
-			WorkSeconds:       increments.Work,
-			NormalSeconds:     increments.Normal,
-			FishSeconds:       increments.Fish,
-			IdleSeconds:       increments.Idle,
-			OfflineSeconds:    increments.Offline,
-			AttendanceSeconds: increments.Attendance,
-			EffectiveSeconds:  increments.Effective,
-		})
-	}
-}
-
-func (h *Handler) createSegmentAndStats(r *http.Request, employeeID int64, start time.Time, end time.Time, status string, description string, source string) {
-	h.createSegmentAndStatsByContext(r.Context(), employeeID, start, end, status, description, source)
-}
-
-func (h *Handler) createSegmentOnly(r *http.Request, employeeID int64, start time.Time, end time.Time, status string, description string, source string) {
-	if end.Before(start) || end.Equal(start) {
-		return
+			WorkSeconds:       increments.Work * sign,
+			NormalSeconds:     increments.Normal * sign,
+			FishSeconds:       increments.Fish * sign,
+			IdleSeconds:       increments.Idle * sign,
+			OfflineSeconds:    increments.Offline * sign,
+			AttendanceSeconds: increments.Attendance * sign,
+			EffectiveSeconds:  increments.Effective * sign,
+			LeaveSeconds:      increments.Leave * sign,
+		}); err != nil {
+			return err
+		}
 	}
-	_ = h.Queries.CreateTimeSegment(r.Context(), sqlc.CreateTimeSegmentParams{
-		EmployeeID:  employeeID,
-		StartAt:     start,
-		EndAt:       end,
-		Status:      sqlc.TimeSegmentsStatus(status),
-		Description: toNullString(description),
-		Source:      sqlc.TimeSegmentsSource(source),
-	})
+	return applyHourlyStatsDelta(ctx, q, employeeID, status, start, end, sign)
 }
 
 type dayPart struct {
@@ -508,6 +503,7 @@ type dailyIncrement struct {
 	Offline    int32
 	Attendance int32
 	Effective  int32
+	Leave      int32
 }
 
 func buildDailyStatIncrement(status string, seconds int64) dailyIncrement {
@@ -528,6 +524,11 @@ func buildDailyStatIncrement(status string, seconds int64) dailyIncrement {
 		inc.Idle = sec
 	case "offline":
 		inc.Offline = sec
+	case "leave":
+		inc.Leave = sec
+		inc.Attendance = sec
+	case "leave_unpaid":
+		inc.Leave = sec
 	case "incident":
 		inc.Offline = 0
 	}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"worksentry/internal/db/sqlc"
+)
+
+// authenticateClient 校验客户端令牌并返回对应员工，失败时已写入错误响应。
+func (h *Handler) authenticateClient(w http.ResponseWriter, r *http.Request) (sqlc.Employee, bool) {
+	token := readBearerToken(r)
+	if token == "" {
+		writeError(w, http.StatusUnauthorized, "缺少令牌")
+		return sqlc.Employee{}, false
+	}
+
+	clientToken, err := h.Queries.GetToken(r.Context(), token)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusUnauthorized, "令牌无效")
+		return sqlc.Employee{}, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "令牌校验失败")
+		return sqlc.Employee{}, false
+	}
+	if clientToken.Revoked {
+		writeError(w, http.StatusUnauthorized, "令牌已失效")
+		return sqlc.Employee{}, false
+	}
+	if clientToken.ExpiresAt.Valid && clientToken.ExpiresAt.Time.Before(time.Now()) {
+		writeError(w, http.StatusUnauthorized, "令牌已过期")
+		return sqlc.Employee{}, false
+	}
+
+	employee, err := h.Queries.GetEmployeeByID(r.Context(), clientToken.EmployeeID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "员工不存在")
+		return sqlc.Employee{}, false
+	}
+	if !employee.Enabled {
+		writeError(w, http.StatusForbidden, "员工已停用")
+		return sqlc.Employee{}, false
+	}
+	return employee, true
+}
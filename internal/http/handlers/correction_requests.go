@@ -0,0 +1,423 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"worksentry/internal/db/sqlc"
+)
+
+const (
+	correctionStatusPending  = "pending"
+	correctionStatusApproved = "approved"
+	correctionStatusRejected = "rejected"
+
+	correctionMaxAgeDays = 31
+)
+
+type ClientCorrectionPayload struct {
+	StartAt      string `json:"startAt"`
+	EndAt        string `json:"endAt"`
+	TargetStatus string `json:"targetStatus"`
+	Reason       string `json:"reason"`
+	Note         string `json:"note"`
+}
+
+type CorrectionReviewPayload struct {
+	ID      int64  `json:"id"`
+	Action  string `json:"action"`
+	Comment string `json:"comment"`
+	Mode    string `json:"mode"`
+}
+
+type CorrectionRequestView struct {
+	ID                int64  `json:"id"`
+	EmployeeCode      string `json:"employeeCode"`
+	Name              string `json:"name"`
+	Department        string `json:"department"`
+	StartAt           string `json:"startAt"`
+	EndAt             string `json:"endAt"`
+	Duration          string `json:"duration"`
+	TargetStatus      string `json:"targetStatus"`
+	TargetStatusLabel string `json:"targetStatusLabel"`
+	Reason            string `json:"reason"`
+	Note              string `json:"note"`
+	Status            string `json:"status"`
+	StatusLabel       string `json:"statusLabel"`
+	ReviewComment     string `json:"reviewComment"`
+	ReviewedAt        string `json:"reviewedAt"`
+	AdjustmentID      int64  `json:"adjustmentId"`
+	CreatedAt         string `json:"createdAt"`
+}
+
+type CorrectionRequestListResponse struct {
+	Total int64                   `json:"total"`
+	Items []CorrectionRequestView `json:"items"`
+}
+
+const correctionSelectSQL = `SELECT c.id, e.employee_code, e.name, COALESCE(d.name, ''), c.start_at, c.end_at, c.target_status,
+ c.reason, c.note, c.status, c.review_comment, c.reviewed_at, c.adjustment_id, c.created_at
+FROM correction_requests c
+JOIN employees e ON c.employee_id = e.id
+LEFT JOIN departments d ON e.department_id = d.id `
+
+func (h *Handler) ClientCorrectionRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	employee, ok := h.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+	if r.Method == http.MethodGet {
+		h.listClientCorrectionRequests(w, r, employee)
+		return
+	}
+	h.createClientCorrectionRequest(w, r, employee)
+}
+
+func (h *Handler) createClientCorrectionRequest(w http.ResponseWriter, r *http.Request, employee sqlc.Employee) {
+	var payload ClientCorrectionPayload
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "参数格式错误")
+		return
+	}
+	payload.Reason = strings.TrimSpace(payload.Reason)
+	payload.Note = strings.TrimSpace(payload.Note)
+	if payload.StartAt == "" || payload.EndAt == "" || payload.Reason == "" {
+		writeError(w, http.StatusBadRequest, "时间与原因不能为空")
+		return
+	}
+	if utf8.RuneCountInString(payload.Reason) > manualLabelMaxLen {
+		writeError(w, http.StatusBadRequest, "原因长度不能超过 64 个字符")
+		return
+	}
+	startAt, err := parseDateTime(payload.StartAt)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "开始时间格式错误")
+		return
+	}
+	endAt, err := parseDateTime(payload.EndAt)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "结束时间格式错误")
+		return
+	}
+	if !endAt.After(startAt) {
+		writeError(w, http.StatusBadRequest, "结束时间必须大于开始时间")
+		return
+	}
+	now := time.Now()
+	if endAt.After(now) {
+		writeError(w, http.StatusBadRequest, "结束时间不能晚于当前时间")
+		return
+	}
+	if startAt.Before(now.AddDate(0, 0, -correctionMaxAgeDays)) {
+		writeError(w, http.StatusBadRequest, "仅可申请 31 天内的更正")
+		return
+	}
+	targetStatus := strings.TrimSpace(payload.TargetStatus)
+	if targetStatus == "" {
+		targetStatus = "work"
+	}
+	if !isValidStatusCode(targetStatus) {
+		writeError(w, http.StatusBadRequest, "更正状态无效")
+		return
+	}
+
+	var pending int64
+	if err := h.DB.QueryRowContext(r.Context(), `SELECT COUNT(1) FROM correction_requests
+WHERE employee_id = ? AND status = 'pending' AND start_at < ? AND end_at > ?`, employee.ID, endAt, startAt).Scan(&pending); err != nil {
+		writeError(w, http.StatusInternalServerError, "提交申请失败")
+		return
+	}
+	if pending > 0 {
+		writeError(w, http.StatusConflict, "该时间段已有待审核的申请")
+		return
+	}
+
+	result, err := h.DB.ExecContext(r.Context(), `INSERT INTO correction_requests (employee_id, start_at, end_at, target_status, reason, note, status)
+VALUES (?, ?, ?, ?, ?, ?, 'pending')`, employee.ID, startAt, endAt, targetStatus, payload.Reason, payload.Note)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "提交申请失败")
+		return
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "提交申请失败")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"id": id, "status": correctionStatusPending})
+}
+
+func (h *Handler) listClientCorrectionRequests(w http.ResponseWriter, r *http.Request, employee sqlc.Employee) {
+	where := "WHERE c.employee_id = ?"
+	args := []any{employee.ID}
+	if status := strings.TrimSpace(r.URL.Query().Get("status")); status != "" {
+		where += " AND c.status = ?"
+		args = append(args, status)
+	}
+	items, err := h.queryCorrectionRequests(r.Context(), where+" ORDER BY c.created_at DESC LIMIT 50", args...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取申请失败")
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (h *Handler) CorrectionRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+
+	status := strings.TrimSpace(r.URL.Query().Get("status"))
+	departmentID := parseInt64(r.URL.Query().Get("departmentId"))
+	keyword := strings.TrimSpace(r.URL.Query().Get("keyword"))
+	page := parseInt(r.URL.Query().Get("page"), 1)
+	pageSize := parseInt(r.URL.Query().Get("pageSize"), 20)
+	if pageSize <= 0 || pageSize > 200 {
+		pageSize = 20
+	}
+	if page <= 0 {
+		page = 1
+	}
+
+	where := "WHERE 1 = 1"
+	args := []any{}
+	if status != "" {
+		where += " AND c.status = ?"
+		args = append(args, status)
+	}
+	if startValue := r.URL.Query().Get("startDate"); startValue != "" {
+		startDate, err := parseDate(startValue)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "开始日期格式错误")
+			return
+		}
+		where += " AND c.start_at >= ?"
+		args = append(args, startDate)
+	}
+	if endValue := r.URL.Query().Get("endDate"); endValue != "" {
+		endDate, err := parseDate(endValue)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "结束日期格式错误")
+			return
+		}
+		where += " AND c.start_at < ?"
+		args = append(args, endDate.AddDate(0, 0, 1))
+	}
+	clause, clauseArgs, err := h.departmentFilter(r.Context(), "e.department_id", departmentID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取部门失败")
+		return
+	}
+	where += clause
+	args = append(args, clauseArgs...)
+	if keyword != "" {
+		where += " AND (e.employee_code LIKE ? OR e.name LIKE ?)"
+		like := "%" + keyword + "%"
+		args = append(args, like, like)
+	}
+
+	var total int64
+	countSQL := "SELECT COUNT(1) FROM correction_requests c JOIN employees e ON c.employee_id = e.id " + where
+	if err := h.DB.QueryRowContext(r.Context(), countSQL, args...).Scan(&total); err != nil {
+		writeError(w, http.StatusInternalServerError, "读取申请失败")
+		return
+	}
+
+	args = append(args, pageSize, (page-1)*pageSize)
+	items, err := h.queryCorrectionRequests(r.Context(), where+" ORDER BY c.status = 'pending' DESC, c.created_at DESC LIMIT ? OFFSET ?", args...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取申请失败")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, CorrectionRequestListResponse{
+		Total: total,
+		Items: items,
+	})
+}
+
+func (h *Handler) CorrectionRequestReview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+
+	var payload CorrectionReviewPayload
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "参数格式错误")
+		return
+	}
+	payload.Action = strings.TrimSpace(payload.Action)
+	payload.Comment = strings.TrimSpace(payload.Comment)
+	if payload.ID <= 0 {
+		writeError(w, http.StatusBadRequest, "申请编号无效")
+		return
+	}
+	if payload.Action != "approve" && payload.Action != "reject" {
+		writeError(w, http.StatusBadRequest, "审核操作无效")
+		return
+	}
+	if payload.Action == "reject" && payload.Comment == "" {
+		writeError(w, http.StatusBadRequest, "驳回时请填写审核意见")
+		return
+	}
+	mode := strings.TrimSpace(payload.Mode)
+	if mode == "" {
+		mode = manualModeFill
+	}
+	if mode != manualModeFill && mode != manualModeOverwrite {
+		writeError(w, http.StatusBadRequest, "补录方式无效")
+		return
+	}
+
+	var employeeID int64
+	if err := h.DB.QueryRowContext(r.Context(), "SELECT employee_id FROM correction_requests WHERE id = ?", payload.ID).Scan(&employeeID); err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "申请不存在")
+		} else {
+			writeError(w, http.StatusInternalServerError, "读取申请失败")
+		}
+		return
+	}
+
+	operatorID := adminIDFromRequest(r)
+	var adjustmentID int64
+	err := h.withEmployeeTx(r.Context(), employeeID, func(qtx *sqlc.Queries, tx *sql.Tx, locked sqlc.Employee) error {
+		var (
+			startAt      time.Time
+			endAt        time.Time
+			targetStatus string
+			reason       string
+			note         string
+			status       string
+		)
+		if err := tx.QueryRowContext(r.Context(), `SELECT start_at, end_at, target_status, reason, note, status
+FROM correction_requests WHERE id = ? FOR UPDATE`, payload.ID).Scan(&startAt, &endAt, &targetStatus, &reason, &note, &status); err != nil {
+			return err
+		}
+		if status != correctionStatusPending {
+			return &manualRangeError{Message: "申请已审核"}
+		}
+
+		newStatus := correctionStatusRejected
+		if payload.Action == "approve" {
+			newStatus = correctionStatusApproved
+			adjustmentNote := note
+			if adjustmentNote == "" {
+				adjustmentNote = reason
+			}
+			var err error
+			adjustmentID, err = h.createManualAdjustmentTx(r.Context(), tx, qtx, locked, manualAdjustmentInput{
+				StartAt:      startAt,
+				EndAt:        endAt,
+				TargetStatus: targetStatus,
+				Label:        reason,
+				Mode:         mode,
+			}, reason, adjustmentNote, operatorID)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.ExecContext(r.Context(), `UPDATE correction_requests
+SET status = ?, reviewer_id = ?, review_comment = ?, reviewed_at = ?, adjustment_id = ?
+WHERE id = ?`, newStatus, operatorID, toNullText(payload.Comment), time.Now(), nullIfZeroID(adjustmentID), payload.ID)
+		return err
+	})
+	if err != nil {
+		var rangeErr *manualRangeError
+		if errors.As(err, &rangeErr) {
+			writeError(w, http.StatusBadRequest, rangeErr.Message)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "审核失败")
+		return
+	}
+
+	action := "reject_correction_request"
+	if payload.Action == "approve" {
+		action = "approve_correction_request"
+	}
+	h.logAudit(r, action, "correction_request", sql.NullInt64{Int64: payload.ID, Valid: true}, payload)
+	writeJSON(w, http.StatusOK, map[string]any{"message": "审核完成", "adjustmentId": adjustmentID})
+}
+
+func (h *Handler) queryCorrectionRequests(ctx context.Context, where string, args ...any) ([]CorrectionRequestView, error) {
+	rows, err := h.DB.QueryContext(ctx, correctionSelectSQL+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]CorrectionRequestView, 0)
+	for rows.Next() {
+		var (
+			item          CorrectionRequestView
+			startAt       time.Time
+			endAt         time.Time
+			reviewComment sql.NullString
+			reviewedAt    sql.NullTime
+			adjustmentID  sql.NullInt64
+			createdAt     time.Time
+		)
+		if err := rows.Scan(&item.ID, &item.EmployeeCode, &item.Name, &item.Department, &startAt, &endAt, &item.TargetStatus,
+			&item.Reason, &item.Note, &item.Status, &reviewComment, &reviewedAt, &adjustmentID, &createdAt); err != nil {
+			return nil, err
+		}
+		item.StartAt = formatTime(startAt)
+		item.EndAt = formatTime(endAt)
+		item.Duration = formatDuration(int64(endAt.Sub(startAt).Seconds()))
+		item.TargetStatusLabel = statusLabel(item.TargetStatus)
+		item.StatusLabel = correctionStatusLabel(item.Status)
+		item.ReviewComment = nullString(reviewComment)
+		if reviewedAt.Valid {
+			item.ReviewedAt = formatTime(reviewedAt.Time)
+		}
+		item.AdjustmentID = adjustmentID.Int64
+		item.CreatedAt = formatTime(createdAt)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func correctionStatusLabel(status string) string {
+	switch status {
+	case correctionStatusPending:
+		return "待审核"
+	case correctionStatusApproved:
+		return "已通过"
+	case correctionStatusRejected:
+		return "已驳回"
+	default:
+		return "未知"
+	}
+}
+
+func nullIfZeroID(value int64) interface{} {
+	if value <= 0 {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,466 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"worksentry/internal/db/sqlc"
+)
+
+const reconcileMaxDays = 92
+
+type DailyStatsValues struct {
+	WorkSeconds       int64 `json:"workSeconds"`
+	NormalSeconds     int64 `json:"normalSeconds"`
+	FishSeconds       int64 `json:"fishSeconds"`
+	IdleSeconds       int64 `json:"idleSeconds"`
+	OfflineSeconds    int64 `json:"offlineSeconds"`
+	AttendanceSeconds int64 `json:"attendanceSeconds"`
+	EffectiveSeconds  int64 `json:"effectiveSeconds"`
+	LeaveSeconds      int64 `json:"leaveSeconds"`
+}
+
+// activeSeconds 实际在岗时长，即在岗时长扣除计入出勤的请假。
+// leave_seconds 还包含不计出勤的请假，不能直接相减，这里按工作、正常与摸鱼求和。
+func (v DailyStatsValues) activeSeconds() int64 {
+	return v.WorkSeconds + v.NormalSeconds + v.FishSeconds
+}
+
+// activeRatio 摸鱼、空闲等占比统一以实际在岗时长为分母，避免请假把占比摊薄。
+func (v DailyStatsValues) activeRatio(seconds int64) float64 {
+	active := v.activeSeconds()
+	if active <= 0 {
+		return 0
+	}
+	return float64(seconds) / float64(active)
+}
+
+// idleRatio 空闲占比，分母为实际在岗时长加空闲时长，结果不会超过 1。
+func (v DailyStatsValues) idleRatio() float64 {
+	total := v.activeSeconds() + v.IdleSeconds
+	if total <= 0 {
+		return 0
+	}
+	return float64(v.IdleSeconds) / float64(total)
+}
+
+type DailyStatsDriftItem struct {
+	StatDate     string           `json:"statDate"`
+	EmployeeCode string           `json:"employeeCode"`
+	Name         string           `json:"name"`
+	Expected     DailyStatsValues `json:"expected"`
+	Actual       DailyStatsValues `json:"actual"`
+	Fields       []string         `json:"fields"`
+	Repaired     bool             `json:"repaired"`
+}
+
+type DailyStatsReconcileResponse struct {
+	StartDate  string                `json:"startDate"`
+	EndDate    string                `json:"endDate"`
+	Checked    int                   `json:"checked"`
+	Mismatched int                   `json:"mismatched"`
+	Repaired   int                   `json:"repaired"`
+	Items      []DailyStatsDriftItem `json:"items"`
+}
+
+type DailyStatsReconcilePayload struct {
+	StartDate    string `json:"startDate"`
+	EndDate      string `json:"endDate"`
+	EmployeeCode string `json:"employeeCode"`
+	Repair       bool   `json:"repair"`
+}
+
+type dailyStatsKey struct {
+	Date       string
+	EmployeeID int64
+}
+
+func (h *Handler) DailyStatsReconcile(w http.ResponseWriter, r *http.Request) {
+	var payload DailyStatsReconcilePayload
+	switch r.Method {
+	case http.MethodGet:
+		payload = DailyStatsReconcilePayload{
+			StartDate:    strings.TrimSpace(r.URL.Query().Get("startDate")),
+			EndDate:      strings.TrimSpace(r.URL.Query().Get("endDate")),
+			EmployeeCode: strings.TrimSpace(r.URL.Query().Get("employeeCode")),
+		}
+	case http.MethodPost:
+		if err := decodeJSON(r, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, "参数格式错误")
+			return
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+
+	if payload.StartDate == "" {
+		payload.StartDate = time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	}
+	if payload.EndDate == "" {
+		payload.EndDate = payload.StartDate
+	}
+	startDate, err := parseDate(payload.StartDate)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "开始日期格式错误")
+		return
+	}
+	endDate, err := parseDate(payload.EndDate)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "结束日期格式错误")
+		return
+	}
+	if endDate.Before(startDate) {
+		writeError(w, http.StatusBadRequest, "结束日期不能早于开始日期")
+		return
+	}
+	if endDate.Sub(startDate) > time.Duration(reconcileMaxDays)*24*time.Hour {
+		writeError(w, http.StatusBadRequest, "日期范围不能超过 92 天")
+		return
+	}
+
+	employeeID := int64(0)
+	if code := strings.TrimSpace(payload.EmployeeCode); code != "" {
+		employee, err := h.Queries.GetEmployeeByCode(r.Context(), code)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "员工不存在")
+			return
+		}
+		employeeID = employee.ID
+	}
+
+	result, err := h.reconcileDailyStats(r.Context(), startDate, endDate.AddDate(0, 0, 1), employeeID, payload.Repair)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "核对统计失败")
+		return
+	}
+
+	if payload.Repair {
+		h.logAudit(r, "repair_daily_stats", "daily_stats", sql.NullInt64{}, map[string]any{
+			"startDate":    result.StartDate,
+			"endDate":      result.EndDate,
+			"employeeCode": payload.EmployeeCode,
+			"mismatched":   result.Mismatched,
+			"repaired":     result.Repaired,
+		})
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h *Handler) reconcileDailyStats(ctx context.Context, start time.Time, end time.Time, employeeID int64, repair bool) (DailyStatsReconcileResponse, error) {
+	result := DailyStatsReconcileResponse{
+		StartDate: start.Format("2006-01-02"),
+		EndDate:   end.AddDate(0, 0, -1).Format("2006-01-02"),
+		Items:     []DailyStatsDriftItem{},
+	}
+
+	expected, err := computeDailyStatsFromSegments(ctx, h.DB, start, end, employeeID)
+	if err != nil {
+		return result, err
+	}
+	actual, err := loadDailyStatsRange(ctx, h.DB, start, end, employeeID)
+	if err != nil {
+		return result, err
+	}
+	offsetChanges, err := loadDayOffsetChanges(ctx, h.DB)
+	if err != nil {
+		return result, err
+	}
+	skipBeforeOffsetChange(expected, offsetChanges)
+	skipBeforeOffsetChange(actual, offsetChanges)
+
+	keys := make(map[dailyStatsKey]struct{}, len(expected)+len(actual))
+	for key := range expected {
+		keys[key] = struct{}{}
+	}
+	for key := range actual {
+		keys[key] = struct{}{}
+	}
+	result.Checked = len(keys)
+
+	drifted := map[int64]bool{}
+	itemEmployeeIDs := []int64{}
+	for key := range keys {
+		fields := diffDailyStats(expected[key], actual[key])
+		if len(fields) == 0 {
+			continue
+		}
+		drifted[key.EmployeeID] = true
+		itemEmployeeIDs = append(itemEmployeeIDs, key.EmployeeID)
+		result.Items = append(result.Items, DailyStatsDriftItem{
+			StatDate: key.Date,
+			Expected: expected[key],
+			Actual:   actual[key],
+			Fields:   fields,
+		})
+	}
+	result.Mismatched = len(result.Items)
+
+	repaired := map[int64]bool{}
+	if repair {
+		for id := range drifted {
+			if err := h.repairEmployeeDailyStats(ctx, id, start, end); err != nil {
+				log.Printf("修复日统计失败: employee=%d %v", id, err)
+				continue
+			}
+			repaired[id] = true
+		}
+	}
+
+	names, err := loadEmployeeNames(ctx, h.DB)
+	if err != nil {
+		return result, err
+	}
+	for i := range result.Items {
+		id := itemEmployeeIDs[i]
+		result.Items[i].EmployeeCode = names[id].Code
+		result.Items[i].Name = names[id].Name
+		if repaired[id] {
+			result.Items[i].Repaired = true
+			result.Repaired++
+		}
+	}
+
+	sort.Slice(result.Items, func(i, j int) bool {
+		if result.Items[i].StatDate != result.Items[j].StatDate {
+			return result.Items[i].StatDate < result.Items[j].StatDate
+		}
+		return result.Items[i].EmployeeCode < result.Items[j].EmployeeCode
+	})
+	return result, nil
+}
+
+// repairEmployeeDailyStats 在员工锁内重新计算并覆盖写入，避免与并发上报的增量互相覆盖。
+func (h *Handler) repairEmployeeDailyStats(ctx context.Context, employeeID int64, start time.Time, end time.Time) error {
+	return h.withEmployeeTx(ctx, employeeID, func(qtx *sqlc.Queries, tx *sql.Tx, locked sqlc.Employee) error {
+		expected, err := computeDailyStatsFromSegments(ctx, tx, start, end, employeeID)
+		if err != nil {
+			return err
+		}
+		actual, err := loadDailyStatsRange(ctx, tx, start, end, employeeID)
+		if err != nil {
+			return err
+		}
+		offsetChanges, err := loadDayOffsetChanges(ctx, h.DB)
+		if err != nil {
+			return err
+		}
+		skipBeforeOffsetChange(expected, offsetChanges)
+		skipBeforeOffsetChange(actual, offsetChanges)
+		for key := range actual {
+			if _, ok := expected[key]; !ok {
+				expected[key] = DailyStatsValues{}
+			}
+		}
+		for key, values := range expected {
+			if len(diffDailyStats(values, actual[key])) == 0 {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, `INSERT INTO daily_stats (stat_date, employee_id, work_seconds, normal_seconds, fish_seconds, idle_seconds, offline_seconds, attendance_seconds, effective_seconds, leave_seconds)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE work_seconds = VALUES(work_seconds), normal_seconds = VALUES(normal_seconds), fish_seconds = VALUES(fish_seconds),
+ idle_seconds = VALUES(idle_seconds), offline_seconds = VALUES(offline_seconds), attendance_seconds = VALUES(attendance_seconds), effective_seconds = VALUES(effective_seconds),
+ leave_seconds = VALUES(leave_seconds)`,
+				key.Date, key.EmployeeID, values.WorkSeconds, values.NormalSeconds, values.FishSeconds, values.IdleSeconds, values.OfflineSeconds, values.AttendanceSeconds, values.EffectiveSeconds, values.LeaveSeconds); err != nil {
+				return err
+			}
+		}
+		// 日统计出现偏差时小时统计大概率同样失真，一并按时间段重算（含跨零点班次的次日部分）
+		return rebuildHourlyStatsTx(ctx, tx, qtx, employeeID, start, end.Add(24*time.Hour))
+	})
+}
+
+// computeDailyStatsFromSegments 按 time_segments 重新汇总日统计，口径与增量写入保持一致：
+// 旧版补录段叠加在离线段之上，计入自身状态的同时需抵扣其覆盖的离线时长。
+// 跨零点班次的业务日整体后移，因此按员工班次偏移裁剪区间。
+func computeDailyStatsFromSegments(ctx context.Context, db sqlc.DBTX, start time.Time, end time.Time, employeeID int64) (map[dailyStatsKey]DailyStatsValues, error) {
+	offsets, err := loadEmployeeDayOffsets(ctx, sqlc.New(db))
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT employee_id, start_at, end_at, status, source, adjustment_id IS NULL
+FROM time_segments
+WHERE start_at < ? AND end_at > ?`
+	args := []any{end.Add(24 * time.Hour), start}
+	if employeeID > 0 {
+		query += " AND employee_id = ?"
+		args = append(args, employeeID)
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := map[dailyStatsKey]DailyStatsValues{}
+	for rows.Next() {
+		var id int64
+		var segStart time.Time
+		var segEnd time.Time
+		var status string
+		var source string
+		var overlay bool
+		if err := rows.Scan(&id, &segStart, &segEnd, &status, &source, &overlay); err != nil {
+			return nil, err
+		}
+		offset := offsets[id]
+		segStart = maxTime(segStart, start.Add(offset))
+		segEnd = minTime(segEnd, end.Add(offset))
+		if !segEnd.After(segStart) {
+			continue
+		}
+		for _, part := range splitByBusinessDay(segStart, segEnd, offset) {
+			key := dailyStatsKey{Date: part.Date.Format("2006-01-02"), EmployeeID: id}
+			values := totals[key]
+			inc := buildDailyStatIncrement(status, part.Seconds)
+			values.WorkSeconds += int64(inc.Work)
+			values.NormalSeconds += int64(inc.Normal)
+			values.FishSeconds += int64(inc.Fish)
+			values.IdleSeconds += int64(inc.Idle)
+			values.OfflineSeconds += int64(inc.Offline)
+			values.AttendanceSeconds += int64(inc.Attendance)
+			values.EffectiveSeconds += int64(inc.Effective)
+			values.LeaveSeconds += int64(inc.Leave)
+			if source == "manual" && overlay {
+				values.OfflineSeconds -= part.Seconds
+			}
+			totals[key] = values
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for key, values := range totals {
+		if values.OfflineSeconds < 0 {
+			values.OfflineSeconds = 0
+		}
+		totals[key] = values
+	}
+	return totals, nil
+}
+
+func loadDailyStatsRange(ctx context.Context, db sqlc.DBTX, start time.Time, end time.Time, employeeID int64) (map[dailyStatsKey]DailyStatsValues, error) {
+	query := `SELECT stat_date, employee_id, work_seconds, normal_seconds, fish_seconds, idle_seconds, offline_seconds, attendance_seconds, effective_seconds, leave_seconds
+FROM daily_stats
+WHERE stat_date >= ? AND stat_date < ?`
+	args := []any{start.Format("2006-01-02"), end.Format("2006-01-02")}
+	if employeeID > 0 {
+		query += " AND employee_id = ?"
+		args = append(args, employeeID)
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := map[dailyStatsKey]DailyStatsValues{}
+	for rows.Next() {
+		var statDate time.Time
+		var id int64
+		var values DailyStatsValues
+		if err := rows.Scan(&statDate, &id, &values.WorkSeconds, &values.NormalSeconds, &values.FishSeconds, &values.IdleSeconds, &values.OfflineSeconds, &values.AttendanceSeconds, &values.EffectiveSeconds, &values.LeaveSeconds); err != nil {
+			return nil, err
+		}
+		stats[dailyStatsKey{Date: statDate.Format("2006-01-02"), EmployeeID: id}] = values
+	}
+	return stats, rows.Err()
+}
+
+func diffDailyStats(expected DailyStatsValues, actual DailyStatsValues) []string {
+	var fields []string
+	if expected.WorkSeconds != actual.WorkSeconds {
+		fields = append(fields, "work")
+	}
+	if expected.NormalSeconds != actual.NormalSeconds {
+		fields = append(fields, "normal")
+	}
+	if expected.FishSeconds != actual.FishSeconds {
+		fields = append(fields, "fish")
+	}
+	if expected.IdleSeconds != actual.IdleSeconds {
+		fields = append(fields, "idle")
+	}
+	if expected.OfflineSeconds != actual.OfflineSeconds {
+		fields = append(fields, "offline")
+	}
+	if expected.AttendanceSeconds != actual.AttendanceSeconds {
+		fields = append(fields, "attendance")
+	}
+	if expected.EffectiveSeconds != actual.EffectiveSeconds {
+		fields = append(fields, "effective")
+	}
+	if expected.LeaveSeconds != actual.LeaveSeconds {
+		fields = append(fields, "leave")
+	}
+	return fields
+}
+
+type employeeName struct {
+	Code string
+	Name string
+}
+
+func loadEmployeeNames(ctx context.Context, db sqlc.DBTX) (map[int64]employeeName, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, employee_code, name FROM employees")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names := map[int64]employeeName{}
+	for rows.Next() {
+		var id int64
+		var item employeeName
+		if err := rows.Scan(&id, &item.Code, &item.Name); err != nil {
+			return nil, err
+		}
+		names[id] = item
+	}
+	return names, rows.Err()
+}
+
+func (h *Handler) dailyStatsReconcileLoop(ctx context.Context) {
+	for {
+		now := time.Now()
+		next := time.Date(now.Year(), now.Month(), now.Day(), 0, 30, 0, 0, now.Location())
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			h.reconcilePreviousDay(ctx)
+		}
+	}
+}
+
+func (h *Handler) reconcilePreviousDay(ctx context.Context) {
+	if h.DB == nil {
+		return
+	}
+	today := time.Now()
+	end := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location())
+	// 跨零点班次的前一业务日在凌晨尚未结束，多核对一天以覆盖其完整区间。
+	start := end.AddDate(0, 0, -2)
+	result, err := h.reconcileDailyStats(ctx, start, end, 0, true)
+	if err != nil {
+		log.Printf("日统计核对失败: %v", err)
+		return
+	}
+	if result.Mismatched > 0 {
+		log.Printf("日统计核对 %s: 差异 %d 条，已修复 %d 条", result.StartDate, result.Mismatched, result.Repaired)
+	}
+}
@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"worksentry/internal/db/sqlc"
+)
+
+// unassignedDepartmentName 未分配部门的员工在小计中单独成组。
+const unassignedDepartmentName = "未分配部门"
+
+type departmentTree struct {
+	names    map[int64]string
+	parents  map[int64]int64
+	children map[int64][]int64
+}
+
+func loadDepartmentTree(ctx context.Context, q *sqlc.Queries) (*departmentTree, error) {
+	items, err := q.ListDepartments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tree := &departmentTree{
+		names:    make(map[int64]string, len(items)),
+		parents:  make(map[int64]int64, len(items)),
+		children: map[int64][]int64{},
+	}
+	for _, item := range items {
+		tree.names[item.ID] = item.Name
+		if item.ParentID.Valid {
+			tree.parents[item.ID] = item.ParentID.Int64
+		}
+	}
+	for id, parentID := range tree.parents {
+		// 上级部门已删除时按根部门处理
+		if _, ok := tree.names[parentID]; !ok {
+			delete(tree.parents, id)
+			continue
+		}
+		tree.children[parentID] = append(tree.children[parentID], id)
+	}
+	for parentID := range tree.children {
+		sort.Slice(tree.children[parentID], func(i, j int) bool { return tree.children[parentID][i] < tree.children[parentID][j] })
+	}
+	return tree, nil
+}
+
+// subtree 返回部门及其全部下级部门，历史数据中存在环时也能结束。
+func (t *departmentTree) subtree(id int64) []int64 {
+	ids := []int64{id}
+	visited := map[int64]bool{id: true}
+	for i := 0; i < len(ids); i++ {
+		for _, child := range t.children[ids[i]] {
+			if visited[child] {
+				continue
+			}
+			visited[child] = true
+			ids = append(ids, child)
+		}
+	}
+	return ids
+}
+
+// ancestors 返回部门自身及逐级上级部门。
+func (t *departmentTree) ancestors(id int64) []int64 {
+	ids := []int64{id}
+	visited := map[int64]bool{id: true}
+	for {
+		parentID, ok := t.parents[ids[len(ids)-1]]
+		if !ok || visited[parentID] {
+			return ids
+		}
+		visited[parentID] = true
+		ids = append(ids, parentID)
+	}
+}
+
+func (t *departmentTree) roots() []int64 {
+	ids := make([]int64, 0)
+	for id := range t.names {
+		if _, ok := t.parents[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (t *departmentTree) name(id int64) string {
+	if id <= 0 {
+		return unassignedDepartmentName
+	}
+	return t.names[id]
+}
+
+// departmentScope 返回筛选部门及其下级部门，departmentID 为 0 时不限部门。
+func (h *Handler) departmentScope(ctx context.Context, departmentID int64) ([]int64, error) {
+	if departmentID <= 0 {
+		return nil, nil
+	}
+	tree, err := loadDepartmentTree(ctx, h.Queries)
+	if err != nil {
+		return nil, err
+	}
+	return tree.subtree(departmentID), nil
+}
+
+// departmentFilter 生成含下级部门的筛选条件，departmentID 为 0 时返回空条件。
+func (h *Handler) departmentFilter(ctx context.Context, column string, departmentID int64) (string, []any, error) {
+	ids, err := h.departmentScope(ctx, departmentID)
+	if err != nil || len(ids) == 0 {
+		return "", nil, err
+	}
+	clause, args := departmentInClause(column, ids)
+	return " AND " + clause, args, nil
+}
+
+func departmentInClause(column string, ids []int64) (string, []any) {
+	args := make([]any, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	return column + " IN (" + strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",") + ")", args
+}
+
+// dailyStatsParams 构造按日报表查询参数，部门筛选包含下级部门。
+func dailyStatsParams(date time.Time, departmentID int64, tree *departmentTree) sqlc.ListDailyStatsByDateParams {
+	params := sqlc.ListDailyStatsByDateParams{StatDate: date, Column2: departmentID}
+	if departmentID > 0 {
+		for _, id := range tree.subtree(departmentID) {
+			params.DepartmentIds = append(params.DepartmentIds, toNullInt64(id))
+		}
+	}
+	return params
+}
+
+func dailyStatsMembers(rows []sqlc.ListDailyStatsByDateRow) []departmentMember {
+	members := make([]departmentMember, 0, len(rows))
+	for _, row := range rows {
+		members = append(members, departmentMember{
+			DepartmentID: row.DepartmentID.Int64,
+			Values: DailyStatsValues{
+				WorkSeconds:       int64(row.WorkSeconds),
+				NormalSeconds:     int64(row.NormalSeconds),
+				FishSeconds:       int64(row.FishSeconds),
+				IdleSeconds:       int64(row.IdleSeconds),
+				OfflineSeconds:    int64(row.OfflineSeconds),
+				AttendanceSeconds: int64(row.AttendanceSeconds),
+				EffectiveSeconds:  int64(row.EffectiveSeconds),
+				LeaveSeconds:      int64(row.LeaveSeconds),
+			},
+		})
+	}
+	return members
+}
+
+type departmentSubtotal struct {
+	DepartmentID   int64
+	ParentID       int64
+	Department     string
+	Depth          int
+	EmployeeCount  int
+	Workdays       int
+	AttendanceDays int
+	Values         DailyStatsValues
+	BreakSeconds   int64
+	Violations     int64
+}
+
+// departmentMember 中的出勤天数、休息时长与违规数仅在对比和排行时填充。
+type departmentMember struct {
+	DepartmentID   int64
+	Workdays       int
+	AttendanceDays int
+	Values         DailyStatsValues
+	BreakSeconds   int64
+	Violations     int64
+}
+
+// rollupDepartments 将员工数据逐级累加到所属部门及上级部门，按树的先序返回有数据的部门小计。
+// rootID 大于 0 时只统计该部门子树，上级部门不再出现在结果中。
+func (t *departmentTree) rollupDepartments(rootID int64, members []departmentMember) []departmentSubtotal {
+	scope := map[int64]bool{}
+	if rootID > 0 {
+		for _, id := range t.subtree(rootID) {
+			scope[id] = true
+		}
+	}
+	totals := map[int64]*departmentSubtotal{}
+	for _, member := range members {
+		ids := []int64{0}
+		if member.DepartmentID > 0 {
+			ids = t.ancestors(member.DepartmentID)
+		}
+		for _, id := range ids {
+			if rootID > 0 && !scope[id] {
+				continue
+			}
+			total, ok := totals[id]
+			if !ok {
+				total = &departmentSubtotal{DepartmentID: id, Department: t.name(id)}
+				totals[id] = total
+			}
+			total.EmployeeCount++
+			total.Workdays += member.Workdays
+			total.AttendanceDays += member.AttendanceDays
+			addDailyStatsValues(&total.Values, member.Values)
+			total.BreakSeconds += member.BreakSeconds
+			total.Violations += member.Violations
+		}
+	}
+
+	result := make([]departmentSubtotal, 0, len(totals))
+	visited := map[int64]bool{}
+	var walk func(id int64, parentID int64, depth int)
+	walk = func(id int64, parentID int64, depth int) {
+		if visited[id] {
+			return
+		}
+		visited[id] = true
+		total, ok := totals[id]
+		if !ok {
+			return
+		}
+		total.ParentID = parentID
+		total.Depth = depth
+		result = append(result, *total)
+		for _, child := range t.children[id] {
+			walk(child, id, depth+1)
+		}
+	}
+	roots := []int64{rootID}
+	if rootID <= 0 {
+		roots = t.roots()
+	}
+	for _, id := range roots {
+		walk(id, 0, 0)
+	}
+	// 部门已删除但员工仍引用时，不在树中，按根部门追加
+	orphans := make([]int64, 0)
+	for id := range totals {
+		if _, ok := t.names[id]; id > 0 && !ok {
+			orphans = append(orphans, id)
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i] < orphans[j] })
+	for _, id := range orphans {
+		result = append(result, *totals[id])
+	}
+	if total, ok := totals[0]; ok && rootID <= 0 {
+		result = append(result, *total)
+	}
+	return result
+}
+
+type DepartmentSubtotalView struct {
+	DepartmentID          int64  `json:"departmentId"`
+	ParentID              int64  `json:"parentId"`
+	Department            string `json:"department"`
+	Depth                 int    `json:"depth"`
+	EmployeeCount         int    `json:"employeeCount"`
+	WorkDuration          string `json:"workDuration"`
+	NormalDuration        string `json:"normalDuration"`
+	FishDuration          string `json:"fishDuration"`
+	IdleDuration          string `json:"idleDuration"`
+	OfflineDuration       string `json:"offlineDuration"`
+	AttendanceDuration    string `json:"attendanceDuration"`
+	EffectiveDuration     string `json:"effectiveDuration"`
+	LeaveDuration         string `json:"leaveDuration"`
+	AvgAttendanceDuration string `json:"avgAttendanceDuration,omitempty"`
+	AvgEffectiveDuration  string `json:"avgEffectiveDuration,omitempty"`
+	FishRatio             string `json:"fishRatio"`
+}
+
+// view 日均值按成员工作日之和（人·天）计算。
+func (s departmentSubtotal) view() DepartmentSubtotalView {
+	fishRatio := s.Values.activeRatio(s.Values.FishSeconds)
+	item := DepartmentSubtotalView{
+		DepartmentID:       s.DepartmentID,
+		ParentID:           s.ParentID,
+		Department:         s.Department,
+		Depth:              s.Depth,
+		EmployeeCount:      s.EmployeeCount,
+		WorkDuration:       formatDuration(s.Values.WorkSeconds),
+		NormalDuration:     formatDuration(s.Values.NormalSeconds),
+		FishDuration:       formatDuration(s.Values.FishSeconds),
+		IdleDuration:       formatDuration(s.Values.IdleSeconds),
+		OfflineDuration:    formatDuration(s.Values.OfflineSeconds),
+		AttendanceDuration: formatDuration(s.Values.AttendanceSeconds),
+		EffectiveDuration:  formatDuration(s.Values.EffectiveSeconds),
+		LeaveDuration:      formatDuration(s.Values.LeaveSeconds),
+		FishRatio:          formatPercent(fishRatio),
+	}
+	if s.Workdays > 0 {
+		item.AvgAttendanceDuration = formatDuration(s.Values.AttendanceSeconds / int64(s.Workdays))
+		item.AvgEffectiveDuration = formatDuration(s.Values.EffectiveSeconds / int64(s.Workdays))
+	}
+	return item
+}
+
+func departmentSubtotalViews(items []departmentSubtotal) []DepartmentSubtotalView {
+	views := make([]DepartmentSubtotalView, 0, len(items))
+	for _, item := range items {
+		views = append(views, item.view())
+	}
+	return views
+}
+
+// subtotalLabel 导出时按层级缩进部门名称。
+func (s departmentSubtotal) subtotalLabel() string {
+	return strings.Repeat("  ", s.Depth) + s.Department
+}
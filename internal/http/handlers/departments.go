@@ -111,6 +111,20 @@ func (h *Handler) updateDepartment(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "上级部门不能是自己")
 		return
 	}
+	if payload.ParentID > 0 {
+		tree, err := loadDepartmentTree(r.Context(), h.Queries)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "读取部门失败")
+			return
+		}
+		// 报表按部门树逐级汇总，不允许形成环
+		for _, id := range tree.subtree(payload.ID) {
+			if id == payload.ParentID {
+				writeError(w, http.StatusBadRequest, "上级部门不能是自己的下级部门")
+				return
+			}
+		}
+	}
 
 	if err := h.Queries.UpdateDepartment(r.Context(), sqlc.UpdateDepartmentParams{
 		ID:       payload.ID,
@@ -142,12 +156,22 @@ func (h *Handler) deleteDepartment(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "部门下仍有员工，无法删除")
 		return
 	}
+	tree, err := loadDepartmentTree(r.Context(), h.Queries)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "部门校验失败")
+		return
+	}
+	if len(tree.children[id]) > 0 {
+		writeError(w, http.StatusBadRequest, "部门下仍有下级部门，无法删除")
+		return
+	}
 
 	if err := h.Queries.DeleteDepartment(r.Context(), id); err != nil {
 		writeError(w, http.StatusInternalServerError, "删除部门失败")
 		return
 	}
 
+	h.refreshDayOffsets(r.Context())
 	h.logAudit(r, "delete_department", "department", sql.NullInt64{Int64: id, Valid: true}, nil)
 	writeJSON(w, http.StatusOK, map[string]string{"message": "删除成功"})
 }
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+)
+
+// dayOffsetCacheTTL 缓存兜底过期时间，班次或员工归属调整时会主动失效。
+const dayOffsetCacheTTL = 10 * time.Minute
+
+// dayOffsetBaseline 员工首次记录偏移时的生效时间，表示一直沿用该偏移。
+var dayOffsetBaseline = time.Date(1970, 1, 1, 0, 0, 0, 0, time.Local)
+
+// dayOffsetCache 缓存全部员工的业务日偏移，上报热路径上不再逐次查询班次。
+type dayOffsetCache struct {
+	mu       sync.Mutex
+	offsets  map[int64]time.Duration
+	loadedAt time.Time
+}
+
+// employeeDayOffset 读取员工生效班次的业务日偏移，未配置班次时为 0。
+func (h *Handler) employeeDayOffset(ctx context.Context, employeeID int64) (time.Duration, error) {
+	cache := &h.dayOffsets
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.offsets == nil || time.Since(cache.loadedAt) > dayOffsetCacheTTL {
+		offsets, err := loadEmployeeDayOffsets(ctx, h.Queries)
+		if err != nil {
+			return 0, err
+		}
+		cache.offsets = offsets
+		cache.loadedAt = time.Now()
+	}
+	return cache.offsets[employeeID], nil
+}
+
+// refreshDayOffsets 在班次、班次分配或员工归属变化后调用，失败只记录日志。
+func (h *Handler) refreshDayOffsets(ctx context.Context) {
+	if err := h.syncEmployeeDayOffsets(ctx); err != nil {
+		log.Printf("记录业务日偏移变更失败: %v", err)
+	}
+}
+
+// syncEmployeeDayOffsets 使偏移缓存失效，并为偏移发生变化的员工记录变更时间。
+// 变更之前的日统计按旧偏移归属，对账时跳过这些日期，避免用新偏移改写历史。
+func (h *Handler) syncEmployeeDayOffsets(ctx context.Context) error {
+	h.dayOffsets.mu.Lock()
+	h.dayOffsets.offsets = nil
+	h.dayOffsets.mu.Unlock()
+	if h.DB == nil {
+		return nil
+	}
+
+	current, err := loadEmployeeDayOffsets(ctx, h.Queries)
+	if err != nil {
+		return err
+	}
+	rows, err := h.DB.QueryContext(ctx, `SELECT e.id,
+  (SELECT c.offset_minutes FROM employee_day_offset_changes c WHERE c.employee_id = e.id ORDER BY c.changed_at DESC, c.id DESC LIMIT 1)
+FROM employees e`)
+	if err != nil {
+		return err
+	}
+	type offsetChange struct {
+		EmployeeID int64
+		Minutes    int64
+		ChangedAt  time.Time
+	}
+	var changes []offsetChange
+	now := time.Now().Truncate(time.Second)
+	for rows.Next() {
+		var employeeID int64
+		var latest sql.NullInt64
+		if err := rows.Scan(&employeeID, &latest); err != nil {
+			rows.Close()
+			return err
+		}
+		minutes := int64(current[employeeID] / time.Minute)
+		switch {
+		case !latest.Valid:
+			changes = append(changes, offsetChange{EmployeeID: employeeID, Minutes: minutes, ChangedAt: dayOffsetBaseline})
+		case latest.Int64 != minutes:
+			changes = append(changes, offsetChange{EmployeeID: employeeID, Minutes: minutes, ChangedAt: now})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, change := range changes {
+		if _, err := h.DB.ExecContext(ctx, "INSERT INTO employee_day_offset_changes (employee_id, offset_minutes, changed_at) VALUES (?, ?, ?)",
+			change.EmployeeID, change.Minutes, change.ChangedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadDayOffsetChanges 返回每名员工最近一次业务日偏移变更的时间，不含首次记录。
+func loadDayOffsetChanges(ctx context.Context, db *sql.DB) (map[int64]time.Time, error) {
+	rows, err := db.QueryContext(ctx, `SELECT employee_id, MAX(changed_at) FROM employee_day_offset_changes
+WHERE changed_at > ? GROUP BY employee_id`, dayOffsetBaseline)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	changes := map[int64]time.Time{}
+	for rows.Next() {
+		var employeeID int64
+		var changedAt time.Time
+		if err := rows.Scan(&employeeID, &changedAt); err != nil {
+			return nil, err
+		}
+		changes[employeeID] = changedAt
+	}
+	return changes, rows.Err()
+}
+
+// skipBeforeOffsetChange 去掉偏移变更当日及之前的统计。这些日期按旧偏移写入，
+// 用当前偏移重算会把跨零点的时段挪到相邻日期。
+func skipBeforeOffsetChange(values map[dailyStatsKey]DailyStatsValues, changes map[int64]time.Time) {
+	for key := range values {
+		if changedAt, ok := changes[key.EmployeeID]; ok && key.Date <= changedAt.Format("2006-01-02") {
+			delete(values, key)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"worksentry/internal/db/sqlc"
+)
+
+const employeeTxMaxAttempts = 3
+
+// withEmployeeTx 在事务内先锁定员工行（SELECT ... FOR UPDATE），再执行写入。
+// 同一员工的上报与离线刷新因此串行执行，避免重复累计 daily_stats 或产生重叠时间段。
+func (h *Handler) withEmployeeTx(ctx context.Context, employeeID int64, fn func(qtx *sqlc.Queries, tx *sql.Tx, employee sqlc.Employee) error) error {
+	if h.DB == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+
+	var err error
+	for attempt := 1; attempt <= employeeTxMaxAttempts; attempt++ {
+		err = h.runEmployeeTx(ctx, employeeID, fn)
+		if err == nil || !isRetryableTxErr(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt*20) * time.Millisecond):
+		}
+	}
+	return err
+}
+
+func (h *Handler) runEmployeeTx(ctx context.Context, employeeID int64, fn func(qtx *sqlc.Queries, tx *sql.Tx, employee sqlc.Employee) error) error {
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	qtx := h.Queries.WithTx(tx)
+
+	employee, err := qtx.LockEmployeeByID(ctx, employeeID)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if err := fn(qtx, tx, employee); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func isRetryableTxErr(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		// 1213: 死锁；1205: 锁等待超时
+		return mysqlErr.Number == 1213 || mysqlErr.Number == 1205
+	}
+	return false
+}
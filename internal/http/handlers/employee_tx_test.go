@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"worksentry/internal/config"
+)
+
+// openTestDB 在 WORKSENTRY_TEST_DSN 指向的 MySQL 上新建临时库并执行全部迁移，测试结束后删除。
+// 未设置该环境变量时跳过，例如：
+// WORKSENTRY_TEST_DSN="root:password@tcp(127.0.0.1:3306)/?parseTime=true" go test ./...
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("WORKSENTRY_TEST_DSN")
+	if dsn == "" {
+		t.Skip("未设置 WORKSENTRY_TEST_DSN，跳过数据库集成测试")
+	}
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("解析 DSN 失败: %v", err)
+	}
+	cfg.DBName = ""
+	cfg.ParseTime = true
+	cfg.Loc = time.Local
+	cfg.MultiStatements = true
+	server, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatalf("连接数据库失败: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	name := fmt.Sprintf("worksentry_test_%d", time.Now().UnixNano())
+	if _, err := server.Exec("CREATE DATABASE " + name + " DEFAULT CHARSET utf8mb4"); err != nil {
+		t.Fatalf("创建测试库失败: %v", err)
+	}
+	t.Cleanup(func() { _, _ = server.Exec("DROP DATABASE " + name) })
+
+	cfg.DBName = name
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatalf("连接测试库失败: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(32)
+
+	files, err := filepath.Glob(filepath.Join("..", "..", "..", "db", "migrations", "*.sql"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("读取迁移文件失败: %v", err)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("读取迁移文件失败: %v", err)
+		}
+		if _, err := db.Exec(string(content)); err != nil {
+			t.Fatalf("执行迁移 %s 失败: %v", filepath.Base(file), err)
+		}
+	}
+	return db
+}
+
+// TestEmployeeTxConcurrentWrites 同一员工的上报、离线刷新与自动下班并发执行后，
+// daily_stats 应与 time_segments 重新汇总的结果一致，且时间段之间没有重叠。
+func TestEmployeeTxConcurrentWrites(t *testing.T) {
+	db := openTestDB(t)
+	h := NewHandler(&config.Config{}, db)
+	ctx := context.Background()
+
+	now := time.Now().Truncate(time.Second)
+	lastSeen := now.Add(-20 * time.Minute)
+	// 无活动 15 分钟即自动下班，使自动下班与上报争抢同一员工
+	if _, err := db.Exec("INSERT INTO settings (id, session_auto_close_minutes) VALUES (1, 15)"); err != nil {
+		t.Fatalf("写入设置失败: %v", err)
+	}
+	result, err := db.Exec(`INSERT INTO employees (employee_code, name, enabled, last_seen_at, last_status, last_segment_end_at)
+VALUES ('T001', '并发测试', 1, ?, 'work', ?)`, lastSeen, lastSeen)
+	if err != nil {
+		t.Fatalf("写入员工失败: %v", err)
+	}
+	employeeID, _ := result.LastInsertId()
+	if _, err := db.Exec("INSERT INTO client_tokens (token, employee_id, issued_at) VALUES ('test-token', ?, ?)", employeeID, now); err != nil {
+		t.Fatalf("写入令牌失败: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO raw_events (employee_id, received_at, process_name, idle_seconds, status) VALUES (?, ?, 'code.exe', 0, 'work')", employeeID, lastSeen); err != nil {
+		t.Fatalf("写入上报失败: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO work_sessions (employee_id, start_at) VALUES (?, ?)", employeeID, now.Add(-2*time.Hour)); err != nil {
+		t.Fatalf("写入上班记录失败: %v", err)
+	}
+
+	reports := []ClientReportRequest{
+		{ProcessName: "code.exe", WindowTitle: "main.go"},
+		{ProcessName: "chrome.exe", WindowTitle: "文档"},
+		{ProcessName: "code.exe", IdleSeconds: 900},
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(payload ClientReportRequest) {
+				defer wg.Done()
+				body, _ := json.Marshal(payload)
+				req := httptest.NewRequest(http.MethodPost, "/api/v1/client/report", bytes.NewReader(body))
+				req.Header.Set("Authorization", "Bearer test-token")
+				rec := httptest.NewRecorder()
+				h.ClientReport(rec, req)
+				if rec.Code != http.StatusOK {
+					errs <- fmt.Errorf("上报返回 %d: %s", rec.Code, rec.Body.String())
+				}
+			}(reports[i%len(reports)])
+		}
+		for i := 0; i < 3; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				h.refreshOfflineSegments(ctx)
+			}()
+			go func() {
+				defer wg.Done()
+				h.autoCloseWorkSessions(ctx)
+			}()
+		}
+		wg.Wait()
+		// 下一轮的上报与前一轮间隔至少一秒，使时间段有实际时长
+		time.Sleep(1100 * time.Millisecond)
+	}
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, -1)
+	end := start.AddDate(0, 0, 3)
+	expected, err := computeDailyStatsFromSegments(ctx, db, start, end, employeeID)
+	if err != nil {
+		t.Fatalf("汇总时间段失败: %v", err)
+	}
+	actual, err := loadDailyStatsRange(ctx, db, start, end, employeeID)
+	if err != nil {
+		t.Fatalf("读取日统计失败: %v", err)
+	}
+	if len(expected) == 0 {
+		t.Fatal("并发写入后没有任何时间段")
+	}
+	for key, values := range expected {
+		if fields := diffDailyStats(values, actual[key]); len(fields) > 0 {
+			t.Errorf("%s 日统计与时间段不一致 %v: expected=%+v actual=%+v", key.Date, fields, values, actual[key])
+		}
+	}
+	for key, values := range actual {
+		if _, ok := expected[key]; !ok && values != (DailyStatsValues{}) {
+			t.Errorf("%s 存在没有对应时间段的日统计: %+v", key.Date, values)
+		}
+	}
+
+	rows, err := db.Query("SELECT start_at, end_at FROM time_segments WHERE employee_id = ? ORDER BY start_at, id", employeeID)
+	if err != nil {
+		t.Fatalf("读取时间段失败: %v", err)
+	}
+	defer rows.Close()
+	var prevEnd time.Time
+	for rows.Next() {
+		var segStart, segEnd time.Time
+		if err := rows.Scan(&segStart, &segEnd); err != nil {
+			t.Fatalf("读取时间段失败: %v", err)
+		}
+		if segStart.Before(prevEnd) {
+			t.Errorf("时间段重叠: %s 开始早于上一段结束 %s", formatTime(segStart), formatTime(prevEnd))
+		}
+		if !segEnd.After(segStart) {
+			t.Errorf("时间段为空: %s - %s", formatTime(segStart), formatTime(segEnd))
+		}
+		prevEnd = segEnd
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("读取时间段失败: %v", err)
+	}
+}
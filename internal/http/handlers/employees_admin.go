@@ -227,6 +227,7 @@ func (h *Handler) createEmployee(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.refreshDayOffsets(r.Context())
 	h.logAudit(r, "create_employee", "employees", sql.NullInt64{Int64: payload.ID, Valid: payload.ID > 0}, payload)
 	writeJSON(w, http.StatusOK, map[string]string{"message": "ok"})
 }
@@ -275,6 +276,7 @@ func (h *Handler) updateEmployee(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.refreshDayOffsets(r.Context())
 	h.logAudit(r, "update_employee", "employees", sql.NullInt64{Int64: payload.ID, Valid: payload.ID > 0}, payload)
 	writeJSON(w, http.StatusOK, map[string]string{"message": "ok"})
 }
@@ -1,20 +1,37 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/xuri/excelize/v2"
-
-	"worksentry/internal/db/sqlc"
 )
 
+// ExportDaily 默认导出带部门小计的 xlsx；format 为 csv 或 ndjson 时按每人每天一行流式导出。
 func (h *Handler) ExportDaily(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
 		return
 	}
+	format, message := exportFormat(r.URL.Query(), exportFormatXLSX)
+	if message != "" {
+		writeError(w, http.StatusBadRequest, message)
+		return
+	}
+	if format == exportFormatCSV || format == exportFormatNDJSON {
+		h.exportIfRequested(w, r, h.dailyStatsExport)
+		return
+	}
+	if rr, ranged, message := parseReportRange(r.URL.Query()); ranged {
+		if message != "" {
+			writeError(w, http.StatusBadRequest, message)
+			return
+		}
+		h.exportDailyRange(w, r, rr)
+		return
+	}
 	dateValue := r.URL.Query().Get("date")
 	if dateValue == "" {
 		dateValue = time.Now().Format("2006-01-02")
@@ -25,21 +42,32 @@ func (h *Handler) ExportDaily(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	departmentID, _ := strconv.ParseInt(r.URL.Query().Get("departmentId"), 10, 64)
-	rows, err := h.Queries.ListDailyStatsByDate(r.Context(), sqlc.ListDailyStatsByDateParams{
-		StatDate:     date,
-		Column2:      departmentID,
-		DepartmentID: toNullInt64(departmentID),
-	})
+	tree, err := loadDepartmentTree(r.Context(), h.Queries)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取部门失败")
+		return
+	}
+	rows, err := h.Queries.ListDailyStatsByDate(r.Context(), dailyStatsParams(date, departmentID, tree))
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "导出失败")
 		return
 	}
 
+	day, err := h.resolveCalendarDay(r.Context(), departmentID, date)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取工作日历失败")
+		return
+	}
+	dateLabel := dateValue
+	if label := calendarDayLabel(day); label != "" {
+		dateLabel = fmt.Sprintf("%s（%s）", dateValue, label)
+	}
+
 	file := excelize.NewFile()
 	sheet := "日报表"
 	file.SetSheetName("Sheet1", sheet)
 
-	headers := []string{"日期", "工号", "姓名", "部门", "工作时长", "常规时长", "摸鱼时长", "离开时长", "离线时长", "在岗时长", "有效工时"}
+	headers := []string{"日期", "工号", "姓名", "部门", "工作时长", "常规时长", "摸鱼时长", "离开时长", "离线时长", "在岗时长", "有效工时", "请假时长", "班次"}
 	for col, header := range headers {
 		cell, _ := excelize.CoordinatesToCellName(col+1, 1)
 		_ = file.SetCellValue(sheet, cell, header)
@@ -48,7 +76,7 @@ func (h *Handler) ExportDaily(w http.ResponseWriter, r *http.Request) {
 	for i, row := range rows {
 		idx := i + 2
 		values := []any{
-			dateValue,
+			dateLabel,
 			row.EmployeeCode,
 			row.Name,
 			nullString(row.DepartmentName),
@@ -59,16 +87,137 @@ func (h *Handler) ExportDaily(w http.ResponseWriter, r *http.Request) {
 			formatDuration(int64(row.OfflineSeconds)),
 			formatDuration(int64(row.AttendanceSeconds)),
 			formatDuration(int64(row.EffectiveSeconds)),
+			formatDuration(int64(row.LeaveSeconds)),
+			nullString(row.ShiftName),
+		}
+		for col, value := range values {
+			cell, _ := excelize.CoordinatesToCellName(col+1, idx)
+			_ = file.SetCellValue(sheet, cell, spreadsheetCellValue(value))
+		}
+	}
+
+	// 部门小计逐级汇总下级部门，空一行置于明细之后
+	idx := len(rows) + 3
+	for _, subtotal := range tree.rollupDepartments(departmentID, dailyStatsMembers(rows)) {
+		values := []any{
+			dateLabel,
+			"小计",
+			fmt.Sprintf("%d 人", subtotal.EmployeeCount),
+			subtotal.subtotalLabel(),
+			formatDuration(subtotal.Values.WorkSeconds),
+			formatDuration(subtotal.Values.NormalSeconds),
+			formatDuration(subtotal.Values.FishSeconds),
+			formatDuration(subtotal.Values.IdleSeconds),
+			formatDuration(subtotal.Values.OfflineSeconds),
+			formatDuration(subtotal.Values.AttendanceSeconds),
+			formatDuration(subtotal.Values.EffectiveSeconds),
+			formatDuration(subtotal.Values.LeaveSeconds),
 		}
 		for col, value := range values {
 			cell, _ := excelize.CoordinatesToCellName(col+1, idx)
-			_ = file.SetCellValue(sheet, cell, value)
+			_ = file.SetCellValue(sheet, cell, spreadsheetCellValue(value))
 		}
+		idx++
+	}
+
+	file.SetColWidth(sheet, "A", "M", 16)
+
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", "attachment; filename=worksentry_daily.xlsx")
+	_ = file.Write(w)
+}
+
+// exportDailyRange 每个周期一个工作表，首个工作表为整个区间的汇总。
+func (h *Handler) exportDailyRange(w http.ResponseWriter, r *http.Request, rr reportRange) {
+	departmentID := parseInt64(r.URL.Query().Get("departmentId"))
+	result, err := h.loadPeriodStats(r.Context(), rr, departmentID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "导出失败")
+		return
+	}
+	if len(result.Periods) > reportExportMaxSheets {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("周期数量超过 %d 个，请按周或按月汇总导出", reportExportMaxSheets))
+		return
 	}
 
-	file.SetColWidth(sheet, "A", "K", 16)
+	file := excelize.NewFile()
+	summaryLabel := rr.Start.Format("2006-01-02") + " ~ " + rr.End.AddDate(0, 0, -1).Format("2006-01-02")
+	file.SetSheetName("Sheet1", "汇总")
+	writePeriodSheet(file, "汇总", summaryLabel, result.Summary, result.SummarySubtotals)
+	for i, period := range result.Periods {
+		if _, err := file.NewSheet(period.Key); err != nil {
+			writeError(w, http.StatusInternalServerError, "导出失败")
+			return
+		}
+		writePeriodSheet(file, period.Key, period.Label, result.Stats[i], result.Subtotals[i])
+	}
 
 	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
 	w.Header().Set("Content-Disposition", "attachment; filename=worksentry_daily.xlsx")
 	_ = file.Write(w)
 }
+
+func writePeriodSheet(file *excelize.File, sheet string, label string, items []periodStats, subtotals []departmentSubtotal) {
+	headers := []string{"周期", "工号", "姓名", "部门", "工作时长", "常规时长", "摸鱼时长", "离开时长", "离线时长", "在岗时长", "有效工时", "请假时长",
+		"工作日数", "出勤天数", "日均在岗", "日均有效工时", "摸鱼占比"}
+	for col, header := range headers {
+		cell, _ := excelize.CoordinatesToCellName(col+1, 1)
+		_ = file.SetCellValue(sheet, cell, header)
+	}
+	for i, item := range items {
+		view := item.view()
+		values := []any{
+			label,
+			view.EmployeeCode,
+			view.Name,
+			view.Department,
+			view.WorkDuration,
+			view.NormalDuration,
+			view.FishDuration,
+			view.IdleDuration,
+			view.OfflineDuration,
+			view.AttendanceDuration,
+			view.EffectiveDuration,
+			view.LeaveDuration,
+			view.Workdays,
+			view.AttendanceDays,
+			view.AvgAttendanceDuration,
+			view.AvgEffectiveDuration,
+			view.FishRatio,
+		}
+		for col, value := range values {
+			cell, _ := excelize.CoordinatesToCellName(col+1, i+2)
+			_ = file.SetCellValue(sheet, cell, spreadsheetCellValue(value))
+		}
+	}
+	idx := len(items) + 3
+	for _, subtotal := range subtotals {
+		view := subtotal.view()
+		values := []any{
+			label,
+			"小计",
+			fmt.Sprintf("%d 人", subtotal.EmployeeCount),
+			subtotal.subtotalLabel(),
+			view.WorkDuration,
+			view.NormalDuration,
+			view.FishDuration,
+			view.IdleDuration,
+			view.OfflineDuration,
+			view.AttendanceDuration,
+			view.EffectiveDuration,
+			view.LeaveDuration,
+			subtotal.Workdays,
+			"",
+			view.AvgAttendanceDuration,
+			view.AvgEffectiveDuration,
+			view.FishRatio,
+		}
+		for col, value := range values {
+			cell, _ := excelize.CoordinatesToCellName(col+1, idx)
+			_ = file.SetCellValue(sheet, cell, spreadsheetCellValue(value))
+		}
+		idx++
+	}
+	file.SetColWidth(sheet, "A", "A", 26)
+	file.SetColWidth(sheet, "B", "Q", 14)
+}
@@ -0,0 +1,669 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	exportJobQueued    = "queued"
+	exportJobRunning   = "running"
+	exportJobSucceeded = "succeeded"
+	exportJobFailed    = "failed"
+	exportJobExpired   = "expired"
+
+	exportJobPollInterval = 2 * time.Second
+	exportJobTimeout      = 2 * time.Hour
+	// exportProgressInterval 进度写库的最小间隔，避免每批数据都更新一次
+	exportProgressInterval = time.Second
+	// exportLinkTTL 下载链接有效期，过期后重新读取任务即可获得新链接
+	exportLinkTTL = 30 * time.Minute
+	// exportDownloadTimeout 下载大文件时放宽服务端写超时
+	exportDownloadTimeout = 30 * time.Minute
+	// exportJobActiveLimit 每个管理员同时排队或执行中的任务上限
+	exportJobActiveLimit = 5
+)
+
+var (
+	exportSignKeyOnce sync.Once
+	exportSignKey     []byte
+)
+
+type ExportJobPayload struct {
+	Kind   string            `json:"kind"`
+	Format string            `json:"format"`
+	Params map[string]string `json:"params"`
+}
+
+// ExportJobView 导出任务。导出前不单独计数，运行中以 processedRows 展示已写出的行数，
+// totalRows 在完成时写入。
+type ExportJobView struct {
+	ID            int64             `json:"id"`
+	Kind          string            `json:"kind"`
+	Format        string            `json:"format"`
+	Params        map[string]string `json:"params"`
+	Status        string            `json:"status"`
+	StatusLabel   string            `json:"statusLabel"`
+	TotalRows     int64             `json:"totalRows"`
+	ProcessedRows int64             `json:"processedRows"`
+	FileName      string            `json:"fileName"`
+	FileSize      int64             `json:"fileSize"`
+	Error         string            `json:"error"`
+	DownloadURL   string            `json:"downloadUrl"`
+	CreatedAt     string            `json:"createdAt"`
+	StartedAt     string            `json:"startedAt"`
+	FinishedAt    string            `json:"finishedAt"`
+	ExpiresAt     string            `json:"expiresAt"`
+}
+
+type ExportJobListResponse struct {
+	Total int64           `json:"total"`
+	Items []ExportJobView `json:"items"`
+}
+
+type exportJob struct {
+	ID         int64
+	AdminID    int64
+	Kind       string
+	Format     string
+	ParamsJSON string
+}
+
+func exportJobStatusLabel(status string) string {
+	switch status {
+	case exportJobQueued:
+		return "排队中"
+	case exportJobRunning:
+		return "导出中"
+	case exportJobSucceeded:
+		return "已完成"
+	case exportJobFailed:
+		return "失败"
+	case exportJobExpired:
+		return "已过期"
+	default:
+		return status
+	}
+}
+
+func (h *Handler) exportDir() string {
+	if h.Config == nil {
+		return ""
+	}
+	return strings.TrimSpace(h.Config.App.Export.Dir)
+}
+
+func (h *Handler) exportTTL() time.Duration {
+	hours := 24
+	if h.Config != nil && h.Config.App.Export.TTLHours > 0 {
+		hours = h.Config.App.Export.TTLHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// exportJobSignKey 优先使用配置的签名密钥，未配置时在进程内随机生成一次。
+func (h *Handler) exportJobSignKey() []byte {
+	if h.Config != nil && h.Config.App.Export.SignKey != "" {
+		return []byte(h.Config.App.Export.SignKey)
+	}
+	exportSignKeyOnce.Do(func() {
+		exportSignKey = make([]byte, 32)
+		_, _ = rand.Read(exportSignKey)
+	})
+	return exportSignKey
+}
+
+func (h *Handler) signExportFile(id int64, expires int64) string {
+	mac := hmac.New(sha256.New, h.exportJobSignKey())
+	fmt.Fprintf(mac, "%d:%d", id, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (h *Handler) exportDownloadURL(id int64, now time.Time) string {
+	expires := now.Add(exportLinkTTL).Unix()
+	values := url.Values{}
+	values.Set("id", strconv.FormatInt(id, 10))
+	values.Set("expires", strconv.FormatInt(expires, 10))
+	values.Set("sig", h.signExportFile(id, expires))
+	return "/api/v1/export-files?" + values.Encode()
+}
+
+func (h *Handler) ExportJobs(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		if id := parseInt64(r.URL.Query().Get("id")); id > 0 {
+			h.writeExportJob(w, r, id)
+			return
+		}
+		h.listExportJobs(w, r)
+	case http.MethodPost:
+		h.createExportJob(w, r)
+	case http.MethodDelete:
+		h.deleteExportJob(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+	}
+}
+
+// createExportJob 先按导出参数构造一次导出计划做校验，参数错误直接返回，不进入队列。
+func (h *Handler) createExportJob(w http.ResponseWriter, r *http.Request) {
+	var payload ExportJobPayload
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "请求参数错误")
+		return
+	}
+	payload.Kind = strings.TrimSpace(payload.Kind)
+	build, ok := h.exportKinds()[payload.Kind]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "导出类型无效")
+		return
+	}
+	payload.Format = strings.ToLower(strings.TrimSpace(payload.Format))
+	if payload.Format == "" {
+		payload.Format = exportFormatXLSX
+	}
+	if payload.Format != exportFormatXLSX && payload.Format != exportFormatCSV && payload.Format != exportFormatNDJSON {
+		writeError(w, http.StatusBadRequest, "导出格式仅支持 csv、ndjson、xlsx")
+		return
+	}
+	if payload.Params == nil {
+		payload.Params = map[string]string{}
+	}
+	delete(payload.Params, "format")
+	if _, status, message := build(r.Context(), exportJobParams(payload.Params)); message != "" {
+		writeError(w, status, message)
+		return
+	}
+	if h.exportDir() == "" {
+		writeError(w, http.StatusInternalServerError, "未配置导出目录")
+		return
+	}
+
+	adminID := adminIDFromRequest(r)
+	var active int
+	if err := h.DB.QueryRowContext(r.Context(), "SELECT COUNT(1) FROM export_jobs WHERE admin_id = ? AND status IN ('queued', 'running')",
+		adminID).Scan(&active); err != nil {
+		writeError(w, http.StatusInternalServerError, "创建导出任务失败")
+		return
+	}
+	if active >= exportJobActiveLimit {
+		writeError(w, http.StatusTooManyRequests, fmt.Sprintf("同时进行的导出任务不能超过 %d 个", exportJobActiveLimit))
+		return
+	}
+
+	paramsJSON, _ := json.Marshal(payload.Params)
+	result, err := h.DB.ExecContext(r.Context(), "INSERT INTO export_jobs (admin_id, kind, format, params_json) VALUES (?, ?, ?, ?)",
+		adminID, payload.Kind, payload.Format, string(paramsJSON))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "创建导出任务失败")
+		return
+	}
+	id, _ := result.LastInsertId()
+	h.logAudit(r, "create_export_job", "export_job", sql.NullInt64{Int64: id, Valid: true}, map[string]any{
+		"kind":   payload.Kind,
+		"format": payload.Format,
+		"params": payload.Params,
+	})
+	h.writeExportJob(w, r, id)
+}
+
+func exportJobParams(params map[string]string) url.Values {
+	values := url.Values{}
+	for key, value := range params {
+		values.Set(key, value)
+	}
+	return values
+}
+
+func (h *Handler) writeExportJob(w http.ResponseWriter, r *http.Request, id int64) {
+	items, _, err := h.queryExportJobs(r.Context(), " WHERE j.id = ? AND j.admin_id = ?", []any{id, adminIDFromRequest(r)}, 1, 1)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取导出任务失败")
+		return
+	}
+	if len(items) == 0 {
+		writeError(w, http.StatusNotFound, "导出任务不存在")
+		return
+	}
+	writeJSON(w, http.StatusOK, items[0])
+}
+
+// listExportJobs 只列出当前管理员自己提交的任务。
+func (h *Handler) listExportJobs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page := parseInt(query.Get("page"), 1)
+	pageSize := parseInt(query.Get("pageSize"), 20)
+	if pageSize <= 0 || pageSize > 200 {
+		pageSize = 20
+	}
+	if page <= 0 {
+		page = 1
+	}
+
+	where := " WHERE j.admin_id = ?"
+	args := []any{adminIDFromRequest(r)}
+	switch status := strings.TrimSpace(query.Get("status")); status {
+	case "":
+	case exportJobQueued, exportJobRunning, exportJobSucceeded, exportJobFailed, exportJobExpired:
+		where += " AND j.status = ?"
+		args = append(args, status)
+	default:
+		writeError(w, http.StatusBadRequest, "任务状态无效")
+		return
+	}
+	if kind := strings.TrimSpace(query.Get("kind")); kind != "" {
+		where += " AND j.kind = ?"
+		args = append(args, kind)
+	}
+
+	items, total, err := h.queryExportJobs(r.Context(), where, args, page, pageSize)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取导出任务失败")
+		return
+	}
+	writeJSON(w, http.StatusOK, ExportJobListResponse{Total: total, Items: items})
+}
+
+func (h *Handler) queryExportJobs(ctx context.Context, where string, args []any, page int, pageSize int) ([]ExportJobView, int64, error) {
+	var total int64
+	if err := h.DB.QueryRowContext(ctx, "SELECT COUNT(1) FROM export_jobs j"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	args = append(args, pageSize, (page-1)*pageSize)
+	rows, err := h.DB.QueryContext(ctx, `SELECT j.id, j.kind, j.format, j.params_json, j.status, j.total_rows, j.processed_rows,
+ COALESCE(j.file_name, ''), j.file_size, COALESCE(j.error_message, ''), j.created_at, j.started_at, j.finished_at, j.expires_at
+FROM export_jobs j`+where+" ORDER BY j.created_at DESC, j.id DESC LIMIT ? OFFSET ?", args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	now := time.Now()
+	items := make([]ExportJobView, 0)
+	for rows.Next() {
+		var item ExportJobView
+		var paramsJSON string
+		var createdAt time.Time
+		var startedAt, finishedAt, expiresAt sql.NullTime
+		if err := rows.Scan(&item.ID, &item.Kind, &item.Format, &paramsJSON, &item.Status, &item.TotalRows, &item.ProcessedRows,
+			&item.FileName, &item.FileSize, &item.Error, &createdAt, &startedAt, &finishedAt, &expiresAt); err != nil {
+			return nil, 0, err
+		}
+		_ = json.Unmarshal([]byte(paramsJSON), &item.Params)
+		item.StatusLabel = exportJobStatusLabel(item.Status)
+		if item.Status == exportJobSucceeded && expiresAt.Valid && expiresAt.Time.After(now) {
+			item.DownloadURL = h.exportDownloadURL(item.ID, now)
+		}
+		item.CreatedAt = formatTime(createdAt)
+		item.StartedAt = nullTimeText(startedAt)
+		item.FinishedAt = nullTimeText(finishedAt)
+		item.ExpiresAt = nullTimeText(expiresAt)
+		items = append(items, item)
+	}
+	return items, total, rows.Err()
+}
+
+// deleteExportJob 删除已结束的任务及其结果文件，执行中的任务不能删除。
+func (h *Handler) deleteExportJob(w http.ResponseWriter, r *http.Request) {
+	id := parseInt64(r.URL.Query().Get("id"))
+	if id <= 0 {
+		writeError(w, http.StatusBadRequest, "任务编号无效")
+		return
+	}
+	var status string
+	var filePath sql.NullString
+	err := h.DB.QueryRowContext(r.Context(), "SELECT status, file_path FROM export_jobs WHERE id = ? AND admin_id = ?",
+		id, adminIDFromRequest(r)).Scan(&status, &filePath)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "导出任务不存在")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "删除导出任务失败")
+		return
+	}
+	if status == exportJobRunning {
+		writeError(w, http.StatusConflict, "任务正在导出，不能删除")
+		return
+	}
+	result, err := h.DB.ExecContext(r.Context(), "DELETE FROM export_jobs WHERE id = ? AND status <> 'running'", id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "删除导出任务失败")
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		writeError(w, http.StatusConflict, "任务正在导出，不能删除")
+		return
+	}
+	if filePath.Valid && filePath.String != "" {
+		if err := os.Remove(filePath.String); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("删除导出文件失败: id=%d %v", id, err)
+		}
+	}
+	h.logAudit(r, "delete_export_job", "export_job", sql.NullInt64{Int64: id, Valid: true}, map[string]any{"status": status})
+	writeJSON(w, http.StatusOK, map[string]any{"id": id})
+}
+
+// ExportFile 通过签名链接下载导出结果，不需要管理员令牌，便于浏览器直接打开。
+func (h *Handler) ExportFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	query := r.URL.Query()
+	id := parseInt64(query.Get("id"))
+	expires := parseInt64(query.Get("expires"))
+	expected := h.signExportFile(id, expires)
+	if id <= 0 || !hmac.Equal([]byte(expected), []byte(query.Get("sig"))) {
+		writeError(w, http.StatusForbidden, "下载链接无效")
+		return
+	}
+	if time.Now().Unix() > expires {
+		writeError(w, http.StatusForbidden, "下载链接已过期")
+		return
+	}
+
+	var status string
+	var fileName, filePath sql.NullString
+	var expiresAt sql.NullTime
+	err := h.DB.QueryRowContext(r.Context(), "SELECT status, file_name, file_path, expires_at FROM export_jobs WHERE id = ?", id).
+		Scan(&status, &fileName, &filePath, &expiresAt)
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "导出文件不存在")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取导出文件失败")
+		return
+	}
+	if status != exportJobSucceeded || !filePath.Valid || (expiresAt.Valid && expiresAt.Time.Before(time.Now())) {
+		writeError(w, http.StatusGone, "导出文件已过期")
+		return
+	}
+	file, err := os.Open(filePath.String)
+	if err != nil {
+		writeError(w, http.StatusGone, "导出文件已过期")
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取导出文件失败")
+		return
+	}
+
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(exportDownloadTimeout))
+	w.Header().Set("Content-Disposition", attachmentDisposition(fileName.String))
+	http.ServeContent(w, r, fileName.String, info.ModTime(), file)
+}
+
+func (h *Handler) exportJobLoop(ctx context.Context) {
+	if h.DB == nil {
+		return
+	}
+	// 上次退出时未完成的任务重新排队
+	if _, err := h.DB.ExecContext(ctx, "UPDATE export_jobs SET status = 'queued', processed_rows = 0, started_at = NULL WHERE status = 'running'"); err != nil {
+		log.Printf("导出任务恢复失败: %v", err)
+	}
+	workers := 2
+	if h.Config != nil && h.Config.App.Export.Workers > 0 {
+		workers = h.Config.App.Export.Workers
+	}
+	for i := 0; i < workers; i++ {
+		go h.exportJobWorker(ctx)
+	}
+
+	h.cleanupExportJobs(ctx, time.Now())
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.cleanupExportJobs(ctx, time.Now())
+		}
+	}
+}
+
+func (h *Handler) exportJobWorker(ctx context.Context) {
+	ticker := time.NewTicker(exportJobPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// 连续处理到队列为空再等待下一次轮询
+			for ctx.Err() == nil {
+				job, ok := h.claimExportJob(ctx)
+				if !ok {
+					break
+				}
+				h.runExportJob(ctx, job)
+			}
+		}
+	}
+}
+
+// claimExportJob 以状态为条件抢占最早排队的任务，多个 worker 并发时只有一个能更新成功。
+func (h *Handler) claimExportJob(ctx context.Context) (exportJob, bool) {
+	for attempt := 0; attempt < 3; attempt++ {
+		var job exportJob
+		err := h.DB.QueryRowContext(ctx, "SELECT id, admin_id, kind, format, params_json FROM export_jobs WHERE status = 'queued' ORDER BY id LIMIT 1").
+			Scan(&job.ID, &job.AdminID, &job.Kind, &job.Format, &job.ParamsJSON)
+		if err != nil {
+			if err != sql.ErrNoRows && ctx.Err() == nil {
+				log.Printf("读取导出任务失败: %v", err)
+			}
+			return exportJob{}, false
+		}
+		result, err := h.DB.ExecContext(ctx, "UPDATE export_jobs SET status = 'running', started_at = ? WHERE id = ? AND status = 'queued'",
+			time.Now(), job.ID)
+		if err != nil {
+			return exportJob{}, false
+		}
+		if affected, _ := result.RowsAffected(); affected == 1 {
+			return job, true
+		}
+	}
+	return exportJob{}, false
+}
+
+func (h *Handler) runExportJob(parent context.Context, job exportJob) {
+	ctx, cancel := context.WithTimeout(parent, exportJobTimeout)
+	defer cancel()
+
+	fileName, filePath, size, rows, err := h.writeExportJobFile(ctx, job)
+	if err != nil {
+		if parent.Err() != nil {
+			// 服务停止导致中断，保留 running 状态，下次启动时重新排队
+			return
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("导出超过 %s 未完成", exportJobTimeout)
+		}
+		log.Printf("导出任务失败: id=%d kind=%s %v", job.ID, job.Kind, err)
+		message := err.Error()
+		if utf8.RuneCountInString(message) > 500 {
+			message = string([]rune(message)[:500])
+		}
+		if _, err := h.DB.ExecContext(parent, "UPDATE export_jobs SET status = 'failed', error_message = ?, finished_at = ? WHERE id = ?",
+			message, time.Now(), job.ID); err != nil {
+			log.Printf("更新导出任务失败: id=%d %v", job.ID, err)
+		}
+		return
+	}
+	// 只完成仍处于 running 的任务；运行期间被删除的任务不再复活，结果文件一并删除
+	now := time.Now()
+	result, err := h.DB.ExecContext(parent, `UPDATE export_jobs SET status = 'succeeded', total_rows = ?, processed_rows = ?, file_name = ?, file_path = ?,
+ file_size = ?, error_message = NULL, finished_at = ?, expires_at = ? WHERE id = ? AND status = 'running'`,
+		rows, rows, fileName, filePath, size, now, now.Add(h.exportTTL()), job.ID)
+	if err != nil {
+		log.Printf("更新导出任务失败: id=%d %v", job.ID, err)
+		_ = os.Remove(filePath)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		log.Printf("导出任务已被删除，丢弃结果文件: id=%d", job.ID)
+		_ = os.Remove(filePath)
+		return
+	}
+	log.Printf("导出任务完成: id=%d kind=%s rows=%d size=%d", job.ID, job.Kind, rows, size)
+}
+
+// writeExportJobFile 先写入临时文件，完成后再改名，下载方不会读到未写完的文件。
+func (h *Handler) writeExportJobFile(ctx context.Context, job exportJob) (string, string, int64, int64, error) {
+	build, ok := h.exportKinds()[job.Kind]
+	if !ok {
+		return "", "", 0, 0, fmt.Errorf("导出类型无效: %s", job.Kind)
+	}
+	params := map[string]string{}
+	if err := json.Unmarshal([]byte(job.ParamsJSON), &params); err != nil {
+		return "", "", 0, 0, errors.New("导出参数格式错误")
+	}
+	plan, _, message := build(ctx, exportJobParams(params))
+	if message != "" {
+		return "", "", 0, 0, errors.New(message)
+	}
+
+	dir := filepath.Join(h.exportDir(), time.Now().Format("2006-01-02"))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", "", 0, 0, err
+	}
+	token, err := generateToken()
+	if err != nil {
+		return "", "", 0, 0, err
+	}
+	filePath := filepath.Join(dir, fmt.Sprintf("export_%d_%s.%s", job.ID, token[:16], job.Format))
+	tmpPath := filePath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return "", "", 0, 0, err
+	}
+	fail := func(err error) (string, string, int64, int64, error) {
+		file.Close()
+		_ = os.Remove(tmpPath)
+		return "", "", 0, 0, err
+	}
+
+	rows, err := h.DB.QueryContext(ctx, plan.Query, plan.Args...)
+	if err != nil {
+		return fail(err)
+	}
+	defer rows.Close()
+	writer, err := openExportWriter(file, job.Format, plan.Sheet, plan.Columns)
+	if err != nil {
+		return fail(err)
+	}
+	lastProgress := time.Now()
+	written, err := writeExportRows(rows, plan, writer, func(n int64) {
+		if time.Since(lastProgress) < exportProgressInterval {
+			return
+		}
+		lastProgress = time.Now()
+		_, _ = h.DB.ExecContext(ctx, "UPDATE export_jobs SET processed_rows = ? WHERE id = ?", n, job.ID)
+	})
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", "", 0, 0, err
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		_ = os.Remove(tmpPath)
+		return "", "", 0, 0, err
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", "", 0, 0, err
+	}
+	return exportFileName(plan.Name, job.Format), filePath, info.Size(), written, nil
+}
+
+// cleanupExportJobs 删除过期的结果文件并标记任务过期，随后清理已清空的日期目录。
+func (h *Handler) cleanupExportJobs(ctx context.Context, now time.Time) {
+	rows, err := h.DB.QueryContext(ctx, "SELECT id, COALESCE(file_path, '') FROM export_jobs WHERE status = 'succeeded' AND expires_at <= ?", now)
+	if err != nil {
+		log.Printf("清理导出文件失败: %v", err)
+		return
+	}
+	type expiredJob struct {
+		ID   int64
+		Path string
+	}
+	var expired []expiredJob
+	for rows.Next() {
+		var item expiredJob
+		if err := rows.Scan(&item.ID, &item.Path); err != nil {
+			rows.Close()
+			log.Printf("清理导出文件失败: %v", err)
+			return
+		}
+		expired = append(expired, item)
+	}
+	rows.Close()
+
+	for _, item := range expired {
+		if item.Path != "" {
+			if err := os.Remove(item.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Printf("删除导出文件失败: id=%d %v", item.ID, err)
+				continue
+			}
+		}
+		if _, err := h.DB.ExecContext(ctx, "UPDATE export_jobs SET status = 'expired', file_path = NULL WHERE id = ? AND status = 'succeeded'", item.ID); err != nil {
+			log.Printf("更新导出任务失败: id=%d %v", item.ID, err)
+		}
+	}
+	h.removeEmptyExportDirs(now)
+}
+
+// removeEmptyExportDirs 删除早于保留期且已清空的日期目录。
+func (h *Handler) removeEmptyExportDirs(now time.Time) {
+	root := h.exportDir()
+	if root == "" {
+		return
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	cutoff := now.Add(-h.exportTTL()).Format("2006-01-02")
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() < cutoff {
+			// 目录非空时 os.Remove 会失败，保留即可
+			_ = os.Remove(filepath.Join(root, entry.Name()))
+		}
+	}
+}
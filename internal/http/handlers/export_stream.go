@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	exportFormatXLSX   = "xlsx"
+	exportFormatCSV    = "csv"
+	exportFormatNDJSON = "ndjson"
+
+	// exportFlushRows 流式输出每隔若干行刷新一次，避免响应整体缓存在内存中
+	exportFlushRows = 500
+	exportMaxDays   = 366
+)
+
+type exportColumn struct {
+	Key   string
+	Label string
+}
+
+// exportWriter 按行写出导出数据，CSV 与 NDJSON 直接写入响应，xlsx 使用 excelize 流式写入。
+type exportWriter interface {
+	WriteRow(values []any) error
+	Close() error
+}
+
+// exportFormat 读取 format 参数；未提供时返回 fallback，为空表示按 JSON 返回列表。
+func exportFormat(query url.Values, fallback string) (string, string) {
+	format := strings.ToLower(strings.TrimSpace(query.Get("format")))
+	if format == "" {
+		return fallback, ""
+	}
+	switch format {
+	case exportFormatXLSX, exportFormatCSV, exportFormatNDJSON:
+		return format, ""
+	case "json":
+		return "", ""
+	default:
+		return "", "导出格式仅支持 csv、ndjson、xlsx"
+	}
+}
+
+// newExportWriter 写入下载响应头并返回对应格式的写入器，name 为不含扩展名的文件名。
+func newExportWriter(w http.ResponseWriter, format string, name string, sheet string, columns []exportColumn) (exportWriter, error) {
+	switch format {
+	case exportFormatCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	case exportFormatNDJSON:
+		w.Header().Set("Content-Type", "application/x-ndjson; charset=utf-8")
+	case exportFormatXLSX:
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	default:
+		return nil, fmt.Errorf("不支持的导出格式 %s", format)
+	}
+	w.Header().Set("Content-Disposition", attachmentDisposition(exportFileName(name, format)))
+	return openExportWriter(w, format, sheet, columns)
+}
+
+// attachmentDisposition 返回下载响应的 Content-Disposition，文件名含空格或中文时按 RFC 2231 编码。
+func attachmentDisposition(name string) string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": name})
+}
+
+// openExportWriter 返回写入 out 的导出写入器；out 为 HTTP 响应时按批刷新，导出任务则直接写入文件。
+func openExportWriter(out io.Writer, format string, sheet string, columns []exportColumn) (exportWriter, error) {
+	flusher, _ := out.(http.Flusher)
+	switch format {
+	case exportFormatCSV:
+		return newCSVExportWriter(out, flusher, columns)
+	case exportFormatNDJSON:
+		return &ndjsonExportWriter{out: out, flusher: flusher, columns: columns}, nil
+	case exportFormatXLSX:
+		return newXLSXExportWriter(out, sheet, columns)
+	default:
+		return nil, fmt.Errorf("不支持的导出格式 %s", format)
+	}
+}
+
+func exportFileName(name string, format string) string {
+	return fmt.Sprintf("worksentry_%s.%s", name, format)
+}
+
+type csvExportWriter struct {
+	csv     *csv.Writer
+	flusher http.Flusher
+	rows    int
+}
+
+// newCSVExportWriter 写入 UTF-8 BOM，Excel 直接打开时中文不会乱码。
+func newCSVExportWriter(out io.Writer, flusher http.Flusher, columns []exportColumn) (*csvExportWriter, error) {
+	if _, err := out.Write([]byte("\xEF\xBB\xBF")); err != nil {
+		return nil, err
+	}
+	writer := &csvExportWriter{csv: csv.NewWriter(out), flusher: flusher}
+	writer.csv.UseCRLF = true
+	labels := make([]string, 0, len(columns))
+	for _, column := range columns {
+		labels = append(labels, column.Label)
+	}
+	if err := writer.csv.Write(labels); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (c *csvExportWriter) WriteRow(values []any) error {
+	record := make([]string, 0, len(values))
+	for _, value := range values {
+		record = append(record, exportText(spreadsheetCellValue(value)))
+	}
+	if err := c.csv.Write(record); err != nil {
+		return err
+	}
+	c.rows++
+	if c.rows%exportFlushRows == 0 {
+		return c.flush()
+	}
+	return nil
+}
+
+func (c *csvExportWriter) flush() error {
+	c.csv.Flush()
+	if c.flusher != nil {
+		c.flusher.Flush()
+	}
+	return c.csv.Error()
+}
+
+func (c *csvExportWriter) Close() error {
+	return c.flush()
+}
+
+type ndjsonExportWriter struct {
+	out     io.Writer
+	flusher http.Flusher
+	columns []exportColumn
+	buf     bytes.Buffer
+	rows    int
+}
+
+// WriteRow 按列顺序输出字段，保证每行键的顺序与表头一致。
+func (n *ndjsonExportWriter) WriteRow(values []any) error {
+	n.buf.WriteByte('{')
+	for i, column := range n.columns {
+		if i > 0 {
+			n.buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(column.Key)
+		n.buf.Write(key)
+		n.buf.WriteByte(':')
+		var value any
+		if i < len(values) {
+			value = values[i]
+		}
+		if t, ok := value.(time.Time); ok {
+			value = formatTime(t)
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		n.buf.Write(encoded)
+	}
+	n.buf.WriteString("}\n")
+	n.rows++
+	if n.rows%exportFlushRows == 0 {
+		return n.flush()
+	}
+	return nil
+}
+
+func (n *ndjsonExportWriter) flush() error {
+	if _, err := n.out.Write(n.buf.Bytes()); err != nil {
+		return err
+	}
+	n.buf.Reset()
+	if n.flusher != nil {
+		n.flusher.Flush()
+	}
+	return nil
+}
+
+func (n *ndjsonExportWriter) Close() error {
+	return n.flush()
+}
+
+type xlsxExportWriter struct {
+	out    io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+// newXLSXExportWriter 使用 excelize 流式写入，超出内存阈值的行会落到临时文件。
+func newXLSXExportWriter(out io.Writer, sheet string, columns []exportColumn) (*xlsxExportWriter, error) {
+	file := excelize.NewFile()
+	file.SetSheetName("Sheet1", sheet)
+	stream, err := file.NewStreamWriter(sheet)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	_ = stream.SetColWidth(1, len(columns), 16)
+	header := make([]any, 0, len(columns))
+	for _, column := range columns {
+		header = append(header, column.Label)
+	}
+	if err := stream.SetRow("A1", header); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &xlsxExportWriter{out: out, file: file, stream: stream, row: 1}, nil
+}
+
+func (x *xlsxExportWriter) WriteRow(values []any) error {
+	x.row++
+	cell, _ := excelize.CoordinatesToCellName(1, x.row)
+	row := make([]any, len(values))
+	for i, value := range values {
+		row[i] = spreadsheetCellValue(value)
+	}
+	return x.stream.SetRow(cell, row)
+}
+
+func (x *xlsxExportWriter) Close() error {
+	defer x.file.Close()
+	if err := x.stream.Flush(); err != nil {
+		return err
+	}
+	return x.file.Write(x.out)
+}
+
+// exportNumberPattern 纯数字或百分比，电子表格按数值解析，不会当作公式执行。
+var exportNumberPattern = regexp.MustCompile(`^[+-]?\d+(\.\d+)?%?$`)
+
+// spreadsheetCellValue 将 CSV 与 xlsx 的单元格值统一转换：时间格式化为文本，
+// 以 = + - @ 或制表符、回车开头的文本前加单引号，防止打开文件时被当作公式执行。
+// 员工名称、窗口标题、审核意见等都可能由员工自行填写。
+func spreadsheetCellValue(value any) any {
+	switch v := value.(type) {
+	case time.Time:
+		return formatTime(v)
+	case string:
+		if v == "" || v == "-" || exportNumberPattern.MatchString(v) {
+			return v
+		}
+		switch v[0] {
+		case '=', '+', '-', '@', '\t', '\r':
+			return "'" + v
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+func exportText(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return formatTime(v)
+	case float64:
+		// 避免大数值按科学计数法输出
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "是"
+		}
+		return "否"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// parseExportDateRange 解析导出区间：date 为单日，startDate/endDate 为闭区间，均未提供时为当天。
+func parseExportDateRange(query url.Values) (time.Time, time.Time, string) {
+	startValue := strings.TrimSpace(query.Get("startDate"))
+	endValue := strings.TrimSpace(query.Get("endDate"))
+	if startValue == "" && endValue == "" {
+		startValue = strings.TrimSpace(query.Get("date"))
+	}
+	if startValue == "" {
+		startValue = time.Now().Format("2006-01-02")
+	}
+	if endValue == "" {
+		endValue = startValue
+	}
+	start, err := parseDate(startValue)
+	if err != nil {
+		return time.Time{}, time.Time{}, "开始日期格式错误"
+	}
+	end, err := parseDate(endValue)
+	if err != nil {
+		return time.Time{}, time.Time{}, "结束日期格式错误"
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, "结束日期不能早于开始日期"
+	}
+	end = end.AddDate(0, 0, 1)
+	if end.After(start.AddDate(0, 0, exportMaxDays)) {
+		return time.Time{}, time.Time{}, fmt.Sprintf("导出跨度不能超过 %d 天", exportMaxDays)
+	}
+	return start, end, ""
+}
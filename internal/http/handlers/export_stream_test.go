@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"bytes"
+	"mime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestSpreadsheetCellValue(t *testing.T) {
+	at := time.Date(2024, 3, 1, 9, 30, 0, 0, time.Local)
+	tests := []struct {
+		name  string
+		value any
+		want  any
+	}{
+		{name: "普通文本", value: "张三", want: "张三"},
+		{name: "等号公式", value: "=HYPERLINK(\"http://x\")", want: "'=HYPERLINK(\"http://x\")"},
+		{name: "加号", value: "+cmd|' /C calc'!A0", want: "'+cmd|' /C calc'!A0"},
+		{name: "减号", value: "-2+3", want: "'-2+3"},
+		{name: "at 符号", value: "@SUM(A1)", want: "'@SUM(A1)"},
+		{name: "制表符", value: "\t=1", want: "'\t=1"},
+		{name: "回车", value: "\r=1", want: "'\r=1"},
+		{name: "占位符", value: "-", want: "-"},
+		{name: "空文本", value: "", want: ""},
+		{name: "负数文本", value: "-12.5", want: "-12.5"},
+		{name: "百分比变化", value: "+3.5%", want: "+3.5%"},
+		{name: "中间的等号", value: "a=b", want: "a=b"},
+		{name: "整数不变", value: int64(-5), want: int64(-5)},
+		{name: "时间", value: at, want: formatTime(at)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := spreadsheetCellValue(tt.value); got != tt.want {
+				t.Fatalf("spreadsheetCellValue(%#v) = %#v，期望 %#v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSVExportWriterEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	writer, err := openExportWriter(&buf, exportFormatCSV, "明细", []exportColumn{{Key: "name", Label: "姓名"}, {Key: "title", Label: "窗口标题"}, {Key: "seconds", Label: "秒数"}})
+	if err != nil {
+		t.Fatalf("创建写入器失败: %v", err)
+	}
+	if err := writer.WriteRow([]any{"=1+1", "@evil", int64(-30)}); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("关闭失败: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(strings.TrimPrefix(buf.String(), "\xEF\xBB\xBF")), "\r\n")
+	if len(lines) != 2 || lines[1] != "'=1+1,'@evil,-30" {
+		t.Fatalf("CSV 输出 = %q", buf.String())
+	}
+}
+
+func TestAttachmentDisposition(t *testing.T) {
+	for _, name := range []string{"worksentry_daily.xlsx", "worksentry_考勤 三月.csv", "a;b=c.ndjson"} {
+		disposition, params, err := mime.ParseMediaType(attachmentDisposition(name))
+		if err != nil || disposition != "attachment" || params["filename"] != name {
+			t.Fatalf("attachmentDisposition(%q) 解析为 (%q, %v, %v)", name, disposition, params, err)
+		}
+	}
+}
+
+// TestWritePeriodSheetEscapesFormulas 旧版日报 xlsx 导出中的姓名、部门等文本同样需要转义。
+func TestWritePeriodSheetEscapesFormulas(t *testing.T) {
+	file := excelize.NewFile()
+	defer file.Close()
+	items := []periodStats{{EmployeeCode: "E001", Name: "=HYPERLINK(\"http://evil\")", Department: "@研发", AttendanceDays: 1}}
+	writePeriodSheet(file, "Sheet1", "2024-03-01", items, nil)
+	tests := []struct {
+		cell string
+		want string
+	}{
+		{cell: "B2", want: "E001"},
+		{cell: "C2", want: "'=HYPERLINK(\"http://evil\")"},
+		{cell: "D2", want: "'@研发"},
+		{cell: "E2", want: "00:00"},
+	}
+	for _, tt := range tests {
+		got, err := file.GetCellValue("Sheet1", tt.cell)
+		if err != nil || got != tt.want {
+			t.Fatalf("%s = %q（%v），期望 %q", tt.cell, got, err, tt.want)
+		}
+	}
+}
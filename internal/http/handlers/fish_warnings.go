@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"worksentry/internal/db/sqlc"
+)
+
+const (
+	fishWarnScopeDay     = "day"
+	fishWarnScopeSession = "session"
+
+	// 在岗时长过短时比例波动大，达到该时长后才判断
+	fishWarnMinAttendanceSeconds = 30 * 60
+)
+
+type FishWarningView struct {
+	ID                 int64  `json:"id"`
+	EmployeeCode       string `json:"employeeCode"`
+	Name               string `json:"name"`
+	Department         string `json:"department"`
+	Scope              string `json:"scope"`
+	ScopeLabel         string `json:"scopeLabel"`
+	StatDate           string `json:"statDate"`
+	WorkSessionID      int64  `json:"workSessionId"`
+	FishDuration       string `json:"fishDuration"`
+	AttendanceDuration string `json:"attendanceDuration"`
+	RatioPercent       string `json:"ratioPercent"`
+	ThresholdPercent   int32  `json:"thresholdPercent"`
+	ClientNotifiedAt   string `json:"clientNotifiedAt"`
+	CreatedAt          string `json:"createdAt"`
+}
+
+type FishWarningListResponse struct {
+	Total int64             `json:"total"`
+	Items []FishWarningView `json:"items"`
+}
+
+type fishRatioSample struct {
+	Scope         string
+	StatDate      time.Time
+	WorkSessionID int64
+	Fish          int64
+	Attendance    int64
+}
+
+func fishWarnScopeLabel(scope string) string {
+	switch scope {
+	case fishWarnScopeDay:
+		return "当日"
+	case fishWarnScopeSession:
+		return "本次上班"
+	default:
+		return "未知"
+	}
+}
+
+// evaluateFishRatio 上报写入后计算当日与当前上班时段的摸鱼占比，首次超过阈值时记录预警并推送到实时看板。
+// 返回需要提示客户端的文案，未触发或已关闭客户端提醒时为空。
+func (h *Handler) evaluateFishRatio(ctx context.Context, employee sqlc.Employee, settings sqlc.Setting, now time.Time) (string, error) {
+	threshold := settings.FishRatioWarnPercent
+	if threshold <= 0 || h.DB == nil {
+		return "", nil
+	}
+	samples, err := h.loadFishRatioSamples(ctx, employee.ID, now)
+	if err != nil {
+		return "", err
+	}
+
+	notices := []string{}
+	for _, sample := range samples {
+		if sample.Attendance < fishWarnMinAttendanceSeconds || sample.Fish*100 < int64(threshold)*sample.Attendance {
+			continue
+		}
+		ratio := float64(sample.Fish) / float64(sample.Attendance)
+		result, err := h.DB.ExecContext(ctx, `INSERT IGNORE INTO fish_ratio_warnings
+(employee_id, scope, stat_date, work_session_id, fish_seconds, attendance_seconds, ratio_percent, threshold_percent)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, employee.ID, sample.Scope, sample.StatDate.Format("2006-01-02"), sample.WorkSessionID,
+			sample.Fish, sample.Attendance, fmt.Sprintf("%.1f", ratio*100), threshold)
+		if err != nil {
+			return "", err
+		}
+		// 每天、每次上班只预警一次
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			continue
+		}
+		id, _ := result.LastInsertId()
+		view := FishWarningView{
+			ID:                 id,
+			EmployeeCode:       employee.EmployeeCode,
+			Name:               employee.Name,
+			Scope:              sample.Scope,
+			ScopeLabel:         fishWarnScopeLabel(sample.Scope),
+			StatDate:           sample.StatDate.Format("2006-01-02"),
+			WorkSessionID:      sample.WorkSessionID,
+			FishDuration:       formatDuration(sample.Fish),
+			AttendanceDuration: formatDuration(sample.Attendance),
+			RatioPercent:       formatPercent(ratio),
+			ThresholdPercent:   threshold,
+			CreatedAt:          formatTime(now),
+		}
+		h.Hub.Broadcast(LiveMessage{Type: "fish_warning", Item: view, Time: formatTime(now)})
+		if settings.FishWarnClientNotice {
+			notices = append(notices, fmt.Sprintf("%s摸鱼占比已达 %s", fishWarnScopeLabel(sample.Scope), view.RatioPercent))
+			_, _ = h.DB.ExecContext(ctx, "UPDATE fish_ratio_warnings SET client_notified_at = ? WHERE id = ?", now, id)
+		}
+	}
+	if len(notices) == 0 {
+		return "", nil
+	}
+	return strings.Join(notices, "，") + "，请注意调整工作节奏。", nil
+}
+
+// loadFishRatioSamples 当日口径读取业务日的日统计；上班时段口径按时间段汇总当前未结束的上班记录。
+// 两种口径的分母都是实际在岗时长，不含请假。
+func (h *Handler) loadFishRatioSamples(ctx context.Context, employeeID int64, now time.Time) ([]fishRatioSample, error) {
+	offset, err := h.employeeDayOffset(ctx, employeeID)
+	if err != nil {
+		return nil, err
+	}
+	day := fishRatioSample{Scope: fishWarnScopeDay, StatDate: businessDate(now, offset)}
+	var values DailyStatsValues
+	err = h.DB.QueryRowContext(ctx, "SELECT work_seconds, normal_seconds, fish_seconds FROM daily_stats WHERE stat_date = ? AND employee_id = ?",
+		day.StatDate.Format("2006-01-02"), employeeID).Scan(&values.WorkSeconds, &values.NormalSeconds, &values.FishSeconds)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	day.Fish = values.FishSeconds
+	day.Attendance = values.activeSeconds()
+	samples := []fishRatioSample{day}
+
+	var sessionStart time.Time
+	session := fishRatioSample{Scope: fishWarnScopeSession}
+	err = h.DB.QueryRowContext(ctx, `SELECT id, start_at FROM work_sessions
+WHERE employee_id = ? AND end_at IS NULL ORDER BY start_at DESC LIMIT 1`, employeeID).Scan(&session.WorkSessionID, &sessionStart)
+	if err == sql.ErrNoRows {
+		return samples, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	session.StatDate = businessDate(sessionStart, offset)
+	if err := h.DB.QueryRowContext(ctx, `SELECT
+ COALESCE(SUM(CASE WHEN status = 'fish' THEN TIMESTAMPDIFF(SECOND, GREATEST(start_at, ?), end_at) ELSE 0 END), 0),
+ COALESCE(SUM(CASE WHEN status IN ('work', 'normal', 'fish') THEN TIMESTAMPDIFF(SECOND, GREATEST(start_at, ?), end_at) ELSE 0 END), 0)
+FROM time_segments
+WHERE employee_id = ? AND end_at > ? AND start_at < ?`, sessionStart, sessionStart, employeeID, sessionStart, now).Scan(&session.Fish, &session.Attendance); err != nil {
+		return nil, err
+	}
+	return append(samples, session), nil
+}
+
+// FishWarnings 查询摸鱼占比预警记录。
+func (h *Handler) FishWarnings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+
+	query := r.URL.Query()
+	page := parseInt(query.Get("page"), 1)
+	pageSize := parseInt(query.Get("pageSize"), 20)
+	if pageSize <= 0 || pageSize > 200 {
+		pageSize = 20
+	}
+	if page <= 0 {
+		page = 1
+	}
+
+	where := "WHERE 1 = 1"
+	args := []any{}
+	if startValue := query.Get("startDate"); startValue != "" {
+		startDate, err := parseDate(startValue)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "开始日期格式错误")
+			return
+		}
+		where += " AND f.stat_date >= ?"
+		args = append(args, startDate.Format("2006-01-02"))
+	}
+	if endValue := query.Get("endDate"); endValue != "" {
+		endDate, err := parseDate(endValue)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "结束日期格式错误")
+			return
+		}
+		where += " AND f.stat_date <= ?"
+		args = append(args, endDate.Format("2006-01-02"))
+	}
+	switch scope := strings.TrimSpace(query.Get("scope")); scope {
+	case "":
+	case fishWarnScopeDay, fishWarnScopeSession:
+		where += " AND f.scope = ?"
+		args = append(args, scope)
+	default:
+		writeError(w, http.StatusBadRequest, "预警范围无效")
+		return
+	}
+	clause, clauseArgs, err := h.departmentFilter(r.Context(), "e.department_id", parseInt64(query.Get("departmentId")))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取部门失败")
+		return
+	}
+	where += clause
+	args = append(args, clauseArgs...)
+	if keyword := strings.TrimSpace(query.Get("keyword")); keyword != "" {
+		where += " AND (e.employee_code LIKE ? OR e.name LIKE ?)"
+		like := "%" + keyword + "%"
+		args = append(args, like, like)
+	}
+
+	var total int64
+	countSQL := "SELECT COUNT(1) FROM fish_ratio_warnings f JOIN employees e ON f.employee_id = e.id " + where
+	if err := h.DB.QueryRowContext(r.Context(), countSQL, args...).Scan(&total); err != nil {
+		writeError(w, http.StatusInternalServerError, "读取预警失败")
+		return
+	}
+
+	args = append(args, pageSize, (page-1)*pageSize)
+	rows, err := h.DB.QueryContext(r.Context(), `SELECT f.id, e.employee_code, e.name, COALESCE(d.name, ''), f.scope, f.stat_date, f.work_session_id,
+ f.fish_seconds, f.attendance_seconds, f.threshold_percent, f.client_notified_at, f.created_at
+FROM fish_ratio_warnings f
+JOIN employees e ON f.employee_id = e.id
+LEFT JOIN departments d ON e.department_id = d.id `+where+" ORDER BY f.created_at DESC, f.id DESC LIMIT ? OFFSET ?", args...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取预警失败")
+		return
+	}
+	defer rows.Close()
+	items := make([]FishWarningView, 0)
+	for rows.Next() {
+		var item FishWarningView
+		var statDate time.Time
+		var fish int64
+		var attendance int64
+		var notifiedAt sql.NullTime
+		var createdAt time.Time
+		if err := rows.Scan(&item.ID, &item.EmployeeCode, &item.Name, &item.Department, &item.Scope, &statDate, &item.WorkSessionID,
+			&fish, &attendance, &item.ThresholdPercent, &notifiedAt, &createdAt); err != nil {
+			writeError(w, http.StatusInternalServerError, "读取预警失败")
+			return
+		}
+		item.ScopeLabel = fishWarnScopeLabel(item.Scope)
+		item.StatDate = statDate.Format("2006-01-02")
+		item.FishDuration = formatDuration(fish)
+		item.AttendanceDuration = formatDuration(attendance)
+		if attendance > 0 {
+			item.RatioPercent = formatPercent(float64(fish) / float64(attendance))
+		}
+		if notifiedAt.Valid {
+			item.ClientNotifiedAt = formatTime(notifiedAt.Time)
+		}
+		item.CreatedAt = formatTime(createdAt)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "读取预警失败")
+		return
+	}
+	writeJSON(w, http.StatusOK, FishWarningListResponse{Total: total, Items: items})
+}
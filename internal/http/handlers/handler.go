@@ -20,6 +20,9 @@ type Handler struct {
     Queries *sqlc.Queries
     Hub     *LiveHub
     DB      *sql.DB
+
+    dayOffsets dayOffsetCache
+    alertQueue chan alertDeliveryJob
 }
 
 func NewHandler(cfg *config.Config, db sqlc.DBTX) *Handler {
@@ -32,6 +35,8 @@ func NewHandler(cfg *config.Config, db sqlc.DBTX) *Handler {
         Queries: sqlc.New(db),
         Hub:     NewLiveHub(),
         DB:      sqlDB,
+
+        alertQueue: make(chan alertDeliveryJob, alertDeliveryQueueSize),
     }
 }
 
@@ -142,6 +147,10 @@ func statusLabel(status string) string {
         return "已下班"
     case "incident":
         return "系统事故"
+    case "leave":
+        return "请假"
+    case "leave_unpaid":
+        return "请假（不计出勤）"
     default:
         return "未知"
     }
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"worksentry/internal/db/sqlc"
+)
+
+// hourlyBackfillChunkDays 回填时每个员工按此天数分批加锁重算，避免长时间持有员工锁。
+const hourlyBackfillChunkDays = 7
+
+type hourlyStatsKey struct {
+	Hour       time.Time
+	EmployeeID int64
+}
+
+func truncateHour(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+}
+
+// splitByHour 按自然小时拆分区间，小时统计不受班次业务日偏移影响。
+func splitByHour(start time.Time, end time.Time) []dayPart {
+	if !end.After(start) {
+		return nil
+	}
+	var parts []dayPart
+	current := start
+	for current.Before(end) {
+		hour := truncateHour(current)
+		segmentEnd := minTime(hour.Add(time.Hour), end)
+		parts = append(parts, dayPart{Date: hour, Seconds: int64(segmentEnd.Sub(current).Seconds())})
+		current = segmentEnd
+	}
+	return parts
+}
+
+// applyHourlyStatsDelta 与日统计同步维护小时汇总，口径与 buildDailyStatIncrement 一致。
+func applyHourlyStatsDelta(ctx context.Context, q *sqlc.Queries, employeeID int64, status string, start time.Time, end time.Time, sign int32) error {
+	for _, part := range splitByHour(start, end) {
+		inc := buildDailyStatIncrement(status, part.Seconds)
+		if err := q.AddHourlyStats(ctx, sqlc.AddHourlyStatsParams{
+			StatHour:          part.Date,
+			EmployeeID:        employeeID,
+			WorkSeconds:       inc.Work * sign,
+			NormalSeconds:     inc.Normal * sign,
+			FishSeconds:       inc.Fish * sign,
+			IdleSeconds:       inc.Idle * sign,
+			OfflineSeconds:    inc.Offline * sign,
+			AttendanceSeconds: inc.Attendance * sign,
+			EffectiveSeconds:  inc.Effective * sign,
+			LeaveSeconds:      inc.Leave * sign,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// computeHourlyStatsFromSegments 按 time_segments 重新汇总 [start, end) 内的小时统计，
+// 旧版补录段同样需抵扣其覆盖的离线时长。
+func computeHourlyStatsFromSegments(ctx context.Context, db sqlc.DBTX, start time.Time, end time.Time, employeeID int64) (map[hourlyStatsKey]DailyStatsValues, error) {
+	rows, err := db.QueryContext(ctx, `SELECT start_at, end_at, status, source, adjustment_id IS NULL
+FROM time_segments
+WHERE employee_id = ? AND start_at < ? AND end_at > ?`, employeeID, end, start)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := map[hourlyStatsKey]DailyStatsValues{}
+	for rows.Next() {
+		var segStart time.Time
+		var segEnd time.Time
+		var status string
+		var source string
+		var overlay bool
+		if err := rows.Scan(&segStart, &segEnd, &status, &source, &overlay); err != nil {
+			return nil, err
+		}
+		for _, part := range splitByHour(maxTime(segStart, start), minTime(segEnd, end)) {
+			key := hourlyStatsKey{Hour: part.Date, EmployeeID: employeeID}
+			values := totals[key]
+			inc := buildDailyStatIncrement(status, part.Seconds)
+			values.WorkSeconds += int64(inc.Work)
+			values.NormalSeconds += int64(inc.Normal)
+			values.FishSeconds += int64(inc.Fish)
+			values.IdleSeconds += int64(inc.Idle)
+			values.OfflineSeconds += int64(inc.Offline)
+			values.AttendanceSeconds += int64(inc.Attendance)
+			values.EffectiveSeconds += int64(inc.Effective)
+			values.LeaveSeconds += int64(inc.Leave)
+			if source == "manual" && overlay {
+				values.OfflineSeconds -= part.Seconds
+			}
+			totals[key] = values
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for key, values := range totals {
+		if values.OfflineSeconds < 0 {
+			values.OfflineSeconds = 0
+		}
+		totals[key] = values
+	}
+	return totals, nil
+}
+
+// rebuildHourlyStatsTx 在员工锁内用时间段重算并覆盖 [start, end) 的小时统计，start/end 需按整点对齐。
+func rebuildHourlyStatsTx(ctx context.Context, tx *sql.Tx, qtx *sqlc.Queries, employeeID int64, start time.Time, end time.Time) error {
+	totals, err := computeHourlyStatsFromSegments(ctx, tx, start, end, employeeID)
+	if err != nil {
+		return err
+	}
+	if err := qtx.DeleteHourlyStatsByRange(ctx, sqlc.DeleteHourlyStatsByRangeParams{EmployeeID: employeeID, StatHour: start, StatHour_2: end}); err != nil {
+		return err
+	}
+	for key, values := range totals {
+		if err := qtx.AddHourlyStats(ctx, sqlc.AddHourlyStatsParams{
+			StatHour:          key.Hour,
+			EmployeeID:        key.EmployeeID,
+			WorkSeconds:       int32(values.WorkSeconds),
+			NormalSeconds:     int32(values.NormalSeconds),
+			FishSeconds:       int32(values.FishSeconds),
+			IdleSeconds:       int32(values.IdleSeconds),
+			OfflineSeconds:    int32(values.OfflineSeconds),
+			AttendanceSeconds: int32(values.AttendanceSeconds),
+			EffectiveSeconds:  int32(values.EffectiveSeconds),
+			LeaveSeconds:      int32(values.LeaveSeconds),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackfillHourlyStats 按时间段重建 [start, end) 的小时统计，employeeID 为 0 时处理全部员工。
+func (h *Handler) BackfillHourlyStats(ctx context.Context, start time.Time, end time.Time, employeeID int64) (int, error) {
+	if h.DB == nil {
+		return 0, fmt.Errorf("数据库未初始化")
+	}
+	start = truncateHour(start)
+	end = truncateHour(end)
+	if !end.After(start) {
+		return 0, fmt.Errorf("结束时间必须大于开始时间")
+	}
+
+	ids := []int64{employeeID}
+	if employeeID <= 0 {
+		names, err := loadEmployeeNames(ctx, h.DB)
+		if err != nil {
+			return 0, err
+		}
+		ids = ids[:0]
+		for id := range names {
+			ids = append(ids, id)
+		}
+	}
+
+	processed := 0
+	for _, id := range ids {
+		for chunkStart := start; chunkStart.Before(end); chunkStart = chunkStart.AddDate(0, 0, hourlyBackfillChunkDays) {
+			chunkEnd := minTime(chunkStart.AddDate(0, 0, hourlyBackfillChunkDays), end)
+			if err := h.withEmployeeTx(ctx, id, func(qtx *sqlc.Queries, tx *sql.Tx, locked sqlc.Employee) error {
+				return rebuildHourlyStatsTx(ctx, tx, qtx, id, chunkStart, chunkEnd)
+			}); err != nil {
+				return processed, fmt.Errorf("employee=%d %s: %w", id, chunkStart.Format("2006-01-02"), err)
+			}
+		}
+		processed++
+		if processed%100 == 0 {
+			log.Printf("小时统计回填进度: %d/%d", processed, len(ids))
+		}
+	}
+	return processed, nil
+}
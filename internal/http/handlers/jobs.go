@@ -10,8 +10,19 @@ import (
 )
 
 func (h *Handler) StartBackgroundJobs(ctx context.Context) {
+	// 先为已有员工记录当前的业务日偏移，之后的班次调整才能识别为变更
+	h.refreshDayOffsets(ctx)
 	go h.offlineRefreshLoop(ctx)
 	go h.rawCleanupLoop(ctx)
+	go h.dailyStatsReconcileLoop(ctx)
+	go h.attendanceEvaluateLoop(ctx)
+	go h.workSessionAutoCloseLoop(ctx)
+	go h.overtimeEvaluateLoop(ctx)
+	go h.leaveMaterializeLoop(ctx)
+	go h.alertEvaluateLoop(ctx)
+	go h.alertDeliveryLoop(ctx)
+	go h.reportSubscriptionLoop(ctx)
+	go h.exportJobLoop(ctx)
 }
 
 func (h *Handler) offlineRefreshLoop(ctx context.Context) {
@@ -47,7 +58,7 @@ func (h *Handler) refreshOfflineSegments(ctx context.Context) {
 		return
 	}
 
-	now := time.Now()
+	now := time.Now().Truncate(time.Second)
 	employees, err := h.Queries.ListEmployeesForOfflineRefresh(ctx)
 	if err != nil {
 		log.Printf("离线刷新失败: %v", err)
@@ -58,28 +69,32 @@ func (h *Handler) refreshOfflineSegments(ctx context.Context) {
 		if !employee.LastSeenAt.Valid {
 			continue
 		}
-		gap := now.Sub(employee.LastSeenAt.Time)
-		if gap <= threshold {
+		if now.Sub(employee.LastSeenAt.Time) <= threshold {
 			continue
 		}
 
-		segmentStart := employee.LastSeenAt.Time
-		if employee.LastSegmentEndAt.Valid && employee.LastSegmentEndAt.Time.After(segmentStart) {
-			segmentStart = employee.LastSegmentEndAt.Time
-		}
-		if now.After(segmentStart) {
-			h.createSegmentAndStatsByContext(ctx, employee.ID, segmentStart, now, "offline", "", "offline")
-			_ = h.Queries.UpdateEmployeeLastSegmentEnd(ctx, sqlc.UpdateEmployeeLastSegmentEndParams{
+		err := h.withEmployeeTx(ctx, employee.ID, func(qtx *sqlc.Queries, tx *sql.Tx, locked sqlc.Employee) error {
+			// 加锁后重新判断，期间可能已有新的上报写入
+			if !locked.LastSeenAt.Valid || now.Sub(locked.LastSeenAt.Time) <= threshold {
+				return nil
+			}
+			segmentStart := locked.LastSeenAt.Time
+			if locked.LastSegmentEndAt.Valid && locked.LastSegmentEndAt.Time.After(segmentStart) {
+				segmentStart = locked.LastSegmentEndAt.Time
+			}
+			if !now.After(segmentStart) {
+				return nil
+			}
+			if err := h.createSegmentAndStatsByContext(ctx, tx, locked.ID, segmentStart, now, "offline", "", "offline"); err != nil {
+				return err
+			}
+			return qtx.UpdateEmployeeLastSegmentEnd(ctx, sqlc.UpdateEmployeeLastSegmentEndParams{
 				LastSegmentEndAt: sql.NullTime{Time: now, Valid: true},
-				ID:               employee.ID,
+				ID:               locked.ID,
 			})
+		})
+		if err != nil {
+			log.Printf("离线刷新失败: employee=%d %v", employee.ID, err)
 		}
 	}
 }
-
-func (h *Handler) cleanupRawEvents(ctx context.Context) {
-	cutoff := time.Now().AddDate(0, 0, -7)
-	if err := h.Queries.DeleteRawEventsBefore(ctx, cutoff); err != nil {
-		log.Printf("原始流水清理失败: %v", err)
-	}
-}
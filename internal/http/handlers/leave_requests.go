@@ -0,0 +1,549 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"worksentry/internal/db/sqlc"
+)
+
+const (
+	leaveStatusPending   = "pending"
+	leaveStatusApproved  = "approved"
+	leaveStatusRejected  = "rejected"
+	leaveStatusCancelled = "cancelled"
+
+	leaveSourceAdmin  = "admin"
+	leaveSourceClient = "client"
+
+	leaveMaxSpanDays = 31
+	leaveMaxAgeDays  = 31
+)
+
+type LeaveRequestPayload struct {
+	EmployeeCode string `json:"employeeCode"`
+	LeaveTypeID  int64  `json:"leaveTypeId"`
+	StartAt      string `json:"startAt"`
+	EndAt        string `json:"endAt"`
+	Reason       string `json:"reason"`
+}
+
+type LeaveReviewPayload struct {
+	ID      int64  `json:"id"`
+	Action  string `json:"action"`
+	Comment string `json:"comment"`
+}
+
+type LeaveRequestView struct {
+	ID               int64  `json:"id"`
+	EmployeeCode     string `json:"employeeCode"`
+	Name             string `json:"name"`
+	Department       string `json:"department"`
+	LeaveTypeID      int64  `json:"leaveTypeId"`
+	LeaveType        string `json:"leaveType"`
+	CountPolicyLabel string `json:"countPolicyLabel"`
+	StartAt          string `json:"startAt"`
+	EndAt            string `json:"endAt"`
+	Minutes          int32  `json:"minutes"`
+	Duration         string `json:"duration"`
+	Reason           string `json:"reason"`
+	Status           string `json:"status"`
+	StatusLabel      string `json:"statusLabel"`
+	Source           string `json:"source"`
+	SourceLabel      string `json:"sourceLabel"`
+	ReviewComment    string `json:"reviewComment"`
+	ReviewedAt       string `json:"reviewedAt"`
+	CreatedAt        string `json:"createdAt"`
+}
+
+type LeaveRequestListResponse struct {
+	Total int64              `json:"total"`
+	Items []LeaveRequestView `json:"items"`
+}
+
+type ClientLeaveResponse struct {
+	Items    []LeaveRequestView `json:"items"`
+	Balances []LeaveBalanceView `json:"balances"`
+	Types    []LeaveTypeView    `json:"types"`
+}
+
+const leaveSelectSQL = `SELECT l.id, e.employee_code, e.name, COALESCE(d.name, ''), t.id, t.name, t.count_policy, l.start_at, l.end_at, l.minutes,
+ l.reason, l.status, l.source, l.review_comment, l.reviewed_at, l.created_at
+FROM leave_requests l
+JOIN employees e ON l.employee_id = e.id
+JOIN leave_types t ON l.leave_type_id = t.id
+LEFT JOIN departments d ON e.department_id = d.id `
+
+func (h *Handler) ClientLeaveRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	employee, ok := h.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		items, err := h.queryLeaveRequests(r.Context(), "WHERE l.employee_id = ? ORDER BY l.start_at DESC LIMIT 50", employee.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "读取请假记录失败")
+			return
+		}
+		balances, err := h.listLeaveBalances(r.Context(), employee.ID, time.Now().Year())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "读取假期余额失败")
+			return
+		}
+		types, err := listLeaveTypes(r.Context(), h.DB, true)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "读取假期类型失败")
+			return
+		}
+		typeViews := make([]LeaveTypeView, 0, len(types))
+		for _, item := range types {
+			typeViews = append(typeViews, buildLeaveTypeView(item))
+		}
+		writeJSON(w, http.StatusOK, ClientLeaveResponse{Items: items, Balances: balances, Types: typeViews})
+		return
+	}
+
+	var payload LeaveRequestPayload
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "参数格式错误")
+		return
+	}
+	id, minutes, err := h.submitLeaveRequest(r.Context(), employee, payload, leaveSourceClient, 0)
+	if err != nil {
+		h.writeManualError(w, err, "提交请假失败")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"id": id, "minutes": minutes, "status": leaveStatusPending})
+}
+
+// LeaveRequests 管理端查询请假申请，或代员工录入请假（录入后仍需审核）。
+func (h *Handler) LeaveRequests(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		h.listLeaveRequests(w, r)
+	case http.MethodPost:
+		var payload LeaveRequestPayload
+		if err := decodeJSON(r, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, "参数格式错误")
+			return
+		}
+		payload.EmployeeCode = strings.TrimSpace(payload.EmployeeCode)
+		if payload.EmployeeCode == "" {
+			writeError(w, http.StatusBadRequest, "工号不能为空")
+			return
+		}
+		employee, err := h.Queries.GetEmployeeByCode(r.Context(), payload.EmployeeCode)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "员工不存在")
+			return
+		}
+		id, minutes, err := h.submitLeaveRequest(r.Context(), employee, payload, leaveSourceAdmin, adminIDFromRequest(r))
+		if err != nil {
+			h.writeManualError(w, err, "创建请假失败")
+			return
+		}
+		h.logAudit(r, "create_leave_request", "leave_request", sql.NullInt64{Int64: id, Valid: true}, payload)
+		writeJSON(w, http.StatusOK, map[string]any{"id": id, "minutes": minutes, "status": leaveStatusPending})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+	}
+}
+
+// submitLeaveRequest 校验并创建待审核的请假申请，返回按工作时段折算的请假分钟数。
+func (h *Handler) submitLeaveRequest(ctx context.Context, employee sqlc.Employee, payload LeaveRequestPayload, source string, createdBy int64) (int64, int32, error) {
+	payload.Reason = strings.TrimSpace(payload.Reason)
+	if payload.LeaveTypeID <= 0 || payload.StartAt == "" || payload.EndAt == "" || payload.Reason == "" {
+		return 0, 0, &manualRangeError{Message: "假期类型、时间与事由不能为空"}
+	}
+	if utf8.RuneCountInString(payload.Reason) > 255 {
+		return 0, 0, &manualRangeError{Message: "事由长度不能超过 255 个字符"}
+	}
+	item, err := getLeaveType(ctx, h.DB, payload.LeaveTypeID)
+	if err == sql.ErrNoRows || (err == nil && !item.Enabled) {
+		return 0, 0, &manualRangeError{Message: "假期类型不存在或已停用"}
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	startAt, err := parseDateTime(payload.StartAt)
+	if err != nil {
+		return 0, 0, &manualRangeError{Message: "开始时间格式错误"}
+	}
+	endAt, err := parseDateTime(payload.EndAt)
+	if err != nil {
+		return 0, 0, &manualRangeError{Message: "结束时间格式错误"}
+	}
+	if !endAt.After(startAt) {
+		return 0, 0, &manualRangeError{Message: "结束时间必须大于开始时间"}
+	}
+	if endAt.Sub(startAt) > leaveMaxSpanDays*24*time.Hour {
+		return 0, 0, &manualRangeError{Message: "单次请假不能超过 31 天"}
+	}
+	if startAt.Before(time.Now().AddDate(0, 0, -leaveMaxAgeDays)) {
+		return 0, 0, &manualRangeError{Message: "仅可申请 31 天内的请假"}
+	}
+
+	windows, err := h.buildLeaveWindows(ctx, employee, startAt, endAt)
+	if err != nil {
+		return 0, 0, err
+	}
+	minutes := leaveWindowsMinutes(windows)
+	if minutes == 0 {
+		return 0, 0, &manualRangeError{Message: "所选时间不包含工作时段"}
+	}
+
+	var overlap int64
+	if err := h.DB.QueryRowContext(ctx, `SELECT COUNT(1) FROM leave_requests
+WHERE employee_id = ? AND status IN ('pending', 'approved') AND start_at < ? AND end_at > ?`, employee.ID, endAt, startAt).Scan(&overlap); err != nil {
+		return 0, 0, err
+	}
+	if overlap > 0 {
+		return 0, 0, &manualRangeError{Message: "该时间段已有请假申请"}
+	}
+
+	result, err := h.DB.ExecContext(ctx, `INSERT INTO leave_requests (employee_id, leave_type_id, start_at, end_at, minutes, reason, status, source, created_by)
+VALUES (?, ?, ?, ?, ?, ?, 'pending', ?, ?)`, employee.ID, item.ID, startAt, endAt, minutes, payload.Reason, source, nullIfZeroID(createdBy))
+	if err != nil {
+		return 0, 0, err
+	}
+	id, err := result.LastInsertId()
+	return id, minutes, err
+}
+
+func (h *Handler) listLeaveRequests(w http.ResponseWriter, r *http.Request) {
+	status := strings.TrimSpace(r.URL.Query().Get("status"))
+	departmentID := parseInt64(r.URL.Query().Get("departmentId"))
+	leaveTypeID := parseInt64(r.URL.Query().Get("leaveTypeId"))
+	keyword := strings.TrimSpace(r.URL.Query().Get("keyword"))
+	page := parseInt(r.URL.Query().Get("page"), 1)
+	pageSize := parseInt(r.URL.Query().Get("pageSize"), 20)
+	if pageSize <= 0 || pageSize > 200 {
+		pageSize = 20
+	}
+	if page <= 0 {
+		page = 1
+	}
+
+	where := "WHERE 1 = 1"
+	args := []any{}
+	if status != "" {
+		where += " AND l.status = ?"
+		args = append(args, status)
+	}
+	if leaveTypeID > 0 {
+		where += " AND l.leave_type_id = ?"
+		args = append(args, leaveTypeID)
+	}
+	if startValue := r.URL.Query().Get("startDate"); startValue != "" {
+		startDate, err := parseDate(startValue)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "开始日期格式错误")
+			return
+		}
+		where += " AND l.end_at > ?"
+		args = append(args, startDate)
+	}
+	if endValue := r.URL.Query().Get("endDate"); endValue != "" {
+		endDate, err := parseDate(endValue)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "结束日期格式错误")
+			return
+		}
+		where += " AND l.start_at < ?"
+		args = append(args, endDate.AddDate(0, 0, 1))
+	}
+	clause, clauseArgs, err := h.departmentFilter(r.Context(), "e.department_id", departmentID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取部门失败")
+		return
+	}
+	where += clause
+	args = append(args, clauseArgs...)
+	if keyword != "" {
+		where += " AND (e.employee_code LIKE ? OR e.name LIKE ?)"
+		like := "%" + keyword + "%"
+		args = append(args, like, like)
+	}
+
+	var total int64
+	countSQL := "SELECT COUNT(1) FROM leave_requests l JOIN employees e ON l.employee_id = e.id " + where
+	if err := h.DB.QueryRowContext(r.Context(), countSQL, args...).Scan(&total); err != nil {
+		writeError(w, http.StatusInternalServerError, "读取请假记录失败")
+		return
+	}
+
+	args = append(args, pageSize, (page-1)*pageSize)
+	items, err := h.queryLeaveRequests(r.Context(), where+" ORDER BY l.status = 'pending' DESC, l.start_at DESC LIMIT ? OFFSET ?", args...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取请假记录失败")
+		return
+	}
+	writeJSON(w, http.StatusOK, LeaveRequestListResponse{Total: total, Items: items})
+}
+
+// LeaveRequestReview 审批、驳回或撤销请假：批准时按自然年扣减额度，撤销已批准的请假会退回额度并还原时间段。
+func (h *Handler) LeaveRequestReview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	var payload LeaveReviewPayload
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "参数格式错误")
+		return
+	}
+	payload.Action = strings.TrimSpace(payload.Action)
+	payload.Comment = strings.TrimSpace(payload.Comment)
+	if payload.ID <= 0 {
+		writeError(w, http.StatusBadRequest, "申请编号无效")
+		return
+	}
+	if payload.Action != "approve" && payload.Action != "reject" && payload.Action != "cancel" {
+		writeError(w, http.StatusBadRequest, "审核操作无效")
+		return
+	}
+	if payload.Action != "approve" && payload.Comment == "" {
+		writeError(w, http.StatusBadRequest, "驳回或撤销时请填写审核意见")
+		return
+	}
+
+	var employeeID int64
+	if err := h.DB.QueryRowContext(r.Context(), "SELECT employee_id FROM leave_requests WHERE id = ?", payload.ID).Scan(&employeeID); err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "申请不存在")
+		} else {
+			writeError(w, http.StatusInternalServerError, "读取申请失败")
+		}
+		return
+	}
+
+	operatorID := adminIDFromRequest(r)
+	var minutes int32
+	err := h.withEmployeeTx(r.Context(), employeeID, func(qtx *sqlc.Queries, tx *sql.Tx, locked sqlc.Employee) error {
+		var (
+			leaveTypeID int64
+			startAt     time.Time
+			endAt       time.Time
+			status      string
+		)
+		if err := tx.QueryRowContext(r.Context(), `SELECT leave_type_id, start_at, end_at, minutes, status
+FROM leave_requests WHERE id = ? FOR UPDATE`, payload.ID).Scan(&leaveTypeID, &startAt, &endAt, &minutes, &status); err != nil {
+			return err
+		}
+
+		newStatus := leaveStatusRejected
+		switch payload.Action {
+		case "approve":
+			if status != leaveStatusPending {
+				return &manualRangeError{Message: "申请已审核"}
+			}
+			newStatus = leaveStatusApproved
+			windows, err := h.buildLeaveWindows(r.Context(), locked, startAt, endAt)
+			if err != nil {
+				return err
+			}
+			minutes = leaveWindowsMinutes(windows)
+			if minutes == 0 {
+				return &manualRangeError{Message: "请假时间不包含工作时段"}
+			}
+			byYear := leaveWindowsMinutesByYear(windows)
+			for _, year := range sortedLeaveYears(byYear) {
+				if err := chargeLeaveBalance(r.Context(), tx, locked.ID, leaveTypeID, year, byYear[year]); err != nil {
+					return err
+				}
+			}
+			for _, window := range windows {
+				if _, err := tx.ExecContext(r.Context(), `INSERT INTO leave_request_segments (leave_request_id, employee_id, start_at, end_at, status)
+VALUES (?, ?, ?, ?, 'pending')`, payload.ID, locked.ID, window.StartAt, window.EndAt); err != nil {
+					return err
+				}
+			}
+		case "reject":
+			if status != leaveStatusPending {
+				return &manualRangeError{Message: "申请已审核"}
+			}
+		case "cancel":
+			if status != leaveStatusPending && status != leaveStatusApproved {
+				return &manualRangeError{Message: "申请已结束，无法撤销"}
+			}
+			newStatus = leaveStatusCancelled
+			if status == leaveStatusApproved {
+				byYear, err := leaveRequestMinutesByYear(r.Context(), tx, payload.ID)
+				if err != nil {
+					return err
+				}
+				if len(byYear) == 0 {
+					byYear = map[int]int32{startAt.Year(): minutes}
+				}
+				if err := h.revertLeaveSegmentsTx(r.Context(), tx, qtx, payload.ID); err != nil {
+					return err
+				}
+				for _, year := range sortedLeaveYears(byYear) {
+					if _, err := tx.ExecContext(r.Context(), `UPDATE leave_balances SET used_minutes = GREATEST(0, used_minutes - ?)
+WHERE employee_id = ? AND leave_type_id = ? AND year = ?`, byYear[year], locked.ID, leaveTypeID, year); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		_, err := tx.ExecContext(r.Context(), `UPDATE leave_requests
+SET status = ?, minutes = ?, reviewer_id = ?, review_comment = ?, reviewed_at = ?
+WHERE id = ?`, newStatus, minutes, operatorID, toNullText(payload.Comment), time.Now(), payload.ID)
+		return err
+	})
+	if err != nil {
+		h.writeManualError(w, err, "审核失败")
+		return
+	}
+	if payload.Action == "approve" {
+		h.materializeLeaveSegments(r.Context(), payload.ID)
+	}
+
+	h.logAudit(r, payload.Action+"_leave_request", "leave_request", sql.NullInt64{Int64: payload.ID, Valid: true}, payload)
+	writeJSON(w, http.StatusOK, map[string]any{"message": "审核完成", "minutes": minutes})
+}
+
+// chargeLeaveBalance 扣减指定年份的额度，超出剩余额度时拒绝；不限额的假期只累计已用时长。
+func chargeLeaveBalance(ctx context.Context, tx *sql.Tx, employeeID int64, leaveTypeID int64, year int, minutes int32) error {
+	var typeQuota int32
+	if err := tx.QueryRowContext(ctx, "SELECT annual_quota_minutes FROM leave_types WHERE id = ?", leaveTypeID).Scan(&typeQuota); err != nil {
+		return err
+	}
+	var quota sql.NullInt32
+	var used int32
+	err := tx.QueryRowContext(ctx, `SELECT quota_minutes, used_minutes FROM leave_balances
+WHERE employee_id = ? AND leave_type_id = ? AND year = ? FOR UPDATE`, employeeID, leaveTypeID, year).Scan(&quota, &used)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	limit, unlimited := effectiveLeaveQuota(typeQuota, quota)
+	if !unlimited && used+minutes > limit {
+		return &manualRangeError{Message: fmt.Sprintf("%d 年假期余额不足：剩余 %d 分钟，本次需 %d 分钟", year, limit-used, minutes)}
+	}
+	if err == sql.ErrNoRows {
+		_, err = tx.ExecContext(ctx, `INSERT INTO leave_balances (employee_id, leave_type_id, year, quota_minutes, used_minutes)
+VALUES (?, ?, ?, ?, ?)`, employeeID, leaveTypeID, year, typeQuota, minutes)
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE leave_balances SET used_minutes = used_minutes + ?
+WHERE employee_id = ? AND leave_type_id = ? AND year = ?`, minutes, employeeID, leaveTypeID, year)
+	return err
+}
+
+// leaveRequestMinutesByYear 按审批时生成的时段重算各年扣减的分钟数，撤销时按年退回。
+func leaveRequestMinutesByYear(ctx context.Context, tx *sql.Tx, requestID int64) (map[int]int32, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT start_at, end_at FROM leave_request_segments WHERE leave_request_id = ?", requestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	windows := []leaveWindow{}
+	for rows.Next() {
+		var window leaveWindow
+		if err := rows.Scan(&window.StartAt, &window.EndAt); err != nil {
+			return nil, err
+		}
+		windows = append(windows, window)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return leaveWindowsMinutesByYear(windows), nil
+}
+
+func sortedLeaveYears(byYear map[int]int32) []int {
+	years := make([]int, 0, len(byYear))
+	for year := range byYear {
+		years = append(years, year)
+	}
+	sort.Ints(years)
+	return years
+}
+
+func (h *Handler) queryLeaveRequests(ctx context.Context, where string, args ...any) ([]LeaveRequestView, error) {
+	rows, err := h.DB.QueryContext(ctx, leaveSelectSQL+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]LeaveRequestView, 0)
+	for rows.Next() {
+		var (
+			item          LeaveRequestView
+			policy        string
+			startAt       time.Time
+			endAt         time.Time
+			reviewComment sql.NullString
+			reviewedAt    sql.NullTime
+			createdAt     time.Time
+		)
+		if err := rows.Scan(&item.ID, &item.EmployeeCode, &item.Name, &item.Department, &item.LeaveTypeID, &item.LeaveType, &policy,
+			&startAt, &endAt, &item.Minutes, &item.Reason, &item.Status, &item.Source, &reviewComment, &reviewedAt, &createdAt); err != nil {
+			return nil, err
+		}
+		item.CountPolicyLabel = leavePolicyLabel(policy)
+		item.StartAt = formatTime(startAt)
+		item.EndAt = formatTime(endAt)
+		item.Duration = formatDuration(int64(item.Minutes) * 60)
+		item.StatusLabel = leaveStatusLabel(item.Status)
+		item.SourceLabel = leaveSourceLabel(item.Source)
+		item.ReviewComment = nullString(reviewComment)
+		if reviewedAt.Valid {
+			item.ReviewedAt = formatTime(reviewedAt.Time)
+		}
+		item.CreatedAt = formatTime(createdAt)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func leaveStatusLabel(status string) string {
+	switch status {
+	case leaveStatusPending:
+		return "待审核"
+	case leaveStatusApproved:
+		return "已批准"
+	case leaveStatusRejected:
+		return "已驳回"
+	case leaveStatusCancelled:
+		return "已撤销"
+	default:
+		return "未知"
+	}
+}
+
+func leaveSourceLabel(source string) string {
+	switch source {
+	case leaveSourceAdmin:
+		return "管理员录入"
+	case leaveSourceClient:
+		return "员工申请"
+	default:
+		return "未知"
+	}
+}
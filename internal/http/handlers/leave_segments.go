@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"worksentry/internal/db/sqlc"
+)
+
+const (
+	leaveSegmentPending  = "pending"
+	leaveSegmentApplied  = "applied"
+	leaveSegmentConflict = "conflict"
+	leaveSegmentReverted = "reverted"
+)
+
+type leaveWindow struct {
+	StartAt time.Time
+	EndAt   time.Time
+}
+
+// buildLeaveWindows 将请假区间拆分为每个工作日与应出勤时段的交集，非工作日不计。
+// 未配置班次的员工没有应出勤时段，无法折算请假时长。
+func (h *Handler) buildLeaveWindows(ctx context.Context, employee sqlc.Employee, startAt time.Time, endAt time.Time) ([]leaveWindow, error) {
+	shift, err := h.Queries.GetEmployeeShift(ctx, employee.ID)
+	if err == sql.ErrNoRows {
+		return nil, &manualRangeError{Message: "员工未配置班次，无法计算请假时长"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	offset := shiftDayOffset(shift)
+	firstDay := businessDate(startAt, offset).AddDate(0, 0, -1)
+	lastDay := businessDate(endAt, offset).AddDate(0, 0, 1)
+	days, err := h.resolveCalendarDays(ctx, employee.DepartmentID.Int64, firstDay, lastDay)
+	if err != nil {
+		return nil, err
+	}
+
+	windows := []leaveWindow{}
+	for _, day := range days {
+		if !day.IsWorkday() {
+			continue
+		}
+		expectedStart, expectedEnd := shiftExpectedRange(shift, day.Date)
+		start := maxTime(startAt, expectedStart)
+		end := minTime(endAt, expectedEnd)
+		if !end.After(start) {
+			continue
+		}
+		windows = append(windows, leaveWindow{StartAt: start, EndAt: end})
+	}
+	return windows, nil
+}
+
+func leaveWindowsMinutes(windows []leaveWindow) int32 {
+	var total int32
+	for _, minutes := range leaveWindowsMinutesByYear(windows) {
+		total += minutes
+	}
+	return total
+}
+
+// leaveWindowsMinutesByYear 按时段开始时间所在的自然年汇总请假分钟数，跨年请假分别计入各年额度。
+func leaveWindowsMinutesByYear(windows []leaveWindow) map[int]int32 {
+	durations := map[int]time.Duration{}
+	for _, window := range windows {
+		durations[window.StartAt.Year()] += window.EndAt.Sub(window.StartAt)
+	}
+	minutes := make(map[int]int32, len(durations))
+	for year, total := range durations {
+		minutes[year] = ceilMinutes(total)
+	}
+	return minutes
+}
+
+func (h *Handler) leaveMaterializeLoop(ctx context.Context) {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.materializeLeaveSegments(ctx, 0)
+		}
+	}
+}
+
+type dueLeaveSegment struct {
+	ID         int64
+	EmployeeID int64
+}
+
+// materializeLeaveSegments 将已结束的请假时段写入时间段（覆盖改写），requestID 为 0 时处理全部申请。
+func (h *Handler) materializeLeaveSegments(ctx context.Context, requestID int64) {
+	if h.DB == nil {
+		return
+	}
+	query := `SELECT s.id, s.employee_id FROM leave_request_segments s
+JOIN leave_requests r ON s.leave_request_id = r.id
+WHERE s.status = 'pending' AND r.status = 'approved' AND s.end_at <= ?`
+	args := []any{time.Now()}
+	if requestID > 0 {
+		query += " AND s.leave_request_id = ?"
+		args = append(args, requestID)
+	}
+	rows, err := h.DB.QueryContext(ctx, query+" ORDER BY s.end_at LIMIT 500", args...)
+	if err != nil {
+		log.Printf("请假时段生成失败: %v", err)
+		return
+	}
+	var due []dueLeaveSegment
+	for rows.Next() {
+		var item dueLeaveSegment
+		if err := rows.Scan(&item.ID, &item.EmployeeID); err != nil {
+			rows.Close()
+			log.Printf("请假时段生成失败: %v", err)
+			return
+		}
+		due = append(due, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Printf("请假时段生成失败: %v", err)
+		return
+	}
+
+	for _, item := range due {
+		err := h.applyLeaveSegment(ctx, item)
+		var rangeErr *manualRangeError
+		if errors.As(err, &rangeErr) {
+			// 与已有补录重叠的时段不再自动重试，需人工处理
+			_, _ = h.DB.ExecContext(ctx, "UPDATE leave_request_segments SET status = ? WHERE id = ? AND status = ?", leaveSegmentConflict, item.ID, leaveSegmentPending)
+			log.Printf("请假时段与已有补录冲突: segment=%d %s", item.ID, rangeErr.Message)
+			continue
+		}
+		if err != nil {
+			log.Printf("请假时段生成失败: segment=%d %v", item.ID, err)
+		}
+	}
+}
+
+func (h *Handler) applyLeaveSegment(ctx context.Context, item dueLeaveSegment) error {
+	return h.withEmployeeTx(ctx, item.EmployeeID, func(qtx *sqlc.Queries, tx *sql.Tx, locked sqlc.Employee) error {
+		var (
+			startAt       time.Time
+			endAt         time.Time
+			segmentStatus string
+			requestStatus string
+			reason        string
+			reviewerID    sql.NullInt64
+			typeName      string
+			policy        string
+		)
+		if err := tx.QueryRowContext(ctx, `SELECT s.start_at, s.end_at, s.status, r.status, r.reason, r.reviewer_id, t.name, t.count_policy
+FROM leave_request_segments s
+JOIN leave_requests r ON s.leave_request_id = r.id
+JOIN leave_types t ON r.leave_type_id = t.id
+WHERE s.id = ? FOR UPDATE`, item.ID).Scan(&startAt, &endAt, &segmentStatus, &requestStatus, &reason, &reviewerID, &typeName, &policy); err != nil {
+			return err
+		}
+		if segmentStatus != leaveSegmentPending || requestStatus != leaveStatusApproved {
+			return nil
+		}
+		adjustmentID, err := h.createManualAdjustmentTx(ctx, tx, qtx, locked, manualAdjustmentInput{
+			StartAt:      startAt,
+			EndAt:        endAt,
+			TargetStatus: leaveSegmentStatus(policy),
+			Label:        typeName,
+			Mode:         manualModeOverwrite,
+		}, "请假", reason, reviewerID.Int64)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE leave_request_segments SET status = ?, adjustment_id = ? WHERE id = ?", leaveSegmentApplied, adjustmentID, item.ID)
+		return err
+	})
+}
+
+// revertLeaveSegmentsTx 撤回请假已生成的时间段，需在员工锁内调用。
+func (h *Handler) revertLeaveSegmentsTx(ctx context.Context, tx *sql.Tx, qtx *sqlc.Queries, requestID int64) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, adjustment_id FROM leave_request_segments
+WHERE leave_request_id = ? AND status = 'applied' FOR UPDATE`, requestID)
+	if err != nil {
+		return err
+	}
+	adjustments := map[int64]int64{}
+	for rows.Next() {
+		var id int64
+		var adjustmentID sql.NullInt64
+		if err := rows.Scan(&id, &adjustmentID); err != nil {
+			rows.Close()
+			return err
+		}
+		adjustments[id] = adjustmentID.Int64
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, adjustmentID := range adjustments {
+		if adjustmentID <= 0 {
+			continue
+		}
+		item, err := qtx.GetManualAdjustment(ctx, adjustmentID)
+		if err != nil {
+			return err
+		}
+		if item.Status != sqlc.ManualAdjustmentsStatusActive {
+			continue
+		}
+		if err := qtx.RevokeManualAdjustment(ctx, adjustmentID); err != nil {
+			return err
+		}
+		if err := h.revertManualAdjustment(ctx, tx, qtx, item); err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE leave_request_segments SET status = ? WHERE leave_request_id = ? AND status <> ?", leaveSegmentReverted, requestID, leaveSegmentReverted)
+	return err
+}
+
+type leavePeriod struct {
+	StartAt time.Time
+	EndAt   time.Time
+}
+
+// loadApprovedLeaves 读取与区间重叠的已批准请假，按员工分组。
+func loadApprovedLeaves(ctx context.Context, db sqlc.DBTX, start time.Time, end time.Time) (map[int64][]leavePeriod, error) {
+	rows, err := db.QueryContext(ctx, `SELECT employee_id, start_at, end_at FROM leave_requests
+WHERE status = 'approved' AND start_at < ? AND end_at > ?
+ORDER BY employee_id, start_at`, end, start)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	leaves := map[int64][]leavePeriod{}
+	for rows.Next() {
+		var employeeID int64
+		var item leavePeriod
+		if err := rows.Scan(&employeeID, &item.StartAt, &item.EndAt); err != nil {
+			return nil, err
+		}
+		leaves[employeeID] = append(leaves[employeeID], item)
+	}
+	return leaves, rows.Err()
+}
+
+// trimExpectedRangeByLeave 去掉应出勤时段首尾被请假覆盖的部分；完全覆盖时返回 false。
+func trimExpectedRangeByLeave(expectedStart time.Time, expectedEnd time.Time, leaves []leavePeriod) (time.Time, time.Time, bool) {
+	changed := true
+	for changed {
+		changed = false
+		for _, leave := range leaves {
+			if !leave.StartAt.After(expectedStart) && leave.EndAt.After(expectedStart) {
+				expectedStart = leave.EndAt
+				changed = true
+			}
+			if !leave.EndAt.Before(expectedEnd) && leave.StartAt.Before(expectedEnd) {
+				expectedEnd = leave.StartAt
+				changed = true
+			}
+			if !expectedEnd.After(expectedStart) {
+				return expectedStart, expectedEnd, false
+			}
+		}
+	}
+	return expectedStart, expectedEnd, true
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrimExpectedRangeByLeave(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	at := func(hour, minute int) time.Time {
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+	expectedStart, expectedEnd := at(9, 0), at(18, 0)
+	tests := []struct {
+		name      string
+		leaves    []leavePeriod
+		wantStart time.Time
+		wantEnd   time.Time
+		wantDuty  bool
+	}{
+		{name: "无请假", wantStart: at(9, 0), wantEnd: at(18, 0), wantDuty: true},
+		{name: "上午请假", leaves: []leavePeriod{{StartAt: at(9, 0), EndAt: at(12, 0)}}, wantStart: at(12, 0), wantEnd: at(18, 0), wantDuty: true},
+		{name: "提前开始的请假", leaves: []leavePeriod{{StartAt: at(8, 0), EndAt: at(10, 30)}}, wantStart: at(10, 30), wantEnd: at(18, 0), wantDuty: true},
+		{name: "下午请假", leaves: []leavePeriod{{StartAt: at(14, 0), EndAt: at(19, 0)}}, wantStart: at(9, 0), wantEnd: at(14, 0), wantDuty: true},
+		{name: "中间请假不裁剪", leaves: []leavePeriod{{StartAt: at(11, 0), EndAt: at(13, 0)}}, wantStart: at(9, 0), wantEnd: at(18, 0), wantDuty: true},
+		{name: "首尾都有请假", leaves: []leavePeriod{{StartAt: at(9, 0), EndAt: at(10, 0)}, {StartAt: at(17, 0), EndAt: at(18, 0)}}, wantStart: at(10, 0), wantEnd: at(17, 0), wantDuty: true},
+		{
+			name:      "相连的请假依次裁剪",
+			leaves:    []leavePeriod{{StartAt: at(10, 0), EndAt: at(12, 0)}, {StartAt: at(9, 0), EndAt: at(10, 0)}},
+			wantStart: at(12, 0), wantEnd: at(18, 0), wantDuty: true,
+		},
+		{name: "整天请假", leaves: []leavePeriod{{StartAt: at(0, 0), EndAt: at(23, 59)}}, wantDuty: false},
+		{name: "两段请假覆盖全天", leaves: []leavePeriod{{StartAt: at(9, 0), EndAt: at(13, 0)}, {StartAt: at(13, 0), EndAt: at(18, 0)}}, wantDuty: false},
+		{name: "请假在班次之外", leaves: []leavePeriod{{StartAt: at(19, 0), EndAt: at(21, 0)}}, wantStart: at(9, 0), wantEnd: at(18, 0), wantDuty: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, onDuty := trimExpectedRangeByLeave(expectedStart, expectedEnd, tt.leaves)
+			if onDuty != tt.wantDuty {
+				t.Fatalf("onDuty = %v，期望 %v", onDuty, tt.wantDuty)
+			}
+			if !tt.wantDuty {
+				return
+			}
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Fatalf("得到 %s-%s，期望 %s-%s", formatTime(start), formatTime(end), formatTime(tt.wantStart), formatTime(tt.wantEnd))
+			}
+		})
+	}
+}
+
+func TestLeaveWindowsMinutesByYear(t *testing.T) {
+	window := func(start time.Time, hours int) leaveWindow {
+		return leaveWindow{StartAt: start, EndAt: start.Add(time.Duration(hours) * time.Hour)}
+	}
+	windows := []leaveWindow{
+		window(time.Date(2023, 12, 29, 9, 0, 0, 0, time.Local), 8),
+		window(time.Date(2023, 12, 31, 22, 0, 0, 0, time.Local), 8),
+		window(time.Date(2024, 1, 2, 9, 0, 0, 0, time.Local), 8),
+		{StartAt: time.Date(2024, 1, 3, 9, 0, 0, 0, time.Local), EndAt: time.Date(2024, 1, 3, 9, 0, 30, 0, time.Local)},
+	}
+	got := leaveWindowsMinutesByYear(windows)
+	want := map[int]int32{2023: 16 * 60, 2024: 8*60 + 1}
+	if len(got) != len(want) {
+		t.Fatalf("得到 %v，期望 %v", got, want)
+	}
+	for year, minutes := range want {
+		if got[year] != minutes {
+			t.Fatalf("%d 年 = %d 分钟，期望 %d", year, got[year], minutes)
+		}
+	}
+	if total := leaveWindowsMinutes(windows); total != 24*60+1 {
+		t.Fatalf("合计 = %d 分钟，期望 %d", total, 24*60+1)
+	}
+}
@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"worksentry/internal/db/sqlc"
+)
+
+const (
+	leavePolicyAttendance = "attendance"
+	leavePolicyNone       = "none"
+)
+
+type LeaveTypePayload struct {
+	ID                 int64  `json:"id"`
+	Name               string `json:"name"`
+	CountPolicy        string `json:"countPolicy"`
+	AnnualQuotaMinutes int32  `json:"annualQuotaMinutes"`
+	Enabled            bool   `json:"enabled"`
+}
+
+type LeaveTypeView struct {
+	ID                 int64  `json:"id"`
+	Name               string `json:"name"`
+	CountPolicy        string `json:"countPolicy"`
+	CountPolicyLabel   string `json:"countPolicyLabel"`
+	AnnualQuotaMinutes int32  `json:"annualQuotaMinutes"`
+	Enabled            bool   `json:"enabled"`
+}
+
+type LeaveBalancePayload struct {
+	EmployeeID   int64 `json:"employeeId"`
+	LeaveTypeID  int64 `json:"leaveTypeId"`
+	Year         int   `json:"year"`
+	QuotaMinutes int32 `json:"quotaMinutes"`
+}
+
+type LeaveBalanceView struct {
+	EmployeeID       int64  `json:"employeeId"`
+	EmployeeCode     string `json:"employeeCode"`
+	Name             string `json:"name"`
+	LeaveTypeID      int64  `json:"leaveTypeId"`
+	LeaveType        string `json:"leaveType"`
+	Year             int    `json:"year"`
+	Unlimited        bool   `json:"unlimited"`
+	QuotaMinutes     int32  `json:"quotaMinutes"`
+	UsedMinutes      int32  `json:"usedMinutes"`
+	RemainingMinutes int32  `json:"remainingMinutes"`
+}
+
+type leaveType struct {
+	ID                 int64
+	Name               string
+	CountPolicy        string
+	AnnualQuotaMinutes int32
+	Enabled            bool
+}
+
+func (h *Handler) LeaveTypes(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		items, err := listLeaveTypes(r.Context(), h.DB, false)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "读取假期类型失败")
+			return
+		}
+		views := make([]LeaveTypeView, 0, len(items))
+		for _, item := range items {
+			views = append(views, buildLeaveTypeView(item))
+		}
+		writeJSON(w, http.StatusOK, views)
+	case http.MethodPost:
+		h.saveLeaveType(w, r, false)
+	case http.MethodPut:
+		h.saveLeaveType(w, r, true)
+	case http.MethodDelete:
+		h.deleteLeaveType(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+	}
+}
+
+func (h *Handler) saveLeaveType(w http.ResponseWriter, r *http.Request, update bool) {
+	var payload LeaveTypePayload
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "参数格式错误")
+		return
+	}
+	payload.Name = strings.TrimSpace(payload.Name)
+	payload.CountPolicy = strings.TrimSpace(payload.CountPolicy)
+	if payload.Name == "" {
+		writeError(w, http.StatusBadRequest, "假期名称不能为空")
+		return
+	}
+	if utf8.RuneCountInString(payload.Name) > 64 {
+		writeError(w, http.StatusBadRequest, "假期名称长度不能超过 64 个字符")
+		return
+	}
+	if payload.CountPolicy == "" {
+		payload.CountPolicy = leavePolicyAttendance
+	}
+	if payload.CountPolicy != leavePolicyAttendance && payload.CountPolicy != leavePolicyNone {
+		writeError(w, http.StatusBadRequest, "计入方式无效")
+		return
+	}
+	if payload.AnnualQuotaMinutes < 0 {
+		writeError(w, http.StatusBadRequest, "年度额度不能为负数")
+		return
+	}
+
+	if update {
+		if payload.ID <= 0 {
+			writeError(w, http.StatusBadRequest, "假期类型编号无效")
+			return
+		}
+		result, err := h.DB.ExecContext(r.Context(), `UPDATE leave_types SET name = ?, count_policy = ?, annual_quota_minutes = ?, enabled = ?
+WHERE id = ?`, payload.Name, payload.CountPolicy, payload.AnnualQuotaMinutes, payload.Enabled, payload.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "更新假期类型失败")
+			return
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			if _, err := getLeaveType(r.Context(), h.DB, payload.ID); err == sql.ErrNoRows {
+				writeError(w, http.StatusNotFound, "假期类型不存在")
+				return
+			}
+		}
+		h.logAudit(r, "update_leave_type", "leave_type", sql.NullInt64{Int64: payload.ID, Valid: true}, payload)
+		writeJSON(w, http.StatusOK, map[string]any{"id": payload.ID})
+		return
+	}
+
+	result, err := h.DB.ExecContext(r.Context(), `INSERT INTO leave_types (name, count_policy, annual_quota_minutes, enabled)
+VALUES (?, ?, ?, ?)`, payload.Name, payload.CountPolicy, payload.AnnualQuotaMinutes, payload.Enabled)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "创建假期类型失败")
+		return
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "创建假期类型失败")
+		return
+	}
+	h.logAudit(r, "create_leave_type", "leave_type", sql.NullInt64{Int64: id, Valid: true}, payload)
+	writeJSON(w, http.StatusOK, map[string]any{"id": id})
+}
+
+// deleteLeaveType 仅允许删除未被使用的假期类型，已有申请的类型请停用。
+func (h *Handler) deleteLeaveType(w http.ResponseWriter, r *http.Request) {
+	id := parseInt64(r.URL.Query().Get("id"))
+	if id <= 0 {
+		writeError(w, http.StatusBadRequest, "假期类型编号无效")
+		return
+	}
+	var used int64
+	if err := h.DB.QueryRowContext(r.Context(), "SELECT COUNT(1) FROM leave_requests WHERE leave_type_id = ?", id).Scan(&used); err != nil {
+		writeError(w, http.StatusInternalServerError, "删除假期类型失败")
+		return
+	}
+	if used > 0 {
+		writeError(w, http.StatusConflict, "该假期类型已有申请，请改为停用")
+		return
+	}
+	if _, err := h.DB.ExecContext(r.Context(), "DELETE FROM leave_balances WHERE leave_type_id = ?", id); err != nil {
+		writeError(w, http.StatusInternalServerError, "删除假期类型失败")
+		return
+	}
+	if _, err := h.DB.ExecContext(r.Context(), "DELETE FROM leave_types WHERE id = ?", id); err != nil {
+		writeError(w, http.StatusInternalServerError, "删除假期类型失败")
+		return
+	}
+	h.logAudit(r, "delete_leave_type", "leave_type", sql.NullInt64{Int64: id, Valid: true}, nil)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "已删除"})
+}
+
+// LeaveBalances 查询或设置员工年度假期额度；未单独设置时沿用假期类型的年度额度。
+func (h *Handler) LeaveBalances(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		employeeID := parseInt64(r.URL.Query().Get("employeeId"))
+		if employeeID <= 0 {
+			writeError(w, http.StatusBadRequest, "员工编号无效")
+			return
+		}
+		year := parseInt(r.URL.Query().Get("year"), time.Now().Year())
+		items, err := h.listLeaveBalances(r.Context(), employeeID, year)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "读取假期余额失败")
+			return
+		}
+		writeJSON(w, http.StatusOK, items)
+	case http.MethodPut:
+		h.saveLeaveBalance(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+	}
+}
+
+func (h *Handler) saveLeaveBalance(w http.ResponseWriter, r *http.Request) {
+	var payload LeaveBalancePayload
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "参数格式错误")
+		return
+	}
+	if payload.EmployeeID <= 0 || payload.LeaveTypeID <= 0 {
+		writeError(w, http.StatusBadRequest, "员工或假期类型无效")
+		return
+	}
+	if payload.Year < 2000 || payload.Year > 2100 {
+		writeError(w, http.StatusBadRequest, "年份无效")
+		return
+	}
+	if payload.QuotaMinutes < 0 {
+		writeError(w, http.StatusBadRequest, "额度不能为负数")
+		return
+	}
+	if _, err := h.Queries.GetEmployeeByID(r.Context(), payload.EmployeeID); err != nil {
+		writeError(w, http.StatusNotFound, "员工不存在")
+		return
+	}
+	if _, err := getLeaveType(r.Context(), h.DB, payload.LeaveTypeID); err != nil {
+		writeError(w, http.StatusNotFound, "假期类型不存在")
+		return
+	}
+	if _, err := h.DB.ExecContext(r.Context(), `INSERT INTO leave_balances (employee_id, leave_type_id, year, quota_minutes, used_minutes)
+VALUES (?, ?, ?, ?, 0)
+ON DUPLICATE KEY UPDATE quota_minutes = VALUES(quota_minutes)`, payload.EmployeeID, payload.LeaveTypeID, payload.Year, payload.QuotaMinutes); err != nil {
+		writeError(w, http.StatusInternalServerError, "保存假期额度失败")
+		return
+	}
+	h.logAudit(r, "update_leave_balance", "employee", sql.NullInt64{Int64: payload.EmployeeID, Valid: true}, payload)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "保存成功"})
+}
+
+// listLeaveBalances 返回员工在指定年度各启用假期类型的额度与已用情况。
+func (h *Handler) listLeaveBalances(ctx context.Context, employeeID int64, year int) ([]LeaveBalanceView, error) {
+	rows, err := h.DB.QueryContext(ctx, `SELECT e.id, e.employee_code, e.name, t.id, t.name, t.annual_quota_minutes, b.quota_minutes, COALESCE(b.used_minutes, 0)
+FROM employees e
+JOIN leave_types t ON t.enabled = 1
+LEFT JOIN leave_balances b ON b.employee_id = e.id AND b.leave_type_id = t.id AND b.year = ?
+WHERE e.id = ?
+ORDER BY t.id`, year, employeeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := make([]LeaveBalanceView, 0)
+	for rows.Next() {
+		var item LeaveBalanceView
+		var typeQuota int32
+		var quota sql.NullInt32
+		if err := rows.Scan(&item.EmployeeID, &item.EmployeeCode, &item.Name, &item.LeaveTypeID, &item.LeaveType, &typeQuota, &quota, &item.UsedMinutes); err != nil {
+			return nil, err
+		}
+		item.Year = year
+		item.QuotaMinutes, item.Unlimited = effectiveLeaveQuota(typeQuota, quota)
+		if !item.Unlimited {
+			item.RemainingMinutes = item.QuotaMinutes - item.UsedMinutes
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// effectiveLeaveQuota 员工单独设置的额度优先；假期类型额度为 0 且未单独设置时视为不限额。
+func effectiveLeaveQuota(typeQuota int32, quota sql.NullInt32) (int32, bool) {
+	if quota.Valid {
+		return quota.Int32, false
+	}
+	return typeQuota, typeQuota == 0
+}
+
+func listLeaveTypes(ctx context.Context, db sqlc.DBTX, enabledOnly bool) ([]leaveType, error) {
+	query := "SELECT id, name, count_policy, annual_quota_minutes, enabled FROM leave_types"
+	if enabledOnly {
+		query += " WHERE enabled = 1"
+	}
+	rows, err := db.QueryContext(ctx, query+" ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := make([]leaveType, 0)
+	for rows.Next() {
+		var item leaveType
+		if err := rows.Scan(&item.ID, &item.Name, &item.CountPolicy, &item.AnnualQuotaMinutes, &item.Enabled); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func getLeaveType(ctx context.Context, db sqlc.DBTX, id int64) (leaveType, error) {
+	var item leaveType
+	err := db.QueryRowContext(ctx, "SELECT id, name, count_policy, annual_quota_minutes, enabled FROM leave_types WHERE id = ?", id).
+		Scan(&item.ID, &item.Name, &item.CountPolicy, &item.AnnualQuotaMinutes, &item.Enabled)
+	return item, err
+}
+
+func buildLeaveTypeView(item leaveType) LeaveTypeView {
+	return LeaveTypeView{
+		ID:                 item.ID,
+		Name:               item.Name,
+		CountPolicy:        item.CountPolicy,
+		CountPolicyLabel:   leavePolicyLabel(item.CountPolicy),
+		AnnualQuotaMinutes: item.AnnualQuotaMinutes,
+		Enabled:            item.Enabled,
+	}
+}
+
+// leaveSegmentStatus 按计入方式决定请假时间段的状态，不计出勤的假期单独记为 leave_unpaid。
+func leaveSegmentStatus(policy string) string {
+	if policy == leavePolicyNone {
+		return "leave_unpaid"
+	}
+	return "leave"
+}
+
+func leavePolicyLabel(policy string) string {
+	switch policy {
+	case leavePolicyAttendance:
+		return "计入出勤"
+	case leavePolicyNone:
+		return "不计出勤"
+	default:
+		return "未知"
+	}
+}
@@ -0,0 +1,502 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// exportPlan 描述一次导出：文件名、工作表、列定义以及逐行转换的查询，同步下载与异步导出任务共用。
+// 一条记录需要展开为多行时使用 Expand 代替 Convert。
+type exportPlan struct {
+	Name    string
+	Sheet   string
+	Columns []exportColumn
+	Query   string
+	Args    []any
+	Convert func(*sql.Rows) ([]any, error)
+	Expand  func(*sql.Rows) ([][]any, error)
+}
+
+// exportPlanBuilder 根据查询参数构造导出计划，参数错误时返回 HTTP 状态码与提示。
+type exportPlanBuilder func(ctx context.Context, params url.Values) (exportPlan, int, string)
+
+// exportKinds 异步导出任务支持的导出类型，参数与对应列表接口一致。
+func (h *Handler) exportKinds() map[string]exportPlanBuilder {
+	return map[string]exportPlanBuilder{
+		"daily":                   h.dailyStatsExport,
+		"timeline":                h.timelineExport,
+		"offline_segments":        h.offlineSegmentsExport,
+		"manual_adjustments":      h.manualAdjustmentsExport,
+		"audit_logs":              h.auditLogsExport,
+		"checkout_records":        h.checkoutRecordsExport,
+		"work_session_reviews":    h.workSessionReviewsExport,
+		"work_session_violations": h.workSessionViolationsExport,
+	}
+}
+
+// exportIfRequested 列表接口带 format 参数时改为流式导出全部匹配记录（不分页），返回 true 表示已写出响应。
+func (h *Handler) exportIfRequested(w http.ResponseWriter, r *http.Request, build exportPlanBuilder) bool {
+	format, message := exportFormat(r.URL.Query(), "")
+	if message != "" {
+		writeError(w, http.StatusBadRequest, message)
+		return true
+	}
+	if format == "" {
+		return false
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return true
+	}
+	plan, status, message := build(r.Context(), r.URL.Query())
+	if message != "" {
+		writeError(w, status, message)
+		return true
+	}
+	h.streamExport(w, r, format, plan)
+	return true
+}
+
+// serveExport 独立导出接口，未指定 format 时导出 xlsx。
+func (h *Handler) serveExport(w http.ResponseWriter, r *http.Request, build exportPlanBuilder) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	format, message := exportFormat(r.URL.Query(), exportFormatXLSX)
+	if message != "" {
+		writeError(w, http.StatusBadRequest, message)
+		return
+	}
+	if format == "" {
+		writeError(w, http.StatusBadRequest, "导出格式仅支持 csv、ndjson、xlsx")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	plan, status, message := build(r.Context(), r.URL.Query())
+	if message != "" {
+		writeError(w, status, message)
+		return
+	}
+	h.streamExport(w, r, format, plan)
+}
+
+// streamExport 执行查询后逐行转换并写出；查询失败时仍可返回错误，开始写出后出错只能记录日志并中断。
+func (h *Handler) streamExport(w http.ResponseWriter, r *http.Request, format string, plan exportPlan) {
+	rows, err := h.DB.QueryContext(r.Context(), plan.Query, plan.Args...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "导出失败")
+		return
+	}
+	defer rows.Close()
+
+	writer, err := newExportWriter(w, format, plan.Name, plan.Sheet, plan.Columns)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "导出失败")
+		return
+	}
+	if _, err := writeExportRows(rows, plan, writer, nil); err != nil {
+		log.Printf("导出中断: %s %v", plan.Name, err)
+		return
+	}
+	if err := writer.Close(); err != nil {
+		log.Printf("导出失败: %s %v", plan.Name, err)
+	}
+}
+
+// writeExportRows 逐行转换写出并返回写出的行数，Convert 返回 nil 表示跳过该行。
+// progress 非空时每读取一批记录回调一次已读取的记录数，与导出任务开始时统计的记录总数对应。
+func writeExportRows(rows *sql.Rows, plan exportPlan, writer exportWriter, progress func(int64)) (int64, error) {
+	expand := plan.Expand
+	if expand == nil {
+		expand = func(rows *sql.Rows) ([][]any, error) {
+			values, err := plan.Convert(rows)
+			if err != nil || values == nil {
+				return nil, err
+			}
+			return [][]any{values}, nil
+		}
+	}
+	var read, written int64
+	for rows.Next() {
+		items, err := expand(rows)
+		if err != nil {
+			return written, err
+		}
+		for _, values := range items {
+			if err := writer.WriteRow(values); err != nil {
+				return written, err
+			}
+			written++
+		}
+		read++
+		if progress != nil && read%exportFlushRows == 0 {
+			progress(read)
+		}
+	}
+	return written, rows.Err()
+}
+
+var dailyStatsExportColumns = []exportColumn{
+	{Key: "date", Label: "日期"},
+	{Key: "employeeCode", Label: "工号"},
+	{Key: "name", Label: "姓名"},
+	{Key: "department", Label: "部门"},
+	{Key: "workDuration", Label: "工作时长"},
+	{Key: "normalDuration", Label: "常规时长"},
+	{Key: "fishDuration", Label: "摸鱼时长"},
+	{Key: "idleDuration", Label: "离开时长"},
+	{Key: "offlineDuration", Label: "离线时长"},
+	{Key: "attendanceDuration", Label: "在岗时长"},
+	{Key: "effectiveDuration", Label: "有效工时"},
+	{Key: "leaveDuration", Label: "请假时长"},
+	{Key: "shift", Label: "班次"},
+}
+
+// dailyStatsExport 每人每天一行，支持 date 或 startDate/endDate 区间。
+func (h *Handler) dailyStatsExport(ctx context.Context, params url.Values) (exportPlan, int, string) {
+	start, end, message := parseExportDateRange(params)
+	if message != "" {
+		return exportPlan{}, http.StatusBadRequest, message
+	}
+	clause, clauseArgs, err := h.departmentFilter(ctx, "e.department_id", parseInt64(params.Get("departmentId")))
+	if err != nil {
+		return exportPlan{}, http.StatusInternalServerError, "读取部门失败"
+	}
+	query := `SELECT ds.stat_date, e.employee_code, e.name, COALESCE(d.name, ''), ds.work_seconds, ds.normal_seconds, ds.fish_seconds,
+ ds.idle_seconds, ds.offline_seconds, ds.attendance_seconds, ds.effective_seconds, ds.leave_seconds, COALESCE(s.name, '')
+FROM daily_stats ds
+JOIN employees e ON ds.employee_id = e.id
+LEFT JOIN departments d ON e.department_id = d.id
+LEFT JOIN work_shifts s ON s.id = COALESCE(e.shift_id, d.shift_id) AND s.enabled = 1
+WHERE ds.stat_date >= ? AND ds.stat_date < ?` + clause + " ORDER BY ds.stat_date, e.employee_code"
+	args := append([]any{start.Format("2006-01-02"), end.Format("2006-01-02")}, clauseArgs...)
+
+	return exportPlan{
+		Name:    "daily",
+		Sheet:   "日报表",
+		Columns: dailyStatsExportColumns,
+		Query:   query,
+		Args:    args,
+		Convert: func(rows *sql.Rows) ([]any, error) {
+			var date time.Time
+			var code, name, department, shift string
+			var values DailyStatsValues
+			if err := rows.Scan(&date, &code, &name, &department, &values.WorkSeconds, &values.NormalSeconds, &values.FishSeconds, &values.IdleSeconds,
+				&values.OfflineSeconds, &values.AttendanceSeconds, &values.EffectiveSeconds, &values.LeaveSeconds, &shift); err != nil {
+				return nil, err
+			}
+			return []any{date.Format("2006-01-02"), code, name, department, formatDuration(values.WorkSeconds), formatDuration(values.NormalSeconds),
+				formatDuration(values.FishSeconds), formatDuration(values.IdleSeconds), formatDuration(values.OfflineSeconds),
+				formatDuration(values.AttendanceSeconds), formatDuration(values.EffectiveSeconds), formatDuration(values.LeaveSeconds), shift}, nil
+		},
+	}, 0, ""
+}
+
+// timelineExport 导出区间内的时间段，跨零点班次按业务日取数。未指定工号时导出全部（或 departmentId 下）员工，
+// 数据量较大，适合通过导出任务异步生成。
+func (h *Handler) timelineExport(ctx context.Context, params url.Values) (exportPlan, int, string) {
+	start, end, message := parseExportDateRange(params)
+	if message != "" {
+		return exportPlan{}, http.StatusBadRequest, message
+	}
+	columns := []exportColumn{
+		{Key: "employeeCode", Label: "工号"},
+		{Key: "name", Label: "姓名"},
+		{Key: "status", Label: "状态"},
+		{Key: "startAt", Label: "开始时间"},
+		{Key: "endAt", Label: "结束时间"},
+		{Key: "duration", Label: "时长"},
+		{Key: "description", Label: "描述"},
+		{Key: "source", Label: "来源"},
+	}
+	if code := strings.TrimSpace(params.Get("employeeCode")); code != "" {
+		employee, err := h.Queries.GetEmployeeByCode(ctx, code)
+		if err != nil {
+			return exportPlan{}, http.StatusNotFound, "员工不存在"
+		}
+		offset, err := h.employeeDayOffset(ctx, employee.ID)
+		if err != nil {
+			return exportPlan{}, http.StatusInternalServerError, "读取班次失败"
+		}
+		query := `SELECT start_at, end_at, status, COALESCE(description, ''), source
+FROM time_segments
+WHERE employee_id = ? AND start_at < ? AND end_at > ?
+ORDER BY start_at`
+		return exportPlan{
+			Name:    "timeline_" + employee.EmployeeCode,
+			Sheet:   "时间轴",
+			Columns: columns,
+			Query:   query,
+			Args:    []any{employee.ID, end.Add(offset), start.Add(offset)},
+			Convert: func(rows *sql.Rows) ([]any, error) {
+				var startAt, endAt time.Time
+				var status, description, source string
+				if err := rows.Scan(&startAt, &endAt, &status, &description, &source); err != nil {
+					return nil, err
+				}
+				return []any{employee.EmployeeCode, employee.Name, statusLabel(status), formatTime(startAt), formatTime(endAt),
+					formatDuration(int64(endAt.Sub(startAt).Seconds())), description, sourceLabel(source)}, nil
+			},
+		}, 0, ""
+	}
+
+	offsets, err := loadEmployeeDayOffsets(ctx, h.Queries)
+	if err != nil {
+		return exportPlan{}, http.StatusInternalServerError, "读取班次失败"
+	}
+	var maxOffset time.Duration
+	for _, offset := range offsets {
+		if offset > maxOffset {
+			maxOffset = offset
+		}
+	}
+	clause, clauseArgs, err := h.departmentFilter(ctx, "e.department_id", parseInt64(params.Get("departmentId")))
+	if err != nil {
+		return exportPlan{}, http.StatusInternalServerError, "读取部门失败"
+	}
+	// 按最大班次偏移放宽查询区间，再逐行按员工自己的偏移过滤
+	query := `SELECT e.id, e.employee_code, e.name, ts.start_at, ts.end_at, ts.status, COALESCE(ts.description, ''), ts.source
+FROM time_segments ts
+JOIN employees e ON ts.employee_id = e.id
+WHERE ts.start_at < ? AND ts.end_at > ?` + clause + " ORDER BY e.employee_code, ts.start_at"
+	args := append([]any{end.Add(maxOffset), start}, clauseArgs...)
+	return exportPlan{
+		Name:    "timeline",
+		Sheet:   "时间轴",
+		Columns: columns,
+		Query:   query,
+		Args:    args,
+		Convert: func(rows *sql.Rows) ([]any, error) {
+			var employeeID int64
+			var code, name, status, description, source string
+			var startAt, endAt time.Time
+			if err := rows.Scan(&employeeID, &code, &name, &startAt, &endAt, &status, &description, &source); err != nil {
+				return nil, err
+			}
+			offset := offsets[employeeID]
+			if !startAt.Before(end.Add(offset)) || !endAt.After(start.Add(offset)) {
+				return nil, nil
+			}
+			return []any{code, name, statusLabel(status), formatTime(startAt), formatTime(endAt),
+				formatDuration(int64(endAt.Sub(startAt).Seconds())), description, sourceLabel(source)}, nil
+		},
+	}, 0, ""
+}
+
+func (h *Handler) offlineSegmentsExport(ctx context.Context, params url.Values) (exportPlan, int, string) {
+	start, end, message := parseExportDateRange(params)
+	if message != "" {
+		return exportPlan{}, http.StatusBadRequest, message
+	}
+	query := `SELECT e.employee_code, e.name, COALESCE(d.name, ''), ts.start_at, ts.end_at
+FROM time_segments ts
+JOIN employees e ON ts.employee_id = e.id
+LEFT JOIN departments d ON e.department_id = d.id
+WHERE ts.status = 'offline' AND ts.start_at < ? AND ts.end_at > ?`
+	args := []any{end, start}
+	if code := strings.TrimSpace(params.Get("employeeCode")); code != "" {
+		query += " AND e.employee_code = ?"
+		args = append(args, code)
+	}
+	clause, clauseArgs, err := h.departmentFilter(ctx, "e.department_id", parseInt64(params.Get("departmentId")))
+	if err != nil {
+		return exportPlan{}, http.StatusInternalServerError, "读取部门失败"
+	}
+	query += clause + " ORDER BY ts.start_at"
+	args = append(args, clauseArgs...)
+
+	columns := []exportColumn{
+		{Key: "employeeCode", Label: "工号"},
+		{Key: "name", Label: "姓名"},
+		{Key: "department", Label: "部门"},
+		{Key: "startAt", Label: "开始时间"},
+		{Key: "endAt", Label: "结束时间"},
+		{Key: "duration", Label: "时长"},
+	}
+	return exportPlan{
+		Name:    "offline_segments",
+		Sheet:   "离线段",
+		Columns: columns,
+		Query:   query,
+		Args:    args,
+		Convert: func(rows *sql.Rows) ([]any, error) {
+			var code, name, department string
+			var startAt, endAt time.Time
+			if err := rows.Scan(&code, &name, &department, &startAt, &endAt); err != nil {
+				return nil, err
+			}
+			return []any{code, name, department, formatTime(startAt), formatTime(endAt), formatDuration(int64(endAt.Sub(startAt).Seconds()))}, nil
+		},
+	}, 0, ""
+}
+
+// manualAdjustmentsExport 按补录开始时间筛选区间。
+func (h *Handler) manualAdjustmentsExport(ctx context.Context, params url.Values) (exportPlan, int, string) {
+	start, end, message := parseExportDateRange(params)
+	if message != "" {
+		return exportPlan{}, http.StatusBadRequest, message
+	}
+	query := `SELECT ma.id, e.employee_code, e.name, COALESCE(d.name, ''), ma.start_at, ma.end_at, ma.target_status, COALESCE(ma.label, ''),
+ ma.mode, ma.reason, ma.note, ma.status, ma.created_at
+FROM manual_adjustments ma
+JOIN employees e ON ma.employee_id = e.id
+LEFT JOIN departments d ON e.department_id = d.id
+WHERE ma.start_at >= ? AND ma.start_at < ?`
+	args := []any{start, end}
+	clause, clauseArgs, err := h.departmentFilter(ctx, "e.department_id", parseInt64(params.Get("departmentId")))
+	if err != nil {
+		return exportPlan{}, http.StatusInternalServerError, "读取部门失败"
+	}
+	query += clause + " ORDER BY ma.start_at, ma.id"
+	args = append(args, clauseArgs...)
+
+	columns := []exportColumn{
+		{Key: "id", Label: "编号"},
+		{Key: "employeeCode", Label: "工号"},
+		{Key: "name", Label: "姓名"},
+		{Key: "department", Label: "部门"},
+		{Key: "startAt", Label: "开始时间"},
+		{Key: "endAt", Label: "结束时间"},
+		{Key: "targetStatus", Label: "补录状态"},
+		{Key: "label", Label: "标签"},
+		{Key: "mode", Label: "方式"},
+		{Key: "reason", Label: "原因"},
+		{Key: "note", Label: "备注"},
+		{Key: "status", Label: "状态"},
+		{Key: "createdAt", Label: "创建时间"},
+	}
+	return exportPlan{
+		Name:    "manual_adjustments",
+		Sheet:   "补录记录",
+		Columns: columns,
+		Query:   query,
+		Args:    args,
+		Convert: func(rows *sql.Rows) ([]any, error) {
+			var id int64
+			var code, name, department, targetStatus, label, mode, reason, note, status string
+			var startAt, endAt, createdAt time.Time
+			if err := rows.Scan(&id, &code, &name, &department, &startAt, &endAt, &targetStatus, &label, &mode, &reason, &note, &status, &createdAt); err != nil {
+				return nil, err
+			}
+			return []any{id, code, name, department, formatTime(startAt), formatTime(endAt), statusLabel(targetStatus), label,
+				manualModeLabel(mode), reason, note, manualStatusLabel(status), formatTime(createdAt)}, nil
+		},
+	}, 0, ""
+}
+
+func (h *Handler) auditLogsExport(ctx context.Context, params url.Values) (exportPlan, int, string) {
+	start, end, message := parseExportDateRange(params)
+	if message != "" {
+		return exportPlan{}, http.StatusBadRequest, message
+	}
+	query := `SELECT a.operator_id, COALESCE(u.display_name, ''), a.action, a.target_type, a.target_id, a.detail, a.created_at
+FROM audit_logs a
+LEFT JOIN admin_users u ON a.operator_id = u.id
+WHERE a.created_at >= ? AND a.created_at < ?
+ORDER BY a.created_at, a.id`
+	columns := []exportColumn{
+		{Key: "createdAt", Label: "时间"},
+		{Key: "operator", Label: "操作人"},
+		{Key: "action", Label: "操作"},
+		{Key: "targetType", Label: "对象类型"},
+		{Key: "targetId", Label: "对象编号"},
+		{Key: "detail", Label: "详情"},
+	}
+	return exportPlan{
+		Name:    "audit_logs",
+		Sheet:   "审计日志",
+		Columns: columns,
+		Query:   query,
+		Args:    []any{start, end},
+		Convert: func(rows *sql.Rows) ([]any, error) {
+			var operatorID int64
+			var operator, action, targetType string
+			var targetID sql.NullInt64
+			var detail []byte
+			var createdAt time.Time
+			if err := rows.Scan(&operatorID, &operator, &action, &targetType, &targetID, &detail, &createdAt); err != nil {
+				return nil, err
+			}
+			if operator == "" {
+				operator = auditOperator(operatorID)
+			}
+			var target any
+			if targetID.Valid {
+				target = targetID.Int64
+			}
+			text := string(detail)
+			var compact any
+			if err := json.Unmarshal(detail, &compact); err == nil {
+				if b, err := json.Marshal(compact); err == nil {
+					text = string(b)
+				}
+			}
+			return []any{formatTime(createdAt), operator, action, targetType, target, text}, nil
+		},
+	}, 0, ""
+}
+
+func (h *Handler) workSessionReviewsExport(ctx context.Context, params url.Values) (exportPlan, int, string) {
+	where, args, status, message := h.workSessionReviewFilter(ctx, params)
+	if message != "" {
+		return exportPlan{}, status, message
+	}
+	query := `SELECT r.id, r.work_date, e.employee_code, e.name, COALESCE(d.name, ''), ws.start_at, ws.end_at,
+ r.work_standard_seconds, r.break_seconds, r.need_reason, r.reason, r.violations_json
+FROM work_session_reviews r
+JOIN employees e ON r.employee_id = e.id
+LEFT JOIN departments d ON e.department_id = d.id
+LEFT JOIN work_sessions ws ON r.work_session_id = ws.id ` + where + " ORDER BY r.work_date, r.id"
+	columns := []exportColumn{
+		{Key: "id", Label: "编号"},
+		{Key: "workDate", Label: "日期"},
+		{Key: "employeeCode", Label: "工号"},
+		{Key: "name", Label: "姓名"},
+		{Key: "department", Label: "部门"},
+		{Key: "startAt", Label: "上班时间"},
+		{Key: "endAt", Label: "下班时间"},
+		{Key: "workStandard", Label: "标准工时"},
+		{Key: "breakDuration", Label: "休息时长"},
+		{Key: "violationSummary", Label: "违规情况"},
+		{Key: "reasonStatus", Label: "补录状态"},
+		{Key: "reason", Label: "原因"},
+	}
+	return exportPlan{
+		Name:    "work_session_reviews",
+		Sheet:   "上班考核",
+		Columns: columns,
+		Query:   query,
+		Args:    args,
+		Convert: func(rows *sql.Rows) ([]any, error) {
+			var id int64
+			var workDate time.Time
+			var code, name, department, violationsJSON string
+			var startAt, endAt sql.NullTime
+			var workSeconds, breakSeconds int64
+			var needReason bool
+			var reason sql.NullString
+			if err := rows.Scan(&id, &workDate, &code, &name, &department, &startAt, &endAt, &workSeconds, &breakSeconds, &needReason, &reason, &violationsJSON); err != nil {
+				return nil, err
+			}
+			reasonStatus := "无需补录"
+			if needReason {
+				reasonStatus = "未补录"
+				if strings.TrimSpace(reason.String) != "" {
+					reasonStatus = "已补录"
+				}
+			}
+			return []any{id, workDate.Format("2006-01-02"), code, name, department, nullTimeText(startAt), nullTimeText(endAt),
+				formatDuration(workSeconds), formatDuration(breakSeconds), buildViolationSummary(violationsJSON), reasonStatus, reason.String}, nil
+		},
+	}, 0, ""
+}
@@ -27,12 +27,27 @@ func (h *Handler) buildLiveSnapshot(r *http.Request) []LiveView {
     if err != nil {
         return []LiveView{}
     }
+    // departmentId 筛选包含下级部门
+    var scope map[int64]bool
+    if departmentID := parseInt64(r.URL.Query().Get("departmentId")); departmentID > 0 {
+        ids, err := h.departmentScope(r.Context(), departmentID)
+        if err != nil {
+            return []LiveView{}
+        }
+        scope = make(map[int64]bool, len(ids))
+        for _, id := range ids {
+            scope[id] = true
+        }
+    }
 
     settings := h.getSettingsOrDefault(r)
     now := time.Now()
 
     items := make([]LiveView, 0, len(rows))
     for _, row := range rows {
+        if scope != nil && !scope[row.DepartmentID.Int64] {
+            continue
+        }
         isWorking := row.IsWorking > 0
         status := "offwork"
         delaySeconds := int64(0)
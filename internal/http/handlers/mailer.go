@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"worksentry/internal/config"
+)
+
+const mailTimeout = 30 * time.Second
+
+type mailAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+type mailMessage struct {
+	To          []string
+	Subject     string
+	Body        string
+	HTML        bool // 正文按 text/html 发送
+	Attachments []mailAttachment
+}
+
+// parseMailRecipients 拆分以逗号、分号或空白分隔的收件人。
+func parseMailRecipients(value string) []string {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ';' || r == '，' || r == '；' || r == ' ' || r == '\n' || r == '\t'
+	})
+	recipients := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if field = strings.TrimSpace(field); field != "" {
+			recipients = append(recipients, field)
+		}
+	}
+	return recipients
+}
+
+func validMailAddress(address string) bool {
+	at := strings.LastIndex(address, "@")
+	return at > 0 && at < len(address)-1 && !strings.ContainsAny(address, "<>\r\n\"")
+}
+
+// sendMail 通过配置的 SMTP 服务器发送邮件，可带附件。
+// 整个会话最长 mailTimeout，ctx 取消或到期时立即断开连接。
+func sendMail(ctx context.Context, cfg config.SMTPConfig, msg mailMessage) error {
+	if cfg.Host == "" {
+		return errors.New("未配置邮件服务器")
+	}
+	if len(msg.To) == 0 {
+		return errors.New("收件人为空")
+	}
+	from := cfg.From
+	if from == "" {
+		from = cfg.Username
+	}
+	port := cfg.Port
+	if port <= 0 {
+		port = 25
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+
+	ctx, cancel := context.WithTimeout(ctx, mailTimeout)
+	defer cancel()
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{}
+	if cfg.TLS {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: cfg.Host}}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	// 服务停止时中断阻塞中的读写
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	client, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if !cfg.TLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: cfg.Host}); err != nil {
+				return err
+			}
+		}
+	}
+	if cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(buildMailBody(from, msg)); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func buildMailBody(from string, msg mailMessage) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+
+	if len(msg.Attachments) == 0 {
+		fmt.Fprintf(&buf, "Content-Type: %s; charset=UTF-8\r\n", msg.contentType())
+		buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+		writeBase64Lines(&buf, []byte(msg.Body))
+		return buf.Bytes()
+	}
+
+	boundary := mailBoundary()
+	fmt.Fprintf(&buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+	fmt.Fprintf(&buf, "--%s\r\n", boundary)
+	fmt.Fprintf(&buf, "Content-Type: %s; charset=UTF-8\r\n", msg.contentType())
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	writeBase64Lines(&buf, []byte(msg.Body))
+	for _, attachment := range msg.Attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		filename := mime.BEncoding.Encode("UTF-8", attachment.Filename)
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		fmt.Fprintf(&buf, "Content-Type: %s; name=%q\r\n", contentType, filename)
+		buf.WriteString("Content-Transfer-Encoding: base64\r\n")
+		fmt.Fprintf(&buf, "Content-Disposition: attachment; filename=%q\r\n\r\n", filename)
+		writeBase64Lines(&buf, attachment.Data)
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+	return buf.Bytes()
+}
+
+func (m mailMessage) contentType() string {
+	if m.HTML {
+		return "text/html"
+	}
+	return "text/plain"
+}
+
+// writeBase64Lines 按 RFC 2045 每行 76 个字符折行。
+func writeBase64Lines(buf *bytes.Buffer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76])
+		buf.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded)
+	buf.WriteString("\r\n")
+}
+
+func mailBoundary() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return "worksentry-" + hex.EncodeToString(b)
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"mime"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"worksentry/internal/config"
+)
+
+// fakeMail 本地 SMTP 替身收到的一封邮件。
+type fakeMail struct {
+	From string
+	To   []string
+	Data string
+}
+
+// startFakeSMTP 在本地端口启动只支持明文会话的 SMTP 替身，收到的邮件写入返回的通道。
+func startFakeSMTP(t *testing.T) (config.SMTPConfig, <-chan fakeMail) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("无法监听本地端口: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	mails := make(chan fakeMail, 16)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeSMTP(conn, mails)
+		}
+	}()
+	addr := listener.Addr().(*net.TCPAddr)
+	return config.SMTPConfig{Host: "127.0.0.1", Port: addr.Port, From: "worksentry@example.com"}, mails
+}
+
+func serveFakeSMTP(conn net.Conn, mails chan<- fakeMail) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	_ = text.PrintfLine("220 fake ESMTP")
+	var mail fakeMail
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			_ = text.PrintfLine("250 fake")
+		case strings.HasPrefix(command, "MAIL FROM:"):
+			mail = fakeMail{From: strings.Trim(line[len("MAIL FROM:"):], "<> ")}
+			_ = text.PrintfLine("250 OK")
+		case strings.HasPrefix(command, "RCPT TO:"):
+			mail.To = append(mail.To, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			_ = text.PrintfLine("250 OK")
+		case command == "DATA":
+			_ = text.PrintfLine("354 end with .")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			mail.Data = string(data)
+			mails <- mail
+			_ = text.PrintfLine("250 queued")
+		case command == "QUIT":
+			_ = text.PrintfLine("221 bye")
+			return
+		default:
+			_ = text.PrintfLine("250 OK")
+		}
+	}
+}
+
+// TestSendMailFakeSMTP 通过本地 SMTP 替身验证发件人、收件人、标题编码与附件。
+func TestSendMailFakeSMTP(t *testing.T) {
+	cfg, mails := startFakeSMTP(t)
+	err := sendMail(context.Background(), cfg, mailMessage{
+		To:          []string{"a@example.com", "b@example.com"},
+		Subject:     "日报 2024-03-01",
+		Body:        "<p>正文</p>",
+		HTML:        true,
+		Attachments: []mailAttachment{{Filename: "日报.xlsx", Data: []byte("xlsx")}},
+	})
+	if err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	var mail fakeMail
+	select {
+	case mail = <-mails:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SMTP 替身未收到邮件")
+	}
+	if mail.From != cfg.From {
+		t.Fatalf("发件人 = %q，期望 %q", mail.From, cfg.From)
+	}
+	if strings.Join(mail.To, ",") != "a@example.com,b@example.com" {
+		t.Fatalf("收件人 = %v", mail.To)
+	}
+	header, err := textproto.NewReader(bufio.NewReader(strings.NewReader(mail.Data))).ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("解析邮件头失败: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(header.Get("Subject"))
+	if err != nil || subject != "日报 2024-03-01" {
+		t.Fatalf("标题 = %q（%v）", subject, err)
+	}
+	if !strings.HasPrefix(header.Get("Content-Type"), "multipart/mixed") {
+		t.Fatalf("Content-Type = %q，期望 multipart/mixed", header.Get("Content-Type"))
+	}
+	if !strings.Contains(mail.Data, "Content-Disposition: attachment") {
+		t.Fatal("邮件缺少附件")
+	}
+}
+
+// TestSendMailHonoursContext 服务器接受连接后不响应时，sendMail 应在 ctx 到期后立即返回。
+func TestSendMailHonoursContext(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("无法监听本地端口: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	cfg := config.SMTPConfig{Host: "127.0.0.1", Port: addr.Port, From: "worksentry@example.com"}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	err = sendMail(ctx, cfg, mailMessage{To: []string{"admin@example.com"}, Subject: "测试", Body: "正文"})
+	if err == nil {
+		t.Fatal("服务器未响应时应返回错误")
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Fatalf("sendMail 耗时 %s，未按 ctx 超时返回", elapsed)
+	}
+}
@@ -1,36 +1,72 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"net/http"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"worksentry/internal/db/sqlc"
 )
 
+const (
+	manualModeLegacy    = "legacy"
+	manualModeFill      = "fill"
+	manualModeOverwrite = "overwrite"
+	manualLabelMaxLen   = 64
+)
+
 type ManualAdjustmentPayload struct {
 	ID           int64  `json:"id"`
 	EmployeeCode string `json:"employeeCode"`
 	StartAt      string `json:"startAt"`
 	EndAt        string `json:"endAt"`
+	TargetStatus string `json:"targetStatus"`
+	Label        string `json:"label"`
+	Mode         string `json:"mode"`
 	Reason       string `json:"reason"`
 	Note         string `json:"note"`
 }
 
 type ManualAdjustmentView struct {
-	ID           int64  `json:"id"`
-	EmployeeCode string `json:"employeeCode"`
-	Name         string `json:"name"`
-	Department   string `json:"department"`
-	StartAt      string `json:"startAt"`
-	EndAt        string `json:"endAt"`
-	Reason       string `json:"reason"`
-	Note         string `json:"note"`
-	Status       string `json:"status"`
-	StatusLabel  string `json:"statusLabel"`
-	CreatedAt    string `json:"createdAt"`
+	ID                int64  `json:"id"`
+	EmployeeCode      string `json:"employeeCode"`
+	Name              string `json:"name"`
+	Department        string `json:"department"`
+	StartAt           string `json:"startAt"`
+	EndAt             string `json:"endAt"`
+	TargetStatus      string `json:"targetStatus"`
+	TargetStatusLabel string `json:"targetStatusLabel"`
+	Label             string `json:"label"`
+	Mode              string `json:"mode"`
+	ModeLabel         string `json:"modeLabel"`
+	Reason            string `json:"reason"`
+	Note              string `json:"note"`
+	Status            string `json:"status"`
+	StatusLabel       string `json:"statusLabel"`
+	CreatedAt         string `json:"createdAt"`
+}
+
+// manualRangeError 表示补录范围校验未通过，需以 400 返回给调用方。
+type manualRangeError struct {
+	Message string
+}
+
+func (e *manualRangeError) Error() string {
+	return e.Message
+}
+
+type manualAdjustmentInput struct {
+	StartAt      time.Time
+	EndAt        time.Time
+	TargetStatus string
+	Label        string
+	Mode         string
 }
 
 func (h *Handler) ManualAdjustments(w http.ResponseWriter, r *http.Request) {
@@ -49,6 +85,9 @@ func (h *Handler) ManualAdjustments(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) listManualAdjustments(w http.ResponseWriter, r *http.Request) {
+	if h.exportIfRequested(w, r, h.manualAdjustmentsExport) {
+		return
+	}
 	date := r.URL.Query().Get("date")
 	params := sqlc.ListManualAdjustmentsParams{
 		Column1: date,
@@ -72,17 +111,22 @@ func (h *Handler) listManualAdjustments(w http.ResponseWriter, r *http.Request)
 	views := make([]ManualAdjustmentView, 0, len(items))
 	for _, item := range items {
 		views = append(views, ManualAdjustmentView{
-			ID:           item.ID,
-			EmployeeCode: item.EmployeeCode,
-			Name:         item.Name,
-			Department:   nullString(item.DepartmentName),
-			StartAt:      formatTime(item.StartAt),
-			EndAt:        formatTime(item.EndAt),
-			Reason:       item.Reason,
-			Note:         item.Note,
-			Status:       string(item.Status),
-			StatusLabel:  manualStatusLabel(string(item.Status)),
-			CreatedAt:    formatTime(item.CreatedAt),
+			ID:                item.ID,
+			EmployeeCode:      item.EmployeeCode,
+			Name:              item.Name,
+			Department:        nullString(item.DepartmentName),
+			StartAt:           formatTime(item.StartAt),
+			EndAt:             formatTime(item.EndAt),
+			TargetStatus:      item.TargetStatus,
+			TargetStatusLabel: statusLabel(item.TargetStatus),
+			Label:             nullString(item.Label),
+			Mode:              item.Mode,
+			ModeLabel:         manualModeLabel(item.Mode),
+			Reason:            item.Reason,
+			Note:              item.Note,
+			Status:            string(item.Status),
+			StatusLabel:       manualStatusLabel(string(item.Status)),
+			CreatedAt:         formatTime(item.CreatedAt),
 		})
 	}
 
@@ -99,18 +143,9 @@ func (h *Handler) createManualAdjustment(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusBadRequest, "工号、时间、原因、备注不能为空")
 		return
 	}
-	startAt, err := parseDateTime(payload.StartAt)
-	if err != nil {
-		writeError(w, http.StatusBadRequest, "开始时间格式错误")
-		return
-	}
-	endAt, err := parseDateTime(payload.EndAt)
-	if err != nil {
-		writeError(w, http.StatusBadRequest, "结束时间格式错误")
-		return
-	}
-	if !endAt.After(startAt) {
-		writeError(w, http.StatusBadRequest, "结束时间必须大于开始时间")
+	input, message := parseManualAdjustmentInput(payload)
+	if message != "" {
+		writeError(w, http.StatusBadRequest, message)
 		return
 	}
 
@@ -120,37 +155,55 @@ func (h *Handler) createManualAdjustment(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := h.validateManualRange(r, employee.ID, startAt, endAt); err != "" {
-		writeError(w, http.StatusBadRequest, err)
+	id, err := h.createManualAdjustmentRecord(r.Context(), employee.ID, input, payload.Reason, payload.Note, adminIDFromRequest(r))
+	if err != nil {
+		h.writeManualError(w, err, "补录失败")
 		return
 	}
 
-	operatorID := adminIDFromRequest(r)
-	result, err := h.Queries.CreateManualAdjustment(r.Context(), sqlc.CreateManualAdjustmentParams{
-		EmployeeID: employee.ID,
-		StartAt:    startAt,
-		EndAt:      endAt,
-		Reason:     payload.Reason,
-		Note:       payload.Note,
-		OperatorID: operatorID,
+	h.logAudit(r, "create_manual_adjustment", "manual_adjustment", sql.NullInt64{Int64: id, Valid: true}, payload)
+	writeJSON(w, http.StatusOK, map[string]any{"id": id})
+}
+
+// createManualAdjustmentRecord 在员工锁内写入补录记录并改写时间段与日统计。
+func (h *Handler) createManualAdjustmentRecord(ctx context.Context, employeeID int64, input manualAdjustmentInput, reason string, note string, operatorID int64) (int64, error) {
+	var id int64
+	err := h.withEmployeeTx(ctx, employeeID, func(qtx *sqlc.Queries, tx *sql.Tx, locked sqlc.Employee) error {
+		var err error
+		id, err = h.createManualAdjustmentTx(ctx, tx, qtx, locked, input, reason, note, operatorID)
+		return err
 	})
-	if err != nil {
-		writeError(w, http.StatusInternalServerError, "补录失败")
-		return
+	return id, err
+}
+
+func (h *Handler) createManualAdjustmentTx(ctx context.Context, tx *sql.Tx, qtx *sqlc.Queries, employee sqlc.Employee, input manualAdjustmentInput, reason string, note string, operatorID int64) (int64, error) {
+	if input.Mode == manualModeFill {
+		if message := validateManualRange(ctx, qtx, employee.ID, input.StartAt, input.EndAt); message != "" {
+			return 0, &manualRangeError{Message: message}
+		}
 	}
 
+	result, err := qtx.CreateManualAdjustment(ctx, sqlc.CreateManualAdjustmentParams{
+		EmployeeID:   employee.ID,
+		StartAt:      input.StartAt,
+		EndAt:        input.EndAt,
+		TargetStatus: input.TargetStatus,
+		Label:        toNullString(input.Label),
+		Mode:         input.Mode,
+		Reason:       reason,
+		Note:         note,
+		OperatorID:   operatorID,
+	})
+	if err != nil {
+		return 0, err
+	}
 	id, err := result.LastInsertId()
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "补录失败")
-		return
+		return 0, err
 	}
-
-	h.applyManualStats(r, employee.ID, startAt, endAt, true)
-	h.createSegmentOnly(r, employee.ID, startAt, endAt, "work", "补录", "manual")
-
-	h.logAudit(r, "create_manual_adjustment", "manual_adjustment", sql.NullInt64{Int64: id, Valid: true}, payload)
-	writeJSON(w, http.StatusOK, map[string]any{"id": id})
+	return id, h.applyManualOverwrite(ctx, tx, qtx, employee, id, input)
 }
+
 func (h *Handler) updateManualAdjustment(w http.ResponseWriter, r *http.Request) {
 	var payload ManualAdjustmentPayload
 	if err := decodeJSON(r, &payload); err != nil {
@@ -165,18 +218,9 @@ func (h *Handler) updateManualAdjustment(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusBadRequest, "原因与备注不能为空")
 		return
 	}
-	startAt, err := parseDateTime(payload.StartAt)
-	if err != nil {
-		writeError(w, http.StatusBadRequest, "开始时间格式错误")
-		return
-	}
-	endAt, err := parseDateTime(payload.EndAt)
-	if err != nil {
-		writeError(w, http.StatusBadRequest, "结束时间格式错误")
-		return
-	}
-	if !endAt.After(startAt) {
-		writeError(w, http.StatusBadRequest, "结束时间必须大于开始时间")
+	input, message := parseManualAdjustmentInput(payload)
+	if message != "" {
+		writeError(w, http.StatusBadRequest, message)
 		return
 	}
 
@@ -190,27 +234,41 @@ func (h *Handler) updateManualAdjustment(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := h.validateManualRange(r, oldItem.EmployeeID, startAt, endAt); err != "" {
-		writeError(w, http.StatusBadRequest, err)
-		return
-	}
-
-	if err := h.Queries.UpdateManualAdjustment(r.Context(), sqlc.UpdateManualAdjustmentParams{
-		ID:      payload.ID,
-		StartAt: startAt,
-		EndAt:   endAt,
-		Reason:  payload.Reason,
-		Note:    payload.Note,
-	}); err != nil {
-		writeError(w, http.StatusInternalServerError, "更新补录失败")
+	err = h.withEmployeeTx(r.Context(), oldItem.EmployeeID, func(qtx *sqlc.Queries, tx *sql.Tx, locked sqlc.Employee) error {
+		current, err := qtx.GetManualAdjustment(r.Context(), payload.ID)
+		if err != nil {
+			return err
+		}
+		if current.Status != sqlc.ManualAdjustmentsStatusActive {
+			return &manualRangeError{Message: "补录已撤销"}
+		}
+		if err := h.revertManualAdjustment(r.Context(), tx, qtx, current); err != nil {
+			return err
+		}
+		if input.Mode == manualModeFill {
+			if message := validateManualRange(r.Context(), qtx, locked.ID, input.StartAt, input.EndAt); message != "" {
+				return &manualRangeError{Message: message}
+			}
+		}
+		if err := qtx.UpdateManualAdjustment(r.Context(), sqlc.UpdateManualAdjustmentParams{
+			ID:           payload.ID,
+			StartAt:      input.StartAt,
+			EndAt:        input.EndAt,
+			TargetStatus: input.TargetStatus,
+			Label:        toNullString(input.Label),
+			Mode:         input.Mode,
+			Reason:       payload.Reason,
+			Note:         payload.Note,
+		}); err != nil {
+			return err
+		}
+		return h.applyManualOverwrite(r.Context(), tx, qtx, locked, payload.ID, input)
+	})
+	if err != nil {
+		h.writeManualError(w, err, "更新补录失败")
 		return
 	}
 
-	_ = h.Queries.DeleteManualSegment(r.Context(), sqlc.DeleteManualSegmentParams{EmployeeID: oldItem.EmployeeID, StartAt: oldItem.StartAt, EndAt: oldItem.EndAt})
-	h.applyManualStats(r, oldItem.EmployeeID, oldItem.StartAt, oldItem.EndAt, false)
-	h.applyManualStats(r, oldItem.EmployeeID, startAt, endAt, true)
-	h.createSegmentOnly(r, oldItem.EmployeeID, startAt, endAt, "work", "补录", "manual")
-
 	h.logAudit(r, "update_manual_adjustment", "manual_adjustment", sql.NullInt64{Int64: payload.ID, Valid: true}, payload)
 	writeJSON(w, http.StatusOK, map[string]string{"message": "更新成功"})
 }
@@ -232,18 +290,78 @@ func (h *Handler) revokeManualAdjustment(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := h.Queries.RevokeManualAdjustment(r.Context(), id); err != nil {
-		writeError(w, http.StatusInternalServerError, "撤销失败")
+	err = h.withEmployeeTx(r.Context(), item.EmployeeID, func(qtx *sqlc.Queries, tx *sql.Tx, locked sqlc.Employee) error {
+		current, err := qtx.GetManualAdjustment(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		if current.Status != sqlc.ManualAdjustmentsStatusActive {
+			return &manualRangeError{Message: "补录已撤销"}
+		}
+		if err := qtx.RevokeManualAdjustment(r.Context(), id); err != nil {
+			return err
+		}
+		return h.revertManualAdjustment(r.Context(), tx, qtx, current)
+	})
+	if err != nil {
+		h.writeManualError(w, err, "撤销失败")
 		return
 	}
 
-	_ = h.Queries.DeleteManualSegment(r.Context(), sqlc.DeleteManualSegmentParams{EmployeeID: item.EmployeeID, StartAt: item.StartAt, EndAt: item.EndAt})
-	h.applyManualStats(r, item.EmployeeID, item.StartAt, item.EndAt, false)
-
 	h.logAudit(r, "revoke_manual_adjustment", "manual_adjustment", sql.NullInt64{Int64: id, Valid: true}, nil)
 	writeJSON(w, http.StatusOK, map[string]string{"message": "已撤销"})
 }
 
+func (h *Handler) writeManualError(w http.ResponseWriter, err error, fallback string) {
+	var rangeErr *manualRangeError
+	if errors.As(err, &rangeErr) {
+		writeError(w, http.StatusBadRequest, rangeErr.Message)
+		return
+	}
+	writeError(w, http.StatusInternalServerError, fallback)
+}
+
+func parseManualAdjustmentInput(payload ManualAdjustmentPayload) (manualAdjustmentInput, string) {
+	input := manualAdjustmentInput{
+		TargetStatus: strings.TrimSpace(payload.TargetStatus),
+		Label:        strings.TrimSpace(payload.Label),
+		Mode:         strings.TrimSpace(payload.Mode),
+	}
+	startAt, err := parseDateTime(payload.StartAt)
+	if err != nil {
+		return input, "开始时间格式错误"
+	}
+	endAt, err := parseDateTime(payload.EndAt)
+	if err != nil {
+		return input, "结束时间格式错误"
+	}
+	if !endAt.After(startAt) {
+		return input, "结束时间必须大于开始时间"
+	}
+	if endAt.After(time.Now()) {
+		return input, "补录结束时间不能晚于当前时间"
+	}
+	input.StartAt = startAt
+	input.EndAt = endAt
+
+	if input.TargetStatus == "" {
+		input.TargetStatus = "work"
+	}
+	if !isValidStatusCode(input.TargetStatus) {
+		return input, "补录状态无效"
+	}
+	if input.Mode == "" {
+		input.Mode = manualModeFill
+	}
+	if input.Mode != manualModeFill && input.Mode != manualModeOverwrite {
+		return input, "补录方式无效"
+	}
+	if utf8.RuneCountInString(input.Label) > manualLabelMaxLen {
+		return input, "标签长度不能超过 64 个字符"
+	}
+	return input, ""
+}
+
 func manualStatusLabel(status string) string {
 	switch status {
 	case "active":
@@ -255,8 +373,21 @@ func manualStatusLabel(status string) string {
 	}
 }
 
-func (h *Handler) validateManualRange(r *http.Request, employeeID int64, startAt time.Time, endAt time.Time) string {
-	segments, err := h.Queries.ListOfflineSegmentsByEmployeeAndRange(r.Context(), sqlc.ListOfflineSegmentsByEmployeeAndRangeParams{EmployeeID: employeeID, StartAt: endAt, EndAt: startAt})
+func manualModeLabel(mode string) string {
+	switch mode {
+	case manualModeLegacy:
+		return "离线补录（旧）"
+	case manualModeFill:
+		return "离线补录"
+	case manualModeOverwrite:
+		return "覆盖改写"
+	default:
+		return "未知"
+	}
+}
+
+func validateManualRange(ctx context.Context, q *sqlc.Queries, employeeID int64, startAt time.Time, endAt time.Time) string {
+	segments, err := q.ListOfflineSegmentsByEmployeeAndRange(ctx, sqlc.ListOfflineSegmentsByEmployeeAndRangeParams{EmployeeID: employeeID, StartAt: endAt, EndAt: startAt})
 	if err != nil {
 		return "离线段校验失败"
 	}
@@ -281,7 +412,7 @@ func (h *Handler) validateManualRange(r *http.Request, employeeID int64, startAt
 		return "补录时间必须完全覆盖在离线段内"
 	}
 
-	overlap, err := h.Queries.CountNonOfflineSegmentsOverlap(r.Context(), sqlc.CountNonOfflineSegmentsOverlapParams{EmployeeID: employeeID, StartAt: endAt, EndAt: startAt})
+	overlap, err := q.CountNonOfflineSegmentsOverlap(ctx, sqlc.CountNonOfflineSegmentsOverlapParams{EmployeeID: employeeID, StartAt: endAt, EndAt: startAt})
 	if err != nil {
 		return "时间段校验失败"
 	}
@@ -291,14 +422,188 @@ func (h *Handler) validateManualRange(r *http.Request, employeeID int64, startAt
 	return ""
 }
 
-func (h *Handler) applyManualStats(r *http.Request, employeeID int64, startAt time.Time, endAt time.Time, add bool) {
-	for _, part := range splitByDay(startAt, endAt) {
-		inc := buildDailyStatIncrement("work", part.Seconds)
-		sign := int32(1)
-		if !add {
-			sign = -1
+type overlappedSegment struct {
+	ID          int64
+	StartAt     time.Time
+	EndAt       time.Time
+	Status      string
+	Description sql.NullString
+	Source      string
+}
+
+// applyManualOverwrite 用补录段替换范围内的已有时间段：被覆盖的原始段先备份到
+// manual_adjustment_originals，范围外的剩余部分拆分保留，日统计按精确差值调整。
+func (h *Handler) applyManualOverwrite(ctx context.Context, tx *sql.Tx, qtx *sqlc.Queries, employee sqlc.Employee, adjustmentID int64, input manualAdjustmentInput) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, start_at, end_at, status, description, source
+FROM time_segments
+WHERE employee_id = ? AND start_at < ? AND end_at > ?
+ORDER BY start_at, id
+FOR UPDATE`, employee.ID, input.EndAt, input.StartAt)
+	if err != nil {
+		return err
+	}
+	var segments []overlappedSegment
+	for rows.Next() {
+		var seg overlappedSegment
+		if err := rows.Scan(&seg.ID, &seg.StartAt, &seg.EndAt, &seg.Status, &seg.Description, &seg.Source); err != nil {
+			rows.Close()
+			return err
+		}
+		segments = append(segments, seg)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, seg := range segments {
+		if seg.Source == "manual" {
+			return &manualRangeError{Message: "补录时间与已有补录重叠"}
+		}
+		// 系统事故段记录的是服务端故障，覆盖后会丢失事故记录
+		if seg.Status == "incident" || seg.Source == "incident" {
+			return &manualRangeError{Message: "补录时间与系统事故时段重叠，请避开事故时段"}
 		}
-		_ = h.Queries.AddDailyStats(r.Context(), sqlc.AddDailyStatsParams{
+	}
+
+	for _, seg := range segments {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO manual_adjustment_originals (adjustment_id, employee_id, start_at, end_at, status, description, source)
+VALUES (?, ?, ?, ?, ?, ?, ?)`, adjustmentID, employee.ID, seg.StartAt, seg.EndAt, seg.Status, seg.Description, seg.Source); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM time_segments WHERE id = ?", seg.ID); err != nil {
+			return err
+		}
+
+		overlapStart := maxTime(seg.StartAt, input.StartAt)
+		overlapEnd := minTime(seg.EndAt, input.EndAt)
+		if err := h.applyDailyStatsDelta(ctx, qtx, employee.ID, seg.Status, overlapStart, overlapEnd, -1); err != nil {
+			return err
+		}
+
+		if seg.StartAt.Before(input.StartAt) {
+			if err := insertTimeSegment(ctx, tx, employee.ID, seg.StartAt, input.StartAt, seg.Status, seg.Description, seg.Source, sql.NullInt64{}); err != nil {
+				return err
+			}
+		}
+		if seg.EndAt.After(input.EndAt) {
+			if err := insertTimeSegment(ctx, tx, employee.ID, input.EndAt, seg.EndAt, seg.Status, seg.Description, seg.Source, sql.NullInt64{}); err != nil {
+				return err
+			}
+		}
+	}
+
+	description := input.Label
+	if description == "" {
+		description = "补录"
+	}
+	if err := insertTimeSegment(ctx, tx, employee.ID, input.StartAt, input.EndAt, input.TargetStatus, toNullString(description), "manual", sql.NullInt64{Int64: adjustmentID, Valid: true}); err != nil {
+		return err
+	}
+	if err := h.applyDailyStatsDelta(ctx, qtx, employee.ID, input.TargetStatus, input.StartAt, input.EndAt, 1); err != nil {
+		return err
+	}
+
+	// 补录段之后的实时上报需从补录结束时间接续，避免产生重叠段
+	if !employee.LastSegmentEndAt.Valid || employee.LastSegmentEndAt.Time.Before(input.EndAt) {
+		return qtx.UpdateEmployeeLastSegmentEnd(ctx, sqlc.UpdateEmployeeLastSegmentEndParams{
+			LastSegmentEndAt: sql.NullTime{Time: input.EndAt, Valid: true},
+			ID:               employee.ID,
+		})
+	}
+	return nil
+}
+
+// revertManualAdjustment 撤回补录对时间段与日统计的影响，并按备份恢复被覆盖的原始段。
+func (h *Handler) revertManualAdjustment(ctx context.Context, tx *sql.Tx, qtx *sqlc.Queries, item sqlc.ManualAdjustment) error {
+	if item.Mode == manualModeLegacy {
+		if err := qtx.DeleteManualSegment(ctx, sqlc.DeleteManualSegmentParams{EmployeeID: item.EmployeeID, StartAt: item.StartAt, EndAt: item.EndAt}); err != nil {
+			return err
+		}
+		return h.applyManualStats(ctx, qtx, item.EmployeeID, item.StartAt, item.EndAt, false)
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, start_at, end_at, status FROM time_segments WHERE adjustment_id = ? FOR UPDATE`, item.ID)
+	if err != nil {
+		return err
+	}
+	var manualSegments []overlappedSegment
+	for rows.Next() {
+		var seg overlappedSegment
+		if err := rows.Scan(&seg.ID, &seg.StartAt, &seg.EndAt, &seg.Status); err != nil {
+			rows.Close()
+			return err
+		}
+		manualSegments = append(manualSegments, seg)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, seg := range manualSegments {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM time_segments WHERE id = ?", seg.ID); err != nil {
+			return err
+		}
+		if err := h.applyDailyStatsDelta(ctx, qtx, item.EmployeeID, seg.Status, seg.StartAt, seg.EndAt, -1); err != nil {
+			return err
+		}
+	}
+
+	rows, err = tx.QueryContext(ctx, `SELECT id, start_at, end_at, status, description, source
+FROM manual_adjustment_originals WHERE adjustment_id = ? ORDER BY start_at, id`, item.ID)
+	if err != nil {
+		return err
+	}
+	var originals []overlappedSegment
+	for rows.Next() {
+		var seg overlappedSegment
+		if err := rows.Scan(&seg.ID, &seg.StartAt, &seg.EndAt, &seg.Status, &seg.Description, &seg.Source); err != nil {
+			rows.Close()
+			return err
+		}
+		originals = append(originals, seg)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// 原始段在补录范围外的部分已拆分保留，这里只恢复被覆盖的区间
+	for _, seg := range originals {
+		start := maxTime(seg.StartAt, item.StartAt)
+		end := minTime(seg.EndAt, item.EndAt)
+		if !end.After(start) {
+			continue
+		}
+		if err := insertTimeSegment(ctx, tx, item.EmployeeID, start, end, seg.Status, seg.Description, seg.Source, sql.NullInt64{}); err != nil {
+			return err
+		}
+		if err := h.applyDailyStatsDelta(ctx, qtx, item.EmployeeID, seg.Status, start, end, 1); err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM manual_adjustment_originals WHERE adjustment_id = ?", item.ID)
+	return err
+}
+
+func insertTimeSegment(ctx context.Context, db sqlc.DBTX, employeeID int64, start time.Time, end time.Time, status string, description sql.NullString, source string, adjustmentID sql.NullInt64) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO time_segments (employee_id, start_at, end_at, status, description, source, adjustment_id)
+VALUES (?, ?, ?, ?, ?, ?, ?)`, employeeID, start, end, status, description, source, adjustmentID)
+	return err
+}
+
+func (h *Handler) applyManualStats(ctx context.Context, q *sqlc.Queries, employeeID int64, startAt time.Time, endAt time.Time, add bool) error {
+	offset, err := h.employeeDayOffset(ctx, employeeID)
+	if err != nil {
+		return err
+	}
+	sign := int32(1)
+	if !add {
+		sign = -1
+	}
+	for _, part := range splitByBusinessDay(startAt, endAt, offset) {
+		inc := buildDailyStatIncrement("work", part.Seconds)
+		if err := q.AddDailyStats(ctx, sqlc.AddDailyStatsParams{
 			StatDate:          part.Date,
 			EmployeeID:        employeeID,
 			WorkSeconds:       inc.Work * sign,
@@ -308,6 +613,27 @@ func (h *Handler) applyManualStats(r *http.Request, employeeID int64, startAt ti
 			OfflineSeconds:    int32(part.Seconds) * -sign,
 			AttendanceSeconds: inc.Attendance * sign,
 			EffectiveSeconds:  inc.Effective * sign,
-		})
+		}); err != nil {
+			return err
+		}
+	}
+	// 旧版补录叠加在离线段上：小时统计同样计入工作并抵扣离线
+	if err := applyHourlyStatsDelta(ctx, q, employeeID, "work", startAt, endAt, sign); err != nil {
+		return err
+	}
+	return applyHourlyStatsDelta(ctx, q, employeeID, "offline", startAt, endAt, -sign)
+}
+
+func maxTime(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a time.Time, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
 	}
+	return b
 }
@@ -22,6 +22,9 @@ func (h *Handler) OfflineSegments(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
 		return
 	}
+	if h.exportIfRequested(w, r, h.offlineSegmentsExport) {
+		return
+	}
 	dateValue := r.URL.Query().Get("date")
 	code := strings.TrimSpace(r.URL.Query().Get("employeeCode"))
 	var date time.Time
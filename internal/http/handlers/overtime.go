@@ -0,0 +1,431 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"worksentry/internal/db/sqlc"
+)
+
+const (
+	overtimeWeekday = "weekday"
+	overtimeRestday = "restday"
+	overtimeHoliday = "holiday"
+
+	overtimeRoundFloor   = "floor"
+	overtimeRoundCeil    = "ceil"
+	overtimeRoundNearest = "nearest"
+
+	overtimeStatusPending  = "pending"
+	overtimeStatusApproved = "approved"
+	overtimeStatusRejected = "rejected"
+)
+
+type OvertimeRuleView struct {
+	DepartmentID    int64  `json:"departmentId"`
+	RoundingMinutes int32  `json:"roundingMinutes"`
+	RoundingMode    string `json:"roundingMode"`
+	MinMinutes      int32  `json:"minMinutes"`
+	Configured      bool   `json:"configured"`
+}
+
+type OvertimeEvaluatePayload struct {
+	StartDate string `json:"startDate"`
+	EndDate   string `json:"endDate"`
+}
+
+type overtimeEvaluateResult struct {
+	Sessions int `json:"sessions"`
+	Records  int `json:"records"`
+	Skipped  int `json:"skipped"`
+}
+
+type overtimeRule struct {
+	RoundingMinutes int32
+	RoundingMode    string
+	MinMinutes      int32
+}
+
+type overtimeSession struct {
+	ID           int64
+	EmployeeID   int64
+	DepartmentID int64
+	StartAt      time.Time
+	EndAt        time.Time
+}
+
+func defaultOvertimeRule() overtimeRule {
+	return overtimeRule{RoundingMinutes: 30, RoundingMode: overtimeRoundFloor, MinMinutes: 30}
+}
+
+// roundMinutes 按取整单位与方式换算加班分钟数，不足起算时长记为 0。
+func (r overtimeRule) roundMinutes(seconds int64) int32 {
+	if seconds <= 0 {
+		return 0
+	}
+	minutes := seconds / 60
+	if int64(r.MinMinutes) > 0 && minutes < int64(r.MinMinutes) {
+		return 0
+	}
+	unit := int64(r.RoundingMinutes)
+	if unit <= 1 {
+		return int32(minutes)
+	}
+	switch r.RoundingMode {
+	case overtimeRoundCeil:
+		minutes = (minutes + unit - 1) / unit * unit
+	case overtimeRoundNearest:
+		minutes = (minutes + unit/2) / unit * unit
+	default:
+		minutes = minutes / unit * unit
+	}
+	return int32(minutes)
+}
+
+func (h *Handler) OvertimeRules(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		departmentID := parseInt64(r.URL.Query().Get("departmentId"))
+		rules, err := loadOvertimeRules(r.Context(), h.DB)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "读取加班规则失败")
+			return
+		}
+		rule, configured := rules[departmentID]
+		if !configured {
+			rule = resolveOvertimeRule(rules, departmentID)
+		}
+		writeJSON(w, http.StatusOK, OvertimeRuleView{
+			DepartmentID:    departmentID,
+			RoundingMinutes: rule.RoundingMinutes,
+			RoundingMode:    rule.RoundingMode,
+			MinMinutes:      rule.MinMinutes,
+			Configured:      configured,
+		})
+	case http.MethodPut:
+		var payload OvertimeRuleView
+		if err := decodeJSON(r, &payload); err != nil {
+			writeError(w, http.StatusBadRequest, "参数格式错误")
+			return
+		}
+		if payload.DepartmentID < 0 {
+			writeError(w, http.StatusBadRequest, "部门无效")
+			return
+		}
+		if !payload.Configured {
+			if _, err := h.DB.ExecContext(r.Context(), "DELETE FROM overtime_rules WHERE department_id = ?", payload.DepartmentID); err != nil {
+				writeError(w, http.StatusInternalServerError, "保存加班规则失败")
+				return
+			}
+			h.logAudit(r, "delete_overtime_rule", "overtime_rule", sql.NullInt64{Int64: payload.DepartmentID, Valid: true}, payload)
+			writeJSON(w, http.StatusOK, map[string]string{"message": "已保存"})
+			return
+		}
+		if payload.RoundingMinutes < 0 || payload.RoundingMinutes > 240 || payload.MinMinutes < 0 || payload.MinMinutes > 720 {
+			writeError(w, http.StatusBadRequest, "时长配置不正确")
+			return
+		}
+		if payload.RoundingMode != overtimeRoundFloor && payload.RoundingMode != overtimeRoundCeil && payload.RoundingMode != overtimeRoundNearest {
+			writeError(w, http.StatusBadRequest, "取整方式无效")
+			return
+		}
+		if _, err := h.DB.ExecContext(r.Context(), `INSERT INTO overtime_rules (department_id, rounding_minutes, rounding_mode, min_minutes)
+VALUES (?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE rounding_minutes = VALUES(rounding_minutes), rounding_mode = VALUES(rounding_mode), min_minutes = VALUES(min_minutes)`,
+			payload.DepartmentID, payload.RoundingMinutes, payload.RoundingMode, payload.MinMinutes); err != nil {
+			writeError(w, http.StatusInternalServerError, "保存加班规则失败")
+			return
+		}
+		h.logAudit(r, "save_overtime_rule", "overtime_rule", sql.NullInt64{Int64: payload.DepartmentID, Valid: true}, payload)
+		writeJSON(w, http.StatusOK, map[string]string{"message": "已保存"})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+	}
+}
+
+func loadOvertimeRules(ctx context.Context, db sqlc.DBTX) (map[int64]overtimeRule, error) {
+	rows, err := db.QueryContext(ctx, "SELECT department_id, rounding_minutes, rounding_mode, min_minutes FROM overtime_rules")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	rules := map[int64]overtimeRule{}
+	for rows.Next() {
+		var departmentID int64
+		var rule overtimeRule
+		if err := rows.Scan(&departmentID, &rule.RoundingMinutes, &rule.RoundingMode, &rule.MinMinutes); err != nil {
+			return nil, err
+		}
+		rules[departmentID] = rule
+	}
+	return rules, rows.Err()
+}
+
+// resolveOvertimeRule 部门规则优先，其次全局规则（department_id = 0），最后使用默认值。
+func resolveOvertimeRule(rules map[int64]overtimeRule, departmentID int64) overtimeRule {
+	if rule, ok := rules[departmentID]; ok {
+		return rule
+	}
+	if rule, ok := rules[0]; ok {
+		return rule
+	}
+	return defaultOvertimeRule()
+}
+
+// evaluateOvertime 计算 [start, end) 业务日内已下班记录的加班：
+// 工作日先扣除当天应出勤时长（班次时长扣除班次休息，未配置班次时取部门工时标准），休息日与节假日全部计为加班。
+// 已审核的记录不再改写；自动下班的记录结束时间取最后活动，照常计算并在审批列表中标记。
+func (h *Handler) evaluateOvertime(ctx context.Context, start time.Time, end time.Time) (overtimeEvaluateResult, error) {
+	result := overtimeEvaluateResult{}
+	shifts, err := h.Queries.ListEmployeeShifts(ctx)
+	if err != nil {
+		return result, err
+	}
+	shiftMap := make(map[int64]sqlc.WorkShift, len(shifts))
+	for _, item := range shifts {
+		shiftMap[item.EmployeeID] = item.WorkShift
+	}
+	rules, err := loadOvertimeRules(ctx, h.DB)
+	if err != nil {
+		return result, err
+	}
+	targets, err := loadDepartmentTargets(ctx, h.DB)
+	if err != nil {
+		return result, err
+	}
+
+	rows, err := h.DB.QueryContext(ctx, `SELECT ws.id, ws.employee_id, COALESCE(e.department_id, 0), ws.start_at, ws.end_at
+FROM work_sessions ws
+JOIN employees e ON ws.employee_id = e.id
+WHERE ws.end_at IS NOT NULL AND ws.start_at >= ? AND ws.start_at < ?
+ORDER BY ws.start_at ASC`, start.AddDate(0, 0, -1), end.AddDate(0, 0, 1))
+	if err != nil {
+		return result, err
+	}
+	groups := map[attendanceKey][]overtimeSession{}
+	keys := []attendanceKey{}
+	for rows.Next() {
+		var item overtimeSession
+		if err := rows.Scan(&item.ID, &item.EmployeeID, &item.DepartmentID, &item.StartAt, &item.EndAt); err != nil {
+			rows.Close()
+			return result, err
+		}
+		date := businessDate(item.StartAt, shiftDayOffset(shiftMap[item.EmployeeID]))
+		if date.Before(start) || !date.Before(end) {
+			continue
+		}
+		key := attendanceKey{EmployeeID: item.EmployeeID, Date: date.Format("2006-01-02")}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Date != keys[j].Date {
+			return keys[i].Date < keys[j].Date
+		}
+		return keys[i].EmployeeID < keys[j].EmployeeID
+	})
+
+	calendars := map[int64]map[string]calendarDay{}
+	for _, key := range keys {
+		sessions := groups[key]
+		departmentID := sessions[0].DepartmentID
+		calendar, ok := calendars[departmentID]
+		if !ok {
+			days, err := h.resolveCalendarDays(ctx, departmentID, start, end)
+			if err != nil {
+				return result, err
+			}
+			calendar = make(map[string]calendarDay, len(days))
+			for _, day := range days {
+				calendar[day.Date.Format("2006-01-02")] = day
+			}
+			calendars[departmentID] = calendar
+		}
+
+		category := overtimeWeekday
+		scheduled := int64(0)
+		switch calendar[key.Date].Type {
+		case dayTypeRestday:
+			category = overtimeRestday
+		case dayTypeHoliday:
+			category = overtimeHoliday
+		default:
+			if shift, ok := shiftMap[key.EmployeeID]; ok {
+				scheduled = shiftDurationSeconds(shift)
+			} else {
+				scheduled = targets[departmentID]
+			}
+			if scheduled <= 0 {
+				// 没有班次和工时标准时无法判断加班
+				result.Skipped += len(sessions)
+				continue
+			}
+		}
+
+		rule := resolveOvertimeRule(rules, departmentID)
+		remaining := scheduled
+		for _, session := range sessions {
+			breaks, err := h.calcBreakSummary(ctx, session.EmployeeID, session.StartAt, session.EndAt)
+			if err != nil {
+				return result, err
+			}
+			worked := int64(session.EndAt.Sub(session.StartAt).Seconds()) - breaks.TotalSeconds
+			if worked < 0 {
+				worked = 0
+			}
+			sessionScheduled := remaining
+			if sessionScheduled > worked {
+				sessionScheduled = worked
+			}
+			remaining -= sessionScheduled
+			overtimeSeconds := worked - sessionScheduled
+			minutes := rule.roundMinutes(overtimeSeconds)
+			result.Sessions++
+
+			if minutes <= 0 {
+				if _, err := h.DB.ExecContext(ctx, "DELETE FROM overtime_records WHERE work_session_id = ? AND status = 'pending'", session.ID); err != nil {
+					return result, err
+				}
+				continue
+			}
+			if _, err := h.DB.ExecContext(ctx, `INSERT INTO overtime_records (work_session_id, employee_id, department_id, work_date, category, scheduled_seconds, worked_seconds, overtime_seconds, overtime_minutes, status)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 'pending')
+ON DUPLICATE KEY UPDATE
+  department_id = IF(status = 'pending', VALUES(department_id), department_id),
+  work_date = IF(status = 'pending', VALUES(work_date), work_date),
+  category = IF(status = 'pending', VALUES(category), category),
+  scheduled_seconds = IF(status = 'pending', VALUES(scheduled_seconds), scheduled_seconds),
+  worked_seconds = IF(status = 'pending', VALUES(worked_seconds), worked_seconds),
+  overtime_seconds = IF(status = 'pending', VALUES(overtime_seconds), overtime_seconds),
+  overtime_minutes = IF(status = 'pending', VALUES(overtime_minutes), overtime_minutes)`,
+				session.ID, session.EmployeeID, nullIfZeroID(departmentID), key.Date, category, sessionScheduled, worked, overtimeSeconds, minutes); err != nil {
+				return result, err
+			}
+			result.Records++
+		}
+	}
+	return result, nil
+}
+
+// shiftDurationSeconds 班次应出勤时长，不含班次内的休息时间。
+func shiftDurationSeconds(shift sqlc.WorkShift) int64 {
+	minutes := int64(shift.EndMinute - shift.StartMinute)
+	if minutes <= 0 {
+		minutes += minutesPerDay
+	}
+	minutes -= int64(shift.BreakMinutes)
+	if minutes < 0 {
+		minutes = 0
+	}
+	return minutes * 60
+}
+
+func loadDepartmentTargets(ctx context.Context, db sqlc.DBTX) (map[int64]int64, error) {
+	rows, err := db.QueryContext(ctx, "SELECT department_id, target_seconds FROM department_work_rules WHERE target_seconds > 0")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	targets := map[int64]int64{}
+	for rows.Next() {
+		var departmentID int64
+		var seconds int64
+		if err := rows.Scan(&departmentID, &seconds); err != nil {
+			return nil, err
+		}
+		targets[departmentID] = seconds
+	}
+	return targets, rows.Err()
+}
+
+func (h *Handler) overtimeEvaluateLoop(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if h.DB == nil {
+				continue
+			}
+			now := time.Now()
+			end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1)
+			if _, err := h.evaluateOvertime(ctx, end.AddDate(0, 0, -3), end); err != nil {
+				log.Printf("加班计算失败: %v", err)
+			}
+		}
+	}
+}
+
+func (h *Handler) OvertimeEvaluate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	var payload OvertimeEvaluatePayload
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "参数格式错误")
+		return
+	}
+	start, end, message := parseAttendanceRange(payload.StartDate, payload.EndDate)
+	if message != "" {
+		writeError(w, http.StatusBadRequest, message)
+		return
+	}
+	result, err := h.evaluateOvertime(r.Context(), start, end)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "加班计算失败")
+		return
+	}
+	h.logAudit(r, "evaluate_overtime", "overtime_record", sql.NullInt64{}, map[string]any{
+		"startDate": start.Format("2006-01-02"),
+		"endDate":   end.AddDate(0, 0, -1).Format("2006-01-02"),
+		"records":   result.Records,
+	})
+	writeJSON(w, http.StatusOK, result)
+}
+
+func overtimeStatusLabel(status string) string {
+	switch status {
+	case overtimeStatusPending:
+		return "待审核"
+	case overtimeStatusApproved:
+		return "已通过"
+	case overtimeStatusRejected:
+		return "已驳回"
+	default:
+		return "未知"
+	}
+}
+
+func overtimeCategoryLabel(category string) string {
+	switch category {
+	case overtimeWeekday:
+		return "工作日加班"
+	case overtimeRestday:
+		return "休息日加班"
+	case overtimeHoliday:
+		return "节假日加班"
+	default:
+		return "未知"
+	}
+}
@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+type OvertimeRecordView struct {
+	ID              int64  `json:"id"`
+	WorkDate        string `json:"workDate"`
+	EmployeeCode    string `json:"employeeCode"`
+	Name            string `json:"name"`
+	Department      string `json:"department"`
+	SessionStartAt  string `json:"sessionStartAt"`
+	SessionEndAt    string `json:"sessionEndAt"`
+	AutoClosed      bool   `json:"autoClosed"`
+	Category        string `json:"category"`
+	CategoryLabel   string `json:"categoryLabel"`
+	Scheduled       string `json:"scheduled"`
+	Worked          string `json:"worked"`
+	OvertimeMinutes int32  `json:"overtimeMinutes"`
+	ApprovedMinutes int32  `json:"approvedMinutes"`
+	Status          string `json:"status"`
+	StatusLabel     string `json:"statusLabel"`
+	ReviewComment   string `json:"reviewComment"`
+	ReviewedAt      string `json:"reviewedAt"`
+}
+
+type OvertimeListResponse struct {
+	Total int64                `json:"total"`
+	Items []OvertimeRecordView `json:"items"`
+}
+
+type OvertimeReviewPayload struct {
+	IDs             []int64 `json:"ids"`
+	Action          string  `json:"action"`
+	Comment         string  `json:"comment"`
+	ApprovedMinutes *int32  `json:"approvedMinutes"`
+}
+
+type OvertimeSummaryItem struct {
+	EmployeeCode   string  `json:"employeeCode"`
+	Name           string  `json:"name"`
+	Department     string  `json:"department"`
+	WeekdayMinutes int64   `json:"weekdayMinutes"`
+	RestdayMinutes int64   `json:"restdayMinutes"`
+	HolidayMinutes int64   `json:"holidayMinutes"`
+	TotalMinutes   int64   `json:"totalMinutes"`
+	TotalHours     float64 `json:"totalHours"`
+}
+
+type OvertimeReportResponse struct {
+	StartDate string                `json:"startDate"`
+	EndDate   string                `json:"endDate"`
+	Items     []OvertimeSummaryItem `json:"items"`
+}
+
+const overtimeSelectSQL = `SELECT o.id, o.work_date, e.employee_code, e.name, COALESCE(d.name, ''), ws.start_at, ws.end_at, COALESCE(ws.auto_closed, 0), o.category,
+ o.scheduled_seconds, o.worked_seconds, o.overtime_minutes, o.approved_minutes, o.status, o.review_comment, o.reviewed_at
+FROM overtime_records o
+JOIN employees e ON o.employee_id = e.id
+LEFT JOIN departments d ON o.department_id = d.id
+LEFT JOIN work_sessions ws ON o.work_session_id = ws.id `
+
+// Overtime 加班审批列表，可按状态、日期、部门与关键字筛选。
+func (h *Handler) Overtime(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	status := strings.TrimSpace(r.URL.Query().Get("status"))
+	if status != "" && status != overtimeStatusPending && status != overtimeStatusApproved && status != overtimeStatusRejected {
+		writeError(w, http.StatusBadRequest, "审批状态无效")
+		return
+	}
+	where, args, message, err := h.buildOvertimeFilter(r, status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取部门失败")
+		return
+	}
+	if message != "" {
+		writeError(w, http.StatusBadRequest, message)
+		return
+	}
+
+	page := parseInt(r.URL.Query().Get("page"), 1)
+	pageSize := parseInt(r.URL.Query().Get("pageSize"), 20)
+	if pageSize <= 0 || pageSize > 200 {
+		pageSize = 20
+	}
+	if page <= 0 {
+		page = 1
+	}
+
+	var total int64
+	if err := h.DB.QueryRowContext(r.Context(), "SELECT COUNT(1) FROM overtime_records o JOIN employees e ON o.employee_id = e.id "+where, args...).Scan(&total); err != nil {
+		writeError(w, http.StatusInternalServerError, "读取加班记录失败")
+		return
+	}
+	args = append(args, pageSize, (page-1)*pageSize)
+	items, err := h.queryOvertimeRecords(r.Context(), where+" ORDER BY o.work_date DESC, o.id DESC LIMIT ? OFFSET ?", args...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取加班记录失败")
+		return
+	}
+	writeJSON(w, http.StatusOK, OvertimeListResponse{Total: total, Items: items})
+}
+
+// OvertimeReview 批量审批加班；调整核定分钟数仅支持单条审批。
+func (h *Handler) OvertimeReview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	var payload OvertimeReviewPayload
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "参数格式错误")
+		return
+	}
+	payload.Comment = strings.TrimSpace(payload.Comment)
+	if len(payload.IDs) == 0 || len(payload.IDs) > 200 {
+		writeError(w, http.StatusBadRequest, "请选择 1-200 条加班记录")
+		return
+	}
+	if payload.Action != "approve" && payload.Action != "reject" {
+		writeError(w, http.StatusBadRequest, "审核操作无效")
+		return
+	}
+	if payload.Action == "reject" && payload.Comment == "" {
+		writeError(w, http.StatusBadRequest, "驳回时请填写审核意见")
+		return
+	}
+	if payload.ApprovedMinutes != nil && (len(payload.IDs) != 1 || payload.Action != "approve" || *payload.ApprovedMinutes < 0) {
+		writeError(w, http.StatusBadRequest, "核定时长仅支持单条通过")
+		return
+	}
+
+	tx, err := h.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "审核失败")
+		return
+	}
+	operatorID := adminIDFromRequest(r)
+	now := time.Now()
+	updated := 0
+	for _, id := range payload.IDs {
+		var status string
+		var overtimeMinutes int32
+		if err := tx.QueryRowContext(r.Context(), "SELECT status, overtime_minutes FROM overtime_records WHERE id = ? FOR UPDATE", id).Scan(&status, &overtimeMinutes); err != nil {
+			_ = tx.Rollback()
+			if err == sql.ErrNoRows {
+				writeError(w, http.StatusNotFound, "加班记录不存在")
+			} else {
+				writeError(w, http.StatusInternalServerError, "审核失败")
+			}
+			return
+		}
+		if status != overtimeStatusPending {
+			continue
+		}
+		newStatus := overtimeStatusRejected
+		var approved interface{}
+		if payload.Action == "approve" {
+			newStatus = overtimeStatusApproved
+			approvedMinutes := overtimeMinutes
+			if payload.ApprovedMinutes != nil {
+				if *payload.ApprovedMinutes > overtimeMinutes {
+					_ = tx.Rollback()
+					writeError(w, http.StatusBadRequest, "核定时长不能超过计算时长")
+					return
+				}
+				approvedMinutes = *payload.ApprovedMinutes
+			}
+			approved = approvedMinutes
+		}
+		if _, err := tx.ExecContext(r.Context(), `UPDATE overtime_records
+SET status = ?, approved_minutes = ?, reviewer_id = ?, review_comment = ?, reviewed_at = ?
+WHERE id = ?`, newStatus, approved, operatorID, toNullText(payload.Comment), now, id); err != nil {
+			_ = tx.Rollback()
+			writeError(w, http.StatusInternalServerError, "审核失败")
+			return
+		}
+		updated++
+	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "审核失败")
+		return
+	}
+
+	action := "reject_overtime"
+	if payload.Action == "approve" {
+		action = "approve_overtime"
+	}
+	h.logAudit(r, action, "overtime_record", sql.NullInt64{}, payload)
+	writeJSON(w, http.StatusOK, map[string]any{"message": "审核完成", "updated": updated})
+}
+
+// ReportOvertime 仅统计已通过的加班，按员工与加班类别汇总。
+func (h *Handler) ReportOvertime(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	where, args, message, err := h.buildOvertimeFilter(r, overtimeStatusApproved)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取部门失败")
+		return
+	}
+	if message != "" {
+		writeError(w, http.StatusBadRequest, message)
+		return
+	}
+	items, err := h.summarizeApprovedOvertime(r.Context(), where, args...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取加班报表失败")
+		return
+	}
+	start, end, _ := parseAttendanceRange(r.URL.Query().Get("startDate"), r.URL.Query().Get("endDate"))
+	writeJSON(w, http.StatusOK, OvertimeReportResponse{
+		StartDate: start.Format("2006-01-02"),
+		EndDate:   end.AddDate(0, 0, -1).Format("2006-01-02"),
+		Items:     items,
+	})
+}
+
+func (h *Handler) ExportOvertime(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	where, args, message, err := h.buildOvertimeFilter(r, overtimeStatusApproved)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取部门失败")
+		return
+	}
+	if message != "" {
+		writeError(w, http.StatusBadRequest, message)
+		return
+	}
+	summary, err := h.summarizeApprovedOvertime(r.Context(), where, args...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "导出失败")
+		return
+	}
+	details, err := h.queryOvertimeRecords(r.Context(), where+" ORDER BY o.work_date ASC, e.employee_code ASC", args...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "导出失败")
+		return
+	}
+
+	file := excelize.NewFile()
+	summarySheet := "加班汇总"
+	file.SetSheetName("Sheet1", summarySheet)
+	summaryHeaders := []string{"工号", "姓名", "部门", "工作日加班(分钟)", "休息日加班(分钟)", "节假日加班(分钟)", "合计(分钟)", "合计(小时)"}
+	for col, header := range summaryHeaders {
+		cell, _ := excelize.CoordinatesToCellName(col+1, 1)
+		_ = file.SetCellValue(summarySheet, cell, header)
+	}
+	for i, item := range summary {
+		values := []any{item.EmployeeCode, item.Name, item.Department, item.WeekdayMinutes, item.RestdayMinutes, item.HolidayMinutes, item.TotalMinutes, item.TotalHours}
+		for col, value := range values {
+			cell, _ := excelize.CoordinatesToCellName(col+1, i+2)
+			_ = file.SetCellValue(summarySheet, cell, spreadsheetCellValue(value))
+		}
+	}
+	file.SetColWidth(summarySheet, "A", "H", 18)
+
+	detailSheet := "加班明细"
+	_, _ = file.NewSheet(detailSheet)
+	detailHeaders := []string{"日期", "工号", "姓名", "部门", "加班类别", "上班时间", "下班时间", "自动下班", "应出勤", "实际工时", "计算加班(分钟)", "核定加班(分钟)", "审核意见", "审核时间"}
+	for col, header := range detailHeaders {
+		cell, _ := excelize.CoordinatesToCellName(col+1, 1)
+		_ = file.SetCellValue(detailSheet, cell, header)
+	}
+	for i, item := range details {
+		autoClosed := ""
+		if item.AutoClosed {
+			autoClosed = "是"
+		}
+		values := []any{item.WorkDate, item.EmployeeCode, item.Name, item.Department, item.CategoryLabel, item.SessionStartAt, item.SessionEndAt, autoClosed,
+			item.Scheduled, item.Worked, item.OvertimeMinutes, item.ApprovedMinutes, item.ReviewComment, item.ReviewedAt}
+		for col, value := range values {
+			cell, _ := excelize.CoordinatesToCellName(col+1, i+2)
+			_ = file.SetCellValue(detailSheet, cell, spreadsheetCellValue(value))
+		}
+	}
+	file.SetColWidth(detailSheet, "A", "N", 18)
+
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", "attachment; filename=worksentry_overtime.xlsx")
+	_ = file.Write(w)
+}
+
+func (h *Handler) buildOvertimeFilter(r *http.Request, status string) (string, []any, string, error) {
+	start, end, message := parseAttendanceRange(r.URL.Query().Get("startDate"), r.URL.Query().Get("endDate"))
+	if message != "" {
+		return "", nil, message, nil
+	}
+	where := "WHERE o.work_date >= ? AND o.work_date < ?"
+	args := []any{start.Format("2006-01-02"), end.Format("2006-01-02")}
+	if status != "" {
+		where += " AND o.status = ?"
+		args = append(args, status)
+	}
+	if category := strings.TrimSpace(r.URL.Query().Get("category")); category != "" {
+		where += " AND o.category = ?"
+		args = append(args, category)
+	}
+	clause, clauseArgs, err := h.departmentFilter(r.Context(), "o.department_id", parseInt64(r.URL.Query().Get("departmentId")))
+	if err != nil {
+		return "", nil, "", err
+	}
+	where += clause
+	args = append(args, clauseArgs...)
+	if keyword := strings.TrimSpace(r.URL.Query().Get("keyword")); keyword != "" {
+		where += " AND (e.employee_code LIKE ? OR e.name LIKE ?)"
+		like := "%" + keyword + "%"
+		args = append(args, like, like)
+	}
+	return where, args, "", nil
+}
+
+func (h *Handler) queryOvertimeRecords(ctx context.Context, where string, args ...any) ([]OvertimeRecordView, error) {
+	rows, err := h.DB.QueryContext(ctx, overtimeSelectSQL+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]OvertimeRecordView, 0)
+	for rows.Next() {
+		var (
+			item          OvertimeRecordView
+			workDate      time.Time
+			startAt       sql.NullTime
+			endAt         sql.NullTime
+			scheduled     int64
+			worked        int64
+			approved      sql.NullInt32
+			reviewComment sql.NullString
+			reviewedAt    sql.NullTime
+		)
+		if err := rows.Scan(&item.ID, &workDate, &item.EmployeeCode, &item.Name, &item.Department, &startAt, &endAt, &item.AutoClosed, &item.Category,
+			&scheduled, &worked, &item.OvertimeMinutes, &approved, &item.Status, &reviewComment, &reviewedAt); err != nil {
+			return nil, err
+		}
+		item.WorkDate = workDate.Format("2006-01-02")
+		item.SessionStartAt = "-"
+		if startAt.Valid {
+			item.SessionStartAt = formatTime(startAt.Time)
+		}
+		item.SessionEndAt = "-"
+		if endAt.Valid {
+			item.SessionEndAt = formatTime(endAt.Time)
+		}
+		item.CategoryLabel = overtimeCategoryLabel(item.Category)
+		item.Scheduled = formatDuration(scheduled)
+		item.Worked = formatDuration(worked)
+		item.ApprovedMinutes = approved.Int32
+		item.StatusLabel = overtimeStatusLabel(item.Status)
+		item.ReviewComment = nullString(reviewComment)
+		if reviewedAt.Valid {
+			item.ReviewedAt = formatTime(reviewedAt.Time)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (h *Handler) summarizeApprovedOvertime(ctx context.Context, where string, args ...any) ([]OvertimeSummaryItem, error) {
+	rows, err := h.DB.QueryContext(ctx, `SELECT e.employee_code, e.name, COALESCE(d.name, ''), o.category, SUM(COALESCE(o.approved_minutes, o.overtime_minutes))
+FROM overtime_records o
+JOIN employees e ON o.employee_id = e.id
+LEFT JOIN departments d ON e.department_id = d.id `+where+`
+GROUP BY e.id, e.employee_code, e.name, d.name, o.category`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byCode := map[string]*OvertimeSummaryItem{}
+	for rows.Next() {
+		var code, name, department, category string
+		var minutes int64
+		if err := rows.Scan(&code, &name, &department, &category, &minutes); err != nil {
+			return nil, err
+		}
+		item, ok := byCode[code]
+		if !ok {
+			item = &OvertimeSummaryItem{EmployeeCode: code, Name: name, Department: department}
+			byCode[code] = item
+		}
+		switch category {
+		case overtimeWeekday:
+			item.WeekdayMinutes += minutes
+		case overtimeRestday:
+			item.RestdayMinutes += minutes
+		case overtimeHoliday:
+			item.HolidayMinutes += minutes
+		}
+		item.TotalMinutes += minutes
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	items := make([]OvertimeSummaryItem, 0, len(byCode))
+	for _, item := range byCode {
+		item.TotalHours = float64(item.TotalMinutes) / 60
+		items = append(items, *item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].TotalMinutes != items[j].TotalMinutes {
+			return items[i].TotalMinutes > items[j].TotalMinutes
+		}
+		return items[i].EmployeeCode < items[j].EmployeeCode
+	})
+	return items, nil
+}
@@ -0,0 +1,536 @@
+package handlers
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRawRetentionDays = 7
+	rawArchiveBatchSize     = 5000
+	// 单次清理最多归档的天数，积压较多时分多轮完成
+	rawArchiveMaxDaysPerRun = 60
+)
+
+type rawArchiveRecord struct {
+	ID            int64     `json:"id"`
+	EmployeeID    int64     `json:"employeeId"`
+	ReceivedAt    time.Time `json:"receivedAt"`
+	ProcessName   *string   `json:"processName,omitempty"`
+	WindowTitle   *string   `json:"windowTitle,omitempty"`
+	IdleSeconds   int32     `json:"idleSeconds"`
+	Status        string    `json:"status"`
+	ClientVersion *string   `json:"clientVersion,omitempty"`
+	IPAddress     *string   `json:"ipAddress,omitempty"`
+}
+
+type RawArchiveView struct {
+	Date       string `json:"date"`
+	RowCount   int32  `json:"rowCount"`
+	SizeBytes  int64  `json:"sizeBytes"`
+	ArchivedAt string `json:"archivedAt"`
+	RestoredAt string `json:"restoredAt"`
+}
+
+type RawArchiveEventView struct {
+	ID            int64  `json:"id"`
+	EmployeeCode  string `json:"employeeCode"`
+	Name          string `json:"name"`
+	ReceivedAt    string `json:"receivedAt"`
+	ProcessName   string `json:"processName"`
+	WindowTitle   string `json:"windowTitle"`
+	IdleSeconds   int32  `json:"idleSeconds"`
+	Status        string `json:"status"`
+	StatusLabel   string `json:"statusLabel"`
+	ClientVersion string `json:"clientVersion"`
+	IPAddress     string `json:"ipAddress"`
+}
+
+type RawArchiveEventListResponse struct {
+	Total int64                 `json:"total"`
+	Items []RawArchiveEventView `json:"items"`
+}
+
+type RawArchiveRestorePayload struct {
+	Date         string `json:"date"`
+	EmployeeCode string `json:"employeeCode"`
+}
+
+// cleanupRawEvents 清理超过保留天数的原始流水；配置了归档目录时先按天归档再删除。
+func (h *Handler) cleanupRawEvents(ctx context.Context) {
+	settings := h.getSettingsOrDefaultByContext(ctx)
+	days := int(settings.RawRetentionDays)
+	if days <= 0 {
+		days = defaultRawRetentionDays
+	}
+	now := time.Now()
+	cutoff := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -days)
+
+	dir := h.rawArchiveDir()
+	if dir == "" || h.DB == nil {
+		if err := h.Queries.DeleteRawEventsBefore(ctx, cutoff); err != nil {
+			log.Printf("原始流水清理失败: %v", err)
+		}
+		return
+	}
+
+	for i := 0; i < rawArchiveMaxDaysPerRun; i++ {
+		var oldest sql.NullTime
+		if err := h.DB.QueryRowContext(ctx, "SELECT MIN(received_at) FROM raw_events WHERE received_at < ?", cutoff).Scan(&oldest); err != nil {
+			log.Printf("原始流水归档失败: %v", err)
+			return
+		}
+		if !oldest.Valid {
+			return
+		}
+		day := time.Date(oldest.Time.Year(), oldest.Time.Month(), oldest.Time.Day(), 0, 0, 0, 0, oldest.Time.Location())
+		count, err := h.archiveRawEventsDay(ctx, dir, day)
+		if err != nil {
+			// 归档失败时保留数据，下次重试
+			log.Printf("原始流水归档失败: date=%s %v", day.Format("2006-01-02"), err)
+			return
+		}
+		log.Printf("原始流水已归档: date=%s rows=%d", day.Format("2006-01-02"), count)
+	}
+}
+
+func (h *Handler) rawArchiveDir() string {
+	if h.Config == nil {
+		return ""
+	}
+	return strings.TrimSpace(h.Config.App.RawArchiveDir)
+}
+
+func rawArchivePath(dir string, day time.Time) string {
+	return filepath.Join(dir, day.Format("2006"), day.Format("01"), "raw_events_"+day.Format("2006-01-02")+".ndjson.gz")
+}
+
+// archiveRawEventsDay 将某天的流水写入当天归档文件后删除。文件已存在时以新的 gzip 分段追加，
+// 并跳过已归档的记录，因此中途失败或恢复后再次归档都不会产生重复。
+func (h *Handler) archiveRawEventsDay(ctx context.Context, dir string, day time.Time) (int, error) {
+	path := rawArchivePath(dir, day)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+	archived := map[int64]struct{}{}
+	if _, err := os.Stat(path); err == nil {
+		if err := readRawArchive(path, func(record rawArchiveRecord) bool {
+			archived[record.ID] = struct{}{}
+			return true
+		}); err != nil {
+			return 0, err
+		}
+	}
+
+	tmpPath := path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmpPath)
+
+	end := day.AddDate(0, 0, 1)
+	gz := gzip.NewWriter(tmp)
+	encoder := json.NewEncoder(gz)
+	count := 0
+	var lastID int64
+	for {
+		rows, err := h.DB.QueryContext(ctx, `SELECT id, employee_id, received_at, process_name, window_title, idle_seconds, status, client_version, ip_address
+FROM raw_events
+WHERE received_at >= ? AND received_at < ? AND id > ?
+ORDER BY id
+LIMIT ?`, day, end, lastID, rawArchiveBatchSize)
+		if err != nil {
+			tmp.Close()
+			return 0, err
+		}
+		batch := 0
+		for rows.Next() {
+			var (
+				record        rawArchiveRecord
+				processName   sql.NullString
+				windowTitle   sql.NullString
+				clientVersion sql.NullString
+				ipAddress     sql.NullString
+			)
+			if err := rows.Scan(&record.ID, &record.EmployeeID, &record.ReceivedAt, &processName, &windowTitle, &record.IdleSeconds, &record.Status, &clientVersion, &ipAddress); err != nil {
+				rows.Close()
+				tmp.Close()
+				return 0, err
+			}
+			batch++
+			lastID = record.ID
+			if _, ok := archived[record.ID]; ok {
+				continue
+			}
+			record.ProcessName = nullStringPtr(processName)
+			record.WindowTitle = nullStringPtr(windowTitle)
+			record.ClientVersion = nullStringPtr(clientVersion)
+			record.IPAddress = nullStringPtr(ipAddress)
+			if err := encoder.Encode(record); err != nil {
+				rows.Close()
+				tmp.Close()
+				return 0, err
+			}
+			count++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			tmp.Close()
+			return 0, err
+		}
+		if batch < rawArchiveBatchSize {
+			break
+		}
+	}
+	if err := gz.Close(); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+
+	if count > 0 {
+		if err := appendFile(path, tmpPath); err != nil {
+			return 0, err
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	var size int64
+	if info != nil {
+		size = info.Size()
+	}
+	if _, err := h.DB.ExecContext(ctx, `INSERT INTO raw_event_archives (archive_date, file_path, row_count, size_bytes, archived_at)
+VALUES (?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE file_path = VALUES(file_path), row_count = row_count + VALUES(row_count), size_bytes = VALUES(size_bytes), archived_at = VALUES(archived_at)`,
+		day.Format("2006-01-02"), path, count, size, time.Now()); err != nil {
+		return 0, err
+	}
+
+	// 只删除已写入归档的记录，扫描之后新写入的同日数据留待下次处理
+	for {
+		result, err := h.DB.ExecContext(ctx, "DELETE FROM raw_events WHERE received_at >= ? AND received_at < ? AND id <= ? LIMIT ?", day, end, lastID, rawArchiveBatchSize)
+		if err != nil {
+			return count, err
+		}
+		affected, _ := result.RowsAffected()
+		if affected < rawArchiveBatchSize {
+			break
+		}
+	}
+	return count, nil
+}
+
+func appendFile(path string, fromPath string) error {
+	from, err := os.Open(fromPath)
+	if err != nil {
+		return err
+	}
+	defer from.Close()
+	target, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(target, from); err != nil {
+		target.Close()
+		return err
+	}
+	if err := target.Sync(); err != nil {
+		target.Close()
+		return err
+	}
+	return target.Close()
+}
+
+// readRawArchive 逐行读取归档文件，fn 返回 false 时提前结束。
+func readRawArchive(path string, fn func(record rawArchiveRecord) bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	decoder := json.NewDecoder(gz)
+	for {
+		var record rawArchiveRecord
+		if err := decoder.Decode(&record); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if !fn(record) {
+			return nil
+		}
+	}
+}
+
+func nullStringPtr(value sql.NullString) *string {
+	if !value.Valid {
+		return nil
+	}
+	return &value.String
+}
+
+func derefString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func (h *Handler) RawArchives(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	where := "WHERE 1 = 1"
+	args := []any{}
+	if startValue := r.URL.Query().Get("startDate"); startValue != "" {
+		startDate, err := parseDate(startValue)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "开始日期格式错误")
+			return
+		}
+		where += " AND archive_date >= ?"
+		args = append(args, startDate.Format("2006-01-02"))
+	}
+	if endValue := r.URL.Query().Get("endDate"); endValue != "" {
+		endDate, err := parseDate(endValue)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "结束日期格式错误")
+			return
+		}
+		where += " AND archive_date <= ?"
+		args = append(args, endDate.Format("2006-01-02"))
+	}
+	rows, err := h.DB.QueryContext(r.Context(), "SELECT archive_date, row_count, size_bytes, archived_at, restored_at FROM raw_event_archives "+where+" ORDER BY archive_date DESC LIMIT 400", args...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取归档失败")
+		return
+	}
+	defer rows.Close()
+	items := make([]RawArchiveView, 0)
+	for rows.Next() {
+		var item RawArchiveView
+		var date time.Time
+		var archivedAt time.Time
+		var restoredAt sql.NullTime
+		if err := rows.Scan(&date, &item.RowCount, &item.SizeBytes, &archivedAt, &restoredAt); err != nil {
+			writeError(w, http.StatusInternalServerError, "读取归档失败")
+			return
+		}
+		item.Date = date.Format("2006-01-02")
+		item.ArchivedAt = formatTime(archivedAt)
+		if restoredAt.Valid {
+			item.RestoredAt = formatTime(restoredAt.Time)
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "读取归档失败")
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+// RawArchiveEvents 直接从归档文件查询某天的原始流水，可按工号、状态和关键词过滤。
+func (h *Handler) RawArchiveEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	day, employeeID, message := h.parseRawArchiveTarget(r.Context(), r.URL.Query().Get("date"), r.URL.Query().Get("employeeCode"))
+	if message != "" {
+		writeError(w, http.StatusBadRequest, message)
+		return
+	}
+	status := strings.TrimSpace(r.URL.Query().Get("status"))
+	keyword := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("keyword")))
+	page := parseInt(r.URL.Query().Get("page"), 1)
+	pageSize := parseInt(r.URL.Query().Get("pageSize"), 20)
+	if pageSize <= 0 || pageSize > 200 {
+		pageSize = 20
+	}
+	if page <= 0 {
+		page = 1
+	}
+
+	path := rawArchivePath(h.rawArchiveDir(), day)
+	if _, err := os.Stat(path); err != nil {
+		writeError(w, http.StatusNotFound, "该日期没有归档")
+		return
+	}
+	names, err := loadEmployeeNames(r.Context(), h.DB)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取员工失败")
+		return
+	}
+
+	offset := (page - 1) * pageSize
+	var total int64
+	items := make([]RawArchiveEventView, 0, pageSize)
+	err = readRawArchive(path, func(record rawArchiveRecord) bool {
+		if employeeID > 0 && record.EmployeeID != employeeID {
+			return true
+		}
+		if status != "" && record.Status != status {
+			return true
+		}
+		if keyword != "" && !strings.Contains(strings.ToLower(derefString(record.ProcessName)+" "+derefString(record.WindowTitle)), keyword) {
+			return true
+		}
+		total++
+		if total <= int64(offset) || len(items) >= pageSize {
+			return true
+		}
+		name := names[record.EmployeeID]
+		items = append(items, RawArchiveEventView{
+			ID:            record.ID,
+			EmployeeCode:  name.Code,
+			Name:          name.Name,
+			ReceivedAt:    formatTime(record.ReceivedAt.In(time.Local)),
+			ProcessName:   derefString(record.ProcessName),
+			WindowTitle:   derefString(record.WindowTitle),
+			IdleSeconds:   record.IdleSeconds,
+			Status:        record.Status,
+			StatusLabel:   statusLabel(record.Status),
+			ClientVersion: derefString(record.ClientVersion),
+			IPAddress:     derefString(record.IPAddress),
+		})
+		return true
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取归档文件失败")
+		return
+	}
+	writeJSON(w, http.StatusOK, RawArchiveEventListResponse{Total: total, Items: items})
+}
+
+// RawArchiveRestore 将归档的某天流水写回 raw_events（保留原 ID，已存在的跳过）。
+// 恢复的数据仍早于保留期，会在下次清理时再次移除，但不会重复写入归档。
+func (h *Handler) RawArchiveRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	var payload RawArchiveRestorePayload
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "参数格式错误")
+		return
+	}
+	day, employeeID, message := h.parseRawArchiveTarget(r.Context(), payload.Date, payload.EmployeeCode)
+	if message != "" {
+		writeError(w, http.StatusBadRequest, message)
+		return
+	}
+	path := rawArchivePath(h.rawArchiveDir(), day)
+	if _, err := os.Stat(path); err != nil {
+		writeError(w, http.StatusNotFound, "该日期没有归档")
+		return
+	}
+
+	restored := 0
+	var insertErr error
+	batch := make([]rawArchiveRecord, 0, 500)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		query := "INSERT IGNORE INTO raw_events (id, employee_id, received_at, process_name, window_title, idle_seconds, status, client_version, ip_address) VALUES "
+		args := make([]any, 0, len(batch)*9)
+		for i, record := range batch {
+			if i > 0 {
+				query += ", "
+			}
+			query += "(?, ?, ?, ?, ?, ?, ?, ?, ?)"
+			args = append(args, record.ID, record.EmployeeID, record.ReceivedAt.In(time.Local), record.ProcessName, record.WindowTitle,
+				record.IdleSeconds, record.Status, record.ClientVersion, record.IPAddress)
+		}
+		result, err := h.DB.ExecContext(r.Context(), query, args...)
+		if err != nil {
+			return err
+		}
+		affected, _ := result.RowsAffected()
+		restored += int(affected)
+		batch = batch[:0]
+		return nil
+	}
+	err := readRawArchive(path, func(record rawArchiveRecord) bool {
+		if employeeID > 0 && record.EmployeeID != employeeID {
+			return true
+		}
+		batch = append(batch, record)
+		if len(batch) >= cap(batch) {
+			if insertErr = flush(); insertErr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if err == nil && insertErr == nil {
+		insertErr = flush()
+	}
+	if err != nil || insertErr != nil {
+		writeError(w, http.StatusInternalServerError, "恢复归档失败")
+		return
+	}
+	_, _ = h.DB.ExecContext(r.Context(), "UPDATE raw_event_archives SET restored_at = ? WHERE archive_date = ?", time.Now(), day.Format("2006-01-02"))
+
+	h.logAudit(r, "restore_raw_archive", "raw_event_archive", sql.NullInt64{}, map[string]any{
+		"date":         day.Format("2006-01-02"),
+		"employeeCode": payload.EmployeeCode,
+		"restored":     restored,
+	})
+	writeJSON(w, http.StatusOK, map[string]any{"restored": restored})
+}
+
+func (h *Handler) parseRawArchiveTarget(ctx context.Context, dateValue string, employeeCode string) (time.Time, int64, string) {
+	if h.DB == nil {
+		return time.Time{}, 0, "数据库未初始化"
+	}
+	if h.rawArchiveDir() == "" {
+		return time.Time{}, 0, "未配置归档目录"
+	}
+	day, err := parseDate(dateValue)
+	if err != nil {
+		return time.Time{}, 0, "日期格式错误"
+	}
+	employeeCode = strings.TrimSpace(employeeCode)
+	if employeeCode == "" {
+		return day, 0, ""
+	}
+	employee, err := h.Queries.GetEmployeeByCode(ctx, employeeCode)
+	if err != nil {
+		return day, 0, fmt.Sprintf("员工 %s 不存在", employeeCode)
+	}
+	return day, employee.ID, ""
+}
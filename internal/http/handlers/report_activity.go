@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	activityMaxDays      = 92
+	activityTitleMaxLen  = 120
+	activityDefaultLimit = 20
+	activityMaxLimit     = 200
+)
+
+// activityStatuses 带窗口信息的状态，导出时按此顺序展开。
+var activityStatuses = []string{"work", "normal", "fish", "idle"}
+
+var (
+	// 标题前缀的未读数与修改标记，如 "(3) "、"[2] "、"● "、"* "
+	titleBadgePattern = regexp.MustCompile(`^(\(\d+\)|\[\d+\]|[●•*])\s*`)
+	titleCountPattern = regexp.MustCompile(`\s*[(（]\d+[)）]`)
+	titleSpacePattern = regexp.MustCompile(`\s+`)
+
+	personalPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+		regexp.MustCompile(`(^|\D)1[3-9]\d{9}(\D|$)`),
+		regexp.MustCompile(`(^|\D)\d{17}[\dXx](\D|$)`),
+		regexp.MustCompile(`(^|\D)\d{16,19}(\D|$)`),
+	}
+	personalKeywords = []string{"身份证", "银行卡", "密码", "工资", "薪资", "病历", "体检", "password", "passport"}
+)
+
+// splitDescription 拆分 "进程：标题"，与 buildDescription 对应；无分隔符时整体视为进程名。
+func splitDescription(description string) (string, string) {
+	description = strings.TrimSpace(description)
+	if process, title, ok := strings.Cut(description, "："); ok {
+		return strings.TrimSpace(process), strings.TrimSpace(title)
+	}
+	return description, ""
+}
+
+// normalizeTitle 去掉未读数、修改标记与多余空白，使同一文档或页面的标题聚合到一起。
+func normalizeTitle(title string) string {
+	for {
+		trimmed := titleBadgePattern.ReplaceAllString(title, "")
+		if trimmed == title {
+			break
+		}
+		title = trimmed
+	}
+	title = titleCountPattern.ReplaceAllString(title, "")
+	title = strings.TrimSpace(titleSpacePattern.ReplaceAllString(title, " "))
+	if utf8.RuneCountInString(title) > activityTitleMaxLen {
+		title = string([]rune(title)[:activityTitleMaxLen]) + "…"
+	}
+	return title
+}
+
+// looksPersonal 判断标题是否疑似包含邮箱、手机号、证件号、卡号等个人信息。
+func looksPersonal(title string) bool {
+	lower := strings.ToLower(title)
+	for _, keyword := range personalKeywords {
+		if strings.Contains(lower, keyword) {
+			return true
+		}
+	}
+	for _, pattern := range personalPatterns {
+		if pattern.MatchString(title) {
+			return true
+		}
+	}
+	return false
+}
+
+type ActivityTopItem struct {
+	Process      string           `json:"process,omitempty"`
+	Name         string           `json:"name"`
+	TotalSeconds int64            `json:"totalSeconds"`
+	Duration     string           `json:"duration"`
+	Share        string           `json:"share"`
+	Seconds      map[string]int64 `json:"seconds"`
+}
+
+type ActivityTopResponse struct {
+	StartDate       string            `json:"startDate"`
+	EndDate         string            `json:"endDate"`
+	EmployeeCode    string            `json:"employeeCode"`
+	DepartmentID    int64             `json:"departmentId"`
+	TotalSeconds    int64             `json:"totalSeconds"`
+	ExcludedSeconds int64             `json:"excludedSeconds"`
+	Processes       []ActivityTopItem `json:"processes"`
+	Titles          []ActivityTopItem `json:"titles"`
+}
+
+type activityTotal struct {
+	Process string
+	Name    string
+	Total   int64
+	Seconds map[string]int64
+}
+
+func (a *activityTotal) add(status string, seconds int64) {
+	a.Total += seconds
+	a.Seconds[status] += seconds
+}
+
+// buildActivityTop 按描述与状态汇总区间内的时长，再拆分进程与标题聚合；
+// excludePersonal 时疑似含个人信息的描述不计入标题排行，进程名本身不含个人信息时仍计入进程。
+func (h *Handler) buildActivityTop(ctx context.Context, filter activityFilter, limit int, excludePersonal bool) (ActivityTopResponse, error) {
+	resp := ActivityTopResponse{
+		StartDate:    filter.Start.Format("2006-01-02"),
+		EndDate:      filter.End.AddDate(0, 0, -1).Format("2006-01-02"),
+		EmployeeCode: filter.EmployeeCode,
+		DepartmentID: filter.DepartmentID,
+	}
+	query := `SELECT ts.description, ts.status,
+ SUM(TIMESTAMPDIFF(SECOND, GREATEST(ts.start_at, ?), LEAST(ts.end_at, ?)))
+FROM time_segments ts
+JOIN employees e ON ts.employee_id = e.id
+WHERE ts.start_at < ? AND ts.end_at > ? AND ts.description IS NOT NULL AND ts.description <> ''`
+	args := []any{filter.Start, filter.End, filter.End, filter.Start}
+	if filter.EmployeeID > 0 {
+		query += " AND ts.employee_id = ?"
+		args = append(args, filter.EmployeeID)
+	}
+	clause, clauseArgs, err := h.departmentFilter(ctx, "e.department_id", filter.DepartmentID)
+	if err != nil {
+		return resp, err
+	}
+	query += clause + " GROUP BY ts.description, ts.status"
+	args = append(args, clauseArgs...)
+
+	rows, err := h.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return resp, err
+	}
+	defer rows.Close()
+	processes := map[string]*activityTotal{}
+	titles := map[string]*activityTotal{}
+	for rows.Next() {
+		var description string
+		var status string
+		var seconds int64
+		if err := rows.Scan(&description, &status, &seconds); err != nil {
+			return resp, err
+		}
+		if seconds <= 0 {
+			continue
+		}
+		process, title := splitDescription(description)
+		resp.TotalSeconds += seconds
+		// 在截断与拆分之前检查完整描述：只有标题的描述会被当作进程名，长标题截断后也可能丢掉个人信息
+		personal := excludePersonal && looksPersonal(description)
+		if personal {
+			resp.ExcludedSeconds += seconds
+		}
+		if process != "" && !(personal && looksPersonal(process)) {
+			item, ok := processes[process]
+			if !ok {
+				item = &activityTotal{Name: process, Seconds: map[string]int64{}}
+				processes[process] = item
+			}
+			item.add(status, seconds)
+		}
+		title = normalizeTitle(title)
+		if title == "" || personal {
+			continue
+		}
+		key := process + "\x00" + title
+		item, ok := titles[key]
+		if !ok {
+			item = &activityTotal{Process: process, Name: title, Seconds: map[string]int64{}}
+			titles[key] = item
+		}
+		item.add(status, seconds)
+	}
+	if err := rows.Err(); err != nil {
+		return resp, err
+	}
+	resp.Processes = topActivityItems(processes, limit, resp.TotalSeconds)
+	resp.Titles = topActivityItems(titles, limit, resp.TotalSeconds)
+	return resp, nil
+}
+
+func topActivityItems(totals map[string]*activityTotal, limit int, grandTotal int64) []ActivityTopItem {
+	list := make([]*activityTotal, 0, len(totals))
+	for _, item := range totals {
+		list = append(list, item)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Total != list[j].Total {
+			return list[i].Total > list[j].Total
+		}
+		return list[i].Name < list[j].Name
+	})
+	items := make([]ActivityTopItem, 0, minInt(len(list), limit))
+	for i := 0; i < len(list) && i < limit; i++ {
+		share := 0.0
+		if grandTotal > 0 {
+			share = float64(list[i].Total) / float64(grandTotal)
+		}
+		items = append(items, ActivityTopItem{
+			Process:      list[i].Process,
+			Name:         list[i].Name,
+			TotalSeconds: list[i].Total,
+			Duration:     formatDuration(list[i].Total),
+			Share:        formatPercent(share),
+			Seconds:      list[i].Seconds,
+		})
+	}
+	return items
+}
+
+func (h *Handler) loadActivityTop(w http.ResponseWriter, r *http.Request) (ActivityTopResponse, bool) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return ActivityTopResponse{}, false
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return ActivityTopResponse{}, false
+	}
+	filter, status, message := h.parseActivityFilter(r, activityMaxDays)
+	if message != "" {
+		writeError(w, status, message)
+		return ActivityTopResponse{}, false
+	}
+	limit := parseInt(r.URL.Query().Get("limit"), activityDefaultLimit)
+	if limit <= 0 || limit > activityMaxLimit {
+		limit = activityDefaultLimit
+	}
+	excludePersonal := r.URL.Query().Get("excludePersonal")
+	resp, err := h.buildActivityTop(r.Context(), filter, limit, excludePersonal == "1" || excludePersonal == "true")
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取时间段失败")
+		return resp, false
+	}
+	return resp, true
+}
+
+// ReportActivityTop 返回员工、部门或全公司在区间内时长最多的应用与窗口标题。
+func (h *Handler) ReportActivityTop(w http.ResponseWriter, r *http.Request) {
+	resp, ok := h.loadActivityTop(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) ExportActivityTop(w http.ResponseWriter, r *http.Request) {
+	resp, ok := h.loadActivityTop(w, r)
+	if !ok {
+		return
+	}
+
+	file := excelize.NewFile()
+	file.SetSheetName("Sheet1", "应用")
+	writeActivitySheet(file, "应用", []string{"排名", "应用"}, resp.Processes, false)
+	if _, err := file.NewSheet("窗口标题"); err != nil {
+		writeError(w, http.StatusInternalServerError, "导出失败")
+		return
+	}
+	writeActivitySheet(file, "窗口标题", []string{"排名", "应用", "窗口标题"}, resp.Titles, true)
+
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", "attachment; filename=worksentry_activity.xlsx")
+	_ = file.Write(w)
+}
+
+func writeActivitySheet(file *excelize.File, sheet string, headers []string, items []ActivityTopItem, withProcess bool) {
+	headers = append(headers, "总时长", "占比")
+	for _, code := range activityStatuses {
+		headers = append(headers, statusLabel(code))
+	}
+	for col, header := range headers {
+		cell, _ := excelize.CoordinatesToCellName(col+1, 1)
+		_ = file.SetCellValue(sheet, cell, header)
+	}
+	for i, item := range items {
+		values := []any{i + 1}
+		if withProcess {
+			values = append(values, item.Process)
+		}
+		values = append(values, item.Name, item.Duration, item.Share)
+		for _, code := range activityStatuses {
+			values = append(values, formatDuration(item.Seconds[code]))
+		}
+		for col, value := range values {
+			cell, _ := excelize.CoordinatesToCellName(col+1, i+2)
+			_ = file.SetCellValue(sheet, cell, spreadsheetCellValue(value))
+		}
+	}
+	nameCol, firstValueCol := "B", "C"
+	if withProcess {
+		nameCol, firstValueCol = "C", "D"
+		file.SetColWidth(sheet, "B", "B", 20)
+	}
+	file.SetColWidth(sheet, nameCol, nameCol, 48)
+	lastCol, _ := excelize.ColumnNumberToName(len(headers))
+	file.SetColWidth(sheet, firstValueCol, lastCol, 12)
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitDescription(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		wantProcess string
+		wantTitle   string
+	}{
+		{name: "进程与标题", description: "chrome：周报 - 飞书", wantProcess: "chrome", wantTitle: "周报 - 飞书"},
+		{name: "首尾空白", description: "  code ： main.go  ", wantProcess: "code", wantTitle: "main.go"},
+		{name: "标题含分隔符", description: "wechat：张三：你好", wantProcess: "wechat", wantTitle: "张三：你好"},
+		{name: "只有进程", description: "explorer", wantProcess: "explorer"},
+		{name: "英文冒号不拆分", description: "notepad: a.txt", wantProcess: "notepad: a.txt"},
+		{name: "空进程", description: "：标题", wantTitle: "标题"},
+		{name: "空描述", description: "   "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			process, title := splitDescription(tt.description)
+			if process != tt.wantProcess || title != tt.wantTitle {
+				t.Fatalf("splitDescription(%q) = (%q, %q)，期望 (%q, %q)", tt.description, process, title, tt.wantProcess, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestLooksPersonalRawDescription(t *testing.T) {
+	longTitle := "chrome：" + strings.Repeat("项目文档", activityTitleMaxLen/4) + " 联系 13812345678"
+	tests := []struct {
+		name        string
+		description string
+		want        bool
+	}{
+		{name: "普通标题", description: "code：main.go - worksentry", want: false},
+		{name: "只有标题的邮箱", description: "zhangsan@example.com - 收件箱", want: true},
+		{name: "只有标题的手机号", description: "联系人 13812345678", want: true},
+		{name: "关键字", description: "excel：2024 工资表.xlsx", want: true},
+		{name: "截断位置之后的手机号", description: longTitle, want: true},
+		{name: "短数字不算卡号", description: "chrome：订单 202401", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := looksPersonal(tt.description); got != tt.want {
+				t.Fatalf("looksPersonal(%q) = %v，期望 %v", tt.description, got, tt.want)
+			}
+		})
+	}
+	// 截断后的标题已看不到手机号，必须在截断前检查
+	_, title := splitDescription(longTitle)
+	if looksPersonal(normalizeTitle(title)) {
+		t.Fatal("截断后的标题不应再包含手机号，用例需调整")
+	}
+}
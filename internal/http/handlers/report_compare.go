@@ -0,0 +1,422 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// compareDefaultThreshold 默认变化幅度达到 20% 视为显著变化
+const compareDefaultThreshold = 0.2
+
+type compareValues struct {
+	AttendanceDays int
+	Values         DailyStatsValues
+	BreakSeconds   int64
+	Violations     int64
+}
+
+// compareMetric 对比指标。时长类按出勤日均值比较，消除区间长度和请假天数的影响；
+// Floor 为判定显著变化所需的最小绝对变化量，避免基数很小时百分比被放大。
+type compareMetric struct {
+	Key          string
+	Label        string
+	Kind         string
+	HigherBetter bool
+	Floor        float64
+	Value        func(compareValues) float64
+}
+
+const (
+	compareKindDuration = "duration"
+	compareKindRatio    = "ratio"
+	compareKindCount    = "count"
+)
+
+func perAttendanceDay(seconds int64, days int) float64 {
+	if days <= 0 {
+		return 0
+	}
+	return float64(seconds) / float64(days)
+}
+
+var compareMetrics = []compareMetric{
+	{Key: "effective", Label: "日均有效工时", Kind: compareKindDuration, HigherBetter: true, Floor: 600, Value: func(v compareValues) float64 {
+		return perAttendanceDay(v.Values.EffectiveSeconds, v.AttendanceDays)
+	}},
+	{Key: "attendance", Label: "日均在岗时长", Kind: compareKindDuration, HigherBetter: true, Floor: 600, Value: func(v compareValues) float64 {
+		return perAttendanceDay(v.Values.AttendanceSeconds, v.AttendanceDays)
+	}},
+	{Key: "fishRatio", Label: "摸鱼占比", Kind: compareKindRatio, Floor: 0.02, Value: func(v compareValues) float64 {
+		return v.Values.activeRatio(v.Values.FishSeconds)
+	}},
+	{Key: "break", Label: "日均休息时长", Kind: compareKindDuration, Floor: 300, Value: func(v compareValues) float64 {
+		return perAttendanceDay(v.BreakSeconds, v.AttendanceDays)
+	}},
+	{Key: "violations", Label: "考核违规次数", Kind: compareKindCount, Floor: 2, Value: func(v compareValues) float64 {
+		return float64(v.Violations)
+	}},
+}
+
+type CompareMetricView struct {
+	Metric       string   `json:"metric"`
+	Label        string   `json:"label"`
+	Previous     float64  `json:"previous"`
+	Current      float64  `json:"current"`
+	PreviousText string   `json:"previousText"`
+	CurrentText  string   `json:"currentText"`
+	Delta        float64  `json:"delta"`
+	DeltaText    string   `json:"deltaText"`
+	ChangeRate   *float64 `json:"changeRate"`
+	ChangeText   string   `json:"changeText"`
+	Trend        string   `json:"trend"`
+	Significant  bool     `json:"significant"`
+	// Assessment 仅在显著变化时给出：improved 为改善，worsened 为变差
+	Assessment string `json:"assessment,omitempty"`
+}
+
+type CompareRangeView struct {
+	StartDate string `json:"startDate"`
+	EndDate   string `json:"endDate"`
+}
+
+type CompareEmployeeItem struct {
+	EmployeeCode           string              `json:"employeeCode"`
+	Name                   string              `json:"name"`
+	Department             string              `json:"department"`
+	PreviousAttendanceDays int                 `json:"previousAttendanceDays"`
+	CurrentAttendanceDays  int                 `json:"currentAttendanceDays"`
+	Metrics                []CompareMetricView `json:"metrics"`
+	Significant            bool                `json:"significant"`
+}
+
+type CompareDepartmentItem struct {
+	DepartmentID      int64               `json:"departmentId"`
+	ParentID          int64               `json:"parentId"`
+	Department        string              `json:"department"`
+	Depth             int                 `json:"depth"`
+	PreviousEmployees int                 `json:"previousEmployees"`
+	CurrentEmployees  int                 `json:"currentEmployees"`
+	Metrics           []CompareMetricView `json:"metrics"`
+	Significant       bool                `json:"significant"`
+}
+
+type CompareReportResponse struct {
+	Current     CompareRangeView        `json:"current"`
+	Previous    CompareRangeView        `json:"previous"`
+	Threshold   float64                 `json:"threshold"`
+	Departments []CompareDepartmentItem `json:"departments"`
+	Employees   []CompareEmployeeItem   `json:"employees"`
+}
+
+type reviewTotals struct {
+	BreakSeconds int64
+	Violations   int64
+}
+
+// loadReviewTotals 按员工汇总区间内上班考核的休息时长与违规次数。
+func (h *Handler) loadReviewTotals(ctx context.Context, start time.Time, end time.Time, departmentID int64) (map[int64]reviewTotals, error) {
+	clause, clauseArgs, err := h.departmentFilter(ctx, "e.department_id", departmentID)
+	if err != nil {
+		return nil, err
+	}
+	args := append([]any{start.Format("2006-01-02"), end.Format("2006-01-02")}, clauseArgs...)
+	rows, err := h.DB.QueryContext(ctx, `SELECT r.employee_id, r.break_seconds, r.violations_json
+FROM work_session_reviews r
+JOIN employees e ON r.employee_id = e.id
+WHERE r.work_date >= ? AND r.work_date < ?`+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	totals := map[int64]reviewTotals{}
+	for rows.Next() {
+		var employeeID, breakSeconds int64
+		var violationsJSON string
+		if err := rows.Scan(&employeeID, &breakSeconds, &violationsJSON); err != nil {
+			return nil, err
+		}
+		payload := WorkSessionReviewPayload{}
+		_ = json.Unmarshal([]byte(violationsJSON), &payload)
+		total := totals[employeeID]
+		total.BreakSeconds += breakSeconds
+		total.Violations += int64(len(payload.Violations))
+		totals[employeeID] = total
+	}
+	return totals, rows.Err()
+}
+
+// loadRangeSummary 返回区间内每名员工的合计，并附带上班考核的休息时长与违规次数。
+func (h *Handler) loadRangeSummary(ctx context.Context, start time.Time, end time.Time, departmentID int64) ([]periodStats, error) {
+	result, err := h.loadPeriodStats(ctx, reportRange{Start: start, End: end, GroupBy: groupByMonth}, departmentID)
+	if err != nil {
+		return nil, err
+	}
+	reviews, err := h.loadReviewTotals(ctx, start, end, departmentID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range result.Summary {
+		total := reviews[result.Summary[i].EmployeeID]
+		result.Summary[i].BreakSeconds = total.BreakSeconds
+		result.Summary[i].Violations = total.Violations
+	}
+	return result.Summary, nil
+}
+
+// parseCompareRanges 解析本期 startDate/endDate 与对比期 compareStartDate/compareEndDate；
+// 未指定对比期时取本期之前等长的区间，两个区间不能重叠。
+func parseCompareRanges(query url.Values) (reportRange, reportRange, string) {
+	if strings.TrimSpace(query.Get("startDate")) == "" {
+		return reportRange{}, reportRange{}, "请选择开始日期"
+	}
+	current, _, message := parseReportRange(url.Values{
+		"startDate": {query.Get("startDate")},
+		"endDate":   {query.Get("endDate")},
+	})
+	if message != "" {
+		return reportRange{}, reportRange{}, message
+	}
+	compareStart := strings.TrimSpace(query.Get("compareStartDate"))
+	compareEnd := strings.TrimSpace(query.Get("compareEndDate"))
+	if compareStart == "" && compareEnd == "" {
+		days := int(math.Round(current.End.Sub(current.Start).Hours() / 24))
+		previous := reportRange{Start: current.Start.AddDate(0, 0, -days), End: current.Start}
+		return current, previous, ""
+	}
+	if compareStart == "" || compareEnd == "" {
+		return reportRange{}, reportRange{}, "对比区间需同时提供开始与结束日期"
+	}
+	previous, _, message := parseReportRange(url.Values{"startDate": {compareStart}, "endDate": {compareEnd}})
+	if message != "" {
+		return reportRange{}, reportRange{}, "对比区间" + message
+	}
+	// 区间结束为次日零点，相邻不算重叠
+	if previous.Start.Before(current.End) && current.Start.Before(previous.End) {
+		return reportRange{}, reportRange{}, "对比区间不能与当前区间重叠"
+	}
+	return current, previous, ""
+}
+
+// ReportCompare 对比两个区间的员工与部门指标，threshold 为显著变化的百分比阈值（默认 20）。
+func (h *Handler) ReportCompare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	query := r.URL.Query()
+	current, previous, message := parseCompareRanges(query)
+	if message != "" {
+		writeError(w, http.StatusBadRequest, message)
+		return
+	}
+	threshold := compareDefaultThreshold
+	if value := strings.TrimSpace(query.Get("threshold")); value != "" {
+		percent, err := strconv.ParseFloat(value, 64)
+		if err != nil || percent <= 0 || percent > 1000 {
+			writeError(w, http.StatusBadRequest, "变化阈值需为大于 0 的百分比")
+			return
+		}
+		threshold = percent / 100
+	}
+	departmentID := parseInt64(query.Get("departmentId"))
+	onlySignificant := query.Get("onlySignificant") == "1" || query.Get("onlySignificant") == "true"
+
+	currentStats, err := h.loadRangeSummary(r.Context(), current.Start, current.End, departmentID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取统计失败")
+		return
+	}
+	previousStats, err := h.loadRangeSummary(r.Context(), previous.Start, previous.End, departmentID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取统计失败")
+		return
+	}
+	tree, err := loadDepartmentTree(r.Context(), h.Queries)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取部门失败")
+		return
+	}
+
+	response := CompareReportResponse{
+		Current:     CompareRangeView{StartDate: current.Start.Format("2006-01-02"), EndDate: current.End.AddDate(0, 0, -1).Format("2006-01-02")},
+		Previous:    CompareRangeView{StartDate: previous.Start.Format("2006-01-02"), EndDate: previous.End.AddDate(0, 0, -1).Format("2006-01-02")},
+		Threshold:   threshold,
+		Departments: []CompareDepartmentItem{},
+		Employees:   []CompareEmployeeItem{},
+	}
+
+	// 部门按两期成员合并后的树序输出，某一期没有数据的部门按零值对比
+	currentMembers := periodStatsMembers(currentStats)
+	previousMembers := periodStatsMembers(previousStats)
+	currentTotals := map[int64]departmentSubtotal{}
+	for _, item := range tree.rollupDepartments(departmentID, currentMembers) {
+		currentTotals[item.DepartmentID] = item
+	}
+	previousTotals := map[int64]departmentSubtotal{}
+	for _, item := range tree.rollupDepartments(departmentID, previousMembers) {
+		previousTotals[item.DepartmentID] = item
+	}
+	for _, item := range tree.rollupDepartments(departmentID, append(append([]departmentMember{}, currentMembers...), previousMembers...)) {
+		cur, prev := currentTotals[item.DepartmentID], previousTotals[item.DepartmentID]
+		metrics, significant := compareMetricViews(subtotalCompareValues(prev), subtotalCompareValues(cur), threshold)
+		if onlySignificant && !significant {
+			continue
+		}
+		response.Departments = append(response.Departments, CompareDepartmentItem{
+			DepartmentID:      item.DepartmentID,
+			ParentID:          item.ParentID,
+			Department:        item.Department,
+			Depth:             item.Depth,
+			PreviousEmployees: prev.EmployeeCount,
+			CurrentEmployees:  cur.EmployeeCount,
+			Metrics:           metrics,
+			Significant:       significant,
+		})
+	}
+
+	employees := map[int64]*[2]periodStats{}
+	order := []int64{}
+	for i, items := range [][]periodStats{previousStats, currentStats} {
+		for _, item := range items {
+			pair, ok := employees[item.EmployeeID]
+			if !ok {
+				pair = &[2]periodStats{}
+				employees[item.EmployeeID] = pair
+				order = append(order, item.EmployeeID)
+			}
+			pair[i] = item
+		}
+	}
+	for _, id := range order {
+		pair := employees[id]
+		prev, cur := pair[0], pair[1]
+		info := cur
+		if info.EmployeeID == 0 {
+			info = prev
+		}
+		metrics, significant := compareMetricViews(periodCompareValues(prev), periodCompareValues(cur), threshold)
+		if onlySignificant && !significant {
+			continue
+		}
+		response.Employees = append(response.Employees, CompareEmployeeItem{
+			EmployeeCode:           info.EmployeeCode,
+			Name:                   info.Name,
+			Department:             info.Department,
+			PreviousAttendanceDays: prev.AttendanceDays,
+			CurrentAttendanceDays:  cur.AttendanceDays,
+			Metrics:                metrics,
+			Significant:            significant,
+		})
+	}
+	// 有显著变化的员工排在前面
+	sort.SliceStable(response.Employees, func(i, j int) bool {
+		a, b := response.Employees[i], response.Employees[j]
+		if a.Significant != b.Significant {
+			return a.Significant
+		}
+		return a.EmployeeCode < b.EmployeeCode
+	})
+	writeJSON(w, http.StatusOK, response)
+}
+
+func periodCompareValues(s periodStats) compareValues {
+	return compareValues{AttendanceDays: s.AttendanceDays, Values: s.Values, BreakSeconds: s.BreakSeconds, Violations: s.Violations}
+}
+
+func subtotalCompareValues(s departmentSubtotal) compareValues {
+	return compareValues{AttendanceDays: s.AttendanceDays, Values: s.Values, BreakSeconds: s.BreakSeconds, Violations: s.Violations}
+}
+
+// compareMetricViews 计算各指标的变化，第二个返回值表示是否有任一指标显著变化。
+func compareMetricViews(previous compareValues, current compareValues, threshold float64) ([]CompareMetricView, bool) {
+	views := make([]CompareMetricView, 0, len(compareMetrics))
+	anySignificant := false
+	for _, metric := range compareMetrics {
+		prev, cur := metric.Value(previous), metric.Value(current)
+		if metric.Kind == compareKindDuration {
+			prev, cur = math.Round(prev), math.Round(cur)
+		}
+		delta := cur - prev
+		view := CompareMetricView{
+			Metric:       metric.Key,
+			Label:        metric.Label,
+			Previous:     roundRatio(prev, metric.Kind),
+			Current:      roundRatio(cur, metric.Kind),
+			PreviousText: compareValueText(prev, metric.Kind),
+			CurrentText:  compareValueText(cur, metric.Kind),
+			Delta:        roundRatio(delta, metric.Kind),
+			DeltaText:    compareDeltaText(delta, metric.Kind),
+			Trend:        "flat",
+		}
+		switch {
+		case delta > 0:
+			view.Trend = "up"
+		case delta < 0:
+			view.Trend = "down"
+		}
+		if prev != 0 {
+			rate := math.Round(delta/prev*10000) / 10000
+			view.ChangeRate = &rate
+			view.ChangeText = fmt.Sprintf("%+.1f%%", rate*100)
+		} else if cur != 0 {
+			view.ChangeText = "新增"
+		}
+		// 上期为零时只要绝对变化达到下限即视为显著
+		if math.Abs(delta) >= metric.Floor && (prev == 0 || math.Abs(delta/prev) >= threshold) {
+			view.Significant = true
+			anySignificant = true
+			if (delta > 0) == metric.HigherBetter {
+				view.Assessment = "improved"
+			} else {
+				view.Assessment = "worsened"
+			}
+		}
+		views = append(views, view)
+	}
+	return views, anySignificant
+}
+
+func roundRatio(value float64, kind string) float64 {
+	if kind == compareKindRatio {
+		return math.Round(value*10000) / 10000
+	}
+	return value
+}
+
+func compareValueText(value float64, kind string) string {
+	switch kind {
+	case compareKindDuration:
+		return formatDuration(int64(value))
+	case compareKindRatio:
+		return formatPercent(value)
+	default:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+}
+
+func compareDeltaText(delta float64, kind string) string {
+	sign := "+"
+	if delta < 0 {
+		sign = "-"
+	}
+	switch kind {
+	case compareKindDuration:
+		return sign + formatDuration(int64(math.Abs(delta)))
+	case compareKindRatio:
+		return fmt.Sprintf("%s%.1f 个百分点", sign, math.Abs(delta)*100)
+	default:
+		return sign + strconv.FormatFloat(math.Abs(delta), 'f', -1, 64)
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseCompareRanges(t *testing.T) {
+	tests := []struct {
+		name         string
+		query        url.Values
+		wantPrevious string
+		wantMessage  string
+	}{
+		{
+			name:         "默认取之前等长区间",
+			query:        url.Values{"startDate": {"2024-03-08"}, "endDate": {"2024-03-14"}},
+			wantPrevious: "2024-03-01~2024-03-08",
+		},
+		{
+			name:         "相邻区间",
+			query:        url.Values{"startDate": {"2024-03-08"}, "endDate": {"2024-03-14"}, "compareStartDate": {"2024-03-01"}, "compareEndDate": {"2024-03-07"}},
+			wantPrevious: "2024-03-01~2024-03-08",
+		},
+		{
+			name:        "对比区间与本期重叠",
+			query:       url.Values{"startDate": {"2024-03-08"}, "endDate": {"2024-03-14"}, "compareStartDate": {"2024-03-01"}, "compareEndDate": {"2024-03-08"}},
+			wantMessage: "对比区间不能与当前区间重叠",
+		},
+		{
+			name:        "对比区间包含本期",
+			query:       url.Values{"startDate": {"2024-03-08"}, "endDate": {"2024-03-09"}, "compareStartDate": {"2024-03-01"}, "compareEndDate": {"2024-03-31"}},
+			wantMessage: "对比区间不能与当前区间重叠",
+		},
+		{
+			name:        "对比区间不完整",
+			query:       url.Values{"startDate": {"2024-03-08"}, "compareStartDate": {"2024-03-01"}},
+			wantMessage: "对比区间需同时提供开始与结束日期",
+		},
+		{
+			name:        "缺少开始日期",
+			query:       url.Values{},
+			wantMessage: "请选择开始日期",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, previous, message := parseCompareRanges(tt.query)
+			if message != tt.wantMessage {
+				t.Fatalf("message = %q，期望 %q", message, tt.wantMessage)
+			}
+			if message != "" {
+				return
+			}
+			if got := previous.Start.Format("2006-01-02") + "~" + previous.End.Format("2006-01-02"); got != tt.wantPrevious {
+				t.Fatalf("对比区间 = %s，期望 %s", got, tt.wantPrevious)
+			}
+		})
+	}
+}
+
+func TestCompareMetricViews(t *testing.T) {
+	type expect struct {
+		trend       string
+		significant bool
+		assessment  string
+		deltaText   string
+		changeText  string
+	}
+	hours := func(n int64) int64 { return n * 3600 }
+	tests := []struct {
+		name            string
+		previous        compareValues
+		current         compareValues
+		wantSignificant bool
+		want            map[string]expect
+	}{
+		{
+			name: "工时下降且摸鱼新增",
+			previous: compareValues{AttendanceDays: 5, Values: DailyStatsValues{
+				EffectiveSeconds: hours(40), AttendanceSeconds: hours(45), WorkSeconds: hours(40),
+			}},
+			current: compareValues{AttendanceDays: 5, Violations: 1, Values: DailyStatsValues{
+				EffectiveSeconds: hours(30), AttendanceSeconds: hours(45), WorkSeconds: hours(36), FishSeconds: hours(4),
+			}},
+			wantSignificant: true,
+			want: map[string]expect{
+				"effective":  {trend: "down", significant: true, assessment: "worsened", deltaText: "-02:00", changeText: "-25.0%"},
+				"attendance": {trend: "flat", deltaText: "+00:00", changeText: "+0.0%"},
+				"fishRatio":  {trend: "up", significant: true, assessment: "worsened", deltaText: "+10.0 个百分点", changeText: "新增"},
+				"break":      {trend: "flat", deltaText: "+00:00"},
+				"violations": {trend: "up", deltaText: "+1", changeText: "新增"},
+			},
+		},
+		{
+			name:     "变化未达阈值",
+			previous: compareValues{AttendanceDays: 4, BreakSeconds: hours(4), Values: DailyStatsValues{EffectiveSeconds: hours(32)}},
+			current:  compareValues{AttendanceDays: 4, BreakSeconds: hours(4) + 1800, Values: DailyStatsValues{EffectiveSeconds: hours(30)}},
+			want: map[string]expect{
+				"effective": {trend: "down", deltaText: "-00:30", changeText: "-6.2%"},
+				"break":     {trend: "up", deltaText: "+00:07", changeText: "+12.5%"},
+			},
+		},
+		{
+			name:            "出勤天数为零",
+			previous:        compareValues{Violations: 3},
+			current:         compareValues{Violations: 6},
+			wantSignificant: true,
+			want: map[string]expect{
+				"effective":  {trend: "flat", deltaText: "+00:00"},
+				"violations": {trend: "up", significant: true, assessment: "worsened", deltaText: "+3", changeText: "+100.0%"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			views, significant := compareMetricViews(tt.previous, tt.current, compareDefaultThreshold)
+			if significant != tt.wantSignificant {
+				t.Fatalf("anySignificant = %v，期望 %v", significant, tt.wantSignificant)
+			}
+			if len(views) != len(compareMetrics) {
+				t.Fatalf("指标数 = %d，期望 %d", len(views), len(compareMetrics))
+			}
+			for _, view := range views {
+				want, ok := tt.want[view.Metric]
+				if !ok {
+					continue
+				}
+				got := expect{trend: view.Trend, significant: view.Significant, assessment: view.Assessment, deltaText: view.DeltaText, changeText: view.ChangeText}
+				if got != want {
+					t.Fatalf("%s = %+v，期望 %+v", view.Metric, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// heatmapMaxDays 热力图直接扫描时间段，跨度过大时读取量不可控。
+const heatmapMaxDays = 92
+
+// heatmapStatuses 固定状态顺序，保证矩阵结构稳定。
+var heatmapStatuses = []string{"work", "normal", "fish", "idle", "break", "offline", "incident", "leave", "leave_unpaid"}
+
+var weekdayLabels = []string{"周一", "周二", "周三", "周四", "周五", "周六", "周日"}
+
+type HeatmapStatus struct {
+	Code  string `json:"code"`
+	Label string `json:"label"`
+}
+
+type HeatmapCell struct {
+	Weekday int              `json:"weekday"`
+	Hour    int              `json:"hour"`
+	Seconds map[string]int64 `json:"seconds"`
+}
+
+type HeatmapResponse struct {
+	StartDate    string          `json:"startDate"`
+	EndDate      string          `json:"endDate"`
+	EmployeeCode string          `json:"employeeCode"`
+	DepartmentID int64           `json:"departmentId"`
+	Statuses     []HeatmapStatus `json:"statuses"`
+	Weekdays     []string        `json:"weekdays"`
+	WeekdayDays  []int           `json:"weekdayDays"`
+	Matrix       [][]HeatmapCell `json:"matrix"`
+}
+
+type activityFilter struct {
+	Start        time.Time
+	End          time.Time
+	EmployeeID   int64
+	EmployeeCode string
+	DepartmentID int64
+}
+
+// heatmapWeekday 周一为 0。
+func heatmapWeekday(t time.Time) int {
+	return (int(t.Weekday()) + 6) % 7
+}
+
+// parseActivityFilter 解析 startDate/endDate（含）与员工或部门筛选，默认最近 7 天。
+func (h *Handler) parseActivityFilter(r *http.Request, maxDays int) (activityFilter, int, string) {
+	query := r.URL.Query()
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	filter := activityFilter{Start: today.AddDate(0, 0, -6), End: today.AddDate(0, 0, 1)}
+	if value := strings.TrimSpace(query.Get("startDate")); value != "" {
+		start, err := parseDate(value)
+		if err != nil {
+			return filter, http.StatusBadRequest, "开始日期格式错误"
+		}
+		filter.Start = start
+	}
+	if value := strings.TrimSpace(query.Get("endDate")); value != "" {
+		end, err := parseDate(value)
+		if err != nil {
+			return filter, http.StatusBadRequest, "结束日期格式错误"
+		}
+		filter.End = end.AddDate(0, 0, 1)
+	}
+	if !filter.End.After(filter.Start) {
+		return filter, http.StatusBadRequest, "结束日期不能早于开始日期"
+	}
+	if filter.End.After(filter.Start.AddDate(0, 0, maxDays)) {
+		return filter, http.StatusBadRequest, fmt.Sprintf("查询跨度不能超过 %d 天", maxDays)
+	}
+	filter.DepartmentID = parseInt64(query.Get("departmentId"))
+	if code := strings.TrimSpace(query.Get("employeeCode")); code != "" {
+		employee, err := h.Queries.GetEmployeeByCode(r.Context(), code)
+		if err != nil {
+			return filter, http.StatusNotFound, "员工不存在"
+		}
+		filter.EmployeeID = employee.ID
+		filter.EmployeeCode = employee.EmployeeCode
+	}
+	return filter, 0, ""
+}
+
+// buildHeatmap 按自然小时拆分时间段，累加到星期 × 小时矩阵；旧版补录段需抵扣其覆盖的离线时长。
+func (h *Handler) buildHeatmap(ctx context.Context, filter activityFilter) ([7][24]map[string]int64, error) {
+	var matrix [7][24]map[string]int64
+	for weekday := range matrix {
+		for hour := range matrix[weekday] {
+			matrix[weekday][hour] = map[string]int64{}
+		}
+	}
+
+	query := `SELECT ts.start_at, ts.end_at, ts.status, ts.source, ts.adjustment_id IS NULL
+FROM time_segments ts
+JOIN employees e ON ts.employee_id = e.id
+WHERE ts.start_at < ? AND ts.end_at > ?`
+	args := []any{filter.End, filter.Start}
+	if filter.EmployeeID > 0 {
+		query += " AND ts.employee_id = ?"
+		args = append(args, filter.EmployeeID)
+	}
+	clause, clauseArgs, err := h.departmentFilter(ctx, "e.department_id", filter.DepartmentID)
+	if err != nil {
+		return matrix, err
+	}
+	query += clause
+	args = append(args, clauseArgs...)
+
+	rows, err := h.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return matrix, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var segStart time.Time
+		var segEnd time.Time
+		var status string
+		var source string
+		var overlay bool
+		if err := rows.Scan(&segStart, &segEnd, &status, &source, &overlay); err != nil {
+			return matrix, err
+		}
+		for _, part := range splitByHour(maxTime(segStart, filter.Start), minTime(segEnd, filter.End)) {
+			cell := matrix[heatmapWeekday(part.Date)][part.Date.Hour()]
+			cell[status] += part.Seconds
+			if source == "manual" && overlay {
+				cell["offline"] -= part.Seconds
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return matrix, err
+	}
+	for weekday := range matrix {
+		for hour := range matrix[weekday] {
+			if matrix[weekday][hour]["offline"] < 0 {
+				matrix[weekday][hour]["offline"] = 0
+			}
+		}
+	}
+	return matrix, nil
+}
+
+func heatmapWeekdayDays(start time.Time, end time.Time) []int {
+	days := make([]int, 7)
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		days[heatmapWeekday(day)]++
+	}
+	return days
+}
+
+// ReportHeatmap 返回员工或部门在区间内按星期 × 小时分布的各状态时长。
+func (h *Handler) ReportHeatmap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	filter, status, message := h.parseActivityFilter(r, heatmapMaxDays)
+	if message != "" {
+		writeError(w, status, message)
+		return
+	}
+	matrix, err := h.buildHeatmap(r.Context(), filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取时间段失败")
+		return
+	}
+
+	statuses := make([]HeatmapStatus, 0, len(heatmapStatuses))
+	for _, code := range heatmapStatuses {
+		statuses = append(statuses, HeatmapStatus{Code: code, Label: statusLabel(code)})
+	}
+	cells := make([][]HeatmapCell, 7)
+	for weekday := range matrix {
+		cells[weekday] = make([]HeatmapCell, 24)
+		for hour := range matrix[weekday] {
+			seconds := make(map[string]int64, len(heatmapStatuses))
+			for _, code := range heatmapStatuses {
+				seconds[code] = matrix[weekday][hour][code]
+			}
+			cells[weekday][hour] = HeatmapCell{Weekday: weekday + 1, Hour: hour, Seconds: seconds}
+		}
+	}
+	writeJSON(w, http.StatusOK, HeatmapResponse{
+		StartDate:    filter.Start.Format("2006-01-02"),
+		EndDate:      filter.End.AddDate(0, 0, -1).Format("2006-01-02"),
+		EmployeeCode: filter.EmployeeCode,
+		DepartmentID: filter.DepartmentID,
+		Statuses:     statuses,
+		Weekdays:     weekdayLabels,
+		WeekdayDays:  heatmapWeekdayDays(filter.Start, filter.End),
+		Matrix:       cells,
+	})
+}
+
+// ExportHeatmap 每个状态一个工作表，行为星期、列为小时。
+func (h *Handler) ExportHeatmap(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	filter, status, message := h.parseActivityFilter(r, heatmapMaxDays)
+	if message != "" {
+		writeError(w, status, message)
+		return
+	}
+	matrix, err := h.buildHeatmap(r.Context(), filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "导出失败")
+		return
+	}
+
+	file := excelize.NewFile()
+	weekdayDays := heatmapWeekdayDays(filter.Start, filter.End)
+	for i, code := range heatmapStatuses {
+		sheet := statusLabel(code)
+		if i == 0 {
+			file.SetSheetName("Sheet1", sheet)
+		} else if _, err := file.NewSheet(sheet); err != nil {
+			writeError(w, http.StatusInternalServerError, "导出失败")
+			return
+		}
+		_ = file.SetCellValue(sheet, "A1", "星期")
+		_ = file.SetCellValue(sheet, "B1", "天数")
+		for hour := 0; hour < 24; hour++ {
+			cell, _ := excelize.CoordinatesToCellName(hour+3, 1)
+			_ = file.SetCellValue(sheet, cell, fmt.Sprintf("%02d:00", hour))
+		}
+		for weekday := range matrix {
+			row := weekday + 2
+			_ = file.SetCellValue(sheet, fmt.Sprintf("A%d", row), weekdayLabels[weekday])
+			_ = file.SetCellValue(sheet, fmt.Sprintf("B%d", row), weekdayDays[weekday])
+			for hour := range matrix[weekday] {
+				cell, _ := excelize.CoordinatesToCellName(hour+3, row)
+				_ = file.SetCellValue(sheet, cell, formatDuration(matrix[weekday][hour][code]))
+			}
+		}
+		file.SetColWidth(sheet, "A", "B", 8)
+		file.SetColWidth(sheet, "C", "Z", 8)
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", "attachment; filename=worksentry_heatmap.xlsx")
+	_ = file.Write(w)
+}
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	// 邮件附件单表行数上限，超出部分请到管理端导出
+	reportMailMaxRows = 5000
+	// 邮件正文每个表格只展示前若干行，完整数据见附件
+	reportMailHTMLRows = 50
+)
+
+// reportTable 订阅邮件中的一个表格，同时用于生成 HTML 正文与 xlsx 工作表。
+type reportTable struct {
+	Title     string
+	Headers   []string
+	Rows      [][]any
+	Truncated bool
+}
+
+type renderedReport struct {
+	Subject  string
+	Title    string
+	Filename string
+	Tables   []reportTable
+}
+
+func subscriptionReportLabel(reportType string) string {
+	switch reportType {
+	case reportTypeDaily:
+		return "工时日报"
+	case reportTypeRank:
+		return "排行榜"
+	case reportTypeReviews:
+		return "上班考核"
+	case reportTypeCheckoutRecords:
+		return "下班填报"
+	default:
+		return "报表"
+	}
+}
+
+// renderSubscriptionReport 按订阅的报表类型、部门与周期生成表格。
+func (h *Handler) renderSubscriptionReport(ctx context.Context, sub reportSubscription, period reportPeriod) (renderedReport, error) {
+	label := subscriptionReportLabel(sub.ReportType)
+	report := renderedReport{
+		Subject:  fmt.Sprintf("%s %s", label, period.Label),
+		Title:    fmt.Sprintf("%s（%s）", label, period.Label),
+		Filename: fmt.Sprintf("worksentry_%s_%s.xlsx", sub.ReportType, period.Key),
+	}
+	if sub.Department != "" {
+		report.Subject = sub.Department + " " + report.Subject
+		report.Title = sub.Department + " " + report.Title
+	}
+
+	var err error
+	switch sub.ReportType {
+	case reportTypeDaily, reportTypeRank:
+		rr := reportRange{Start: period.Start, End: period.End, GroupBy: scheduleGroupBy(sub.Schedule)}
+		var result periodStatsResult
+		result, err = h.loadPeriodStats(ctx, rr, sub.DepartmentID)
+		if err != nil {
+			return report, err
+		}
+		if sub.ReportType == reportTypeDaily {
+			report.Tables = dailyReportTables(result.Summary, result.SummarySubtotals)
+		} else {
+			workTop, fishTop := rankPeriodStats(result.Summary)
+			report.Tables = []reportTable{rankReportTable("有效工时排行", workTop), rankReportTable("摸鱼占比排行", fishTop)}
+		}
+	case reportTypeReviews:
+		var table reportTable
+		table, err = h.reviewReportTable(ctx, sub.DepartmentID, period)
+		report.Tables = []reportTable{table}
+	case reportTypeCheckoutRecords:
+		var table reportTable
+		table, err = h.checkoutReportTable(ctx, sub.DepartmentID, period)
+		report.Tables = []reportTable{table}
+	default:
+		err = fmt.Errorf("未知报表类型 %s", sub.ReportType)
+	}
+	return report, err
+}
+
+func dailyReportTables(items []periodStats, subtotals []departmentSubtotal) []reportTable {
+	employees := reportTable{
+		Title: "员工汇总",
+		Headers: []string{"工号", "姓名", "部门", "工作时长", "常规时长", "摸鱼时长", "离开时长", "离线时长", "在岗时长", "有效工时", "请假时长",
+			"工作日数", "出勤天数", "日均在岗", "日均有效工时", "摸鱼占比"},
+	}
+	for _, item := range items {
+		view := item.view()
+		employees.Rows = append(employees.Rows, []any{view.EmployeeCode, view.Name, view.Department, view.WorkDuration, view.NormalDuration,
+			view.FishDuration, view.IdleDuration, view.OfflineDuration, view.AttendanceDuration, view.EffectiveDuration, view.LeaveDuration,
+			view.Workdays, view.AttendanceDays, view.AvgAttendanceDuration, view.AvgEffectiveDuration, view.FishRatio})
+	}
+	departments := reportTable{
+		Title: "部门小计",
+		Headers: []string{"部门", "人数", "工作时长", "常规时长", "摸鱼时长", "离开时长", "离线时长", "在岗时长", "有效工时", "请假时长",
+			"日均在岗", "日均有效工时", "摸鱼占比"},
+	}
+	for _, subtotal := range subtotals {
+		view := subtotal.view()
+		departments.Rows = append(departments.Rows, []any{subtotal.subtotalLabel(), subtotal.EmployeeCount, view.WorkDuration, view.NormalDuration,
+			view.FishDuration, view.IdleDuration, view.OfflineDuration, view.AttendanceDuration, view.EffectiveDuration, view.LeaveDuration,
+			view.AvgAttendanceDuration, view.AvgEffectiveDuration, view.FishRatio})
+	}
+	return []reportTable{employees, departments}
+}
+
+func rankReportTable(title string, items []RankItem) reportTable {
+	table := reportTable{Title: title, Headers: []string{"排名", "工号", "姓名", "部门", "数值"}}
+	for i, item := range items {
+		table.Rows = append(table.Rows, []any{i + 1, item.EmployeeCode, item.Name, item.Department, item.Value})
+	}
+	return table
+}
+
+func (h *Handler) reviewReportTable(ctx context.Context, departmentID int64, period reportPeriod) (reportTable, error) {
+	table := reportTable{
+		Title:   "上班考核",
+		Headers: []string{"日期", "工号", "姓名", "部门", "上班时间", "下班时间", "标准工时", "休息时长", "违规情况", "补录状态", "原因"},
+	}
+	query := `SELECT r.work_date, e.employee_code, e.name, COALESCE(d.name, ''), ws.start_at, ws.end_at,
+ r.work_standard_seconds, r.break_seconds, r.need_reason, r.reason, r.violations_json
+FROM work_session_reviews r
+JOIN employees e ON r.employee_id = e.id
+LEFT JOIN departments d ON e.department_id = d.id
+LEFT JOIN work_sessions ws ON r.work_session_id = ws.id
+WHERE r.work_date >= ? AND r.work_date < ?`
+	args := []any{period.Start.Format("2006-01-02"), period.End.Format("2006-01-02")}
+	clause, clauseArgs, err := h.departmentFilter(ctx, "e.department_id", departmentID)
+	if err != nil {
+		return table, err
+	}
+	query += clause + " ORDER BY r.work_date, e.employee_code LIMIT ?"
+	args = append(append(args, clauseArgs...), reportMailMaxRows+1)
+
+	rows, err := h.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return table, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var workDate time.Time
+		var code string
+		var name string
+		var department string
+		var startAt sql.NullTime
+		var endAt sql.NullTime
+		var workSeconds int64
+		var breakSeconds int64
+		var needReason bool
+		var reason sql.NullString
+		var violationsJSON string
+		if err := rows.Scan(&workDate, &code, &name, &department, &startAt, &endAt, &workSeconds, &breakSeconds, &needReason, &reason, &violationsJSON); err != nil {
+			return table, err
+		}
+		if len(table.Rows) == reportMailMaxRows {
+			table.Truncated = true
+			break
+		}
+		reasonStatus := "无需补录"
+		if needReason {
+			reasonStatus = "未补录"
+			if strings.TrimSpace(reason.String) != "" {
+				reasonStatus = "已补录"
+			}
+		}
+		table.Rows = append(table.Rows, []any{workDate.Format("2006-01-02"), code, name, department, nullTimeText(startAt), nullTimeText(endAt),
+			formatDuration(workSeconds), formatDuration(breakSeconds), buildViolationSummary(violationsJSON), reasonStatus, reason.String})
+	}
+	return table, rows.Err()
+}
+
+func (h *Handler) checkoutReportTable(ctx context.Context, departmentID int64, period reportPeriod) (reportTable, error) {
+	table := reportTable{
+		Title:   "下班填报",
+		Headers: []string{"提交时间", "工号", "姓名", "部门", "上班时间", "下班时间", "模板", "填报内容"},
+	}
+	query := `SELECT c.created_at, e.employee_code, e.name, COALESCE(d.name, ''), ws.start_at, ws.end_at, COALESCE(t.name_zh, ''),
+ c.template_snapshot_json, c.data_json
+FROM work_session_checkouts c
+JOIN work_sessions ws ON ws.id = c.work_session_id
+JOIN employees e ON e.id = ws.employee_id
+LEFT JOIN departments d ON d.id = e.department_id
+LEFT JOIN checkout_templates t ON t.id = c.template_id
+WHERE c.created_at >= ? AND c.created_at < ?`
+	args := []any{period.Start, period.End}
+	clause, clauseArgs, err := h.departmentFilter(ctx, "e.department_id", departmentID)
+	if err != nil {
+		return table, err
+	}
+	query += clause + " ORDER BY c.created_at LIMIT ?"
+	args = append(append(args, clauseArgs...), reportMailMaxRows+1)
+
+	rows, err := h.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return table, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var createdAt time.Time
+		var code string
+		var name string
+		var department string
+		var startAt sql.NullTime
+		var endAt sql.NullTime
+		var templateName string
+		var snapshotJSON string
+		var dataJSON string
+		if err := rows.Scan(&createdAt, &code, &name, &department, &startAt, &endAt, &templateName, &snapshotJSON, &dataJSON); err != nil {
+			return table, err
+		}
+		if len(table.Rows) == reportMailMaxRows {
+			table.Truncated = true
+			break
+		}
+		table.Rows = append(table.Rows, []any{formatTime(createdAt), code, name, department, nullTimeText(startAt), nullTimeText(endAt),
+			templateName, buildCheckoutSummary(snapshotJSON, dataJSON)})
+	}
+	return table, rows.Err()
+}
+
+func nullTimeText(value sql.NullTime) string {
+	if !value.Valid {
+		return "-"
+	}
+	return formatTime(value.Time)
+}
+
+// buildReportXLSX 每个表格一个工作表。
+func buildReportXLSX(report renderedReport) ([]byte, error) {
+	file := excelize.NewFile()
+	defer file.Close()
+	for i, table := range report.Tables {
+		sheet := table.Title
+		if i == 0 {
+			file.SetSheetName("Sheet1", sheet)
+		} else if _, err := file.NewSheet(sheet); err != nil {
+			return nil, err
+		}
+		for col, header := range table.Headers {
+			cell, _ := excelize.CoordinatesToCellName(col+1, 1)
+			_ = file.SetCellValue(sheet, cell, header)
+		}
+		for rowIndex, row := range table.Rows {
+			for col, value := range row {
+				cell, _ := excelize.CoordinatesToCellName(col+1, rowIndex+2)
+				_ = file.SetCellValue(sheet, cell, spreadsheetCellValue(value))
+			}
+		}
+		if table.Truncated {
+			cell, _ := excelize.CoordinatesToCellName(1, len(table.Rows)+3)
+			_ = file.SetCellValue(sheet, cell, fmt.Sprintf("仅包含前 %d 行，完整数据请在管理端导出", reportMailMaxRows))
+		}
+	}
+	buf, err := file.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// buildReportHTML 邮件正文，每个表格只展示前若干行。
+func buildReportHTML(report renderedReport, attached bool) string {
+	var b strings.Builder
+	b.WriteString(`<html><body style="font-family:sans-serif;font-size:13px;color:#222">`)
+	fmt.Fprintf(&b, "<h2>%s</h2>", html.EscapeString(report.Title))
+	for _, table := range report.Tables {
+		fmt.Fprintf(&b, "<h3>%s（%d 行）</h3>", html.EscapeString(table.Title), len(table.Rows))
+		if len(table.Rows) == 0 {
+			b.WriteString("<p>无数据</p>")
+			continue
+		}
+		b.WriteString(`<table cellpadding="4" cellspacing="0" border="1" style="border-collapse:collapse;border-color:#ddd"><tr style="background:#f5f5f5">`)
+		for _, header := range table.Headers {
+			fmt.Fprintf(&b, "<th>%s</th>", html.EscapeString(header))
+		}
+		b.WriteString("</tr>")
+		for i, row := range table.Rows {
+			if i == reportMailHTMLRows {
+				break
+			}
+			b.WriteString("<tr>")
+			for _, value := range row {
+				fmt.Fprintf(&b, "<td>%s</td>", html.EscapeString(fmt.Sprint(value)))
+			}
+			b.WriteString("</tr>")
+		}
+		b.WriteString("</table>")
+		if len(table.Rows) > reportMailHTMLRows {
+			note := fmt.Sprintf("正文仅展示前 %d 行", reportMailHTMLRows)
+			if attached {
+				note += "，完整数据见附件"
+			}
+			fmt.Fprintf(&b, "<p>%s。</p>", note)
+		}
+	}
+	fmt.Fprintf(&b, `<p style="color:#888">本邮件由 WorkSentry 于 %s 自动发送。</p>`, formatTime(time.Now()))
+	b.WriteString("</body></html>")
+	return b.String()
+}
@@ -0,0 +1,449 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	groupByDay   = "day"
+	groupByWeek  = "week"
+	groupByMonth = "month"
+
+	reportRangeMaxDays = 366
+	// 导出每个周期一个工作表，过多时要求改用更粗的汇总粒度
+	reportExportMaxSheets = 62
+)
+
+type reportRange struct {
+	Start   time.Time
+	End     time.Time
+	GroupBy string
+}
+
+type reportPeriod struct {
+	Key   string
+	Label string
+	Start time.Time
+	End   time.Time
+}
+
+type PeriodReportItem struct {
+	EmployeeCode          string `json:"employeeCode"`
+	Name                  string `json:"name"`
+	Department            string `json:"department"`
+	WorkDuration          string `json:"workDuration"`
+	NormalDuration        string `json:"normalDuration"`
+	FishDuration          string `json:"fishDuration"`
+	IdleDuration          string `json:"idleDuration"`
+	OfflineDuration       string `json:"offlineDuration"`
+	AttendanceDuration    string `json:"attendanceDuration"`
+	EffectiveDuration     string `json:"effectiveDuration"`
+	LeaveDuration         string `json:"leaveDuration"`
+	Workdays              int    `json:"workdays"`
+	AttendanceDays        int    `json:"attendanceDays"`
+	AvgAttendanceDuration string `json:"avgAttendanceDuration"`
+	AvgEffectiveDuration  string `json:"avgEffectiveDuration"`
+	FishRatio             string `json:"fishRatio"`
+}
+
+type PeriodReport struct {
+	Period    string                   `json:"period"`
+	Label     string                   `json:"label"`
+	StartDate string                   `json:"startDate"`
+	EndDate   string                   `json:"endDate"`
+	Items     []PeriodReportItem       `json:"items"`
+	Subtotals []DepartmentSubtotalView `json:"subtotals"`
+}
+
+type PeriodReportResponse struct {
+	StartDate        string                   `json:"startDate"`
+	EndDate          string                   `json:"endDate"`
+	GroupBy          string                   `json:"groupBy"`
+	Periods          []PeriodReport           `json:"periods"`
+	Summary          []PeriodReportItem       `json:"summary"`
+	SummarySubtotals []DepartmentSubtotalView `json:"summarySubtotals"`
+}
+
+type periodStats struct {
+	EmployeeID     int64
+	EmployeeCode   string
+	Name           string
+	DepartmentID   int64
+	Department     string
+	AttendanceDays int
+	Workdays       int
+	Values         DailyStatsValues
+	// BreakSeconds 与 Violations 来自上班考核，由 loadRangeSummary 填充
+	BreakSeconds int64
+	Violations   int64
+}
+
+func (s periodStats) fishRatio() float64 {
+	return s.Values.activeRatio(s.Values.FishSeconds)
+}
+
+// view 日均值按所属部门日历的工作日计算，周期内没有工作日时留空。
+func (s periodStats) view() PeriodReportItem {
+	item := PeriodReportItem{
+		EmployeeCode:       s.EmployeeCode,
+		Name:               s.Name,
+		Department:         s.Department,
+		WorkDuration:       formatDuration(s.Values.WorkSeconds),
+		NormalDuration:     formatDuration(s.Values.NormalSeconds),
+		FishDuration:       formatDuration(s.Values.FishSeconds),
+		IdleDuration:       formatDuration(s.Values.IdleSeconds),
+		OfflineDuration:    formatDuration(s.Values.OfflineSeconds),
+		AttendanceDuration: formatDuration(s.Values.AttendanceSeconds),
+		EffectiveDuration:  formatDuration(s.Values.EffectiveSeconds),
+		LeaveDuration:      formatDuration(s.Values.LeaveSeconds),
+		Workdays:           s.Workdays,
+		AttendanceDays:     s.AttendanceDays,
+		FishRatio:          formatPercent(s.fishRatio()),
+	}
+	if s.Workdays > 0 {
+		item.AvgAttendanceDuration = formatDuration(s.Values.AttendanceSeconds / int64(s.Workdays))
+		item.AvgEffectiveDuration = formatDuration(s.Values.EffectiveSeconds / int64(s.Workdays))
+	}
+	return item
+}
+
+type periodStatsResult struct {
+	Periods          []reportPeriod
+	Stats            [][]periodStats
+	Subtotals        [][]departmentSubtotal
+	Summary          []periodStats
+	SummarySubtotals []departmentSubtotal
+}
+
+func periodStatsMembers(items []periodStats) []departmentMember {
+	members := make([]departmentMember, 0, len(items))
+	for _, item := range items {
+		members = append(members, departmentMember{
+			DepartmentID:   item.DepartmentID,
+			Workdays:       item.Workdays,
+			AttendanceDays: item.AttendanceDays,
+			Values:         item.Values,
+			BreakSeconds:   item.BreakSeconds,
+			Violations:     item.Violations,
+		})
+	}
+	return members
+}
+
+// parseReportRange 解析 startDate/endDate/groupBy；三者均未提供时返回 false，沿用单日 date 口径。
+func parseReportRange(query url.Values) (reportRange, bool, string) {
+	startValue := strings.TrimSpace(query.Get("startDate"))
+	endValue := strings.TrimSpace(query.Get("endDate"))
+	groupBy := strings.TrimSpace(query.Get("groupBy"))
+	if startValue == "" && endValue == "" && groupBy == "" {
+		return reportRange{}, false, ""
+	}
+	if startValue == "" {
+		startValue = strings.TrimSpace(query.Get("date"))
+	}
+	if startValue == "" {
+		startValue = time.Now().Format("2006-01-02")
+	}
+	start, err := parseDate(startValue)
+	if err != nil {
+		return reportRange{}, true, "开始日期格式错误"
+	}
+	end := start
+	if endValue != "" {
+		end, err = parseDate(endValue)
+		if err != nil {
+			return reportRange{}, true, "结束日期格式错误"
+		}
+	}
+	if end.Before(start) {
+		return reportRange{}, true, "结束日期不能早于开始日期"
+	}
+	end = end.AddDate(0, 0, 1)
+	if end.After(start.AddDate(0, 0, reportRangeMaxDays)) {
+		return reportRange{}, true, fmt.Sprintf("查询跨度不能超过 %d 天", reportRangeMaxDays)
+	}
+	switch groupBy {
+	case "":
+		groupBy = groupByDay
+	case groupByDay, groupByWeek, groupByMonth:
+	default:
+		return reportRange{}, true, "汇总周期仅支持 day、week、month"
+	}
+	return reportRange{Start: start, End: end, GroupBy: groupBy}, true, ""
+}
+
+// periodStart 返回日期所在周期的起点，周以周一开始。
+func periodStart(t time.Time, groupBy string) time.Time {
+	switch groupBy {
+	case groupByWeek:
+		weekday := (int(t.Weekday()) + 6) % 7
+		return t.AddDate(0, 0, -weekday)
+	case groupByMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	default:
+		return t
+	}
+}
+
+// buildReportPeriods 按汇总周期切分区间，首尾周期截断到查询范围内。
+func buildReportPeriods(rr reportRange) []reportPeriod {
+	var periods []reportPeriod
+	for current := periodStart(rr.Start, rr.GroupBy); current.Before(rr.End); {
+		var next time.Time
+		var key string
+		switch rr.GroupBy {
+		case groupByWeek:
+			next = current.AddDate(0, 0, 7)
+			year, week := current.ISOWeek()
+			key = fmt.Sprintf("%d-W%02d", year, week)
+		case groupByMonth:
+			next = current.AddDate(0, 1, 0)
+			key = current.Format("2006-01")
+		default:
+			next = current.AddDate(0, 0, 1)
+			key = current.Format("2006-01-02")
+		}
+		start := maxTime(current, rr.Start)
+		end := minTime(next, rr.End)
+		label := start.Format("2006-01-02")
+		if end.Sub(start) > 24*time.Hour {
+			label += " ~ " + end.AddDate(0, 0, -1).Format("2006-01-02")
+		}
+		periods = append(periods, reportPeriod{Key: key, Label: label, Start: start, End: end})
+		current = next
+	}
+	return periods
+}
+
+// loadPeriodStats 按周期汇总 daily_stats，并按员工所属部门的日历统计每个周期的应出勤工作日。
+// 部门筛选包含下级部门，部门小计逐级汇总到上级部门。
+func (h *Handler) loadPeriodStats(ctx context.Context, rr reportRange, departmentID int64) (periodStatsResult, error) {
+	result := periodStatsResult{Periods: buildReportPeriods(rr)}
+	tree, err := loadDepartmentTree(ctx, h.Queries)
+	if err != nil {
+		return result, err
+	}
+	result.Stats = make([][]periodStats, len(result.Periods))
+	index := map[string]int{}
+	for i, period := range result.Periods {
+		index[periodStart(period.Start, rr.GroupBy).Format("2006-01-02")] = i
+		result.Stats[i] = []periodStats{}
+	}
+
+	bucket := "DATE_FORMAT(s.stat_date, '%Y-%m-%d')"
+	switch rr.GroupBy {
+	case groupByWeek:
+		bucket = "DATE_FORMAT(DATE_SUB(s.stat_date, INTERVAL WEEKDAY(s.stat_date) DAY), '%Y-%m-%d')"
+	case groupByMonth:
+		bucket = "DATE_FORMAT(s.stat_date, '%Y-%m-01')"
+	}
+	query := "SELECT " + bucket + `, e.id, e.employee_code, e.name, COALESCE(e.department_id, 0), COALESCE(d.name, ''),
+ COUNT(CASE WHEN s.attendance_seconds > 0 THEN 1 END)` + statsSeriesSums + `
+FROM daily_stats s
+JOIN employees e ON s.employee_id = e.id
+LEFT JOIN departments d ON e.department_id = d.id
+WHERE s.stat_date >= ? AND s.stat_date < ?`
+	args := []any{rr.Start.Format("2006-01-02"), rr.End.Format("2006-01-02")}
+	if departmentID > 0 {
+		clause, clauseArgs := departmentInClause("e.department_id", tree.subtree(departmentID))
+		query += " AND " + clause
+		args = append(args, clauseArgs...)
+	}
+	query += " GROUP BY 1, e.id, e.employee_code, e.name, e.department_id, d.name ORDER BY 1, SUM(s.attendance_seconds) DESC"
+
+	rows, err := h.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		var item periodStats
+		if err := rows.Scan(&key, &item.EmployeeID, &item.EmployeeCode, &item.Name, &item.DepartmentID, &item.Department, &item.AttendanceDays,
+			&item.Values.WorkSeconds, &item.Values.NormalSeconds, &item.Values.FishSeconds, &item.Values.IdleSeconds,
+			&item.Values.OfflineSeconds, &item.Values.AttendanceSeconds, &item.Values.EffectiveSeconds, &item.Values.LeaveSeconds); err != nil {
+			return result, err
+		}
+		i, ok := index[key]
+		if !ok {
+			continue
+		}
+		result.Stats[i] = append(result.Stats[i], item)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	// 每个部门的日历只读取一次，按周期计数工作日
+	workdays := map[int64][]int{}
+	summary := map[int64]*periodStats{}
+	order := []int64{}
+	for i := range result.Stats {
+		for j := range result.Stats[i] {
+			item := &result.Stats[i][j]
+			counts, ok := workdays[item.DepartmentID]
+			if !ok {
+				counts, err = h.countPeriodWorkdays(ctx, item.DepartmentID, rr, result.Periods)
+				if err != nil {
+					return result, err
+				}
+				workdays[item.DepartmentID] = counts
+			}
+			item.Workdays = counts[i]
+
+			total, ok := summary[item.EmployeeID]
+			if !ok {
+				total = &periodStats{
+					EmployeeID:   item.EmployeeID,
+					EmployeeCode: item.EmployeeCode,
+					Name:         item.Name,
+					DepartmentID: item.DepartmentID,
+					Department:   item.Department,
+					Workdays:     counts[len(counts)-1],
+				}
+				summary[item.EmployeeID] = total
+				order = append(order, item.EmployeeID)
+			}
+			total.AttendanceDays += item.AttendanceDays
+			addDailyStatsValues(&total.Values, item.Values)
+		}
+	}
+	result.Summary = make([]periodStats, 0, len(order))
+	for _, id := range order {
+		result.Summary = append(result.Summary, *summary[id])
+	}
+	sort.SliceStable(result.Summary, func(i, j int) bool {
+		return result.Summary[i].Values.AttendanceSeconds > result.Summary[j].Values.AttendanceSeconds
+	})
+
+	result.Subtotals = make([][]departmentSubtotal, len(result.Stats))
+	for i, items := range result.Stats {
+		result.Subtotals[i] = tree.rollupDepartments(departmentID, periodStatsMembers(items))
+	}
+	result.SummarySubtotals = tree.rollupDepartments(departmentID, periodStatsMembers(result.Summary))
+	return result, nil
+}
+
+// countPeriodWorkdays 返回每个周期的工作日数，末尾追加整个区间的合计。
+func (h *Handler) countPeriodWorkdays(ctx context.Context, departmentID int64, rr reportRange, periods []reportPeriod) ([]int, error) {
+	days, err := h.resolveCalendarDays(ctx, departmentID, rr.Start, rr.End)
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]int, len(periods)+1)
+	i := 0
+	for _, day := range days {
+		for i < len(periods) && !day.Date.Before(periods[i].End) {
+			i++
+		}
+		if i >= len(periods) {
+			break
+		}
+		if day.IsWorkday() {
+			counts[i]++
+			counts[len(periods)]++
+		}
+	}
+	return counts, nil
+}
+
+func addDailyStatsValues(total *DailyStatsValues, values DailyStatsValues) {
+	total.WorkSeconds += values.WorkSeconds
+	total.NormalSeconds += values.NormalSeconds
+	total.FishSeconds += values.FishSeconds
+	total.IdleSeconds += values.IdleSeconds
+	total.OfflineSeconds += values.OfflineSeconds
+	total.AttendanceSeconds += values.AttendanceSeconds
+	total.EffectiveSeconds += values.EffectiveSeconds
+	total.LeaveSeconds += values.LeaveSeconds
+}
+
+func periodStatsViews(items []periodStats) []PeriodReportItem {
+	views := make([]PeriodReportItem, 0, len(items))
+	for _, item := range items {
+		views = append(views, item.view())
+	}
+	return views
+}
+
+func (h *Handler) reportDailyRange(w http.ResponseWriter, r *http.Request, rr reportRange, departmentID int64) {
+	result, err := h.loadPeriodStats(r.Context(), rr, departmentID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取报表失败")
+		return
+	}
+	periods := make([]PeriodReport, 0, len(result.Periods))
+	for i, period := range result.Periods {
+		periods = append(periods, PeriodReport{
+			Period:    period.Key,
+			Label:     period.Label,
+			StartDate: period.Start.Format("2006-01-02"),
+			EndDate:   period.End.AddDate(0, 0, -1).Format("2006-01-02"),
+			Items:     periodStatsViews(result.Stats[i]),
+			Subtotals: departmentSubtotalViews(result.Subtotals[i]),
+		})
+	}
+	writeJSON(w, http.StatusOK, PeriodReportResponse{
+		StartDate:        rr.Start.Format("2006-01-02"),
+		EndDate:          rr.End.AddDate(0, 0, -1).Format("2006-01-02"),
+		GroupBy:          rr.GroupBy,
+		Periods:          periods,
+		Summary:          periodStatsViews(result.Summary),
+		SummarySubtotals: departmentSubtotalViews(result.SummarySubtotals),
+	})
+}
+
+// rankPeriodStats 有效工时按周期合计排序，摸鱼按摸鱼时长占在岗时长的比例排序。
+func rankPeriodStats(items []periodStats) ([]RankItem, []RankItem) {
+	workList := make([]rankValue, 0, len(items))
+	fishList := make([]rankValue, 0, len(items))
+	for _, item := range items {
+		workList = append(workList, rankValue{
+			Item:  RankItem{EmployeeCode: item.EmployeeCode, Name: item.Name, Department: item.Department, Value: formatDuration(item.Values.EffectiveSeconds)},
+			Score: float64(item.Values.EffectiveSeconds),
+		})
+		ratio := item.fishRatio()
+		fishList = append(fishList, rankValue{
+			Item:  RankItem{EmployeeCode: item.EmployeeCode, Name: item.Name, Department: item.Department, Value: formatPercent(ratio)},
+			Score: ratio,
+		})
+	}
+	return topRankItems(workList, 10), topRankItems(fishList, 10)
+}
+
+func topRankItems(list []rankValue, limit int) []RankItem {
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Score > list[j].Score })
+	items := make([]RankItem, 0, minInt(len(list), limit))
+	for i := 0; i < len(list) && i < limit; i++ {
+		items = append(items, list[i].Item)
+	}
+	return items
+}
+
+func (h *Handler) reportRankRange(w http.ResponseWriter, r *http.Request, rr reportRange, departmentID int64) {
+	result, err := h.loadPeriodStats(r.Context(), rr, departmentID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取排行失败")
+		return
+	}
+	periods := make([]RankPeriod, 0, len(result.Periods))
+	for i, period := range result.Periods {
+		workTop, fishTop := rankPeriodStats(result.Stats[i])
+		periods = append(periods, RankPeriod{Period: period.Key, Label: period.Label, WorkTop: workTop, FishTop: fishTop})
+	}
+	workTop, fishTop := rankPeriodStats(result.Summary)
+	writeJSON(w, http.StatusOK, RankResponse{
+		StartDate: rr.Start.Format("2006-01-02"),
+		EndDate:   rr.End.AddDate(0, 0, -1).Format("2006-01-02"),
+		GroupBy:   rr.GroupBy,
+		WorkTop:   workTop,
+		FishTop:   fishTop,
+		Periods:   periods,
+	})
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	rankDefaultLimit = 10
+	rankMaxLimit     = 100
+	rankOrderTop     = "top"
+	rankOrderBottom  = "bottom"
+)
+
+// rankMetric 排行指标。时长类按区间合计排序，摸鱼占比按合计的实际在岗时长计算，空闲占比的分母另含空闲时长。
+type rankMetric struct {
+	Key   string
+	Label string
+	Kind  string
+	Value func(periodStats) float64
+}
+
+var rankMetrics = []rankMetric{
+	{Key: "effective", Label: "有效工时", Kind: compareKindDuration, Value: func(s periodStats) float64 { return float64(s.Values.EffectiveSeconds) }},
+	{Key: "attendance", Label: "在岗时长", Kind: compareKindDuration, Value: func(s periodStats) float64 { return float64(s.Values.AttendanceSeconds) }},
+	{Key: "work", Label: "工作时长", Kind: compareKindDuration, Value: func(s periodStats) float64 { return float64(s.Values.WorkSeconds) }},
+	{Key: "fishRatio", Label: "摸鱼占比", Kind: compareKindRatio, Value: func(s periodStats) float64 { return s.Values.activeRatio(s.Values.FishSeconds) }},
+	{Key: "idleRatio", Label: "空闲占比", Kind: compareKindRatio, Value: func(s periodStats) float64 { return s.Values.idleRatio() }},
+	{Key: "break", Label: "休息时长", Kind: compareKindDuration, Value: func(s periodStats) float64 { return float64(s.BreakSeconds) }},
+	{Key: "violations", Label: "考核违规次数", Kind: compareKindCount, Value: func(s periodStats) float64 { return float64(s.Violations) }},
+}
+
+func findRankMetric(key string) (rankMetric, bool) {
+	for _, metric := range rankMetrics {
+		if metric.Key == key {
+			return metric, true
+		}
+	}
+	return rankMetric{}, false
+}
+
+type MetricRankItem struct {
+	Rank               int     `json:"rank"`
+	EmployeeCode       string  `json:"employeeCode"`
+	Name               string  `json:"name"`
+	Department         string  `json:"department"`
+	Value              string  `json:"value"`
+	Score              float64 `json:"score"`
+	AttendanceDays     int     `json:"attendanceDays"`
+	AttendanceDuration string  `json:"attendanceDuration"`
+}
+
+type MetricRankResponse struct {
+	Metric               string           `json:"metric"`
+	Label                string           `json:"label"`
+	Order                string           `json:"order"`
+	Limit                int              `json:"limit"`
+	StartDate            string           `json:"startDate"`
+	EndDate              string           `json:"endDate"`
+	DepartmentID         int64            `json:"departmentId"`
+	MinAttendanceDays    int              `json:"minAttendanceDays"`
+	MinAttendanceSeconds int64            `json:"minAttendanceSeconds"`
+	Total                int              `json:"total"`
+	Items                []MetricRankItem `json:"items"`
+}
+
+// reportRankMetric 按指定指标排行。order 为 top/bottom，limit 默认 10；
+// 区间取 startDate/endDate，未指定时取 date 当天；
+// minAttendanceDays（默认 1）与 minAttendanceHours 用于排除出勤过少的员工，避免占比类指标失真。
+func (h *Handler) reportRankMetric(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	query := r.URL.Query()
+	metric, ok := findRankMetric(strings.TrimSpace(query.Get("metric")))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "排行指标仅支持 effective、attendance、work、fishRatio、idleRatio、break、violations")
+		return
+	}
+	order := strings.TrimSpace(query.Get("order"))
+	switch order {
+	case "":
+		order = rankOrderTop
+	case rankOrderTop, rankOrderBottom:
+	default:
+		writeError(w, http.StatusBadRequest, "排序方式仅支持 top、bottom")
+		return
+	}
+	limit := parseInt(query.Get("limit"), rankDefaultLimit)
+	if limit <= 0 || limit > rankMaxLimit {
+		writeError(w, http.StatusBadRequest, "排行数量需在 1 到 100 之间")
+		return
+	}
+	minDays := parseInt(query.Get("minAttendanceDays"), 1)
+	if minDays < 0 {
+		writeError(w, http.StatusBadRequest, "最少出勤天数不能为负数")
+		return
+	}
+	var minSeconds int64
+	if value := strings.TrimSpace(query.Get("minAttendanceHours")); value != "" {
+		hours, err := strconv.ParseFloat(value, 64)
+		if err != nil || hours < 0 {
+			writeError(w, http.StatusBadRequest, "最少在岗小时数格式错误")
+			return
+		}
+		minSeconds = int64(math.Round(hours * 3600))
+	}
+
+	startValue := strings.TrimSpace(query.Get("startDate"))
+	if startValue == "" {
+		startValue = strings.TrimSpace(query.Get("date"))
+	}
+	if startValue == "" {
+		startValue = time.Now().Format("2006-01-02")
+	}
+	rr, _, message := parseReportRange(url.Values{"startDate": {startValue}, "endDate": {query.Get("endDate")}})
+	if message != "" {
+		writeError(w, http.StatusBadRequest, message)
+		return
+	}
+	departmentID := parseInt64(query.Get("departmentId"))
+
+	stats, err := h.loadRangeSummary(r.Context(), rr.Start, rr.End, departmentID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取排行失败")
+		return
+	}
+	type scoredStats struct {
+		Stats periodStats
+		Score float64
+	}
+	list := make([]scoredStats, 0, len(stats))
+	for _, item := range stats {
+		if item.AttendanceDays < minDays || item.Values.AttendanceSeconds < minSeconds {
+			continue
+		}
+		list = append(list, scoredStats{Stats: item, Score: metric.Value(item)})
+	}
+	// 同分按工号排序，保证结果稳定
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Score != list[j].Score {
+			if order == rankOrderBottom {
+				return list[i].Score < list[j].Score
+			}
+			return list[i].Score > list[j].Score
+		}
+		return list[i].Stats.EmployeeCode < list[j].Stats.EmployeeCode
+	})
+
+	response := MetricRankResponse{
+		Metric:               metric.Key,
+		Label:                metric.Label,
+		Order:                order,
+		Limit:                limit,
+		StartDate:            rr.Start.Format("2006-01-02"),
+		EndDate:              rr.End.AddDate(0, 0, -1).Format("2006-01-02"),
+		DepartmentID:         departmentID,
+		MinAttendanceDays:    minDays,
+		MinAttendanceSeconds: minSeconds,
+		Total:                len(list),
+		Items:                make([]MetricRankItem, 0, minInt(len(list), limit)),
+	}
+	for i := 0; i < len(list) && i < limit; i++ {
+		// 并列时名次相同
+		rank := i + 1
+		if i > 0 && list[i].Score == list[i-1].Score {
+			rank = response.Items[i-1].Rank
+		}
+		item := list[i].Stats
+		response.Items = append(response.Items, MetricRankItem{
+			Rank:               rank,
+			EmployeeCode:       item.EmployeeCode,
+			Name:               item.Name,
+			Department:         item.Department,
+			Value:              compareValueText(list[i].Score, metric.Kind),
+			Score:              roundRatio(list[i].Score, metric.Kind),
+			AttendanceDays:     item.AttendanceDays,
+			AttendanceDuration: formatDuration(item.Values.AttendanceSeconds),
+		})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import "testing"
+
+func TestRankMetricRatios(t *testing.T) {
+	hours := func(n int64) int64 { return n * 3600 }
+	tests := []struct {
+		name     string
+		values   DailyStatsValues
+		wantFish float64
+		wantIdle float64
+	}{
+		{name: "无数据", values: DailyStatsValues{}},
+		{name: "空闲多于在岗", values: DailyStatsValues{WorkSeconds: hours(1), IdleSeconds: hours(2)}, wantIdle: 2.0 / 3},
+		{name: "只有空闲", values: DailyStatsValues{IdleSeconds: hours(3)}, wantIdle: 1},
+		{
+			name:     "请假不摊薄占比",
+			values:   DailyStatsValues{WorkSeconds: hours(3), FishSeconds: hours(1), IdleSeconds: hours(1), AttendanceSeconds: hours(12), LeaveSeconds: hours(8)},
+			wantFish: 0.25, wantIdle: 0.2,
+		},
+	}
+	fishRatio, _ := findRankMetric("fishRatio")
+	idleRatio, _ := findRankMetric("idleRatio")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := periodStats{Values: tt.values}
+			if got := fishRatio.Value(stats); got != tt.wantFish {
+				t.Fatalf("摸鱼占比 = %v，期望 %v", got, tt.wantFish)
+			}
+			got := idleRatio.Value(stats)
+			if got != tt.wantIdle {
+				t.Fatalf("空闲占比 = %v，期望 %v", got, tt.wantIdle)
+			}
+			if got < 0 || got > 1 {
+				t.Fatalf("空闲占比 %v 超出 0-1", got)
+			}
+		})
+	}
+}
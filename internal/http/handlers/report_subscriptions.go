@@ -0,0 +1,776 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	reportTypeDaily           = "daily"
+	reportTypeRank            = "rank"
+	reportTypeReviews         = "reviews"
+	reportTypeCheckoutRecords = "checkout_records"
+
+	scheduleDaily   = "daily"
+	scheduleWeekly  = "weekly"
+	scheduleMonthly = "monthly"
+
+	deliveryStatusPending = "pending"
+	deliveryStatusSent    = "sent"
+	deliveryStatusFailed  = "failed"
+
+	deliveryTriggerSchedule = "schedule"
+	deliveryTriggerManual   = "manual"
+
+	// 首次发送加重试共 4 次，间隔见 reportDeliveryBackoff
+	reportDeliveryMaxAttempts = 4
+	reportDeliveryBatchSize   = 20
+)
+
+var reportDeliveryBackoff = []time.Duration{5 * time.Minute, 15 * time.Minute, time.Hour}
+
+type ReportSubscriptionPayload struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	ReportType   string `json:"reportType"`
+	Schedule     string `json:"schedule"`
+	SendHour     int    `json:"sendHour"`
+	Weekday      int    `json:"weekday"`
+	MonthDay     int    `json:"monthDay"`
+	DepartmentID int64  `json:"departmentId"`
+	Recipients   string `json:"recipients"`
+	AttachXLSX   bool   `json:"attachXlsx"`
+	Enabled      bool   `json:"enabled"`
+}
+
+type ReportSubscriptionView struct {
+	ID              int64    `json:"id"`
+	Name            string   `json:"name"`
+	ReportType      string   `json:"reportType"`
+	ReportTypeLabel string   `json:"reportTypeLabel"`
+	Schedule        string   `json:"schedule"`
+	ScheduleLabel   string   `json:"scheduleLabel"`
+	SendHour        int      `json:"sendHour"`
+	Weekday         int      `json:"weekday"`
+	MonthDay        int      `json:"monthDay"`
+	DepartmentID    int64    `json:"departmentId"`
+	Department      string   `json:"department"`
+	Recipients      []string `json:"recipients"`
+	AttachXLSX      bool     `json:"attachXlsx"`
+	Enabled         bool     `json:"enabled"`
+	NextRunAt       string   `json:"nextRunAt"`
+}
+
+type ReportDeliveryView struct {
+	ID               int64  `json:"id"`
+	SubscriptionID   int64  `json:"subscriptionId"`
+	SubscriptionName string `json:"subscriptionName"`
+	TriggerType      string `json:"triggerType"`
+	PeriodKey        string `json:"periodKey"`
+	PeriodStart      string `json:"periodStart"`
+	PeriodEnd        string `json:"periodEnd"`
+	Recipients       string `json:"recipients"`
+	Status           string `json:"status"`
+	StatusLabel      string `json:"statusLabel"`
+	Attempts         int    `json:"attempts"`
+	NextAttemptAt    string `json:"nextAttemptAt"`
+	Error            string `json:"error"`
+	SentAt           string `json:"sentAt"`
+	CreatedAt        string `json:"createdAt"`
+}
+
+type ReportDeliveryListResponse struct {
+	Total int64                `json:"total"`
+	Items []ReportDeliveryView `json:"items"`
+}
+
+type reportSubscription struct {
+	ID           int64
+	Name         string
+	ReportType   string
+	Schedule     string
+	SendHour     int
+	Weekday      int
+	MonthDay     int
+	DepartmentID int64
+	Department   string
+	Recipients   string
+	AttachXLSX   bool
+	Enabled      bool
+	NextRunAt    sql.NullTime
+}
+
+type reportDelivery struct {
+	ID             int64
+	SubscriptionID int64
+	PeriodKey      string
+	PeriodStart    time.Time
+	PeriodEnd      time.Time
+	Recipients     string
+	Attempts       int
+}
+
+func reportScheduleLabel(schedule string) string {
+	switch schedule {
+	case scheduleDaily:
+		return "每天"
+	case scheduleWeekly:
+		return "每周"
+	case scheduleMonthly:
+		return "每月"
+	default:
+		return "未知"
+	}
+}
+
+func reportDeliveryStatusLabel(status string) string {
+	switch status {
+	case deliveryStatusPending:
+		return "待发送"
+	case deliveryStatusSent:
+		return "已发送"
+	case deliveryStatusFailed:
+		return "发送失败"
+	default:
+		return "未知"
+	}
+}
+
+// scheduleGroupBy 每天发送前一天，每周发送上一自然周，每月发送上一自然月。
+func scheduleGroupBy(schedule string) string {
+	switch schedule {
+	case scheduleWeekly:
+		return groupByWeek
+	case scheduleMonthly:
+		return groupByMonth
+	default:
+		return groupByDay
+	}
+}
+
+// nextSubscriptionRun 返回 after 之后的下一次发送时间；weekday 以周一为 1。
+func nextSubscriptionRun(sub reportSubscription, after time.Time) time.Time {
+	for i := 0; i <= 62; i++ {
+		day := after.AddDate(0, 0, i)
+		run := time.Date(day.Year(), day.Month(), day.Day(), sub.SendHour, 0, 0, 0, time.Local)
+		if !run.After(after) {
+			continue
+		}
+		switch sub.Schedule {
+		case scheduleWeekly:
+			if heatmapWeekday(run)+1 != sub.Weekday {
+				continue
+			}
+		case scheduleMonthly:
+			if run.Day() != sub.MonthDay {
+				continue
+			}
+		}
+		return run
+	}
+	return after.AddDate(0, 0, 1)
+}
+
+// subscriptionPeriod 返回发送时间之前最近一个完整周期。
+func subscriptionPeriod(schedule string, runAt time.Time) reportPeriod {
+	groupBy := scheduleGroupBy(schedule)
+	day := time.Date(runAt.Year(), runAt.Month(), runAt.Day(), 0, 0, 0, 0, time.Local)
+	end := periodStart(day, groupBy)
+	var start time.Time
+	switch groupBy {
+	case groupByWeek:
+		start = end.AddDate(0, 0, -7)
+	case groupByMonth:
+		start = end.AddDate(0, -1, 0)
+	default:
+		start = end.AddDate(0, 0, -1)
+	}
+	return buildReportPeriods(reportRange{Start: start, End: end, GroupBy: groupBy})[0]
+}
+
+func (s reportSubscription) view() ReportSubscriptionView {
+	item := ReportSubscriptionView{
+		ID:              s.ID,
+		Name:            s.Name,
+		ReportType:      s.ReportType,
+		ReportTypeLabel: subscriptionReportLabel(s.ReportType),
+		Schedule:        s.Schedule,
+		ScheduleLabel:   reportScheduleLabel(s.Schedule),
+		SendHour:        s.SendHour,
+		Weekday:         s.Weekday,
+		MonthDay:        s.MonthDay,
+		DepartmentID:    s.DepartmentID,
+		Department:      s.Department,
+		Recipients:      parseMailRecipients(s.Recipients),
+		AttachXLSX:      s.AttachXLSX,
+		Enabled:         s.Enabled,
+	}
+	if s.NextRunAt.Valid && s.Enabled {
+		item.NextRunAt = formatTime(s.NextRunAt.Time)
+	}
+	return item
+}
+
+const reportSubscriptionColumns = `s.id, s.name, s.report_type, s.schedule, s.send_hour, s.weekday, s.month_day, s.department_id, COALESCE(d.name, ''),
+ s.recipients, s.attach_xlsx, s.enabled, s.next_run_at
+FROM report_subscriptions s
+LEFT JOIN departments d ON s.department_id = d.id`
+
+func scanReportSubscription(scanner interface{ Scan(...any) error }) (reportSubscription, error) {
+	var item reportSubscription
+	err := scanner.Scan(&item.ID, &item.Name, &item.ReportType, &item.Schedule, &item.SendHour, &item.Weekday, &item.MonthDay, &item.DepartmentID,
+		&item.Department, &item.Recipients, &item.AttachXLSX, &item.Enabled, &item.NextRunAt)
+	return item, err
+}
+
+func (h *Handler) listReportSubscriptions(ctx context.Context, enabledOnly bool) ([]reportSubscription, error) {
+	query := "SELECT " + reportSubscriptionColumns
+	if enabledOnly {
+		query += " WHERE s.enabled = 1"
+	}
+	rows, err := h.DB.QueryContext(ctx, query+" ORDER BY s.id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := make([]reportSubscription, 0)
+	for rows.Next() {
+		item, err := scanReportSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (h *Handler) getReportSubscription(ctx context.Context, id int64) (reportSubscription, error) {
+	return scanReportSubscription(h.DB.QueryRowContext(ctx, "SELECT "+reportSubscriptionColumns+" WHERE s.id = ?", id))
+}
+
+// ReportSubscriptions 维护报表邮件订阅。
+func (h *Handler) ReportSubscriptions(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		items, err := h.listReportSubscriptions(r.Context(), false)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "读取报表订阅失败")
+			return
+		}
+		views := make([]ReportSubscriptionView, 0, len(items))
+		for _, item := range items {
+			views = append(views, item.view())
+		}
+		writeJSON(w, http.StatusOK, views)
+	case http.MethodPost:
+		h.saveReportSubscription(w, r, false)
+	case http.MethodPut:
+		h.saveReportSubscription(w, r, true)
+	case http.MethodDelete:
+		h.deleteReportSubscription(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+	}
+}
+
+func normalizeReportSubscription(payload *ReportSubscriptionPayload) string {
+	payload.Name = strings.TrimSpace(payload.Name)
+	if payload.Name == "" {
+		return "订阅名称不能为空"
+	}
+	if utf8.RuneCountInString(payload.Name) > 64 {
+		return "订阅名称长度不能超过 64 个字符"
+	}
+	switch payload.ReportType {
+	case reportTypeDaily, reportTypeRank, reportTypeReviews, reportTypeCheckoutRecords:
+	default:
+		return "报表类型无效"
+	}
+	switch payload.Schedule {
+	case scheduleDaily:
+	case scheduleWeekly:
+		if payload.Weekday < 1 || payload.Weekday > 7 {
+			return "每周发送日需在周一到周日之间"
+		}
+	case scheduleMonthly:
+		// 只允许到 28 日，保证每个月都会发送
+		if payload.MonthDay < 1 || payload.MonthDay > 28 {
+			return "每月发送日需在 1 到 28 之间"
+		}
+	default:
+		return "发送周期无效"
+	}
+	if payload.Weekday < 1 || payload.Weekday > 7 {
+		payload.Weekday = 1
+	}
+	if payload.MonthDay < 1 || payload.MonthDay > 28 {
+		payload.MonthDay = 1
+	}
+	if payload.SendHour < 0 || payload.SendHour > 23 {
+		return "发送时间需在 0 到 23 点之间"
+	}
+	if payload.DepartmentID < 0 {
+		return "部门无效"
+	}
+	recipients := parseMailRecipients(payload.Recipients)
+	if len(recipients) == 0 {
+		return "收件人不能为空"
+	}
+	for _, recipient := range recipients {
+		if !validMailAddress(recipient) {
+			return "收件人邮箱格式错误：" + recipient
+		}
+	}
+	payload.Recipients = strings.Join(recipients, ",")
+	if len(payload.Recipients) > 1024 {
+		return "收件人过多"
+	}
+	return ""
+}
+
+func (h *Handler) saveReportSubscription(w http.ResponseWriter, r *http.Request, update bool) {
+	var payload ReportSubscriptionPayload
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "参数格式错误")
+		return
+	}
+	if message := normalizeReportSubscription(&payload); message != "" {
+		writeError(w, http.StatusBadRequest, message)
+		return
+	}
+	if payload.DepartmentID > 0 {
+		var exists int64
+		if err := h.DB.QueryRowContext(r.Context(), "SELECT COUNT(1) FROM departments WHERE id = ?", payload.DepartmentID).Scan(&exists); err != nil {
+			writeError(w, http.StatusInternalServerError, "读取部门失败")
+			return
+		}
+		if exists == 0 {
+			writeError(w, http.StatusBadRequest, "部门不存在")
+			return
+		}
+	}
+	// 修改发送计划后重新计算下次发送时间
+	nextRun := nextSubscriptionRun(reportSubscription{Schedule: payload.Schedule, SendHour: payload.SendHour, Weekday: payload.Weekday,
+		MonthDay: payload.MonthDay}, time.Now())
+
+	if update {
+		if payload.ID <= 0 {
+			writeError(w, http.StatusBadRequest, "订阅编号无效")
+			return
+		}
+		if _, err := h.getReportSubscription(r.Context(), payload.ID); err != nil {
+			if err == sql.ErrNoRows {
+				writeError(w, http.StatusNotFound, "报表订阅不存在")
+				return
+			}
+			writeError(w, http.StatusInternalServerError, "更新报表订阅失败")
+			return
+		}
+		_, err := h.DB.ExecContext(r.Context(), `UPDATE report_subscriptions SET name = ?, report_type = ?, schedule = ?, send_hour = ?, weekday = ?,
+ month_day = ?, department_id = ?, recipients = ?, attach_xlsx = ?, enabled = ?, next_run_at = ?
+WHERE id = ?`, payload.Name, payload.ReportType, payload.Schedule, payload.SendHour, payload.Weekday, payload.MonthDay, payload.DepartmentID,
+			payload.Recipients, payload.AttachXLSX, payload.Enabled, nextRun, payload.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "更新报表订阅失败")
+			return
+		}
+		h.logAudit(r, "update_report_subscription", "report_subscription", sql.NullInt64{Int64: payload.ID, Valid: true}, payload)
+		writeJSON(w, http.StatusOK, map[string]any{"id": payload.ID})
+		return
+	}
+
+	result, err := h.DB.ExecContext(r.Context(), `INSERT INTO report_subscriptions
+(name, report_type, schedule, send_hour, weekday, month_day, department_id, recipients, attach_xlsx, enabled, next_run_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, payload.Name, payload.ReportType, payload.Schedule, payload.SendHour, payload.Weekday, payload.MonthDay,
+		payload.DepartmentID, payload.Recipients, payload.AttachXLSX, payload.Enabled, nextRun)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "创建报表订阅失败")
+		return
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "创建报表订阅失败")
+		return
+	}
+	h.logAudit(r, "create_report_subscription", "report_subscription", sql.NullInt64{Int64: id, Valid: true}, payload)
+	writeJSON(w, http.StatusOK, map[string]any{"id": id})
+}
+
+// deleteReportSubscription 保留发送记录，未发送的记录标记为失败。
+func (h *Handler) deleteReportSubscription(w http.ResponseWriter, r *http.Request) {
+	id := parseInt64(r.URL.Query().Get("id"))
+	if id <= 0 {
+		writeError(w, http.StatusBadRequest, "订阅编号无效")
+		return
+	}
+	result, err := h.DB.ExecContext(r.Context(), "DELETE FROM report_subscriptions WHERE id = ?", id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "删除报表订阅失败")
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		writeError(w, http.StatusNotFound, "报表订阅不存在")
+		return
+	}
+	if _, err := h.DB.ExecContext(r.Context(), "UPDATE report_deliveries SET status = ?, error_message = ? WHERE subscription_id = ? AND status = ?",
+		deliveryStatusFailed, "订阅已删除", id, deliveryStatusPending); err != nil {
+		writeError(w, http.StatusInternalServerError, "删除报表订阅失败")
+		return
+	}
+	h.logAudit(r, "delete_report_subscription", "report_subscription", sql.NullInt64{Int64: id, Valid: true}, nil)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "已删除"})
+}
+
+// ReportSubscriptionSend 为订阅最近一个完整周期的报表创建待发送记录，用于验证收件人与邮件服务器配置；
+// 邮件由 reportSubscriptionLoop 在一分钟内发送，请求不等待 SMTP。
+func (h *Handler) ReportSubscriptionSend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	var payload struct {
+		ID int64 `json:"id"`
+	}
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "参数格式错误")
+		return
+	}
+	sub, err := h.getReportSubscription(r.Context(), payload.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			writeError(w, http.StatusNotFound, "报表订阅不存在")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "读取报表订阅失败")
+		return
+	}
+	now := time.Now()
+	id, err := h.createReportDelivery(r.Context(), sub, deliveryTriggerManual, subscriptionPeriod(sub.Schedule, now), now)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "创建发送记录失败")
+		return
+	}
+	h.logAudit(r, "send_report_subscription", "report_subscription", sql.NullInt64{Int64: sub.ID, Valid: true}, map[string]any{"deliveryId": id})
+	h.writeReportDelivery(w, r, http.StatusAccepted, id)
+}
+
+// ReportDeliveryRetry 将失败的发送记录重新排队，由后台再尝试一次。
+func (h *Handler) ReportDeliveryRetry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	var payload struct {
+		ID int64 `json:"id"`
+	}
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "参数格式错误")
+		return
+	}
+	now := time.Now()
+	result, err := h.DB.ExecContext(r.Context(), "UPDATE report_deliveries SET status = ?, next_attempt_at = ? WHERE id = ? AND status = ?",
+		deliveryStatusPending, now, payload.ID, deliveryStatusFailed)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "更新发送记录失败")
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		writeError(w, http.StatusConflict, "只能重试发送失败的记录")
+		return
+	}
+	h.logAudit(r, "retry_report_delivery", "report_delivery", sql.NullInt64{Int64: payload.ID, Valid: true}, nil)
+	h.writeReportDelivery(w, r, http.StatusAccepted, payload.ID)
+}
+
+func (h *Handler) writeReportDelivery(w http.ResponseWriter, r *http.Request, status int, id int64) {
+	items, _, err := h.queryReportDeliveries(r.Context(), " WHERE rd.id = ?", []any{id}, 1, 1)
+	if err != nil || len(items) == 0 {
+		writeError(w, http.StatusInternalServerError, "读取发送记录失败")
+		return
+	}
+	writeJSON(w, status, items[0])
+}
+
+// ReportDeliveries 查询报表邮件发送记录。
+func (h *Handler) ReportDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+
+	query := r.URL.Query()
+	page := parseInt(query.Get("page"), 1)
+	pageSize := parseInt(query.Get("pageSize"), 20)
+	if pageSize <= 0 || pageSize > 200 {
+		pageSize = 20
+	}
+	if page <= 0 {
+		page = 1
+	}
+
+	where := " WHERE 1 = 1"
+	args := []any{}
+	if subscriptionID := parseInt64(query.Get("subscriptionId")); subscriptionID > 0 {
+		where += " AND rd.subscription_id = ?"
+		args = append(args, subscriptionID)
+	}
+	switch status := strings.TrimSpace(query.Get("status")); status {
+	case "":
+	case deliveryStatusPending, deliveryStatusSent, deliveryStatusFailed:
+		where += " AND rd.status = ?"
+		args = append(args, status)
+	default:
+		writeError(w, http.StatusBadRequest, "发送状态无效")
+		return
+	}
+	if startValue := query.Get("startDate"); startValue != "" {
+		startDate, err := parseDate(startValue)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "开始日期格式错误")
+			return
+		}
+		where += " AND rd.created_at >= ?"
+		args = append(args, startDate)
+	}
+	if endValue := query.Get("endDate"); endValue != "" {
+		endDate, err := parseDate(endValue)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "结束日期格式错误")
+			return
+		}
+		where += " AND rd.created_at < ?"
+		args = append(args, endDate.AddDate(0, 0, 1))
+	}
+
+	items, total, err := h.queryReportDeliveries(r.Context(), where, args, page, pageSize)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取发送记录失败")
+		return
+	}
+	writeJSON(w, http.StatusOK, ReportDeliveryListResponse{Total: total, Items: items})
+}
+
+func (h *Handler) queryReportDeliveries(ctx context.Context, where string, args []any, page int, pageSize int) ([]ReportDeliveryView, int64, error) {
+	var total int64
+	if err := h.DB.QueryRowContext(ctx, "SELECT COUNT(1) FROM report_deliveries rd"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	args = append(args, pageSize, (page-1)*pageSize)
+	rows, err := h.DB.QueryContext(ctx, `SELECT rd.id, rd.subscription_id, COALESCE(s.name, ''), rd.trigger_type, rd.period_key, rd.period_start, rd.period_end,
+ rd.recipients, rd.status, rd.attempts, rd.next_attempt_at, COALESCE(rd.error_message, ''), rd.sent_at, rd.created_at
+FROM report_deliveries rd
+LEFT JOIN report_subscriptions s ON rd.subscription_id = s.id`+where+" ORDER BY rd.created_at DESC, rd.id DESC LIMIT ? OFFSET ?", args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	items := make([]ReportDeliveryView, 0)
+	for rows.Next() {
+		var item ReportDeliveryView
+		var periodStart time.Time
+		var periodEnd time.Time
+		var nextAttemptAt time.Time
+		var sentAt sql.NullTime
+		var createdAt time.Time
+		if err := rows.Scan(&item.ID, &item.SubscriptionID, &item.SubscriptionName, &item.TriggerType, &item.PeriodKey, &periodStart, &periodEnd,
+			&item.Recipients, &item.Status, &item.Attempts, &nextAttemptAt, &item.Error, &sentAt, &createdAt); err != nil {
+			return nil, 0, err
+		}
+		item.StatusLabel = reportDeliveryStatusLabel(item.Status)
+		item.PeriodStart = periodStart.Format("2006-01-02")
+		item.PeriodEnd = periodEnd.Format("2006-01-02")
+		if item.Status == deliveryStatusPending {
+			item.NextAttemptAt = formatTime(nextAttemptAt)
+		}
+		if sentAt.Valid {
+			item.SentAt = formatTime(sentAt.Time)
+		}
+		item.CreatedAt = formatTime(createdAt)
+		items = append(items, item)
+	}
+	return items, total, rows.Err()
+}
+
+func (h *Handler) reportSubscriptionLoop(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now()
+			h.scheduleReportSubscriptions(ctx, now)
+			h.processReportDeliveries(ctx, now)
+		}
+	}
+}
+
+// scheduleReportSubscriptions 为到期的订阅生成发送记录；先以原下次发送时间为条件推进计划，避免重复生成。
+func (h *Handler) scheduleReportSubscriptions(ctx context.Context, now time.Time) {
+	if h.DB == nil {
+		return
+	}
+	subs, err := h.listReportSubscriptions(ctx, true)
+	if err != nil {
+		log.Printf("报表订阅检查失败: %v", err)
+		return
+	}
+	for _, sub := range subs {
+		if sub.NextRunAt.Valid && sub.NextRunAt.Time.After(now) {
+			continue
+		}
+		next := nextSubscriptionRun(sub, now)
+		result, err := h.DB.ExecContext(ctx, "UPDATE report_subscriptions SET next_run_at = ? WHERE id = ? AND next_run_at <=> ?",
+			next, sub.ID, sub.NextRunAt)
+		if err != nil {
+			log.Printf("报表订阅检查失败: subscription=%d %v", sub.ID, err)
+			continue
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 || !sub.NextRunAt.Valid {
+			continue
+		}
+		period := subscriptionPeriod(sub.Schedule, sub.NextRunAt.Time)
+		if _, err := h.createReportDelivery(ctx, sub, deliveryTriggerSchedule, period, now); err != nil {
+			log.Printf("创建报表发送记录失败: subscription=%d %v", sub.ID, err)
+		}
+	}
+}
+
+func (h *Handler) createReportDelivery(ctx context.Context, sub reportSubscription, trigger string, period reportPeriod, now time.Time) (int64, error) {
+	result, err := h.DB.ExecContext(ctx, `INSERT INTO report_deliveries
+(subscription_id, trigger_type, period_key, period_start, period_end, recipients, status, next_attempt_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, sub.ID, trigger, period.Key, period.Start.Format("2006-01-02"), period.End.AddDate(0, 0, -1).Format("2006-01-02"),
+		sub.Recipients, deliveryStatusPending, now)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (h *Handler) processReportDeliveries(ctx context.Context, now time.Time) {
+	if h.DB == nil {
+		return
+	}
+	rows, err := h.DB.QueryContext(ctx, `SELECT id FROM report_deliveries WHERE status = ? AND next_attempt_at <= ?
+ORDER BY next_attempt_at, id LIMIT ?`, deliveryStatusPending, now, reportDeliveryBatchSize)
+	if err != nil {
+		log.Printf("读取报表发送记录失败: %v", err)
+		return
+	}
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			log.Printf("读取报表发送记录失败: %v", err)
+			return
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	for _, id := range ids {
+		h.sendReportDelivery(ctx, id, now)
+	}
+}
+
+// sendReportDelivery 生成并发送一条待发送记录，失败时按退避间隔重新排队，超过次数后标记失败。
+// 只由 reportSubscriptionLoop 调用，同一记录不会被并发发送。
+func (h *Handler) sendReportDelivery(ctx context.Context, id int64, now time.Time) {
+	var delivery reportDelivery
+	err := h.DB.QueryRowContext(ctx, `SELECT id, subscription_id, period_key, period_start, period_end, recipients, attempts
+FROM report_deliveries WHERE id = ? AND status = ?`, id, deliveryStatusPending).Scan(&delivery.ID, &delivery.SubscriptionID, &delivery.PeriodKey,
+		&delivery.PeriodStart, &delivery.PeriodEnd, &delivery.Recipients, &delivery.Attempts)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("读取报表发送记录失败: delivery=%d %v", id, err)
+		}
+		return
+	}
+
+	sendErr := h.deliverReport(ctx, delivery)
+	attempts := delivery.Attempts + 1
+	if sendErr == nil {
+		_, err = h.DB.ExecContext(ctx, "UPDATE report_deliveries SET status = ?, attempts = ?, error_message = NULL, sent_at = ? WHERE id = ?",
+			deliveryStatusSent, attempts, time.Now(), id)
+	} else {
+		log.Printf("报表邮件发送失败: delivery=%d attempt=%d %v", id, attempts, sendErr)
+		message := sendErr.Error()
+		if utf8.RuneCountInString(message) > 500 {
+			message = string([]rune(message)[:500])
+		}
+		status := deliveryStatusFailed
+		nextAttempt := now
+		if attempts < reportDeliveryMaxAttempts {
+			status = deliveryStatusPending
+			nextAttempt = now.Add(reportDeliveryBackoff[minInt(attempts, len(reportDeliveryBackoff))-1])
+		}
+		_, err = h.DB.ExecContext(ctx, "UPDATE report_deliveries SET status = ?, attempts = ?, error_message = ?, next_attempt_at = ? WHERE id = ?",
+			status, attempts, message, nextAttempt, id)
+	}
+	if err != nil {
+		log.Printf("更新报表发送记录失败: delivery=%d %v", id, err)
+	}
+}
+
+func (h *Handler) deliverReport(ctx context.Context, delivery reportDelivery) error {
+	sub, err := h.getReportSubscription(ctx, delivery.SubscriptionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("订阅已删除")
+		}
+		return err
+	}
+	period := reportPeriod{Key: delivery.PeriodKey, Start: delivery.PeriodStart, End: delivery.PeriodEnd.AddDate(0, 0, 1)}
+	period.Label = period.Start.Format("2006-01-02")
+	if period.End.Sub(period.Start) > 24*time.Hour {
+		period.Label += " ~ " + delivery.PeriodEnd.Format("2006-01-02")
+	}
+	report, err := h.renderSubscriptionReport(ctx, sub, period)
+	if err != nil {
+		return fmt.Errorf("生成报表失败: %w", err)
+	}
+	msg := mailMessage{
+		To:      parseMailRecipients(delivery.Recipients),
+		Subject: "[WorkSentry] " + report.Subject,
+		Body:    buildReportHTML(report, sub.AttachXLSX),
+		HTML:    true,
+	}
+	if sub.AttachXLSX {
+		data, err := buildReportXLSX(report)
+		if err != nil {
+			return fmt.Errorf("生成附件失败: %w", err)
+		}
+		msg.Attachments = append(msg.Attachments, mailAttachment{
+			Filename:    report.Filename,
+			ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			Data:        data,
+		})
+	}
+	if h.Config == nil {
+		return fmt.Errorf("未配置邮件服务器")
+	}
+	return sendMail(ctx, h.Config.App.SMTP, msg)
+}
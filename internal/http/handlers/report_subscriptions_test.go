@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"worksentry/internal/config"
+)
+
+// TestReportSubscriptionSendQueued 手动发送只创建待发送记录并返回 202，邮件由后台循环发往本地 SMTP 替身。
+func TestReportSubscriptionSendQueued(t *testing.T) {
+	db := openTestDB(t)
+	smtpConfig, mails := startFakeSMTP(t)
+	h := NewHandler(&config.Config{App: config.AppConfig{SMTP: smtpConfig}}, db)
+	ctx := context.Background()
+
+	result, err := db.Exec(`INSERT INTO report_subscriptions (name, report_type, schedule, recipients, attach_xlsx, enabled)
+VALUES ('日报', 'daily', 'daily', 'lead@example.com', 1, 1)`)
+	if err != nil {
+		t.Fatalf("写入订阅失败: %v", err)
+	}
+	subscriptionID, _ := result.LastInsertId()
+
+	body := strings.NewReader(`{"id":` + strconv.FormatInt(subscriptionID, 10) + `}`)
+	rec := httptest.NewRecorder()
+	h.ReportSubscriptionSend(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/report-subscriptions/send", body))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("发送返回 %d: %s", rec.Code, rec.Body.String())
+	}
+	var delivery ReportDeliveryView
+	if err := json.Unmarshal(rec.Body.Bytes(), &delivery); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	if delivery.Status != deliveryStatusPending {
+		t.Fatalf("发送记录状态 = %s，期望 %s", delivery.Status, deliveryStatusPending)
+	}
+	select {
+	case <-mails:
+		t.Fatal("请求处理期间不应发送邮件")
+	default:
+	}
+
+	h.processReportDeliveries(ctx, time.Now())
+	select {
+	case mail := <-mails:
+		if strings.Join(mail.To, ",") != "lead@example.com" {
+			t.Fatalf("收件人 = %v", mail.To)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("后台循环未发送邮件")
+	}
+	var status string
+	if err := db.QueryRow("SELECT status FROM report_deliveries WHERE id = ?", delivery.ID).Scan(&status); err != nil {
+		t.Fatalf("读取发送记录失败: %v", err)
+	}
+	if status != deliveryStatusSent {
+		t.Fatalf("发送后状态 = %s，期望 %s", status, deliveryStatusSent)
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"database/sql"
 	"fmt"
 	"net/http"
 	"sort"
@@ -21,11 +22,17 @@ type DailyReportView struct {
 	OfflineDuration    string `json:"offlineDuration"`
 	AttendanceDuration string `json:"attendanceDuration"`
 	EffectiveDuration  string `json:"effectiveDuration"`
+	LeaveDuration      string `json:"leaveDuration"`
+	Shift              string `json:"shift"`
 }
 
 type DailyReportResponse struct {
-	Date  string            `json:"date"`
-	Items []DailyReportView `json:"items"`
+	Date         string                   `json:"date"`
+	DayType      string                   `json:"dayType"`
+	DayTypeLabel string                   `json:"dayTypeLabel"`
+	DayLabel     string                   `json:"dayLabel"`
+	Items        []DailyReportView        `json:"items"`
+	Subtotals    []DepartmentSubtotalView `json:"subtotals"`
 }
 
 type TimelineItem struct {
@@ -45,12 +52,24 @@ type RankItem struct {
 	Value        string `json:"value"`
 }
 
-type RankResponse struct {
-	Date    string     `json:"date"`
+type RankPeriod struct {
+	Period  string     `json:"period"`
+	Label   string     `json:"label"`
 	WorkTop []RankItem `json:"workTop"`
 	FishTop []RankItem `json:"fishTop"`
 }
 
+// RankResponse 按区间查询时 WorkTop/FishTop 为整个区间的排行，Periods 为各周期排行。
+type RankResponse struct {
+	Date      string       `json:"date,omitempty"`
+	StartDate string       `json:"startDate,omitempty"`
+	EndDate   string       `json:"endDate,omitempty"`
+	GroupBy   string       `json:"groupBy,omitempty"`
+	WorkTop   []RankItem   `json:"workTop"`
+	FishTop   []RankItem   `json:"fishTop"`
+	Periods   []RankPeriod `json:"periods,omitempty"`
+}
+
 type rankValue struct {
 	Item  RankItem
 	Score float64
@@ -61,8 +80,19 @@ func (h *Handler) ReportDaily(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
 		return
 	}
+	if h.exportIfRequested(w, r, h.dailyStatsExport) {
+		return
+	}
 	dateValue := r.URL.Query().Get("date")
 	departmentID := parseInt64(r.URL.Query().Get("departmentId"))
+	if rr, ranged, message := parseReportRange(r.URL.Query()); ranged {
+		if message != "" {
+			writeError(w, http.StatusBadRequest, message)
+			return
+		}
+		h.reportDailyRange(w, r, rr, departmentID)
+		return
+	}
 	if dateValue == "" {
 		dateValue = time.Now().Format("2006-01-02")
 	}
@@ -71,11 +101,12 @@ func (h *Handler) ReportDaily(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "日期格式错误")
 		return
 	}
-	rows, err := h.Queries.ListDailyStatsByDate(r.Context(), sqlc.ListDailyStatsByDateParams{
-		StatDate:     date,
-		Column2:      departmentID,
-		DepartmentID: toNullInt64(departmentID),
-	})
+	tree, err := loadDepartmentTree(r.Context(), h.Queries)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取部门失败")
+		return
+	}
+	rows, err := h.Queries.ListDailyStatsByDate(r.Context(), dailyStatsParams(date, departmentID, tree))
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "读取报表失败")
 		return
@@ -94,12 +125,24 @@ func (h *Handler) ReportDaily(w http.ResponseWriter, r *http.Request) {
 			OfflineDuration:    formatDuration(int64(row.OfflineSeconds)),
 			AttendanceDuration: formatDuration(int64(row.AttendanceSeconds)),
 			EffectiveDuration:  formatDuration(int64(row.EffectiveSeconds)),
+			LeaveDuration:      formatDuration(int64(row.LeaveSeconds)),
+			Shift:              nullString(row.ShiftName),
 		})
 	}
 
+	day, err := h.resolveCalendarDay(r.Context(), departmentID, date)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取工作日历失败")
+		return
+	}
+
 	writeJSON(w, http.StatusOK, DailyReportResponse{
-		Date:  date.Format("2006-01-02"),
-		Items: items,
+		Date:         date.Format("2006-01-02"),
+		DayType:      day.Type,
+		DayTypeLabel: dayTypeLabel(day.Type),
+		DayLabel:     calendarDayLabel(day),
+		Items:        items,
+		Subtotals:    departmentSubtotalViews(tree.rollupDepartments(departmentID, dailyStatsMembers(rows))),
 	})
 }
 
@@ -108,6 +151,9 @@ func (h *Handler) ReportTimeline(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
 		return
 	}
+	if h.exportIfRequested(w, r, h.timelineExport) {
+		return
+	}
 	code := r.URL.Query().Get("employeeCode")
 	dateValue := r.URL.Query().Get("date")
 	if code == "" || dateValue == "" {
@@ -126,8 +172,19 @@ func (h *Handler) ReportTimeline(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
-	end := start.Add(24 * time.Hour)
+	shiftName := ""
+	offset := time.Duration(0)
+	if shift, err := h.Queries.GetEmployeeShift(r.Context(), employee.ID); err == nil {
+		shiftName = shiftLabel(shift)
+		offset = shiftDayOffset(shift)
+	} else if err != sql.ErrNoRows {
+		writeError(w, http.StatusInternalServerError, "读取班次失败")
+		return
+	}
+
+	// 跨零点班次的时间轴按业务日取数，覆盖整个班次。
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local).Add(offset)
+	end := start.AddDate(0, 0, 1)
 	segments, err := h.Queries.ListTimeSegmentsByEmployeeAndRange(r.Context(), sqlc.ListTimeSegmentsByEmployeeAndRangeParams{
 		EmployeeID: employee.ID,
 		StartAt:    end,
@@ -138,6 +195,12 @@ func (h *Handler) ReportTimeline(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	day, err := h.resolveCalendarDay(r.Context(), employee.DepartmentID.Int64, date)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取工作日历失败")
+		return
+	}
+
 	items := make([]TimelineItem, 0, len(segments))
 	for _, seg := range segments {
 		statusCode := string(seg.Status)
@@ -155,6 +218,11 @@ func (h *Handler) ReportTimeline(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]any{
 		"employee": map[string]string{"code": employee.EmployeeCode, "name": employee.Name},
 		"date":     date.Format("2006-01-02"),
+		"shift":    shiftName,
+		"dayType":  day.Type,
+		"dayLabel": calendarDayLabel(day),
+		"startAt":  formatTime(start),
+		"endAt":    formatTime(end),
 		"items":    items,
 	})
 }
@@ -164,6 +232,19 @@ func (h *Handler) ReportRank(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
 		return
 	}
+	// 指定 metric 时按可配置排行返回，否则保持原有的有效工时与摸鱼排行
+	if r.URL.Query().Get("metric") != "" {
+		h.reportRankMetric(w, r)
+		return
+	}
+	if rr, ranged, message := parseReportRange(r.URL.Query()); ranged {
+		if message != "" {
+			writeError(w, http.StatusBadRequest, message)
+			return
+		}
+		h.reportRankRange(w, r, rr, parseInt64(r.URL.Query().Get("departmentId")))
+		return
+	}
 	dateValue := r.URL.Query().Get("date")
 	if dateValue == "" {
 		dateValue = time.Now().Format("2006-01-02")
@@ -174,9 +255,8 @@ func (h *Handler) ReportRank(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	rows, err := h.Queries.ListDailyStatsByDate(r.Context(), sqlc.ListDailyStatsByDateParams{
-		StatDate:     date,
-		Column2:      int64(0),
-		DepartmentID: toNullInt64(0),
+		StatDate: date,
+		Column2:  int64(0),
 	})
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "读取排行失败")
@@ -195,10 +275,8 @@ func (h *Handler) ReportRank(w http.ResponseWriter, r *http.Request) {
 			},
 			Score: float64(row.EffectiveSeconds),
 		})
-		fishRatio := 0.0
-		if row.AttendanceSeconds > 0 {
-			fishRatio = float64(row.FishSeconds) / float64(row.AttendanceSeconds)
-		}
+		values := DailyStatsValues{WorkSeconds: int64(row.WorkSeconds), NormalSeconds: int64(row.NormalSeconds), FishSeconds: int64(row.FishSeconds)}
+		fishRatio := values.activeRatio(values.FishSeconds)
 		fishList = append(fishList, rankValue{
 			Item: RankItem{
 				EmployeeCode: row.EmployeeCode,
@@ -16,6 +16,9 @@ type SettingsPayload struct {
 	UpdatePolicy             int32  `json:"updatePolicy"`
 	LatestVersion            string `json:"latestVersion"`
 	UpdateURL                string `json:"updateUrl"`
+	SessionAutoCloseMinutes  *int32 `json:"sessionAutoCloseMinutes"`
+	RawRetentionDays         *int32 `json:"rawRetentionDays"`
+	FishWarnClientNotice     *bool  `json:"fishWarnClientNotice"`
 }
 
 func (h *Handler) Settings(w http.ResponseWriter, r *http.Request) {
@@ -61,12 +64,47 @@ func (h *Handler) updateSettings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 管理端未提交的字段沿用已保存的配置
+	stored, err := h.Queries.GetSettings(r.Context())
+	if err == sql.ErrNoRows {
+		stored = defaultSettings()
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取配置失败")
+		return
+	}
+
+	// 未提交时沿用已保存的值，只有显式提交 0 才关闭按无活动时长自动下班
+	sessionAutoCloseMinutes := stored.SessionAutoCloseMinutes
+	if payload.SessionAutoCloseMinutes != nil {
+		sessionAutoCloseMinutes = *payload.SessionAutoCloseMinutes
+	}
+	if sessionAutoCloseMinutes < 0 || sessionAutoCloseMinutes > 7*24*60 {
+		writeError(w, http.StatusBadRequest, "自动下班时长范围 0-10080 分钟")
+		return
+	}
+
+	// 未提交保留天数时沿用已保存的值，避免旧版管理端保存配置时误删流水
+	rawRetentionDays := stored.RawRetentionDays
+	if payload.RawRetentionDays != nil {
+		rawRetentionDays = *payload.RawRetentionDays
+	}
+	if rawRetentionDays < 1 || rawRetentionDays > 3650 {
+		writeError(w, http.StatusBadRequest, "原始流水保留天数范围 1-3650")
+		return
+	}
+
+	// 未提交时沿用已保存的值，默认配置为向客户端推送摸鱼提醒
+	fishWarnClientNotice := stored.FishWarnClientNotice
+	if payload.FishWarnClientNotice != nil {
+		fishWarnClientNotice = *payload.FishWarnClientNotice
+	}
+
 	if payload.UpdatePolicy < 0 || payload.UpdatePolicy > 1 {
 		writeError(w, http.StatusBadRequest, "更新策略仅支持 0 或 1")
 		return
 	}
 
-	err := h.Queries.UpsertSettings(r.Context(), sqlc.UpsertSettingsParams{
+	err = h.Queries.UpsertSettings(r.Context(), sqlc.UpsertSettingsParams{
 		IdleThresholdSeconds:     payload.IdleThresholdSeconds,
 		HeartbeatIntervalSeconds: payload.HeartbeatIntervalSeconds,
 		OfflineThresholdSeconds:  payload.OfflineThresholdSeconds,
@@ -74,6 +112,9 @@ func (h *Handler) updateSettings(w http.ResponseWriter, r *http.Request) {
 		UpdatePolicy:             int8(payload.UpdatePolicy),
 		LatestVersion:            toNullString(payload.LatestVersion),
 		UpdateUrl:                toNullString(payload.UpdateURL),
+		SessionAutoCloseMinutes:  sessionAutoCloseMinutes,
+		RawRetentionDays:         rawRetentionDays,
+		FishWarnClientNotice:     fishWarnClientNotice,
 	})
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "保存配置失败")
@@ -93,6 +134,9 @@ func (h *Handler) settingsView(settings sqlc.Setting) SettingsPayload {
 		UpdatePolicy:             int32(settings.UpdatePolicy),
 		LatestVersion:            nullString(settings.LatestVersion),
 		UpdateURL:                nullString(settings.UpdateUrl),
+		SessionAutoCloseMinutes:  &settings.SessionAutoCloseMinutes,
+		RawRetentionDays:         &settings.RawRetentionDays,
+		FishWarnClientNotice:     &settings.FishWarnClientNotice,
 	}
 }
 
@@ -118,5 +162,8 @@ func defaultSettings() sqlc.Setting {
 		UpdatePolicy:             0,
 		LatestVersion:            sql.NullString{},
 		UpdateUrl:                sql.NullString{},
+		SessionAutoCloseMinutes:  defaultSessionAutoCloseMinutes,
+		RawRetentionDays:         defaultRawRetentionDays,
+		FishWarnClientNotice:     true,
 	}
 }
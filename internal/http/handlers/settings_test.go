@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"worksentry/internal/config"
+	"worksentry/internal/db/sqlc"
+)
+
+// TestUpdateSettingsKeepsOmittedFields 旧版管理端不提交的字段保存后仍为已配置的值。
+func TestUpdateSettingsKeepsOmittedFields(t *testing.T) {
+	db := openTestDB(t)
+	h := NewHandler(&config.Config{}, db)
+	ctx := context.Background()
+
+	stored := defaultSettings()
+	stored.RawRetentionDays = 30
+	stored.SessionAutoCloseMinutes = 240
+	stored.FishWarnClientNotice = false
+	if err := h.Queries.UpsertSettings(ctx, sqlc.UpsertSettingsParams{
+		IdleThresholdSeconds:     stored.IdleThresholdSeconds,
+		HeartbeatIntervalSeconds: stored.HeartbeatIntervalSeconds,
+		OfflineThresholdSeconds:  stored.OfflineThresholdSeconds,
+		FishRatioWarnPercent:     stored.FishRatioWarnPercent,
+		UpdatePolicy:             stored.UpdatePolicy,
+		SessionAutoCloseMinutes:  stored.SessionAutoCloseMinutes,
+		RawRetentionDays:         stored.RawRetentionDays,
+		FishWarnClientNotice:     stored.FishWarnClientNotice,
+	}); err != nil {
+		t.Fatalf("写入配置失败: %v", err)
+	}
+
+	body := strings.NewReader(`{"idleThresholdSeconds":600,"heartbeatIntervalSeconds":300,"offlineThresholdSeconds":900,"fishRatioWarnPercent":20,"updatePolicy":0}`)
+	rec := httptest.NewRecorder()
+	h.Settings(rec, httptest.NewRequest(http.MethodPut, "/api/v1/admin/settings", body))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("保存返回 %d: %s", rec.Code, rec.Body.String())
+	}
+
+	saved, err := h.Queries.GetSettings(ctx)
+	if err != nil {
+		t.Fatalf("读取配置失败: %v", err)
+	}
+	if saved.IdleThresholdSeconds != 600 {
+		t.Fatalf("空闲阈值 = %d，期望 600", saved.IdleThresholdSeconds)
+	}
+	if saved.RawRetentionDays != 30 {
+		t.Fatalf("原始流水保留天数 = %d，期望保留 30", saved.RawRetentionDays)
+	}
+	if saved.SessionAutoCloseMinutes != 240 {
+		t.Fatalf("自动下班时长 = %d，期望保留 240", saved.SessionAutoCloseMinutes)
+	}
+	if saved.FishWarnClientNotice {
+		t.Fatal("已关闭的客户端摸鱼提醒被重新打开")
+	}
+}
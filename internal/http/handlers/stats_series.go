@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	granularityHour  = "hour"
+	granularityDay   = "day"
+	granularityMonth = "month"
+
+	// 超过该跨度不再按小时返回，避免点数过多
+	seriesMaxHourSpan = 7 * 24 * time.Hour
+	seriesMaxDaySpan  = 366 * 24 * time.Hour
+)
+
+type StatsSeriesPoint struct {
+	Bucket            string `json:"bucket"`
+	WorkSeconds       int64  `json:"workSeconds"`
+	NormalSeconds     int64  `json:"normalSeconds"`
+	FishSeconds       int64  `json:"fishSeconds"`
+	IdleSeconds       int64  `json:"idleSeconds"`
+	OfflineSeconds    int64  `json:"offlineSeconds"`
+	AttendanceSeconds int64  `json:"attendanceSeconds"`
+	EffectiveSeconds  int64  `json:"effectiveSeconds"`
+	LeaveSeconds      int64  `json:"leaveSeconds"`
+}
+
+type StatsSeriesResponse struct {
+	Granularity string             `json:"granularity"`
+	Start       string             `json:"start"`
+	End         string             `json:"end"`
+	Points      []StatsSeriesPoint `json:"points"`
+}
+
+type statsSeriesFilter struct {
+	DepartmentID int64
+	EmployeeID   int64
+}
+
+// pickStatsGranularity 选择读取量最小的粒度：两天内按小时，半年内按天，更长按月。
+// 七天内的非整天区间只有小时统计能精确回答，更长的区间按整天处理。
+func pickStatsGranularity(start time.Time, end time.Time) string {
+	span := end.Sub(start)
+	aligned := start.Equal(truncateDay(start)) && end.Equal(truncateDay(end))
+	switch {
+	case span <= 48*time.Hour, !aligned && span <= seriesMaxHourSpan:
+		return granularityHour
+	case span <= 183*24*time.Hour:
+		return granularityDay
+	default:
+		return granularityMonth
+	}
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// queryStatsSeries 按粒度读取汇总序列：小时读 hourly_stats，按天、按月读 daily_stats。
+// 日、月粒度以业务日归属，跨零点班次与小时粒度的自然时间存在差异。
+func (h *Handler) queryStatsSeries(ctx context.Context, filter statsSeriesFilter, start time.Time, end time.Time, granularity string) ([]StatsSeriesPoint, error) {
+	var query string
+	var args []any
+	switch granularity {
+	case granularityHour:
+		query = `SELECT DATE_FORMAT(s.stat_hour, '%Y-%m-%d %H:00')`
+		query += statsSeriesSums + ` FROM hourly_stats s JOIN employees e ON s.employee_id = e.id
+WHERE s.stat_hour >= ? AND s.stat_hour < ?`
+		args = append(args, truncateHour(start), end)
+	case granularityDay, granularityMonth:
+		bucket := "DATE_FORMAT(s.stat_date, '%Y-%m-%d')"
+		if granularity == granularityMonth {
+			bucket = "DATE_FORMAT(s.stat_date, '%Y-%m')"
+		}
+		query = "SELECT " + bucket + statsSeriesSums + ` FROM daily_stats s JOIN employees e ON s.employee_id = e.id
+WHERE s.stat_date >= ? AND s.stat_date < ?`
+		args = append(args, start.Format("2006-01-02"), end.Format("2006-01-02"))
+	}
+	clause, clauseArgs, err := h.departmentFilter(ctx, "e.department_id", filter.DepartmentID)
+	if err != nil {
+		return nil, err
+	}
+	query += clause
+	args = append(args, clauseArgs...)
+	if filter.EmployeeID > 0 {
+		query += " AND s.employee_id = ?"
+		args = append(args, filter.EmployeeID)
+	}
+	query += " GROUP BY 1 ORDER BY 1"
+
+	rows, err := h.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	points := make([]StatsSeriesPoint, 0)
+	for rows.Next() {
+		var point StatsSeriesPoint
+		if err := rows.Scan(&point.Bucket, &point.WorkSeconds, &point.NormalSeconds, &point.FishSeconds, &point.IdleSeconds,
+			&point.OfflineSeconds, &point.AttendanceSeconds, &point.EffectiveSeconds, &point.LeaveSeconds); err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+const statsSeriesSums = `, COALESCE(SUM(s.work_seconds), 0), COALESCE(SUM(s.normal_seconds), 0), COALESCE(SUM(s.fish_seconds), 0),
+ COALESCE(SUM(s.idle_seconds), 0), COALESCE(SUM(s.offline_seconds), 0), COALESCE(SUM(s.attendance_seconds), 0),
+ COALESCE(SUM(s.effective_seconds), 0), COALESCE(SUM(s.leave_seconds), 0)`
+
+// ReportSeries 返回区间内的汇总曲线。start/end 支持日期或日期时间，end 为日期时包含当天；
+// granularity 默认 auto，由区间自动选择最省的汇总表。
+func (h *Handler) ReportSeries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	query := r.URL.Query()
+	start, err := parseSeriesTime(query.Get("start"), false)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "开始时间格式错误")
+		return
+	}
+	end, err := parseSeriesTime(query.Get("end"), true)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "结束时间格式错误")
+		return
+	}
+	if !end.After(start) {
+		writeError(w, http.StatusBadRequest, "结束时间必须大于开始时间")
+		return
+	}
+
+	granularity := strings.TrimSpace(query.Get("granularity"))
+	if granularity == "" || granularity == "auto" {
+		granularity = pickStatsGranularity(start, end)
+	}
+	switch granularity {
+	case granularityHour:
+		if end.Sub(start) > seriesMaxHourSpan {
+			writeError(w, http.StatusBadRequest, "按小时查询的跨度不能超过 7 天")
+			return
+		}
+	case granularityDay, granularityMonth:
+		start = truncateDay(start)
+		if !end.Equal(truncateDay(end)) {
+			end = truncateDay(end).AddDate(0, 0, 1)
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "粒度仅支持 hour、day、month")
+		return
+	}
+	if granularity == granularityDay && end.Sub(start) > seriesMaxDaySpan {
+		writeError(w, http.StatusBadRequest, "按天查询的跨度不能超过 366 天")
+		return
+	}
+
+	filter := statsSeriesFilter{DepartmentID: parseInt64(query.Get("departmentId"))}
+	if code := strings.TrimSpace(query.Get("employeeCode")); code != "" {
+		employee, err := h.Queries.GetEmployeeByCode(r.Context(), code)
+		if err != nil {
+			writeError(w, http.StatusNotFound, "员工不存在")
+			return
+		}
+		filter.EmployeeID = employee.ID
+	}
+
+	points, err := h.queryStatsSeries(r.Context(), filter, start, end, granularity)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取统计失败")
+		return
+	}
+	writeJSON(w, http.StatusOK, StatsSeriesResponse{
+		Granularity: granularity,
+		Start:       formatTime(start),
+		End:         formatTime(end),
+		Points:      points,
+	})
+}
+
+// parseSeriesTime 解析日期或日期时间；作为结束时间的纯日期表示包含当天。
+func parseSeriesTime(value string, isEnd bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := parseDate(value); err == nil {
+		if isEnd {
+			return t.AddDate(0, 0, 1), nil
+		}
+		return t, nil
+	}
+	return parseDateTime(value)
+}
@@ -0,0 +1,548 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"worksentry/internal/db/sqlc"
+)
+
+const (
+	dayTypeWorkday = "workday"
+	dayTypeRestday = "restday"
+	dayTypeHoliday = "holiday"
+
+	calendarSourceDefault    = "default"
+	calendarSourceCompany    = "company"
+	calendarSourceDepartment = "department"
+
+	calendarMaxRangeDays = 366
+)
+
+type CalendarDayView struct {
+	Date         string `json:"date"`
+	Weekday      string `json:"weekday"`
+	DayType      string `json:"dayType"`
+	DayTypeLabel string `json:"dayTypeLabel"`
+	Name         string `json:"name"`
+	Source       string `json:"source"`
+}
+
+type CalendarDayPayload struct {
+	DepartmentID int64  `json:"departmentId"`
+	Date         string `json:"date"`
+	DayType      string `json:"dayType"`
+	Name         string `json:"name"`
+}
+
+type CalendarImportPayload struct {
+	DepartmentID int64             `json:"departmentId"`
+	Content      string            `json:"content"`
+	DefaultType  string            `json:"defaultType"`
+	TypeMapping  map[string]string `json:"typeMapping"`
+}
+
+type CalendarImportResponse struct {
+	Imported int      `json:"imported"`
+	Skipped  int      `json:"skipped"`
+	Dates    []string `json:"dates"`
+}
+
+type calendarDay struct {
+	Date   time.Time
+	Type   string
+	Name   string
+	Source string
+}
+
+func (d calendarDay) IsWorkday() bool {
+	return d.Type == dayTypeWorkday
+}
+
+func (h *Handler) Calendar(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		h.listCalendarDays(w, r)
+	case http.MethodPut:
+		h.saveCalendarDay(w, r)
+	case http.MethodDelete:
+		h.deleteCalendarDay(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+	}
+}
+
+func (h *Handler) listCalendarDays(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	startValue := r.URL.Query().Get("startDate")
+	endValue := r.URL.Query().Get("endDate")
+	if startValue == "" {
+		startValue = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local).Format("2006-01-02")
+	}
+	start, err := parseDate(startValue)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "开始日期格式错误")
+		return
+	}
+	end := start.AddDate(0, 1, -1)
+	if endValue != "" {
+		end, err = parseDate(endValue)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "结束日期格式错误")
+			return
+		}
+	}
+	if end.Before(start) {
+		writeError(w, http.StatusBadRequest, "结束日期不能早于开始日期")
+		return
+	}
+	if end.Sub(start) > calendarMaxRangeDays*24*time.Hour {
+		writeError(w, http.StatusBadRequest, "查询范围不能超过一年")
+		return
+	}
+
+	days, err := h.resolveCalendarDays(r.Context(), parseInt64(r.URL.Query().Get("departmentId")), start, end.AddDate(0, 0, 1))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取日历失败")
+		return
+	}
+	items := make([]CalendarDayView, 0, len(days))
+	for _, day := range days {
+		items = append(items, CalendarDayView{
+			Date:         day.Date.Format("2006-01-02"),
+			Weekday:      weekdayLabel(day.Date.Weekday()),
+			DayType:      day.Type,
+			DayTypeLabel: dayTypeLabel(day.Type),
+			Name:         day.Name,
+			Source:       day.Source,
+		})
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (h *Handler) saveCalendarDay(w http.ResponseWriter, r *http.Request) {
+	var payload CalendarDayPayload
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "参数格式错误")
+		return
+	}
+	date, err := parseDate(payload.Date)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "日期格式错误")
+		return
+	}
+	if !isValidDayType(payload.DayType) {
+		writeError(w, http.StatusBadRequest, "日期类型无效")
+		return
+	}
+	payload.Name = strings.TrimSpace(payload.Name)
+	if utf8.RuneCountInString(payload.Name) > 64 {
+		writeError(w, http.StatusBadRequest, "名称长度不能超过 64 个字符")
+		return
+	}
+	if payload.DepartmentID < 0 {
+		writeError(w, http.StatusBadRequest, "部门无效")
+		return
+	}
+
+	if err := upsertCalendarDay(r.Context(), h.DB, payload.DepartmentID, date, payload.DayType, payload.Name, "manual"); err != nil {
+		writeError(w, http.StatusInternalServerError, "保存日历失败")
+		return
+	}
+	h.logAudit(r, "save_calendar_day", "calendar_day", sql.NullInt64{}, payload)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "已保存"})
+}
+
+func (h *Handler) deleteCalendarDay(w http.ResponseWriter, r *http.Request) {
+	date, err := parseDate(r.URL.Query().Get("date"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "日期格式错误")
+		return
+	}
+	departmentID := parseInt64(r.URL.Query().Get("departmentId"))
+	if _, err := h.DB.ExecContext(r.Context(), "DELETE FROM calendar_days WHERE department_id = ? AND cal_date = ?", departmentID, date.Format("2006-01-02")); err != nil {
+		writeError(w, http.StatusInternalServerError, "删除日历失败")
+		return
+	}
+	h.logAudit(r, "delete_calendar_day", "calendar_day", sql.NullInt64{}, map[string]any{
+		"departmentId": departmentID,
+		"date":         date.Format("2006-01-02"),
+	})
+	writeJSON(w, http.StatusOK, map[string]string{"message": "已删除"})
+}
+
+// CalendarImport 导入 ICS 日历：每个事件覆盖 DTSTART 至 DTEND（不含）之间的日期。
+// 日期类型依次取事件的 X-WORKSENTRY-DAY-TYPE 属性、typeMapping 中与标题或分类完全相同的映射，
+// 都没有时按 defaultType 处理（默认节假日）。
+func (h *Handler) CalendarImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	var payload CalendarImportPayload
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "参数格式错误")
+		return
+	}
+	if strings.TrimSpace(payload.Content) == "" {
+		writeError(w, http.StatusBadRequest, "日历内容不能为空")
+		return
+	}
+	defaultType := strings.TrimSpace(payload.DefaultType)
+	if defaultType == "" {
+		defaultType = dayTypeHoliday
+	}
+	if !isValidDayType(defaultType) {
+		writeError(w, http.StatusBadRequest, "日期类型无效")
+		return
+	}
+	if payload.DepartmentID < 0 {
+		writeError(w, http.StatusBadRequest, "部门无效")
+		return
+	}
+
+	mapping := make(map[string]string, len(payload.TypeMapping))
+	for key, value := range payload.TypeMapping {
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key == "" {
+			continue
+		}
+		if !isValidDayType(value) {
+			writeError(w, http.StatusBadRequest, "日期类型映射无效："+key)
+			return
+		}
+		mapping[key] = value
+	}
+
+	events, skipped := parseICSEvents(payload.Content)
+	if len(events) == 0 {
+		writeError(w, http.StatusBadRequest, "未解析到有效的日历事件")
+		return
+	}
+
+	tx, err := h.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "导入失败")
+		return
+	}
+	result := CalendarImportResponse{Skipped: skipped, Dates: []string{}}
+	for _, event := range events {
+		dayType := event.eventDayType(mapping, defaultType)
+		name := event.Summary
+		if utf8.RuneCountInString(name) > 64 {
+			name = string([]rune(name)[:64])
+		}
+		for day := event.Start; day.Before(event.End); day = day.AddDate(0, 0, 1) {
+			if err := upsertCalendarDay(r.Context(), tx, payload.DepartmentID, day, dayType, name, "ics"); err != nil {
+				_ = tx.Rollback()
+				writeError(w, http.StatusInternalServerError, "导入失败")
+				return
+			}
+			result.Imported++
+			result.Dates = append(result.Dates, day.Format("2006-01-02"))
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "导入失败")
+		return
+	}
+
+	h.logAudit(r, "import_calendar", "calendar_day", sql.NullInt64{}, map[string]any{
+		"departmentId": payload.DepartmentID,
+		"imported":     result.Imported,
+		"skipped":      result.Skipped,
+	})
+	writeJSON(w, http.StatusOK, result)
+}
+
+func upsertCalendarDay(ctx context.Context, db sqlc.DBTX, departmentID int64, date time.Time, dayType string, name string, source string) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO calendar_days (department_id, cal_date, day_type, name, source)
+VALUES (?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE day_type = VALUES(day_type), name = VALUES(name), source = VALUES(source)`,
+		departmentID, date.Format("2006-01-02"), dayType, toNullText(name), source)
+	return err
+}
+
+// resolveCalendarDays 返回 [start, end) 内每天的日期类型：部门覆盖优先（本部门优先于上级部门），
+// 其次公司日历，最后按周一至周五上班。
+func (h *Handler) resolveCalendarDays(ctx context.Context, departmentID int64, start time.Time, end time.Time) ([]calendarDay, error) {
+	overrides := map[string]calendarDay{}
+	if h.DB != nil {
+		// 优先级：公司日历为 0，离本部门越近越高
+		priority := map[int64]int{0: 0}
+		if departmentID > 0 {
+			tree, err := loadDepartmentTree(ctx, h.Queries)
+			if err != nil {
+				return nil, err
+			}
+			chain := tree.ancestors(departmentID)
+			for i, id := range chain {
+				priority[id] = len(chain) - i
+			}
+		}
+		placeholders := make([]string, 0, len(priority))
+		args := make([]any, 0, len(priority)+2)
+		for id := range priority {
+			placeholders = append(placeholders, "?")
+			args = append(args, id)
+		}
+		args = append(args, start.Format("2006-01-02"), end.Format("2006-01-02"))
+		rows, err := h.DB.QueryContext(ctx, `SELECT department_id, cal_date, day_type, name
+FROM calendar_days
+WHERE department_id IN (`+strings.Join(placeholders, ",")+`) AND cal_date >= ? AND cal_date < ?`, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		ranks := map[string]int{}
+		for rows.Next() {
+			var deptID int64
+			var date time.Time
+			var item calendarDay
+			var name sql.NullString
+			if err := rows.Scan(&deptID, &date, &item.Type, &name); err != nil {
+				return nil, err
+			}
+			key := date.Format("2006-01-02")
+			if rank, ok := ranks[key]; ok && rank >= priority[deptID] {
+				continue
+			}
+			ranks[key] = priority[deptID]
+			item.Name = nullString(name)
+			item.Source = calendarSourceCompany
+			if deptID > 0 {
+				item.Source = calendarSourceDepartment
+			}
+			overrides[key] = item
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	days := []calendarDay{}
+	for day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location()); day.Before(end); day = day.AddDate(0, 0, 1) {
+		item, ok := overrides[day.Format("2006-01-02")]
+		if !ok {
+			item = calendarDay{Type: dayTypeWorkday, Source: calendarSourceDefault}
+			if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+				item.Type = dayTypeRestday
+			}
+		}
+		item.Date = day
+		days = append(days, item)
+	}
+	return days, nil
+}
+
+func (h *Handler) resolveCalendarDay(ctx context.Context, departmentID int64, date time.Time) (calendarDay, error) {
+	days, err := h.resolveCalendarDays(ctx, departmentID, date, date.AddDate(0, 0, 1))
+	if err != nil || len(days) == 0 {
+		return calendarDay{Date: date, Type: dayTypeWorkday, Source: calendarSourceDefault}, err
+	}
+	return days[0], nil
+}
+
+type icsEvent struct {
+	Start      time.Time
+	End        time.Time
+	Summary    string
+	Categories []string
+	DayType    string
+}
+
+// icsDayTypeProperty 事件上显式指定日期类型的扩展属性
+const icsDayTypeProperty = "X-WORKSENTRY-DAY-TYPE"
+
+func (e icsEvent) eventDayType(mapping map[string]string, defaultType string) string {
+	if isValidDayType(e.DayType) {
+		return e.DayType
+	}
+	if dayType, ok := mapping[e.Summary]; ok {
+		return dayType
+	}
+	for _, category := range e.Categories {
+		if dayType, ok := mapping[category]; ok {
+			return dayType
+		}
+	}
+	return defaultType
+}
+
+// parseICSEvents 解析 VEVENT 的 DTSTART/DTEND/SUMMARY/CATEGORIES 与日期类型扩展属性，返回事件与跳过数量。
+// 带时间的起止时间按 TZID（或 UTC 标记）换算为本地时间后取日期，结束时间不在零点时包含结束当天。
+func parseICSEvents(content string) ([]icsEvent, int) {
+	lines := unfoldICSLines(content)
+	events := []icsEvent{}
+	skipped := 0
+	var current *icsEvent
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		property, params, _ := strings.Cut(name, ";")
+		property = strings.ToUpper(property)
+		switch property {
+		case "BEGIN":
+			if strings.EqualFold(value, "VEVENT") {
+				current = &icsEvent{}
+			}
+		case "END":
+			if !strings.EqualFold(value, "VEVENT") || current == nil {
+				continue
+			}
+			if current.Start.IsZero() {
+				skipped++
+			} else {
+				if current.End.IsZero() || !current.End.After(current.Start) {
+					current.End = current.Start.AddDate(0, 0, 1)
+				}
+				if current.End.Sub(current.Start) > calendarMaxRangeDays*24*time.Hour {
+					skipped++
+				} else {
+					events = append(events, *current)
+				}
+			}
+			current = nil
+		case "DTSTART", "DTEND":
+			if current == nil {
+				continue
+			}
+			instant, err := parseICSDate(value, icsParam(params, "TZID"))
+			if err != nil {
+				continue
+			}
+			date := time.Date(instant.Year(), instant.Month(), instant.Day(), 0, 0, 0, 0, time.Local)
+			if property == "DTSTART" {
+				current.Start = date
+			} else {
+				if instant.After(date) {
+					date = date.AddDate(0, 0, 1)
+				}
+				current.End = date
+			}
+		case "SUMMARY":
+			if current != nil {
+				current.Summary = strings.TrimSpace(unescapeICSText(value))
+			}
+		case "CATEGORIES":
+			if current != nil {
+				for _, category := range strings.Split(value, ",") {
+					if category = strings.TrimSpace(unescapeICSText(category)); category != "" {
+						current.Categories = append(current.Categories, category)
+					}
+				}
+			}
+		case icsDayTypeProperty:
+			if current != nil {
+				current.DayType = strings.ToLower(strings.TrimSpace(value))
+			}
+		}
+	}
+	return events, skipped
+}
+
+func unfoldICSLines(content string) []string {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lines := []string{}
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// parseICSDate 解析 DATE（20240101）或 DATE-TIME（20240101T090000、20240101T010000Z）并换算为本地时间。
+// 不带 Z 的时间按 tzid 所指时区解释，tzid 为空或无法识别时视为本地时间。
+func parseICSDate(value string, tzid string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if len(value) == 8 {
+		return time.ParseInLocation("20060102", value, time.Local)
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return t.In(time.Local), nil
+	}
+	loc := time.Local
+	if tzid != "" {
+		if zone, err := time.LoadLocation(tzid); err == nil {
+			loc = zone
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.In(time.Local), nil
+}
+
+// icsParam 读取属性参数（如 DTSTART;TZID=Asia/Shanghai 中的 TZID）。
+func icsParam(params string, key string) string {
+	for _, param := range strings.Split(params, ";") {
+		name, value, ok := strings.Cut(param, "=")
+		if ok && strings.EqualFold(strings.TrimSpace(name), key) {
+			return strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return ""
+}
+
+func unescapeICSText(value string) string {
+	replacer := strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return replacer.Replace(value)
+}
+
+func isValidDayType(value string) bool {
+	return value == dayTypeWorkday || value == dayTypeRestday || value == dayTypeHoliday
+}
+
+func dayTypeLabel(value string) string {
+	switch value {
+	case dayTypeWorkday:
+		return "工作日"
+	case dayTypeRestday:
+		return "休息日"
+	case dayTypeHoliday:
+		return "节假日"
+	default:
+		return "未知"
+	}
+}
+
+func weekdayLabel(day time.Weekday) string {
+	labels := []string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"}
+	return labels[day]
+}
+
+// calendarDayLabel 生成报表中的日期标注，普通工作日不额外标注。
+func calendarDayLabel(day calendarDay) string {
+	if day.IsWorkday() && day.Name == "" {
+		return ""
+	}
+	if day.Name != "" {
+		return day.Name
+	}
+	return dayTypeLabel(day.Type)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseICSDate(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("缺少时区数据: %v", err)
+	}
+	tests := []struct {
+		name    string
+		value   string
+		tzid    string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "日期", value: "20240101", want: time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)},
+		{name: "本地时间", value: "20240101T090000", want: time.Date(2024, 1, 1, 9, 0, 0, 0, time.Local)},
+		{name: "UTC", value: "20240101T010000Z", want: time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)},
+		{name: "TZID", value: "20240101T200000", tzid: "America/New_York", want: time.Date(2024, 1, 1, 20, 0, 0, 0, newYork)},
+		{name: "UTC 忽略 TZID", value: "20240101T010000Z", tzid: "America/New_York", want: time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)},
+		{name: "未知 TZID 按本地时间", value: "20240101T090000", tzid: "Mars/Olympus", want: time.Date(2024, 1, 1, 9, 0, 0, 0, time.Local)},
+		{name: "格式错误", value: "2024-01-01", wantErr: true},
+		{name: "空值", value: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseICSDate(tt.value, tt.tzid)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseICSDate(%q) 应返回错误，得到 %v", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseICSDate(%q) 返回错误: %v", tt.value, err)
+			}
+			if !got.Equal(tt.want) || got.Location() != time.Local {
+				t.Fatalf("parseICSDate(%q, %q) = %v，期望 %v（本地时间）", tt.value, tt.tzid, got, tt.want.In(time.Local))
+			}
+		})
+	}
+}
+
+func TestParseICSEventsDayType(t *testing.T) {
+	content := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20240210\r\nDTEND;VALUE=DATE:20240213\r\nSUMMARY:春节\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20240204\r\nSUMMARY:春节调休\r\nCATEGORIES:补班,假期\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20240205\r\nSUMMARY:加班\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20240206\r\nSUMMARY:团建\r\nX-WORKSENTRY-DAY-TYPE:restday\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nDTSTART:20240207T090000\r\nDTEND:20240208T120000\r\nSUMMARY:跨天活动\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nSUMMARY:缺少开始\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	events, skipped := parseICSEvents(content)
+	if skipped != 1 {
+		t.Fatalf("skipped = %d，期望 1", skipped)
+	}
+	mapping := map[string]string{"补班": dayTypeWorkday}
+	want := []struct {
+		summary string
+		start   string
+		days    int
+		dayType string
+	}{
+		{summary: "春节", start: "2024-02-10", days: 3, dayType: dayTypeHoliday},
+		{summary: "春节调休", start: "2024-02-04", days: 1, dayType: dayTypeWorkday},
+		{summary: "加班", start: "2024-02-05", days: 1, dayType: dayTypeHoliday},
+		{summary: "团建", start: "2024-02-06", days: 1, dayType: dayTypeRestday},
+		{summary: "跨天活动", start: "2024-02-07", days: 2, dayType: dayTypeHoliday},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("解析到 %d 个事件，期望 %d", len(events), len(want))
+	}
+	for i, w := range want {
+		event := events[i]
+		if event.Summary != w.summary {
+			t.Errorf("事件 %d 标题 = %q，期望 %q", i, event.Summary, w.summary)
+		}
+		if got := event.Start.Format("2006-01-02"); got != w.start {
+			t.Errorf("%s 开始日期 = %s，期望 %s", w.summary, got, w.start)
+		}
+		if got := int(event.End.Sub(event.Start).Hours()/24 + 0.5); got != w.days {
+			t.Errorf("%s 覆盖 %d 天，期望 %d", w.summary, got, w.days)
+		}
+		if got := event.eventDayType(mapping, dayTypeHoliday); got != w.dayType {
+			t.Errorf("%s 日期类型 = %s，期望 %s", w.summary, got, w.dayType)
+		}
+	}
+}
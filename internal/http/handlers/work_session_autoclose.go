@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"worksentry/internal/db/sqlc"
+)
+
+const (
+	closeReasonInactive = "inactive"
+	closeReasonShiftEnd = "shift_end"
+
+	// defaultSessionAutoCloseMinutes 未配置时无活动 12 小时自动下班
+	defaultSessionAutoCloseMinutes = 720
+)
+
+type openWorkSession struct {
+	ID         int64
+	EmployeeID int64
+	StartAt    time.Time
+}
+
+func (h *Handler) workSessionAutoCloseLoop(ctx context.Context) {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.autoCloseWorkSessions(ctx)
+		}
+	}
+}
+
+// autoCloseWorkSessions 关闭遗忘下班的上班记录：超过无活动时长，或班次结束后已离线。
+// 结束时间取最后一次上报，关闭后写入考核记录等待补录原因。
+func (h *Handler) autoCloseWorkSessions(ctx context.Context) {
+	if h.DB == nil {
+		return
+	}
+	settings := h.getSettingsOrDefaultByContext(ctx)
+	inactiveLimit := time.Duration(settings.SessionAutoCloseMinutes) * time.Minute
+	offlineThreshold := time.Duration(settings.OfflineThresholdSeconds) * time.Second
+
+	sessions, err := listOpenWorkSessions(ctx, h.DB)
+	if err != nil {
+		log.Printf("自动下班检查失败: %v", err)
+		return
+	}
+	now := time.Now()
+	for _, session := range sessions {
+		lastActive, err := h.lastActivityAt(ctx, session)
+		if err != nil {
+			log.Printf("自动下班检查失败: employee=%d %v", session.EmployeeID, err)
+			continue
+		}
+
+		reason := ""
+		if inactiveLimit > 0 && now.Sub(lastActive) >= inactiveLimit {
+			reason = closeReasonInactive
+		} else if offlineThreshold > 0 && now.Sub(lastActive) >= offlineThreshold {
+			shift, err := h.Queries.GetEmployeeShift(ctx, session.EmployeeID)
+			if err != nil && err != sql.ErrNoRows {
+				log.Printf("自动下班检查失败: employee=%d %v", session.EmployeeID, err)
+				continue
+			}
+			if err == nil {
+				_, expectedEnd := shiftExpectedRange(shift, businessDate(session.StartAt, shiftDayOffset(shift)))
+				if now.After(expectedEnd.Add(time.Duration(shift.GraceMinutes) * time.Minute)) {
+					reason = closeReasonShiftEnd
+				}
+			}
+		}
+		if reason == "" {
+			continue
+		}
+
+		closed, err := h.closeForgottenSession(ctx, session, lastActive, reason)
+		if err != nil {
+			log.Printf("自动下班失败: employee=%d session=%d %v", session.EmployeeID, session.ID, err)
+			continue
+		}
+		if !closed {
+			continue
+		}
+		detail, _ := json.Marshal(map[string]any{
+			"employeeId": session.EmployeeID,
+			"startAt":    formatTime(session.StartAt),
+			"endAt":      formatTime(lastActive),
+			"reason":     reason,
+		})
+		_ = h.Queries.CreateAuditLog(ctx, sqlc.CreateAuditLogParams{
+			OperatorID: 0,
+			Action:     "auto_close_work_session",
+			TargetType: "work_session",
+			TargetID:   sql.NullInt64{Int64: session.ID, Valid: true},
+			Detail:     detail,
+		})
+	}
+}
+
+func listOpenWorkSessions(ctx context.Context, db sqlc.DBTX) ([]openWorkSession, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, employee_id, start_at FROM work_sessions WHERE end_at IS NULL")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := []openWorkSession{}
+	for rows.Next() {
+		var item openWorkSession
+		if err := rows.Scan(&item.ID, &item.EmployeeID, &item.StartAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, item)
+	}
+	return sessions, rows.Err()
+}
+
+// lastActivityAt 取最后一条上报与 last_seen_at 中较晚者，且不早于上班时间。
+func (h *Handler) lastActivityAt(ctx context.Context, session openWorkSession) (time.Time, error) {
+	lastActive := session.StartAt
+	event, err := h.Queries.GetLastRawEventByEmployee(ctx, session.EmployeeID)
+	if err != nil && err != sql.ErrNoRows {
+		return lastActive, err
+	}
+	if err == nil {
+		lastActive = maxTime(lastActive, event.ReceivedAt)
+	}
+	employee, err := h.Queries.GetEmployeeByID(ctx, session.EmployeeID)
+	if err != nil {
+		return lastActive, err
+	}
+	if employee.LastSeenAt.Valid {
+		lastActive = maxTime(lastActive, employee.LastSeenAt.Time)
+	}
+	return lastActive, nil
+}
+
+func (h *Handler) closeForgottenSession(ctx context.Context, session openWorkSession, endAt time.Time, reason string) (bool, error) {
+	closed := false
+	err := h.withEmployeeTx(ctx, session.EmployeeID, func(qtx *sqlc.Queries, tx *sql.Tx, locked sqlc.Employee) error {
+		closed = false
+		// 加锁后确认仍未下班，且期间没有新的上报
+		if locked.LastSeenAt.Valid && locked.LastSeenAt.Time.After(endAt) {
+			return nil
+		}
+		var openEnd sql.NullTime
+		if err := tx.QueryRowContext(ctx, "SELECT end_at FROM work_sessions WHERE id = ? FOR UPDATE", session.ID).Scan(&openEnd); err != nil {
+			return err
+		}
+		if openEnd.Valid {
+			return nil
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE work_sessions SET end_at = ?, auto_closed = 1, close_reason = ?, updated_at = CURRENT_TIMESTAMP
+WHERE id = ?`, endAt, reason, session.ID); err != nil {
+			return err
+		}
+
+		workSeconds := int64(endAt.Sub(session.StartAt).Seconds())
+		violation := WorkSessionViolation{
+			Type:          "auto_closed",
+			TriggerAction: triggerRequireReason,
+			ActualSeconds: workSeconds,
+			Message:       fmt.Sprintf("未打卡下班，系统按最后活动时间 %s 自动下班（%s）", formatTime(endAt), closeReasonLabel(reason)),
+		}
+		payloadJSON, err := json.Marshal(buildReviewPayload(workSeconds, 0, map[string]int64{}, []WorkSessionViolation{violation}))
+		if err != nil {
+			return err
+		}
+		dayOffset, err := h.employeeDayOffset(ctx, session.EmployeeID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO work_session_reviews (work_session_id, employee_id, department_id, work_date, work_standard_seconds, break_seconds, need_reason, reason, violations_json)
+VALUES (?, ?, ?, ?, ?, 0, 1, NULL, ?)
+ON DUPLICATE KEY UPDATE need_reason = 1, violations_json = VALUES(violations_json)`,
+			session.ID,
+			session.EmployeeID,
+			nullIfZeroInt64(locked.DepartmentID),
+			workDate(session.StartAt, dayOffset),
+			workSeconds,
+			string(payloadJSON),
+		); err != nil {
+			return err
+		}
+		closed = true
+		return nil
+	})
+	return closed, err
+}
+
+func closeReasonLabel(reason string) string {
+	switch reason {
+	case closeReasonInactive:
+		return "长时间无活动"
+	case closeReasonShiftEnd:
+		return "班次已结束"
+	default:
+		return "未知"
+	}
+}
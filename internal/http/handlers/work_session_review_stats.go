@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+var workSessionViolationTypes = []string{"break_total", "break_count", "break_single", "status_threshold"}
+
+type WorkSessionViolationCount struct {
+	Type      string `json:"type"`
+	TypeLabel string `json:"typeLabel"`
+	Count     int64  `json:"count"`
+}
+
+type WorkSessionReviewStatsItem struct {
+	DepartmentID        int64                       `json:"departmentId"`
+	Department          string                      `json:"department"`
+	EmployeeCode        string                      `json:"employeeCode,omitempty"`
+	Name                string                      `json:"name,omitempty"`
+	ReviewCount         int64                       `json:"reviewCount"`
+	ViolationReviews    int64                       `json:"violationReviews"`
+	ViolationCount      int64                       `json:"violationCount"`
+	ViolationsByType    []WorkSessionViolationCount `json:"violationsByType"`
+	NeedReasonCount     int64                       `json:"needReasonCount"`
+	MissingReasonCount  int64                       `json:"missingReasonCount"`
+	MissingReasonRate   float64                     `json:"missingReasonRate"`
+	MissingReasonText   string                      `json:"missingReasonText"`
+	BreakOveruseCount   int64                       `json:"breakOveruseCount"`
+	AvgBreakOveruse     int64                       `json:"avgBreakOveruseSeconds"`
+	AvgBreakOveruseText string                      `json:"avgBreakOveruse"`
+}
+
+type WorkSessionReviewStatsResponse struct {
+	StartDate   string                       `json:"startDate"`
+	EndDate     string                       `json:"endDate"`
+	Total       WorkSessionReviewStatsItem   `json:"total"`
+	Departments []WorkSessionReviewStatsItem `json:"departments"`
+	Employees   []WorkSessionReviewStatsItem `json:"employees"`
+}
+
+// reviewStatsAccumulator 累计一组考核记录，breakOveruse 为休息总时长超出限制的秒数之和。
+type reviewStatsAccumulator struct {
+	item         WorkSessionReviewStatsItem
+	byType       map[string]int64
+	breakOveruse int64
+}
+
+func newReviewStatsAccumulator(item WorkSessionReviewStatsItem) *reviewStatsAccumulator {
+	return &reviewStatsAccumulator{item: item, byType: map[string]int64{}}
+}
+
+func (a *reviewStatsAccumulator) add(needReason bool, reason string, violations []WorkSessionViolation) {
+	a.item.ReviewCount++
+	if len(violations) > 0 {
+		a.item.ViolationReviews++
+	}
+	a.item.ViolationCount += int64(len(violations))
+	for _, v := range violations {
+		a.byType[v.Type]++
+		if v.Type == "break_total" && v.ActualSeconds > v.LimitSeconds {
+			a.item.BreakOveruseCount++
+			a.breakOveruse += v.ActualSeconds - v.LimitSeconds
+		}
+	}
+	if needReason {
+		a.item.NeedReasonCount++
+		if strings.TrimSpace(reason) == "" {
+			a.item.MissingReasonCount++
+		}
+	}
+}
+
+func (a *reviewStatsAccumulator) result() WorkSessionReviewStatsItem {
+	item := a.item
+	item.ViolationsByType = make([]WorkSessionViolationCount, 0, len(workSessionViolationTypes))
+	for _, violationType := range workSessionViolationTypes {
+		item.ViolationsByType = append(item.ViolationsByType, WorkSessionViolationCount{
+			Type:      violationType,
+			TypeLabel: violationTypeLabel(violationType),
+			Count:     a.byType[violationType],
+		})
+	}
+	if item.NeedReasonCount > 0 {
+		item.MissingReasonRate = math.Round(float64(item.MissingReasonCount)/float64(item.NeedReasonCount)*10000) / 10000
+	}
+	item.MissingReasonText = formatPercent(item.MissingReasonRate)
+	if item.BreakOveruseCount > 0 {
+		item.AvgBreakOveruse = a.breakOveruse / item.BreakOveruseCount
+	}
+	item.AvgBreakOveruseText = formatDuration(item.AvgBreakOveruse)
+	return item
+}
+
+func violationTypeLabel(value string) string {
+	switch value {
+	case "break_total":
+		return "休息总时长超限"
+	case "break_count":
+		return "休息次数超限"
+	case "break_single":
+		return "单次休息超限"
+	case "status_threshold":
+		return "状态时长异常"
+	default:
+		return value
+	}
+}
+
+func violationTriggerLabel(value string) string {
+	switch value {
+	case triggerRequireReason:
+		return "需填写原因"
+	case triggerShowOnly:
+		return "仅提示"
+	default:
+		return value
+	}
+}
+
+// WorkSessionReviewStats 按部门与员工汇总上班考核，筛选参数与考核列表一致；format=xlsx 时导出汇总工作簿。
+func (h *Handler) WorkSessionReviewStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	format, message := exportFormat(r.URL.Query(), "")
+	if message != "" {
+		writeError(w, http.StatusBadRequest, message)
+		return
+	}
+	if format != "" && format != exportFormatXLSX {
+		writeError(w, http.StatusBadRequest, "考核汇总仅支持导出 xlsx")
+		return
+	}
+
+	stats, status, message := h.loadWorkSessionReviewStats(r.Context(), r.URL.Query())
+	if message != "" {
+		writeError(w, status, message)
+		return
+	}
+	if format == "" {
+		writeJSON(w, http.StatusOK, stats)
+		return
+	}
+
+	content, err := buildReportXLSX(renderedReport{Tables: workSessionReviewStatsTables(stats)})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "导出失败")
+		return
+	}
+	filename := "worksentry_review_stats_" + stats.StartDate + "_" + stats.EndDate + ".xlsx"
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", attachmentDisposition(filename))
+	http.ServeContent(w, r, filename, time.Now(), bytes.NewReader(content))
+}
+
+func (h *Handler) loadWorkSessionReviewStats(ctx context.Context, params url.Values) (WorkSessionReviewStatsResponse, int, string) {
+	where, args, status, message := h.workSessionReviewFilter(ctx, params)
+	if message != "" {
+		return WorkSessionReviewStatsResponse{}, status, message
+	}
+	startDate, endDate, _ := workSessionReviewDates(params)
+	stats := WorkSessionReviewStatsResponse{StartDate: startDate.Format("2006-01-02"), EndDate: endDate.Format("2006-01-02")}
+
+	rows, err := h.DB.QueryContext(ctx, `SELECT e.id, e.employee_code, e.name, COALESCE(e.department_id, 0), COALESCE(d.name, ''),
+ r.need_reason, COALESCE(r.reason, ''), r.violations_json
+FROM work_session_reviews r
+JOIN employees e ON r.employee_id = e.id
+LEFT JOIN departments d ON e.department_id = d.id `+where, args...)
+	if err != nil {
+		return stats, http.StatusInternalServerError, "读取考核记录失败"
+	}
+	defer rows.Close()
+
+	total := newReviewStatsAccumulator(WorkSessionReviewStatsItem{Department: "合计"})
+	departments := map[int64]*reviewStatsAccumulator{}
+	employees := map[int64]*reviewStatsAccumulator{}
+	for rows.Next() {
+		var employeeID, departmentID int64
+		var code, name, department, reason, violationsJSON string
+		var needReason bool
+		if err := rows.Scan(&employeeID, &code, &name, &departmentID, &department, &needReason, &reason, &violationsJSON); err != nil {
+			return stats, http.StatusInternalServerError, "读取考核记录失败"
+		}
+		payload := WorkSessionReviewPayload{}
+		_ = json.Unmarshal([]byte(violationsJSON), &payload)
+		if department == "" {
+			department = "未分配"
+		}
+
+		total.add(needReason, reason, payload.Violations)
+		dept, ok := departments[departmentID]
+		if !ok {
+			dept = newReviewStatsAccumulator(WorkSessionReviewStatsItem{DepartmentID: departmentID, Department: department})
+			departments[departmentID] = dept
+		}
+		dept.add(needReason, reason, payload.Violations)
+		employee, ok := employees[employeeID]
+		if !ok {
+			employee = newReviewStatsAccumulator(WorkSessionReviewStatsItem{DepartmentID: departmentID, Department: department, EmployeeCode: code, Name: name})
+			employees[employeeID] = employee
+		}
+		employee.add(needReason, reason, payload.Violations)
+	}
+	if err := rows.Err(); err != nil {
+		return stats, http.StatusInternalServerError, "读取考核记录失败"
+	}
+
+	stats.Total = total.result()
+	stats.Departments = make([]WorkSessionReviewStatsItem, 0, len(departments))
+	for _, item := range departments {
+		stats.Departments = append(stats.Departments, item.result())
+	}
+	sort.Slice(stats.Departments, func(i, j int) bool {
+		return stats.Departments[i].Department < stats.Departments[j].Department
+	})
+	// 员工按违规次数从多到少排列，便于考核时优先查看
+	stats.Employees = make([]WorkSessionReviewStatsItem, 0, len(employees))
+	for _, item := range employees {
+		stats.Employees = append(stats.Employees, item.result())
+	}
+	sort.Slice(stats.Employees, func(i, j int) bool {
+		a, b := stats.Employees[i], stats.Employees[j]
+		if a.ViolationCount != b.ViolationCount {
+			return a.ViolationCount > b.ViolationCount
+		}
+		return a.EmployeeCode < b.EmployeeCode
+	})
+	return stats, 0, ""
+}
+
+func workSessionReviewStatsTables(stats WorkSessionReviewStatsResponse) []reportTable {
+	typeHeaders := make([]string, 0, len(workSessionViolationTypes))
+	for _, violationType := range workSessionViolationTypes {
+		typeHeaders = append(typeHeaders, violationTypeLabel(violationType))
+	}
+	statHeaders := append([]string{"考核次数", "违规次数（考核）", "违规条数"}, typeHeaders...)
+	statHeaders = append(statHeaders, "需填写原因", "未填写原因", "未填写率", "休息超限次数", "平均休息超出")
+	statValues := func(item WorkSessionReviewStatsItem) []any {
+		values := []any{item.ReviewCount, item.ViolationReviews, item.ViolationCount}
+		for _, count := range item.ViolationsByType {
+			values = append(values, count.Count)
+		}
+		return append(values, item.NeedReasonCount, item.MissingReasonCount, item.MissingReasonText, item.BreakOveruseCount, item.AvgBreakOveruseText)
+	}
+
+	departmentTable := reportTable{Title: "部门汇总", Headers: append([]string{"部门"}, statHeaders...)}
+	for _, item := range stats.Departments {
+		departmentTable.Rows = append(departmentTable.Rows, append([]any{item.Department}, statValues(item)...))
+	}
+	departmentTable.Rows = append(departmentTable.Rows, append([]any{stats.Total.Department}, statValues(stats.Total)...))
+
+	employeeTable := reportTable{Title: "员工汇总", Headers: append([]string{"工号", "姓名", "部门"}, statHeaders...)}
+	for _, item := range stats.Employees {
+		employeeTable.Rows = append(employeeTable.Rows, append([]any{item.EmployeeCode, item.Name, item.Department}, statValues(item)...))
+	}
+	return []reportTable{departmentTable, employeeTable}
+}
+
+var workSessionViolationExportColumns = []exportColumn{
+	{Key: "reviewId", Label: "考核编号"},
+	{Key: "workDate", Label: "日期"},
+	{Key: "employeeCode", Label: "工号"},
+	{Key: "name", Label: "姓名"},
+	{Key: "department", Label: "部门"},
+	{Key: "startAt", Label: "上班时间"},
+	{Key: "endAt", Label: "下班时间"},
+	{Key: "workStandard", Label: "标准工时"},
+	{Key: "breakDuration", Label: "休息时长"},
+	{Key: "violationType", Label: "违规类型"},
+	{Key: "status", Label: "状态"},
+	{Key: "triggerAction", Label: "处理方式"},
+	{Key: "actual", Label: "实际值"},
+	{Key: "limit", Label: "限制值"},
+	{Key: "deviationSeconds", Label: "偏差秒数"},
+	{Key: "message", Label: "说明"},
+	{Key: "reasonStatus", Label: "补录状态"},
+	{Key: "reason", Label: "原因"},
+}
+
+// workSessionViolationsExport 每条违规一行，没有违规的考核也输出一行以便核对考核总数。
+// 休息次数类违规的实际值与限制值为次数，其余为时长；偏差秒数为超出上限或低于下限的秒数。
+func (h *Handler) workSessionViolationsExport(ctx context.Context, params url.Values) (exportPlan, int, string) {
+	where, args, status, message := h.workSessionReviewFilter(ctx, params)
+	if message != "" {
+		return exportPlan{}, status, message
+	}
+	query := `SELECT r.id, r.work_date, e.employee_code, e.name, COALESCE(d.name, ''), ws.start_at, ws.end_at,
+ r.work_standard_seconds, r.break_seconds, r.need_reason, r.reason, r.violations_json
+FROM work_session_reviews r
+JOIN employees e ON r.employee_id = e.id
+LEFT JOIN departments d ON e.department_id = d.id
+LEFT JOIN work_sessions ws ON r.work_session_id = ws.id ` + where + " ORDER BY r.work_date, e.employee_code, r.id"
+	return exportPlan{
+		Name:    "work_session_violations",
+		Sheet:   "考核违规明细",
+		Columns: workSessionViolationExportColumns,
+		Query:   query,
+		Args:    args,
+		Expand: func(rows *sql.Rows) ([][]any, error) {
+			var id int64
+			var workDate time.Time
+			var code, name, department, violationsJSON string
+			var startAt, endAt sql.NullTime
+			var workSeconds, breakSeconds int64
+			var needReason bool
+			var reason sql.NullString
+			if err := rows.Scan(&id, &workDate, &code, &name, &department, &startAt, &endAt, &workSeconds, &breakSeconds, &needReason, &reason, &violationsJSON); err != nil {
+				return nil, err
+			}
+			reasonStatus := "无需补录"
+			if needReason {
+				reasonStatus = "未补录"
+				if strings.TrimSpace(reason.String) != "" {
+					reasonStatus = "已补录"
+				}
+			}
+			base := []any{id, workDate.Format("2006-01-02"), code, name, department, nullTimeText(startAt), nullTimeText(endAt),
+				formatDuration(workSeconds), formatDuration(breakSeconds)}
+			tail := []any{reasonStatus, reason.String}
+
+			payload := WorkSessionReviewPayload{}
+			_ = json.Unmarshal([]byte(violationsJSON), &payload)
+			if len(payload.Violations) == 0 {
+				row := append(append([]any{}, base...), "", "", "", "", "", nil, "")
+				return [][]any{append(row, tail...)}, nil
+			}
+			items := make([][]any, 0, len(payload.Violations))
+			for _, v := range payload.Violations {
+				var actual, limit any = formatDuration(v.ActualSeconds), formatDuration(v.LimitSeconds)
+				if v.Type == "break_count" {
+					actual, limit = v.ActualSeconds, v.LimitSeconds
+				}
+				var deviation any
+				if v.Type != "break_count" {
+					seconds := v.ActualSeconds - v.LimitSeconds
+					if v.LimitType == "min" {
+						seconds = -seconds
+					}
+					deviation = max(seconds, 0)
+				}
+				row := append(append([]any{}, base...), violationTypeLabel(v.Type), v.StatusLabel, violationTriggerLabel(v.TriggerAction),
+					actual, limit, deviation, v.Message)
+				items = append(items, append(row, tail...))
+			}
+			return items, nil
+		},
+	}, 0, ""
+}
+
+// ExportWorkSessionViolations 默认导出 xlsx，参数与上班考核列表一致。
+func (h *Handler) ExportWorkSessionViolations(w http.ResponseWriter, r *http.Request) {
+	h.serveExport(w, r, h.workSessionViolationsExport)
+}
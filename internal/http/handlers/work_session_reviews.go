@@ -6,6 +6,7 @@ import (
     "encoding/json"
     "fmt"
     "net/http"
+    "net/url"
     "strconv"
     "strings"
     "time"
@@ -129,6 +130,15 @@ func (h *Handler) handleWorkEndAfterReport(ctx context.Context, employee sqlc.Em
     violations := []WorkSessionViolation{}
     needReason := false
 
+    dayOffset, err := h.employeeDayOffset(ctx, employee.ID)
+    if err != nil {
+        return false, fmt.Errorf("读取班次失败")
+    }
+    workDay, err := h.resolveCalendarDay(ctx, employee.DepartmentID.Int64, businessDate(session.StartAt, dayOffset))
+    if err != nil {
+        return false, fmt.Errorf("读取工作日历失败")
+    }
+
     configured := false
     var rule departmentRule
     var thresholds []statusThreshold
@@ -159,7 +169,8 @@ func (h *Handler) handleWorkEndAfterReport(ctx context.Context, employee sqlc.Em
         }
     }
 
-    if configured && rule.TargetSeconds > 0 && workStandardSeconds < rule.TargetSeconds {
+    // 休息日与节假日不要求达到部门工时标准
+    if configured && workDay.IsWorkday() && rule.TargetSeconds > 0 && workStandardSeconds < rule.TargetSeconds {
         return false, &workEndError{
             Status:  http.StatusBadRequest,
             Code:    "work_time_short",
@@ -301,7 +312,7 @@ VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
             session.ID,
             employee.ID,
             nullIfZeroInt64(employee.DepartmentID),
-            workDate(session.StartAt),
+            workDate(session.StartAt, dayOffset),
             workStandardSeconds,
             breakSummary.TotalSeconds,
             boolToTinyInt(needReason),
@@ -439,8 +450,9 @@ func defaultStatusTotals() map[string]int64 {
     }
 }
 
-func workDate(start time.Time) string {
-    return time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, start.Location()).Format("2006-01-02")
+// workDate 返回班次所属业务日，跨零点班次按班次偏移归属到上班当天。
+func workDate(start time.Time, offset time.Duration) string {
+    return businessDate(start, offset).Format("2006-01-02")
 }
 
 func nullIfZeroInt64(value sql.NullInt64) interface{} {
@@ -457,21 +469,10 @@ func toNullText(value string) interface{} {
     return value
 }
 
-func (h *Handler) WorkSessionReviews(w http.ResponseWriter, r *http.Request) {
-    if r.Method != http.MethodGet {
-        writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
-        return
-    }
-    if h.DB == nil {
-        writeError(w, http.StatusInternalServerError, "数据库未初始化")
-        return
-    }
-
-    startValue := r.URL.Query().Get("startDate")
-    endValue := r.URL.Query().Get("endDate")
-    departmentID := parseInt64(r.URL.Query().Get("departmentId"))
-    keyword := strings.TrimSpace(r.URL.Query().Get("keyword"))
-
+// workSessionReviewDates 解析起止日期，未填写时默认为今天。
+func workSessionReviewDates(query url.Values) (time.Time, time.Time, string) {
+    startValue := query.Get("startDate")
+    endValue := query.Get("endDate")
     if startValue == "" {
         startValue = time.Now().Format("2006-01-02")
     }
@@ -481,37 +482,72 @@ func (h *Handler) WorkSessionReviews(w http.ResponseWriter, r *http.Request) {
 
     startDate, err := parseDate(startValue)
     if err != nil {
-        writeError(w, http.StatusBadRequest, "开始日期格式错误")
-        return
+        return time.Time{}, time.Time{}, "开始日期格式错误"
     }
     endDate, err := parseDate(endValue)
     if err != nil {
-        writeError(w, http.StatusBadRequest, "结束日期格式错误")
-        return
+        return time.Time{}, time.Time{}, "结束日期格式错误"
     }
+    return startDate, endDate, ""
+}
 
-    page := parseInt(r.URL.Query().Get("page"), 1)
-    pageSize := parseInt(r.URL.Query().Get("pageSize"), 20)
-    if pageSize <= 0 || pageSize > 200 {
-        pageSize = 20
-    }
-    if page <= 0 {
-        page = 1
+// workSessionReviewFilter 解析列表与导出共用的筛选条件，返回以 WHERE 开头的条件语句。
+func (h *Handler) workSessionReviewFilter(ctx context.Context, query url.Values) (string, []any, int, string) {
+    departmentID := parseInt64(query.Get("departmentId"))
+    keyword := strings.TrimSpace(query.Get("keyword"))
+
+    startDate, endDate, message := workSessionReviewDates(query)
+    if message != "" {
+        return "", nil, http.StatusBadRequest, message
     }
 
     where := "WHERE r.work_date >= ? AND r.work_date <= ?"
     args := []any{startDate.Format("2006-01-02"), endDate.Format("2006-01-02")}
 
-    if departmentID > 0 {
-        where += " AND e.department_id = ?"
-        args = append(args, departmentID)
+    clause, clauseArgs, err := h.departmentFilter(ctx, "e.department_id", departmentID)
+    if err != nil {
+        return "", nil, http.StatusInternalServerError, "读取部门失败"
     }
+    where += clause
+    args = append(args, clauseArgs...)
     if keyword != "" {
         where += " AND (e.employee_code LIKE ? OR e.name LIKE ?)"
         like := "%" + keyword + "%"
         args = append(args, like, like)
     }
 
+    return where, args, 0, ""
+}
+
+func (h *Handler) WorkSessionReviews(w http.ResponseWriter, r *http.Request) {
+    if r.Method != http.MethodGet {
+        writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+        return
+    }
+    if h.DB == nil {
+        writeError(w, http.StatusInternalServerError, "数据库未初始化")
+        return
+    }
+
+    if h.exportIfRequested(w, r, h.workSessionReviewsExport) {
+        return
+    }
+
+    where, args, status, message := h.workSessionReviewFilter(r.Context(), r.URL.Query())
+    if message != "" {
+        writeError(w, status, message)
+        return
+    }
+
+    page := parseInt(r.URL.Query().Get("page"), 1)
+    pageSize := parseInt(r.URL.Query().Get("pageSize"), 20)
+    if pageSize <= 0 || pageSize > 200 {
+        pageSize = 20
+    }
+    if page <= 0 {
+        page = 1
+    }
+
     countSQL := "SELECT COUNT(1) FROM work_session_reviews r JOIN employees e ON r.employee_id = e.id " + where
     var total int64
     if err := h.DB.QueryRowContext(r.Context(), countSQL, args...).Scan(&total); err != nil {
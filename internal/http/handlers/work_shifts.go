@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"worksentry/internal/db/sqlc"
+)
+
+const minutesPerDay = 24 * 60
+
+type WorkShiftPayload struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	StartTime    string `json:"startTime"`
+	EndTime      string `json:"endTime"`
+	GraceMinutes int32  `json:"graceMinutes"`
+	BreakMinutes int32  `json:"breakMinutes"`
+	Enabled      bool   `json:"enabled"`
+}
+
+type WorkShiftView struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	StartTime     string `json:"startTime"`
+	EndTime       string `json:"endTime"`
+	GraceMinutes  int32  `json:"graceMinutes"`
+	BreakMinutes  int32  `json:"breakMinutes"`
+	CrossMidnight bool   `json:"crossMidnight"`
+	DayBoundary   string `json:"dayBoundary"`
+	Label         string `json:"label"`
+	Enabled       bool   `json:"enabled"`
+}
+
+type WorkShiftAssignPayload struct {
+	EmployeeID   int64 `json:"employeeId"`
+	DepartmentID int64 `json:"departmentId"`
+	ShiftID      int64 `json:"shiftId"`
+}
+
+func (h *Handler) WorkShifts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listWorkShifts(w, r)
+	case http.MethodPost:
+		h.saveWorkShift(w, r, false)
+	case http.MethodPut:
+		h.saveWorkShift(w, r, true)
+	case http.MethodDelete:
+		h.deleteWorkShift(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+	}
+}
+
+func (h *Handler) listWorkShifts(w http.ResponseWriter, r *http.Request) {
+	items, err := h.Queries.ListWorkShifts(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取班次失败")
+		return
+	}
+	views := make([]WorkShiftView, 0, len(items))
+	for _, item := range items {
+		views = append(views, buildWorkShiftView(item))
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+func (h *Handler) saveWorkShift(w http.ResponseWriter, r *http.Request, update bool) {
+	var payload WorkShiftPayload
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "参数格式错误")
+		return
+	}
+	payload.Name = strings.TrimSpace(payload.Name)
+	if payload.Name == "" {
+		writeError(w, http.StatusBadRequest, "班次名称不能为空")
+		return
+	}
+	startMinute, err := parseClockMinute(payload.StartTime)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "上班时间格式错误")
+		return
+	}
+	endMinute, err := parseClockMinute(payload.EndTime)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "下班时间格式错误")
+		return
+	}
+	if startMinute == endMinute {
+		writeError(w, http.StatusBadRequest, "上下班时间不能相同")
+		return
+	}
+	if payload.GraceMinutes < 0 || payload.GraceMinutes > 240 {
+		writeError(w, http.StatusBadRequest, "宽限时间需在 0-240 分钟之间")
+		return
+	}
+	shiftMinutes := endMinute - startMinute
+	if shiftMinutes <= 0 {
+		shiftMinutes += minutesPerDay
+	}
+	if payload.BreakMinutes < 0 || payload.BreakMinutes >= shiftMinutes {
+		writeError(w, http.StatusBadRequest, "休息时长需大于等于 0 且小于班次时长")
+		return
+	}
+
+	if update {
+		if payload.ID <= 0 {
+			writeError(w, http.StatusBadRequest, "班次编号无效")
+			return
+		}
+		if _, err := h.Queries.GetWorkShiftByID(r.Context(), payload.ID); err != nil {
+			if err == sql.ErrNoRows {
+				writeError(w, http.StatusNotFound, "班次不存在")
+			} else {
+				writeError(w, http.StatusInternalServerError, "读取班次失败")
+			}
+			return
+		}
+		if err := h.Queries.UpdateWorkShift(r.Context(), sqlc.UpdateWorkShiftParams{
+			ID:           payload.ID,
+			Name:         payload.Name,
+			StartMinute:  startMinute,
+			EndMinute:    endMinute,
+			GraceMinutes: payload.GraceMinutes,
+			BreakMinutes: payload.BreakMinutes,
+			Enabled:      payload.Enabled,
+		}); err != nil {
+			writeError(w, http.StatusInternalServerError, "更新班次失败")
+			return
+		}
+		h.refreshDayOffsets(r.Context())
+		h.logAudit(r, "update_work_shift", "work_shift", sql.NullInt64{Int64: payload.ID, Valid: true}, payload)
+		writeJSON(w, http.StatusOK, map[string]any{"id": payload.ID})
+		return
+	}
+
+	id, err := h.Queries.CreateWorkShift(r.Context(), sqlc.CreateWorkShiftParams{
+		Name:         payload.Name,
+		StartMinute:  startMinute,
+		EndMinute:    endMinute,
+		GraceMinutes: payload.GraceMinutes,
+		BreakMinutes: payload.BreakMinutes,
+		Enabled:      payload.Enabled,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "创建班次失败")
+		return
+	}
+	h.logAudit(r, "create_work_shift", "work_shift", sql.NullInt64{Int64: id, Valid: true}, payload)
+	writeJSON(w, http.StatusOK, map[string]any{"id": id})
+}
+
+func (h *Handler) deleteWorkShift(w http.ResponseWriter, r *http.Request) {
+	id := parseInt64(r.URL.Query().Get("id"))
+	if id <= 0 {
+		writeError(w, http.StatusBadRequest, "班次编号无效")
+		return
+	}
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+
+	tx, err := h.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "删除班次失败")
+		return
+	}
+	qtx := h.Queries.WithTx(tx)
+	if err := qtx.ClearWorkShiftAssignments(r.Context(), id); err != nil {
+		_ = tx.Rollback()
+		writeError(w, http.StatusInternalServerError, "删除班次失败")
+		return
+	}
+	if err := qtx.DeleteWorkShift(r.Context(), id); err != nil {
+		_ = tx.Rollback()
+		writeError(w, http.StatusInternalServerError, "删除班次失败")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeError(w, http.StatusInternalServerError, "删除班次失败")
+		return
+	}
+	h.refreshDayOffsets(r.Context())
+	h.logAudit(r, "delete_work_shift", "work_shift", sql.NullInt64{Int64: id, Valid: true}, nil)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "已删除"})
+}
+
+type WorkShiftAssignmentView struct {
+	TargetType string `json:"targetType"`
+	TargetID   int64  `json:"targetId"`
+	TargetName string `json:"targetName"`
+	ShiftID    int64  `json:"shiftId"`
+}
+
+// WorkShiftAssign 为员工或部门指定班次，shiftId 为 0 表示取消；员工班次优先于部门班次。
+func (h *Handler) WorkShiftAssign(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.listWorkShiftAssignments(w, r)
+	case http.MethodPut:
+		h.saveWorkShiftAssignment(w, r)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "不支持的请求方式")
+	}
+}
+
+func (h *Handler) listWorkShiftAssignments(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		writeError(w, http.StatusInternalServerError, "数据库未初始化")
+		return
+	}
+	rows, err := h.DB.QueryContext(r.Context(), `SELECT 'department', id, name, shift_id FROM departments WHERE shift_id IS NOT NULL
+UNION ALL
+SELECT 'employee', id, CONCAT(employee_code, ' ', name), shift_id FROM employees WHERE shift_id IS NOT NULL`)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "读取班次分配失败")
+		return
+	}
+	defer rows.Close()
+
+	items := make([]WorkShiftAssignmentView, 0)
+	for rows.Next() {
+		var item WorkShiftAssignmentView
+		if err := rows.Scan(&item.TargetType, &item.TargetID, &item.TargetName, &item.ShiftID); err != nil {
+			writeError(w, http.StatusInternalServerError, "读取班次分配失败")
+			return
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, http.StatusInternalServerError, "读取班次分配失败")
+		return
+	}
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (h *Handler) saveWorkShiftAssignment(w http.ResponseWriter, r *http.Request) {
+	var payload WorkShiftAssignPayload
+	if err := decodeJSON(r, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, "参数格式错误")
+		return
+	}
+	if (payload.EmployeeID > 0) == (payload.DepartmentID > 0) {
+		writeError(w, http.StatusBadRequest, "请选择员工或部门")
+		return
+	}
+	var shiftID interface{}
+	if payload.ShiftID > 0 {
+		if _, err := h.Queries.GetWorkShiftByID(r.Context(), payload.ShiftID); err != nil {
+			if err == sql.ErrNoRows {
+				writeError(w, http.StatusNotFound, "班次不存在")
+			} else {
+				writeError(w, http.StatusInternalServerError, "读取班次失败")
+			}
+			return
+		}
+		shiftID = payload.ShiftID
+	}
+
+	if payload.EmployeeID > 0 {
+		if err := h.Queries.SetEmployeeShift(r.Context(), payload.EmployeeID, shiftID); err != nil {
+			writeError(w, http.StatusInternalServerError, "设置班次失败")
+			return
+		}
+		h.refreshDayOffsets(r.Context())
+		h.logAudit(r, "assign_work_shift", "employee", sql.NullInt64{Int64: payload.EmployeeID, Valid: true}, payload)
+	} else {
+		if err := h.Queries.SetDepartmentShift(r.Context(), payload.DepartmentID, shiftID); err != nil {
+			writeError(w, http.StatusInternalServerError, "设置班次失败")
+			return
+		}
+		h.refreshDayOffsets(r.Context())
+		h.logAudit(r, "assign_work_shift", "department", sql.NullInt64{Int64: payload.DepartmentID, Valid: true}, payload)
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "已保存"})
+}
+
+func buildWorkShiftView(item sqlc.WorkShift) WorkShiftView {
+	view := WorkShiftView{
+		ID:            item.ID,
+		Name:          item.Name,
+		StartTime:     formatClockMinute(item.StartMinute),
+		EndTime:       formatClockMinute(item.EndMinute),
+		GraceMinutes:  item.GraceMinutes,
+		BreakMinutes:  item.BreakMinutes,
+		CrossMidnight: isCrossMidnightShift(item),
+		DayBoundary:   formatClockMinute(int32(shiftDayOffset(item) / time.Minute)),
+		Enabled:       item.Enabled,
+	}
+	view.Label = shiftLabel(item)
+	return view
+}
+
+func shiftLabel(item sqlc.WorkShift) string {
+	if isCrossMidnightShift(item) {
+		return fmt.Sprintf("%s %s-次日%s", item.Name, formatClockMinute(item.StartMinute), formatClockMinute(item.EndMinute))
+	}
+	return fmt.Sprintf("%s %s-%s", item.Name, formatClockMinute(item.StartMinute), formatClockMinute(item.EndMinute))
+}
+
+func isCrossMidnightShift(item sqlc.WorkShift) bool {
+	return item.EndMinute <= item.StartMinute
+}
+
+// shiftDayOffset 返回班次业务日的起点（相对自然日零点）。
+// 跨零点班次以“下班时间 + 宽限”为分界，分界前的时间归属前一业务日；
+// 分界不晚于下一次上班前的宽限起点，白班仍按零点切分。
+func shiftDayOffset(item sqlc.WorkShift) time.Duration {
+	if !isCrossMidnightShift(item) {
+		return 0
+	}
+	boundary := item.EndMinute + item.GraceMinutes
+	limit := item.StartMinute - item.GraceMinutes
+	if limit < item.EndMinute {
+		limit = item.EndMinute
+	}
+	if boundary > limit {
+		boundary = limit
+	}
+	return time.Duration(boundary) * time.Minute
+}
+
+func loadEmployeeDayOffsets(ctx context.Context, q *sqlc.Queries) (map[int64]time.Duration, error) {
+	items, err := q.ListEmployeeShifts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	offsets := make(map[int64]time.Duration, len(items))
+	for _, item := range items {
+		if offset := shiftDayOffset(item.WorkShift); offset > 0 {
+			offsets[item.EmployeeID] = offset
+		}
+	}
+	return offsets, nil
+}
+
+// splitByBusinessDay 按业务日切分区间，返回的日期为班次所属日期。
+func splitByBusinessDay(start time.Time, end time.Time, offset time.Duration) []dayPart {
+	return splitByDay(start.Add(-offset), end.Add(-offset))
+}
+
+func businessDate(t time.Time, offset time.Duration) time.Time {
+	shifted := t.Add(-offset)
+	return time.Date(shifted.Year(), shifted.Month(), shifted.Day(), 0, 0, 0, 0, shifted.Location())
+}
+
+func parseClockMinute(value string) (int32, error) {
+	parsed, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, err
+	}
+	return int32(parsed.Hour()*60 + parsed.Minute()), nil
+}
+
+func formatClockMinute(minute int32) string {
+	minute = ((minute % minutesPerDay) + minutesPerDay) % minutesPerDay
+	return fmt.Sprintf("%02d:%02d", minute/60, minute%60)
+}
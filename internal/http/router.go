@@ -15,6 +15,8 @@ func NewRouter(h *handlers.Handler) http.Handler {
 	mux.HandleFunc("/api/v1/client/bind", h.ClientBind)
 	mux.HandleFunc("/api/v1/client/report", h.ClientReport)
 	mux.HandleFunc("/api/v1/client/checkout-template", h.ClientCheckoutTemplate)
+	mux.HandleFunc("/api/v1/client/correction-requests", h.ClientCorrectionRequests)
+	mux.HandleFunc("/api/v1/client/leave-requests", h.ClientLeaveRequests)
 
 	adminOnly := func(fn http.HandlerFunc) http.HandlerFunc {
 		return h.AdminOnly(fn)
@@ -27,14 +29,54 @@ func NewRouter(h *handlers.Handler) http.Handler {
 	mux.HandleFunc("/api/v1/admin/settings", adminOnly(h.Settings))
 	mux.HandleFunc("/api/v1/admin/rules", adminOnly(h.Rules))
 	mux.HandleFunc("/api/v1/admin/live-snapshot", adminOnly(h.LiveSnapshot))
+	mux.HandleFunc("/api/v1/admin/fish-warnings", adminOnly(h.FishWarnings))
+	mux.HandleFunc("/api/v1/admin/alert-rules", adminOnly(h.AlertRules))
+	mux.HandleFunc("/api/v1/admin/alerts", adminOnly(h.Alerts))
+	mux.HandleFunc("/api/v1/admin/alerts/action", adminOnly(h.AlertAction))
+	mux.HandleFunc("/api/v1/admin/report-subscriptions", adminOnly(h.ReportSubscriptions))
+	mux.HandleFunc("/api/v1/admin/report-subscriptions/send", adminOnly(h.ReportSubscriptionSend))
+	mux.HandleFunc("/api/v1/admin/report-deliveries", adminOnly(h.ReportDeliveries))
+	mux.HandleFunc("/api/v1/admin/report-deliveries/retry", adminOnly(h.ReportDeliveryRetry))
 	mux.HandleFunc("/api/v1/admin/reports/daily", adminOnly(h.ReportDaily))
 	mux.HandleFunc("/api/v1/admin/reports/timeline", adminOnly(h.ReportTimeline))
 	mux.HandleFunc("/api/v1/admin/reports/rank", adminOnly(h.ReportRank))
+	mux.HandleFunc("/api/v1/admin/reports/compare", adminOnly(h.ReportCompare))
+	mux.HandleFunc("/api/v1/admin/reports/attendance", adminOnly(h.ReportAttendance))
+	mux.HandleFunc("/api/v1/admin/reports/overtime", adminOnly(h.ReportOvertime))
+	mux.HandleFunc("/api/v1/admin/reports/series", adminOnly(h.ReportSeries))
+	mux.HandleFunc("/api/v1/admin/reports/heatmap", adminOnly(h.ReportHeatmap))
+	mux.HandleFunc("/api/v1/admin/reports/activity-top", adminOnly(h.ReportActivityTop))
 	mux.HandleFunc("/api/v1/admin/department-rules", adminOnly(h.DepartmentRules))
+	mux.HandleFunc("/api/v1/admin/work-shifts", adminOnly(h.WorkShifts))
+	mux.HandleFunc("/api/v1/admin/work-shifts/assign", adminOnly(h.WorkShiftAssign))
+	mux.HandleFunc("/api/v1/admin/calendar", adminOnly(h.Calendar))
+	mux.HandleFunc("/api/v1/admin/calendar/import", adminOnly(h.CalendarImport))
 	mux.HandleFunc("/api/v1/admin/work-session-reviews", adminOnly(h.WorkSessionReviews))
 	mux.HandleFunc("/api/v1/admin/work-session-review", adminOnly(h.WorkSessionReviewDetail))
+	mux.HandleFunc("/api/v1/admin/work-session-reviews/stats", adminOnly(h.WorkSessionReviewStats))
+	mux.HandleFunc("/api/v1/admin/exports/work-session-violations.xlsx", adminOnly(h.ExportWorkSessionViolations))
 	mux.HandleFunc("/api/v1/admin/exports/daily.xlsx", adminOnly(h.ExportDaily))
+	mux.HandleFunc("/api/v1/admin/export-jobs", adminOnly(h.ExportJobs))
+	mux.HandleFunc("/api/v1/admin/exports/attendance.xlsx", adminOnly(h.ExportAttendance))
+	mux.HandleFunc("/api/v1/admin/attendance/evaluate", adminOnly(h.AttendanceEvaluate))
+	mux.HandleFunc("/api/v1/admin/exports/overtime.xlsx", adminOnly(h.ExportOvertime))
+	mux.HandleFunc("/api/v1/admin/exports/heatmap.xlsx", adminOnly(h.ExportHeatmap))
+	mux.HandleFunc("/api/v1/admin/exports/activity-top.xlsx", adminOnly(h.ExportActivityTop))
+	mux.HandleFunc("/api/v1/admin/overtime", adminOnly(h.Overtime))
+	mux.HandleFunc("/api/v1/admin/overtime/review", adminOnly(h.OvertimeReview))
+	mux.HandleFunc("/api/v1/admin/overtime/evaluate", adminOnly(h.OvertimeEvaluate))
+	mux.HandleFunc("/api/v1/admin/overtime-rules", adminOnly(h.OvertimeRules))
+	mux.HandleFunc("/api/v1/admin/daily-stats/reconcile", adminOnly(h.DailyStatsReconcile))
 	mux.HandleFunc("/api/v1/admin/manual-adjustments", adminOnly(h.ManualAdjustments))
+	mux.HandleFunc("/api/v1/admin/correction-requests", adminOnly(h.CorrectionRequests))
+	mux.HandleFunc("/api/v1/admin/correction-requests/review", adminOnly(h.CorrectionRequestReview))
+	mux.HandleFunc("/api/v1/admin/leave-types", adminOnly(h.LeaveTypes))
+	mux.HandleFunc("/api/v1/admin/leave-requests", adminOnly(h.LeaveRequests))
+	mux.HandleFunc("/api/v1/admin/leave-requests/review", adminOnly(h.LeaveRequestReview))
+	mux.HandleFunc("/api/v1/admin/leave-balances", adminOnly(h.LeaveBalances))
+	mux.HandleFunc("/api/v1/admin/raw-archives", adminOnly(h.RawArchives))
+	mux.HandleFunc("/api/v1/admin/raw-archives/events", adminOnly(h.RawArchiveEvents))
+	mux.HandleFunc("/api/v1/admin/raw-archives/restore", adminOnly(h.RawArchiveRestore))
 	mux.HandleFunc("/api/v1/admin/offline-segments", adminOnly(h.OfflineSegments))
 	mux.HandleFunc("/api/v1/admin/system-incidents", adminOnly(h.SystemIncidents))
 	mux.HandleFunc("/api/v1/admin/audit-logs", adminOnly(h.AuditLogs))
@@ -45,6 +87,10 @@ func NewRouter(h *handlers.Handler) http.Handler {
 	mux.HandleFunc("/api/v1/admin/checkout-fields", adminOnly(h.CheckoutFields))
 	mux.HandleFunc("/api/v1/admin/checkout-records", adminOnly(h.CheckoutRecords))
 	mux.HandleFunc("/api/v1/admin/checkout-record", adminOnly(h.CheckoutRecordDetail))
+	mux.HandleFunc("/api/v1/admin/exports/checkout-records.xlsx", adminOnly(h.ExportCheckoutRecords))
+
+	// 导出文件下载，凭签名链接访问
+	mux.HandleFunc("/api/v1/export-files", h.ExportFile)
 
 	// WebSocket
 	mux.HandleFunc("/ws/v1/live", h.LiveWS)